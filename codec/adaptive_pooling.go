@@ -0,0 +1,149 @@
+package codec
+
+import (
+	"bytes"
+	"io"
+)
+
+// poolReference 是一次写入的池索引
+type poolReference struct {
+	poolId string
+	index  int
+}
+
+// encodeMark 是 ptrace 直接编码路径中开始编码一个池化值时的记录数
+type encodeMark struct {
+	overflows  int
+	references int
+}
+
+// writeReference 写入池索引，需要统计时同时记录下来
+func (e *Encoder) writeReference(state *encodeState, poolId string, index int, buf *bytes.Buffer) error {
+	if state.collectReferences {
+		state.references = append(state.references, poolReference{poolId: poolId, index: index})
+	}
+	return e.encodeInt(index, buf)
+}
+
+// maxAdaptivePasses 是开启自适应池化时一批数据最多编码的遍数，包括第一遍全部池化的编码
+const maxAdaptivePasses = 4
+
+// encodeAdaptive 用 encode 编码数据部分并写出整个 payload。
+// 开启自适应池化时第一遍全部池化，统计每个池子的收益，有池子池化之后反而更大时，把这些池子标记为不池化再编码一遍。
+// 不池化外层的池子会改变内层池子的收益，所以每遍编码之后重新统计，直到没有新的池子需要不池化，
+// 最后写出的是各遍中最小的 payload，不会比全部池化更大
+func (e *Encoder) encodeAdaptive(encode func(state *encodeState, buf *bytes.Buffer) error, out io.Writer) (EncodeStats, error) {
+	state, dataBuffer, err := e.encodePass(encode, nil)
+	if err != nil {
+		return EncodeStats{}, err
+	}
+	defer func() {
+		e.releaseBuffers(state)
+	}()
+	if e.opts.adaptivePooling {
+		size := e.payloadSize(state, dataBuffer)
+		var tried map[string]bool
+		planned := e.planUnpooled(state, tried)
+		for pass := 1; pass < maxAdaptivePasses && len(planned) > len(tried); pass++ {
+			next, nextBuffer, err := e.encodePass(encode, planned)
+			if err != nil {
+				return EncodeStats{}, err
+			}
+			tried = planned
+			planned = e.planUnpooled(next, tried)
+			if nextSize := e.payloadSize(next, nextBuffer); nextSize < size {
+				e.releaseBuffers(state)
+				state, dataBuffer, size = next, nextBuffer, nextSize
+			} else {
+				e.releaseBuffers(next)
+			}
+		}
+	}
+	return e.finish(state, dataBuffer, out)
+}
+
+// encodePass 按 unpooled 编码一遍数据部分，开启自适应池化时同时统计池索引
+func (e *Encoder) encodePass(encode func(state *encodeState, buf *bytes.Buffer) error, unpooled map[string]bool) (*encodeState, *bytes.Buffer, error) {
+	state := e.newEncodeState()
+	state.unpooled = unpooled
+	state.collectReferences = e.opts.adaptivePooling
+	dataBuffer := bytes.NewBuffer(make([]byte, 0, initialCompressedBufferSize))
+	dataBuffer.WriteString("cprval")
+	err := encode(state, dataBuffer)
+	if err != nil {
+		e.releaseBuffers(state)
+		return nil, nil, err
+	}
+	return state, dataBuffer, nil
+}
+
+// planUnpooled 根据一遍编码的结果，在 unpooled 之外找出池化不能让 payload 变小的池子，返回包括 unpooled 在内的所有不池化的池子。
+// 池化的代价是 header 中的条目数、每个条目的编码和每次引用的索引（以及池满之后的 inlineMarker），
+// 内联的代价是每次引用都写入完整的条目编码，两者相等时也不池化。
+// 每个池子单独比较，其他池子的编码保持不变，池子嵌套时由 encodeAdaptive 再编码一遍重新比较
+func (e *Encoder) planUnpooled(state *encodeState, unpooled map[string]bool) map[string]bool {
+	pooledCost := make(map[string]int, len(state.valueEncodePools))
+	inlineCost := make(map[string]int, len(state.valueEncodePools))
+	for poolId, encodePool := range state.valueEncodePools {
+		pooledCost[poolId] = e.intSize(len(encodePool))
+		for _, buffer := range encodePool {
+			pooledCost[poolId] += buffer.Len()
+		}
+	}
+	for _, ref := range state.references {
+		pooledCost[ref.poolId] += e.intSize(ref.index)
+		inlineCost[ref.poolId] += state.valueEncodePools[ref.poolId][ref.index].Len()
+	}
+	for _, poolId := range state.overflowLog {
+		pooledCost[poolId] += e.intSize(inlineMarker)
+	}
+	result := unpooled
+	for poolId := range state.valueEncodePools {
+		if inlineCost[poolId] <= pooledCost[poolId] {
+			if len(result) == len(unpooled) {
+				// 不修改传入的 unpooled，它仍然是上一遍编码使用的
+				result = make(map[string]bool, len(unpooled)+1)
+				for unpooledId := range unpooled {
+					result[unpooledId] = true
+				}
+			}
+			result[poolId] = true
+		}
+	}
+	return result
+}
+
+// payloadSize 返回 finish 写出的 payload 的 byte 数
+func (e *Encoder) payloadSize(state *encodeState, dataBuffer *bytes.Buffer) int {
+	size := dataBuffer.Len() + len(e.header)
+	size += e.intSize(len(state.stringPool))
+	for str := range state.stringPool {
+		size += e.intSize(len(str)) + len(str)
+	}
+	size += e.intSize(len(state.valueEncodePools) + len(state.unpooled))
+	for _, field := range e.topologicalFields {
+		encodePool, exist := state.valueEncodePools[field]
+		if state.unpooled[field] {
+			size += e.intSize(len(field)) + len(field) + 1
+		} else if exist {
+			size += e.intSize(len(field)) + len(field) + 1 + e.intSize(len(encodePool))
+			for _, buffer := range encodePool {
+				size += buffer.Len()
+			}
+		}
+	}
+	return size
+}
+
+// intSize 返回 encodeInt 写入 val 占用的 byte 数
+func (e *Encoder) intSize(val int) int {
+	if !e.opts.leb128Enabled {
+		return 8
+	}
+	size := 1
+	for size < 9 && (val >= 64 || val < -64) {
+		val >>= 7
+		size++
+	}
+	return size
+}
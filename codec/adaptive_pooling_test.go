@@ -0,0 +1,189 @@
+package codec
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/beet233/compressotelcollector/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+// encodeWithUnpooled 不做统计，按给定的 unpooled 编码一遍
+func encodeWithUnpooled(t *testing.T, e *Encoder, value model.Value, unpooled map[string]bool) []byte {
+	state := e.newEncodeState()
+	defer e.releaseBuffers(state)
+	state.unpooled = unpooled
+	dataBuffer := bytes.NewBufferString("cprval")
+	require.NoError(t, e.innerEncode(value, e.def, "", state, dataBuffer))
+	var out bytes.Buffer
+	_, err := e.finish(state, dataBuffer, &out)
+	require.NoError(t, err)
+	return out.Bytes()
+}
+
+func TestAdaptivePooling(t *testing.T) {
+	def := &model.Definition{Type: model.Object, Fields: map[string]*model.Definition{
+		"items": {Type: model.Array, ItemDefinition: &model.Definition{Type: model.Object, Fields: map[string]*model.Definition{
+			"requestId": {Type: model.String, Pooled: true},
+			"service":   {Type: model.String, Pooled: true},
+		}}},
+	}}
+	items := make([]any, 0)
+	for i := 0; i < 50; i++ {
+		items = append(items, map[string]any{
+			"requestId": fmt.Sprintf("r%d", i),
+			"service":   fmt.Sprintf("checkout-service-%d", i%2),
+		})
+	}
+	value := model.AnyToValue(map[string]any{"items": items})
+
+	var adaptive, pooled bytes.Buffer
+	stats, err := NewEncoder(def, WithAdaptivePooling(true)).Encode(value, &adaptive)
+	require.NoError(t, err)
+	_, err = NewEncoder(def, WithAdaptivePooling(false)).Encode(value, &pooled)
+	require.NoError(t, err)
+	// 每个 requestId 只出现一次，池化只会多出索引
+	assert.Equal(t, []string{"items item requestId"}, stats.UnpooledPools)
+	assert.Equal(t, map[string]int{"items item service": 2}, stats.ValuePoolSizes)
+	assert.Less(t, adaptive.Len(), pooled.Len())
+
+	decoded, decodeStats, err := NewDecoder(def).Decode(&adaptive)
+	require.NoError(t, err)
+	assert.Equal(t, 0, model.ValueComparator(value, decoded))
+	assert.Equal(t, stats.ValuePoolSizes, decodeStats.ValuePoolSizes)
+}
+
+func TestPlanUnpooled(t *testing.T) {
+	def, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(t, err)
+	for _, opts := range [][]Option{nil, {WithLeb128(false)}} {
+		e := NewEncoder(def, append(opts, WithGenerated(false), WithAdaptivePooling(false))...)
+		for inputName, value := range map[string]model.Value{
+			"rich":      TracesToValue(newRichTraces(30)),
+			"load test": TracesToValue(newLoadTestTraces(3)),
+		} {
+			state := e.newEncodeState()
+			state.collectReferences = true
+			require.NoError(t, e.innerEncode(value, e.def, "", state, bytes.NewBufferString("cprval")))
+			unpooled := e.planUnpooled(state, nil)
+			poolIds := make([]string, 0, len(state.valueEncodePools))
+			for poolId := range state.valueEncodePools {
+				poolIds = append(poolIds, poolId)
+			}
+			e.releaseBuffers(state)
+
+			// 单独不池化一个池子时，payload 不变大的正好是 planUnpooled 选出的池子
+			allPooled := len(encodeWithUnpooled(t, e, value, nil))
+			for _, poolId := range poolIds {
+				size := len(encodeWithUnpooled(t, e, value, map[string]bool{poolId: true}))
+				if unpooled[poolId] {
+					assert.LessOrEqual(t, size, allPooled, inputName+"/"+poolId)
+				} else {
+					assert.Greater(t, size, allPooled, inputName+"/"+poolId)
+				}
+			}
+		}
+	}
+}
+
+func TestAdaptivePoolingTraces(t *testing.T) {
+	def, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(t, err)
+	for inputName, td := range map[string]ptrace.Traces{
+		"rich":      newRichTraces(200),
+		"load test": newLoadTestTraces(3),
+	} {
+		t.Run(inputName, func(t *testing.T) {
+			var adaptive, pooled bytes.Buffer
+			_, err := NewEncoder(def, WithAdaptivePooling(true)).EncodeTraces(td, &adaptive)
+			require.NoError(t, err)
+			_, err = NewEncoder(def, WithAdaptivePooling(false)).EncodeTraces(td, &pooled)
+			require.NoError(t, err)
+			assert.LessOrEqual(t, adaptive.Len(), pooled.Len())
+
+			got, _, err := NewDecoder(def).DecodeTraces(&adaptive)
+			require.NoError(t, err)
+			assert.Equal(t, 0, model.ValueComparator(TracesToValue(td), TracesToValue(got)))
+		})
+	}
+}
+
+// 池子嵌套时不池化外层的池子会让内层池子的引用变多，单独比较每个池子得到的结果可能比全部池化更大
+func TestAdaptivePoolingNested(t *testing.T) {
+	def := &model.Definition{Type: model.Object, Version: 1, Fields: map[string]*model.Definition{
+		"items": {Type: model.Array, ItemDefinition: &model.Definition{Type: model.Object, Fields: map[string]*model.Definition{
+			"resource": {Type: model.Object, Pooled: true, Fields: map[string]*model.Definition{
+				"name": {Type: model.String, Pooled: true},
+			}},
+		}}},
+	}}
+	// 每个 resource 出现两次，只有一个很长的 name 的引用，内联比池化更小；每个 name 只被池中的一个 resource 引用，单独看也不值得池化
+	items := make([]any, 0)
+	for i := 0; i < 20; i++ {
+		resource := map[string]any{"name": fmt.Sprintf("%s-%d", strings.Repeat("service", 20), i/2)}
+		items = append(items, map[string]any{"resource": resource})
+	}
+	value := model.AnyToValue(map[string]any{"items": items})
+
+	for _, opts := range [][]Option{nil, {WithLeb128(false)}, {WithEmbeddedSchema(true)}} {
+		var adaptive, pooled bytes.Buffer
+		_, err := NewEncoder(def, append(opts, WithAdaptivePooling(true))...).Encode(value, &adaptive)
+		require.NoError(t, err)
+		_, err = NewEncoder(def, append(opts, WithAdaptivePooling(false))...).Encode(value, &pooled)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, adaptive.Len(), pooled.Len())
+
+		// payloadSize 和 finish 写出的 byte 数一致，def 有 Version，包括 schema id 或 schema
+		encoder := NewEncoder(def, append(opts, WithAdaptivePooling(true))...)
+		for _, unpooled := range []map[string]bool{nil, {"items item resource": true}} {
+			state := encoder.newEncodeState()
+			state.unpooled = unpooled
+			dataBuffer := bytes.NewBufferString("cprval")
+			require.NoError(t, encoder.innerEncode(value, encoder.def, "", state, dataBuffer))
+			size := encoder.payloadSize(state, dataBuffer)
+			var out bytes.Buffer
+			_, err = encoder.finish(state, dataBuffer, &out)
+			require.NoError(t, err)
+			encoder.releaseBuffers(state)
+			assert.Equal(t, out.Len(), size)
+		}
+
+		decoded, _, err := NewDecoder(def, opts...).Decode(&adaptive)
+		require.NoError(t, err)
+		assert.Equal(t, 0, model.ValueComparator(value, decoded))
+	}
+}
+
+// BenchmarkAdaptivePooling 对比开启自适应池化的耗时和省下的 byte，payload-bytes 是编码结果的大小
+func BenchmarkAdaptivePooling(b *testing.B) {
+	def, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(b, err)
+	for _, input := range []struct {
+		name string
+		td   ptrace.Traces
+	}{
+		{name: "rich", td: newRichTraces(200)},
+		{name: "load test", td: newLoadTestTraces(3)},
+	} {
+		for _, adaptive := range []bool{false, true} {
+			encoder := NewEncoder(def, WithAdaptivePooling(adaptive))
+			b.Run(fmt.Sprintf("%s/adaptive=%v", input.name, adaptive), func(b *testing.B) {
+				var buf bytes.Buffer
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					buf.Reset()
+					_, err := encoder.EncodeTraces(input.td, &buf)
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(buf.Len()), "payload-bytes")
+			})
+		}
+	}
+}
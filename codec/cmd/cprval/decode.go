@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+
+	"github.com/beet233/compressotelcollector/codec"
+)
+
+// runDecode 把 cprval 解码为 OTLP JSON 或 proto
+func runDecode(args []string) error {
+	flags := flag.NewFlagSet("decode", flag.ContinueOnError)
+	c := addCodecFlags(flags)
+	format := flags.String("format", formatAuto, "输出的格式：auto、json 或 proto")
+	positional, err := parseArgs(flags, args, 2)
+	if err != nil {
+		return err
+	}
+	in, out := positional[0], positional[1]
+
+	entry, err := c.entry()
+	if err != nil {
+		return err
+	}
+	outFormat, err := traceFormat(*format, out)
+	if err != nil {
+		return err
+	}
+	data, err := readInput(in)
+	if err != nil {
+		return err
+	}
+	td, _, err := codec.NewEntryDecoder(entry, c.options()...).DecodeTraces(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	result, err := marshalTraces(outFormat, td)
+	if err != nil {
+		return err
+	}
+	return writeOutput(out, result)
+}
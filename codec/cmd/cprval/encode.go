@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/beet233/compressotelcollector/codec"
+)
+
+// runEncode 把 OTLP JSON 或 proto 编码为 cprval
+func runEncode(args []string) error {
+	flags := flag.NewFlagSet("encode", flag.ContinueOnError)
+	c := addCodecFlags(flags)
+	format := flags.String("format", formatAuto, "输入的格式：auto、json 或 proto")
+	adaptivePooling := flags.Bool("adaptive-pooling", false, "是否使用自适应池化，应和 exporter 的 adaptive_pooling_enabled 一致")
+	embeddedSchema := flags.Bool("embedded-schema", false, "是否在 payload 中写入完整的 Definition，否则只写入版本和指纹")
+	positional, err := parseArgs(flags, args, 2)
+	if err != nil {
+		return err
+	}
+	in, out := positional[0], positional[1]
+
+	entry, err := c.entry()
+	if err != nil {
+		return err
+	}
+	inFormat, err := traceFormat(*format, in)
+	if err != nil {
+		return err
+	}
+	data, err := readInput(in)
+	if err != nil {
+		return err
+	}
+	td, err := unmarshalTraces(inFormat, data)
+	if err != nil {
+		return err
+	}
+	encoder := codec.NewEntryEncoder(entry, append(c.options(), codec.WithAdaptivePooling(*adaptivePooling), codec.WithEmbeddedSchema(*embeddedSchema))...)
+	var buf bytes.Buffer
+	_, err = encoder.EncodeTraces(td, &buf)
+	if err != nil {
+		return err
+	}
+	err = writeOutput(out, buf.Bytes())
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "encoded %d spans: %d bytes -> %d bytes\n", td.SpanCount(), len(data), buf.Len())
+	return nil
+}
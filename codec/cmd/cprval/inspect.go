@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+
+	"github.com/beet233/compressotelcollector/codec"
+)
+
+// inspectOutput 是 -json 的输出，解析失败时 payload 是已经解析出的部分
+type inspectOutput struct {
+	Payload *codec.InspectNode `json:"payload"`
+	Error   string             `json:"error,omitempty"`
+}
+
+// runInspect 按 Definition 逐字节解析 cprval，输出 header、各个池子和带 offset 的数据树。
+// 解析失败时仍然输出已经解析出的部分
+func runInspect(args []string) error {
+	flags := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	c := addCodecFlags(flags)
+	jsonOutput := flags.Bool("json", false, "以 JSON 输出")
+	positional, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	entry, err := c.entry()
+	if err != nil {
+		return err
+	}
+	data, err := readInput(positional[0])
+	if err != nil {
+		return err
+	}
+	root, inspectErr := codec.NewEntryDecoder(entry, c.options()...).Inspect(bytes.NewReader(data))
+
+	out := bufio.NewWriter(os.Stdout)
+	if *jsonOutput {
+		output := inspectOutput{Payload: root}
+		if inspectErr != nil {
+			output.Error = inspectErr.Error()
+		}
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(output)
+	} else {
+		err = root.WriteText(out)
+	}
+	if err != nil {
+		return err
+	}
+	err = out.Flush()
+	if err != nil {
+		return err
+	}
+	return inspectErr
+}
@@ -0,0 +1,224 @@
+// cprval 是离线处理 cprval payload 的命令行工具，使用和 collector 组件相同的 Definition 与 Encoder、Decoder。
+//
+// 用法：
+//
+//	cprval encode [flags] traces.json out.cprval
+//	cprval decode [flags] in.cprval traces.json
+//	cprval stats [flags] in.cprval
+//	cprval inspect [flags] in.cprval
+//	cprval validate-definition trace.json
+//
+// 文件名为 - 时使用 stdin 或 stdout，.json 文件按 OTLP JSON 处理，其他按 OTLP proto 处理，可以用 -format 指定。
+// leb128、stringPool 等设置需要和产生 payload 的 exporter 一致，解码的资源限制默认和 receiver 相同，可以用 -max-body-size 等放宽
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/beet233/compressotelcollector/codec"
+	"github.com/beet233/compressotelcollector/model"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+const (
+	formatAuto  = "auto"
+	formatJSON  = "json"
+	formatProto = "proto"
+)
+
+var commands = map[string]func(args []string) error{
+	"encode":              runEncode,
+	"decode":              runDecode,
+	"stats":               runStats,
+	"inspect":             runInspect,
+	"validate-definition": runValidateDefinition,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	command, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	err := command(os.Args[2:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "cprval "+os.Args[1]+":", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `usage:
+  cprval encode [flags] <traces.json|traces.pb> <out.cprval>
+  cprval decode [flags] <in.cprval> <traces.json|traces.pb>
+  cprval stats [flags] <in.cprval>
+  cprval inspect [-json] [flags] <in.cprval>
+  cprval validate-definition <definition.json>
+
+run "cprval <command> -h" for the flags of a command`)
+}
+
+// definitionFiles 是可以重复指定的 -def
+type definitionFiles []string
+
+func (f *definitionFiles) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *definitionFiles) Set(path string) error {
+	*f = append(*f, path)
+	return nil
+}
+
+// codecFlags 是 encode、decode、stats、inspect 共用的 Definition 和编码设置
+type codecFlags struct {
+	defs             *definitionFiles
+	version          *int
+	leb128           *bool
+	stringPool       *bool
+	parentReferences *bool
+	traceGrouping    *bool
+	restoreSpanOrder *bool
+	limits           decodeLimitFlags
+	// registry 是 entry 注册 -def 的 Registry，解码时用于查找 payload 中 schema id 对应的 Definition
+	registry *model.Registry
+}
+
+// decodeLimitFlags 是 Decoder 的资源限制，默认和 receiver 一样是 codec.DefaultDecodeLimits，处理本地的大文件时可以放宽
+type decodeLimitFlags struct {
+	maxBodySize    *int
+	maxPoolEntries *int
+	maxArrayLength *int
+	maxDepth       *int
+	maxDecodedSize *int
+}
+
+func addCodecFlags(flags *flag.FlagSet) *codecFlags {
+	defaults := codec.DefaultDecodeLimits()
+	defs := &definitionFiles{}
+	flags.Var(defs, "def", "Definition 的 JSON 文件，可以重复指定多个版本，不指定时使用 ./trace.json 或内置的 trace.json")
+	return &codecFlags{
+		defs:             defs,
+		version:          flags.Int("version", 0, "使用的 Definition 版本，0 表示版本最高的，应和 exporter 的 trace_model_version 一致"),
+		leb128:           flags.Bool("leb128", true, "整数是否使用 leb128，应和 exporter 的 leb128_enabled 一致"),
+		stringPool:       flags.Bool("string-pool", true, "attributes 中的 string 是否使用 stringPool，应和 exporter 的 string_pool_enabled 一致"),
+		parentReferences: flags.Bool("parent-references", false, "parentSpanId 是否编码为回引用，应和 exporter 的 parent_references_enabled 一致"),
+		traceGrouping:    flags.Bool("trace-grouping", false, "span 是否按 traceId 分组编码，应和 exporter 的 trace_grouping_enabled 一致"),
+		restoreSpanOrder: flags.Bool("restore-span-order", true, "按 traceId 分组时是否还原 span 原来的顺序，应和 exporter 的 restore_span_order 一致"),
+		limits: decodeLimitFlags{
+			maxBodySize:    flags.Int("max-body-size", defaults.MaxBodySize, "解码时 payload 的最大字节数，0 表示不限制"),
+			maxPoolEntries: flags.Int("max-pool-entries", defaults.MaxPoolEntries, "解码时每个池子的最大条目数，0 表示不限制"),
+			maxArrayLength: flags.Int("max-array-length", defaults.MaxArrayLength, "解码时数组的最大长度，0 表示不限制"),
+			maxDepth:       flags.Int("max-depth", defaults.MaxDepth, "解码时 Object、Array 的最大嵌套深度，0 表示不限制"),
+			maxDecodedSize: flags.Int("max-decoded-size", defaults.MaxDecodedSize, "解码后数据的最大估算字节数，0 表示不限制"),
+		},
+	}
+}
+
+// entry 把 -def 的各个文件注册到 Registry，按 -version 选择使用的 Definition，其他版本用于解码这些版本的 payload
+func (c *codecFlags) entry() (*model.Entry, error) {
+	registry := model.NewRegistry()
+	if len(*c.defs) == 0 {
+		_, err := registry.RegisterTraceModel(zap.NewNop())
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, path := range *c.defs {
+		_, err := registry.RegisterFile(model.Traces, path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	c.registry = registry
+	return registry.Resolve(model.Traces, *c.version)
+}
+
+func (c *codecFlags) options() []codec.Option {
+	return []codec.Option{
+		codec.WithLeb128(*c.leb128),
+		codec.WithStringPool(*c.stringPool),
+		codec.WithParentReferences(*c.parentReferences),
+		codec.WithTraceGrouping(*c.traceGrouping),
+		codec.WithRestoreSpanOrder(*c.restoreSpanOrder),
+		codec.WithLimits(codec.DecodeLimits{
+			MaxBodySize:    *c.limits.maxBodySize,
+			MaxPoolEntries: *c.limits.maxPoolEntries,
+			MaxArrayLength: *c.limits.maxArrayLength,
+			MaxDepth:       *c.limits.maxDepth,
+			MaxDecodedSize: *c.limits.maxDecodedSize,
+		}),
+		codec.WithWriters(c.registry),
+	}
+}
+
+// parseArgs 解析 flags 并检查位置参数的个数
+func parseArgs(flags *flag.FlagSet, args []string, positional int) ([]string, error) {
+	err := flags.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+	if flags.NArg() != positional {
+		flags.Usage()
+		return nil, flag.ErrHelp
+	}
+	return flags.Args(), nil
+}
+
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+func writeOutput(path string, data []byte) error {
+	if path == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// traceFormat 返回 path 的 OTLP 格式，format 为 auto 时按扩展名判断
+func traceFormat(format string, path string) (string, error) {
+	switch format {
+	case formatJSON, formatProto:
+		return format, nil
+	case formatAuto:
+		if strings.EqualFold(filepath.Ext(path), ".json") {
+			return formatJSON, nil
+		}
+		return formatProto, nil
+	}
+	return "", fmt.Errorf("unsupported format %q", format)
+}
+
+func unmarshalTraces(format string, data []byte) (ptrace.Traces, error) {
+	if format == formatJSON {
+		return (&ptrace.JSONUnmarshaler{}).UnmarshalTraces(data)
+	}
+	return (&ptrace.ProtoUnmarshaler{}).UnmarshalTraces(data)
+}
+
+func marshalTraces(format string, td ptrace.Traces) ([]byte, error) {
+	if format == formatJSON {
+		return (&ptrace.JSONMarshaler{}).MarshalTraces(td)
+	}
+	return (&ptrace.ProtoMarshaler{}).MarshalTraces(td)
+}
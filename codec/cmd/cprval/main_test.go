@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/beet233/compressotelcollector/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func newTestTraces() ptrace.Traces {
+	td := ptrace.NewTraces()
+	rs := td.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("service.name", "cprval")
+	spans := rs.ScopeSpans().AppendEmpty().Spans()
+	for i := 0; i < 10; i++ {
+		span := spans.AppendEmpty()
+		span.SetTraceID([16]byte{1, byte(i % 2)})
+		span.SetSpanID([8]byte{2, byte(i)})
+		span.SetName(fmt.Sprintf("span-%d", i%3))
+		span.Attributes().PutStr("http.method", "GET")
+		span.Attributes().PutInt("http.status_code", 200)
+	}
+	return td
+}
+
+// captureStdout 返回 run 执行期间写入 stdout 的内容
+func captureStdout(t *testing.T, run func() error) (string, error) {
+	path := filepath.Join(t.TempDir(), "stdout")
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	stdout := os.Stdout
+	os.Stdout = file
+	runErr := run()
+	os.Stdout = stdout
+	require.NoError(t, file.Close())
+	out, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(out), runErr
+}
+
+func TestRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	td := newTestTraces()
+	data, err := (&ptrace.JSONMarshaler{}).MarshalTraces(td)
+	require.NoError(t, err)
+	in := filepath.Join(dir, "traces.json")
+	require.NoError(t, os.WriteFile(in, data, 0o644))
+
+	for _, flags := range [][]string{nil, {"-leb128=false", "-string-pool=false"}, {"-parent-references", "-trace-grouping"}} {
+		t.Run(strings.Join(flags, " "), func(t *testing.T) {
+			payload := filepath.Join(dir, "traces.cprval")
+			require.NoError(t, runEncode(append(append([]string{}, flags...), in, payload)))
+			for _, out := range []string{"decoded.json", "decoded.pb"} {
+				out = filepath.Join(dir, out)
+				require.NoError(t, runDecode(append(append([]string{}, flags...), payload, out)))
+				decoded, err := os.ReadFile(out)
+				require.NoError(t, err)
+				format, err := traceFormat(formatAuto, out)
+				require.NoError(t, err)
+				got, err := unmarshalTraces(format, decoded)
+				require.NoError(t, err)
+				assert.Equal(t, td, got)
+			}
+
+			text, err := captureStdout(t, func() error {
+				return runInspect(append(append([]string{}, flags...), payload))
+			})
+			require.NoError(t, err)
+			assert.Contains(t, text, "stringPool (")
+			assert.Contains(t, text, "span-2")
+
+			output, err := captureStdout(t, func() error {
+				return runInspect(append(append([]string{"-json"}, flags...), payload))
+			})
+			require.NoError(t, err)
+			var inspected inspectOutput
+			require.NoError(t, json.Unmarshal([]byte(output), &inspected))
+			require.NotNil(t, inspected.Payload)
+			assert.Empty(t, inspected.Error)
+
+			stats, err := captureStdout(t, func() error {
+				return runStats(append(append([]string{}, flags...), payload))
+			})
+			require.NoError(t, err)
+			assert.Regexp(t, `(?m)^spans +10$`, stats)
+			// trace 模型有 Version，payload 带有 schema
+			assert.Regexp(t, `(?m)^  schema +\d+ bytes$`, stats)
+		})
+	}
+}
+
+func TestDecodeLimitFlags(t *testing.T) {
+	dir := t.TempDir()
+	data, err := (&ptrace.ProtoMarshaler{}).MarshalTraces(newTestTraces())
+	require.NoError(t, err)
+	in := filepath.Join(dir, "traces.pb")
+	require.NoError(t, os.WriteFile(in, data, 0o644))
+	payload := filepath.Join(dir, "traces.cprval")
+	require.NoError(t, runEncode([]string{in, payload}))
+	out := filepath.Join(dir, "decoded.pb")
+
+	err = runDecode([]string{"-max-body-size", "10", payload, out})
+	assert.ErrorIs(t, err, codec.ErrPayloadTooLarge)
+	err = runDecode([]string{"-max-depth", "1", payload, out})
+	assert.ErrorIs(t, err, codec.ErrLimitExceeded)
+	_, err = captureStdout(t, func() error {
+		return runInspect([]string{"-max-array-length", "1", payload})
+	})
+	assert.ErrorIs(t, err, codec.ErrLimitExceeded)
+	// 0 表示不限制
+	require.NoError(t, runDecode([]string{"-max-body-size", "0", "-max-depth", "0", payload, out}))
+
+	err = runDecode([]string{"-version", "2", payload, out})
+	assert.EqualError(t, err, "Definition traces version 2 is not registered")
+}
+
+func TestWriterDefinitions(t *testing.T) {
+	dir := t.TempDir()
+	data, err := (&ptrace.ProtoMarshaler{}).MarshalTraces(newTestTraces())
+	require.NoError(t, err)
+	in := filepath.Join(dir, "traces.pb")
+	require.NoError(t, os.WriteFile(in, data, 0o644))
+	// v2 在 span 中新增可以为 null 的 flags
+	src, err := os.ReadFile("../../../model/trace.json")
+	require.NoError(t, err)
+	var def map[string]any
+	require.NoError(t, json.Unmarshal(src, &def))
+	v1 := filepath.Join(dir, "v1.json")
+	require.NoError(t, os.WriteFile(v1, src, 0o644))
+	def["Version"] = 2
+	spans := def["Fields"].(map[string]any)["resourceSpans"].(map[string]any)["ItemDefinition"].(map[string]any)["Fields"].(map[string]any)["scopeSpans"].(map[string]any)["ItemDefinition"].(map[string]any)["Fields"].(map[string]any)["spans"]
+	spans.(map[string]any)["ItemDefinition"].(map[string]any)["Fields"].(map[string]any)["flags"] = map[string]any{"Type": "int", "Nullable": true}
+	src, err = json.Marshal(def)
+	require.NoError(t, err)
+	v2 := filepath.Join(dir, "v2.json")
+	require.NoError(t, os.WriteFile(v2, src, 0o644))
+
+	payload := filepath.Join(dir, "traces.cprval")
+	out := filepath.Join(dir, "decoded.pb")
+	require.NoError(t, runEncode([]string{"-def", v1, in, payload}))
+	// payload 只带 schema id，需要用 -def 提供 writer 的 Definition
+	err = runDecode([]string{"-def", v2, payload, out})
+	assert.EqualError(t, err, "payload Definition: Definition traces version 1 is not registered, register it with WithWriters or embed the schema in the payload")
+	require.NoError(t, runDecode([]string{"-def", v1, "-def", v2, payload, out}))
+
+	require.NoError(t, runEncode([]string{"-def", v1, "-embedded-schema", in, payload}))
+	require.NoError(t, runDecode([]string{"-def", v2, payload, out}))
+	decoded, err := os.ReadFile(out)
+	require.NoError(t, err)
+	got, err := unmarshalTraces(formatProto, decoded)
+	require.NoError(t, err)
+	assert.Equal(t, newTestTraces(), got)
+}
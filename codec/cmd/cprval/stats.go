@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/beet233/compressotelcollector/codec"
+	"github.com/klauspost/compress/zstd"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// runStats 输出 cprval payload 各部分的大小、每个池子的条目数，以及和 OTLP proto、zstd 的对比
+func runStats(args []string) error {
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	c := addCodecFlags(flags)
+	positional, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	entry, err := c.entry()
+	if err != nil {
+		return err
+	}
+	data, err := readInput(positional[0])
+	if err != nil {
+		return err
+	}
+	td, stats, err := codec.NewEntryDecoder(entry, c.options()...).DecodeTraces(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	proto, err := (&ptrace.ProtoMarshaler{}).MarshalTraces(td)
+	if err != nil {
+		return err
+	}
+	zstdEncoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		return err
+	}
+	defer zstdEncoder.Close()
+	cprvalZstd := len(zstdEncoder.EncodeAll(data, nil))
+	protoZstd := len(zstdEncoder.EncodeAll(proto, nil))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "spans\t%d\n", td.SpanCount())
+	// ratio 都是 OTLP proto 的大小除以该项的大小
+	fmt.Fprintf(w, "payload\t%d bytes\tratio %.2f\n", stats.CompressedSize, ratio(len(proto), stats.CompressedSize))
+	if stats.SchemaBytes > 0 {
+		fmt.Fprintf(w, "  schema\t%d bytes\n", stats.SchemaBytes)
+	}
+	fmt.Fprintf(w, "  stringPool\t%d bytes\t%d entries\n", stats.StringPoolBytes, stats.StringPoolSize)
+	for _, poolId := range entry.TopologicalFields {
+		size, exist := stats.ValuePoolBytes[poolId]
+		if !exist {
+			continue
+		}
+		entries, pooled := stats.ValuePoolSizes[poolId]
+		if pooled {
+			fmt.Fprintf(w, "  valuePool %s\t%d bytes\t%d entries\n", poolId, size, entries)
+		} else {
+			fmt.Fprintf(w, "  valuePool %s\t%d bytes\tinline\n", poolId, size)
+		}
+	}
+	fmt.Fprintf(w, "  data\t%d bytes\n", stats.DataBytes)
+	fmt.Fprintf(w, "decoded\t%d bytes\n", stats.DecodedSize)
+	fmt.Fprintf(w, "proto\t%d bytes\n", len(proto))
+	fmt.Fprintf(w, "zstd(proto)\t%d bytes\tratio %.2f\n", protoZstd, ratio(len(proto), protoZstd))
+	fmt.Fprintf(w, "zstd(cprval)\t%d bytes\tratio %.2f\n", cprvalZstd, ratio(len(proto), cprvalZstd))
+	return w.Flush()
+}
+
+// ratio 返回 proto 大小与 size 的比值
+func ratio(proto int, size int) float64 {
+	if size == 0 {
+		return 0
+	}
+	return float64(proto) / float64(size)
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/beet233/compressotelcollector/model"
+)
+
+// runValidateDefinition 检查 Definition JSON 是否合法，合法时按编码顺序列出所有池子
+func runValidateDefinition(args []string) error {
+	flags := flag.NewFlagSet("validate-definition", flag.ContinueOnError)
+	positional, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	def, err := model.GetDefinitionFromFile(positional[0])
+	if err != nil {
+		return err
+	}
+	entry, err := model.NewEntry(positional[0], def)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: ok\nfingerprint: %s\nvaluePools:\n", positional[0], entry.Fingerprint)
+	for _, poolId := range entry.TopologicalFields {
+		fmt.Printf("  %s\n", poolId)
+	}
+	return nil
+}
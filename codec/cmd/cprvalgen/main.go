@@ -0,0 +1,42 @@
+// cprvalgen 根据 Definition 的 JSON 生成 codec 包使用的专用编解码代码。
+//
+// 用法：
+//
+//	go run ./cmd/cprvalgen -def ../model/trace.json -name trace -out trace_gen.go
+//
+// 生成的代码在 init 中按 Definition 的摘要注册，NewEncoder、NewDecoder 遇到结构相同的 Definition 时自动使用
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/beet233/compressotelcollector/codec/internal/gen"
+	"github.com/beet233/compressotelcollector/model"
+)
+
+func main() {
+	defPath := flag.String("def", "", "Definition 的 JSON 文件")
+	name := flag.String("name", "", "生成函数的前缀，如 trace")
+	out := flag.String("out", "", "输出的 Go 文件")
+	flag.Parse()
+	if *defPath == "" || *name == "" || *out == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	def, err := model.GetDefinitionFromFile(*defPath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	src, err := gen.Generate(def, *name, filepath.Base(*defPath))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	err = os.WriteFile(*out, src, 0o644)
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
@@ -0,0 +1,147 @@
+// cprvaltune 根据一批 OTLP trace 样本为 Definition 选择 Pooled、SharePooled 和 DiffEncode，输出调优后的 Definition JSON 和报告。
+//
+// 用法：
+//
+//	go run ./cmd/cprvaltune -def ../model/trace.json -out trace.json samples/
+//
+// 样本可以是文件或目录，.json 和 .jsonl 文件按 OTLP JSON 解析，每行一个 ExportTraceServiceRequest，其他文件按 OTLP proto 解析。
+// 每个候选 Definition 都用实际的 Encoder 编码所有样本并经过 zstd 压缩，报告中列出每个 field 每种取值的压缩后总字节数
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/beet233/compressotelcollector/codec"
+	"github.com/beet233/compressotelcollector/codec/internal/tune"
+	"github.com/beet233/compressotelcollector/model"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+func main() {
+	defPath := flag.String("def", "", "初始 Definition 的 JSON 文件，为空时使用内置的 trace.json")
+	out := flag.String("out", "", "输出调优后的 Definition JSON 文件")
+	reportPath := flag.String("report", "", "输出报告的文件，为空时输出到 stdout")
+	rounds := flag.Int("rounds", 1, "对所有字段依次尝试的轮数")
+	leb128 := flag.Bool("leb128", true, "打分时 Encoder 是否使用 leb128，应和 exporter 的 leb128_enabled 一致")
+	stringPool := flag.Bool("string-pool", true, "打分时 Encoder 是否使用 stringPool，应和 exporter 的 string_pool_enabled 一致")
+	adaptivePooling := flag.Bool("adaptive-pooling", false, "打分时 Encoder 是否使用自适应池化，应和 exporter 的 adaptive_pooling_enabled 一致")
+	flag.Parse()
+	if *out == "" || flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var def *model.Definition
+	var err error
+	if *defPath == "" {
+		def, err = model.LoadTraceModel(zap.NewNop())
+	} else {
+		def, err = model.GetDefinitionFromFile(*defPath)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+	corpus, err := readCorpus(flag.Args())
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	tuned, report, err := tune.Tune(def, corpus, tune.Options{
+		Codec: []codec.Option{
+			codec.WithLeb128(*leb128),
+			codec.WithStringPool(*stringPool),
+			codec.WithAdaptivePooling(*adaptivePooling),
+		},
+		Rounds: *rounds,
+	})
+	if err != nil {
+		log.Fatalln(err)
+	}
+	src, err := tune.MarshalDefinition(tuned)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	err = os.WriteFile(*out, src, 0o644)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	reportOut := os.Stdout
+	if *reportPath != "" {
+		reportOut, err = os.Create(*reportPath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer reportOut.Close()
+	}
+	err = report.Write(reportOut)
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// readCorpus 读取 paths 中的所有样本，目录会递归读取其中的文件
+func readCorpus(paths []string) ([]ptrace.Traces, error) {
+	var corpus []ptrace.Traces
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			traces, err := readTraces(path)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			corpus = append(corpus, traces...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return corpus, nil
+}
+
+func readTraces(path string) ([]ptrace.Traces, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext != ".json" && ext != ".jsonl" {
+		td, err := (&ptrace.ProtoUnmarshaler{}).UnmarshalTraces(data)
+		if err != nil {
+			return nil, err
+		}
+		return []ptrace.Traces{td}, nil
+	}
+	var result []ptrace.Traces
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		td, err := (&ptrace.JSONUnmarshaler{}).UnmarshalTraces(line)
+		if err != nil {
+			// 不是一行一个的格式时，按整个文件解析
+			td, err = (&ptrace.JSONUnmarshaler{}).UnmarshalTraces(data)
+			if err != nil {
+				return nil, err
+			}
+			return []ptrace.Traces{td}, nil
+		}
+		result = append(result, td)
+	}
+	return result, scanner.Err()
+}
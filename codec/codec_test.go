@@ -0,0 +1,111 @@
+package codec
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/beet233/compressotelcollector/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeb128RoundTrip(t *testing.T) {
+	values := []int{0, 1, -1, 63, 64, -64, -65, 127, 128, -128, 1 << 20, -(1 << 20), 1 << 48, -(1 << 48),
+		1<<55 - 1, -(1 << 55), 1 << 55, -(1 << 55) - 1, 1 << 62, math.MaxInt64, math.MinInt64}
+	for _, val := range values {
+		var buf bytes.Buffer
+		require.NoError(t, WriteLeb128Int(&buf, val))
+		assert.LessOrEqual(t, buf.Len(), 9)
+		reader := NewDataReader(buf.Bytes())
+		got, err := reader.ReadLeb128Int()
+		require.NoError(t, err)
+		assert.Equal(t, val, got)
+		assert.Equal(t, 0, reader.Len())
+
+		buf.Reset()
+		require.NoError(t, WriteInt(&buf, val))
+		got, err = NewDataReader(buf.Bytes()).ReadInt()
+		require.NoError(t, err)
+		assert.Equal(t, val, got)
+	}
+}
+
+func TestPrimitivesRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteFloat(&buf, -1.5))
+	require.NoError(t, WriteBoolean(&buf, true))
+	require.NoError(t, WriteString(&buf, "abc"))
+	require.NoError(t, WriteBytes(&buf, []byte{1, 2}))
+
+	reader := NewDataReader(buf.Bytes())
+	dbv, err := reader.ReadFloat()
+	require.NoError(t, err)
+	assert.Equal(t, -1.5, dbv)
+	boolv, err := reader.ReadBoolean()
+	require.NoError(t, err)
+	assert.True(t, boolv)
+	strv, err := reader.ReadString(3)
+	require.NoError(t, err)
+	assert.Equal(t, "abc", strv)
+	bv, err := reader.ReadBytes(2)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 2}, bv)
+	assert.Equal(t, 0, reader.Len())
+}
+
+// roundTripDefinition 覆盖池化、共享池、差分编码和自由 map
+var roundTripDefinition = &model.Definition{Type: model.Object, Fields: map[string]*model.Definition{
+	"items": {Type: model.Array, ItemDefinition: &model.Definition{Type: model.Object, Fields: map[string]*model.Definition{
+		"id":         {Type: model.Bytes, SharePooled: true, SharePoolId: "id"},
+		"name":       {Type: model.String, Pooled: true},
+		"time":       {Type: model.Integer, DiffEncode: true},
+		"ratio":      {Type: model.Double},
+		"ok":         {Type: model.Boolean},
+		"attributes": {Type: model.Object, Nullable: true, Pooled: true},
+	}}},
+}}
+
+func roundTripValue() model.Value {
+	item := func(id byte, name string, time int64, attrs map[string]any) map[string]any {
+		return map[string]any{
+			"id":         []byte{id},
+			"name":       name,
+			"time":       time,
+			"ratio":      float64(time) / 3,
+			"ok":         time%2 == 0,
+			"attributes": attrs,
+		}
+	}
+	return model.AnyToValue(map[string]any{
+		"items": []any{
+			// 时间倒序，差分为负数
+			item(1, "a", 1000, map[string]any{"k": "v", "n": int64(-5), "nested": map[string]any{"list": []any{"x", int64(-1 << 40), true}}}),
+			item(2, "b", 10, map[string]any{"k": "v"}),
+			item(1, "a", -300, map[string]any{"k": "w", "f": 2.5}),
+		},
+	})
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opts []Option
+	}{
+		{name: "default"},
+		{name: "fixed int", opts: []Option{WithLeb128(false)}},
+		{name: "no string pool", opts: []Option{WithStringPool(false)}},
+		{name: "fixed int without string pool", opts: []Option{WithLeb128(false), WithStringPool(false)}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			value := roundTripValue()
+			var buf bytes.Buffer
+			_, err := NewEncoder(roundTripDefinition, tt.opts...).Encode(value, &buf)
+			require.NoError(t, err)
+
+			decoded, _, err := NewDecoder(roundTripDefinition, tt.opts...).Decode(&buf)
+			require.NoError(t, err)
+			assert.Equal(t, 0, model.ValueComparator(value, decoded))
+		})
+	}
+}
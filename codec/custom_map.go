@@ -1,4 +1,4 @@
-package compressotelexporter
+package codec
 
 import (
 	"github.com/beet233/compressotelcollector/model"
@@ -6,35 +6,37 @@ import (
 
 // 自定义的HashMap结构
 type HashMap struct {
-	size  int
-	store map[int][]*entry
+	size int
+	// 同一个哈希的 entry 通过 next 串成链表，64 位哈希下冲突很少，大部分链表只有一个 entry
+	store map[uint64]*entry
 }
 
 // entry 存储实际的键值对，其中键是Key类型
 type entry struct {
 	value model.Value
 	index int
+	next  *entry
 }
 
 // NewHashMap 创建一个新的HashMap实例
 func NewHashMap() *HashMap {
-	return &HashMap{size: 0, store: make(map[int][]*entry)}
+	return &HashMap{size: 0, store: make(map[uint64]*entry)}
 }
 
 // Put 设置键值对
 func (h *HashMap) Put(v model.Value, index int) {
 	hash := v.Hash()
-	if e, ok := h.getKeyEntry(v); ok {
+	if e, ok := h.getKeyEntry(v, hash); ok {
 		e.value = v
 		return
 	}
-	h.store[hash] = append(h.store[hash], &entry{value: v, index: index})
+	h.store[hash] = &entry{value: v, index: index, next: h.store[hash]}
 	h.size += 1
 }
 
 // Get 获取与键关联的值
 func (h *HashMap) Get(v model.Value) (int, bool) {
-	if e, ok := h.getKeyEntry(v); ok {
+	if e, ok := h.getKeyEntry(v, v.Hash()); ok {
 		return e.index, true
 	}
 	return 0, false
@@ -45,10 +47,8 @@ func (h *HashMap) Size() int {
 }
 
 // getKeyEntry 工具函数，用来从存储中检索出匹配的Entry
-func (h *HashMap) getKeyEntry(v model.Value) (*entry, bool) {
-	hash := v.Hash()
-	entries := h.store[hash]
-	for _, e := range entries {
+func (h *HashMap) getKeyEntry(v model.Value, hash uint64) (*entry, bool) {
+	for e := h.store[hash]; e != nil; e = e.next {
 		if model.ValueComparator(v, e.value) == 0 {
 			return e, true
 		}
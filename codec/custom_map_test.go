@@ -0,0 +1,114 @@
+package codec
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/beet233/compressotelcollector/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashMap(t *testing.T) {
+	m := NewHashMap()
+	a := model.AnyToValue(map[string]any{"k": "v", "n": int64(1), "list": []any{"x", nil, 2.5}})
+	// 内容相同、插入顺序不同的值是同一个 key
+	b := &model.ObjectValue{Data: map[string]model.Value{}}
+	b.Data["list"] = model.AnyToValue([]any{"x", nil, 2.5})
+	b.Data["n"] = &model.IntegerValue{Data: 1}
+	b.Data["k"] = &model.StringValue{Data: "v"}
+	assert.Equal(t, a.Hash(), b.Hash())
+
+	m.Put(a, m.Size())
+	index, ok := m.Get(b)
+	assert.True(t, ok)
+	assert.Equal(t, 0, index)
+
+	// 数组元素的顺序影响结果
+	c := model.AnyToValue(map[string]any{"k": "v", "n": int64(1), "list": []any{nil, "x", 2.5}})
+	_, ok = m.Get(c)
+	assert.False(t, ok)
+	m.Put(c, m.Size())
+	m.Put(b, 5)
+	assert.Equal(t, 2, m.Size())
+	index, _ = m.Get(c)
+	assert.Equal(t, 1, index)
+}
+
+func TestHashMapCollisions(t *testing.T) {
+	m := &HashMap{store: make(map[uint64]*entry)}
+	values := []model.Value{
+		&model.StringValue{Data: "a"},
+		&model.StringValue{Data: "b"},
+		&model.StringValue{Data: "c"},
+	}
+	// 人为让所有值落在同一个链表上
+	for i, v := range values {
+		m.store[0] = &entry{value: v, index: i, next: m.store[0]}
+		m.size++
+	}
+	for i, v := range values {
+		e, ok := m.getKeyEntry(v, 0)
+		assert.True(t, ok)
+		assert.Equal(t, i, e.index)
+	}
+	_, ok := m.getKeyEntry(&model.StringValue{Data: "d"}, 0)
+	assert.False(t, ok)
+}
+
+func TestHashConcurrent(t *testing.T) {
+	value := model.AnyToValue(map[string]any{"k": "v", "nested": map[string]any{"list": []any{int64(1), "x"}}})
+	expected := model.AnyToValue(map[string]any{"k": "v", "nested": map[string]any{"list": []any{int64(1), "x"}}}).Hash()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, expected, value.Hash())
+		}()
+	}
+	wg.Wait()
+}
+
+// newPoolValues 构造 n 个 attributes 风格的对象，其中一半和之前的值重复
+func newPoolValues(n int) []model.Value {
+	values := make([]model.Value, n)
+	for i := range values {
+		id := i % (n / 2)
+		values[i] = model.AnyToValue(map[string]any{
+			"service.name":   fmt.Sprintf("service-%d", id%10),
+			"host.name":      fmt.Sprintf("host-%d", id),
+			"process.pid":    int64(id),
+			"telemetry.sdk":  map[string]any{"name": "opentelemetry", "language": "go", "version": "1.21.0"},
+			"process.args":   []any{"/bin/app", "--id", int64(id)},
+			"container.id":   []byte{byte(id), byte(id >> 8), 3, 4},
+			"sampling.ratio": 0.25,
+		})
+	}
+	return values
+}
+
+func BenchmarkHashMapPool(b *testing.B) {
+	values := newPoolValues(2000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m := NewHashMap()
+		for _, v := range values {
+			if _, ok := m.Get(v); !ok {
+				m.Put(v, m.Size())
+			}
+		}
+	}
+}
+
+func BenchmarkHashObject(b *testing.B) {
+	values := newPoolValues(2000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, v := range values {
+			v.Hash()
+		}
+	}
+}
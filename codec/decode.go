@@ -0,0 +1,635 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"github.com/beet233/compressotelcollector/model"
+	"io"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+// DecodeStats 是一次 Decode 的统计信息，解码失败时只包含已经解析到的部分
+type DecodeStats struct {
+	// CompressedSize 是 payload 的字节数
+	CompressedSize int
+	// DecodedSize 是解码后数据的估算字节数，计算方式与 DecodeLimits.MaxDecodedSize 相同
+	DecodedSize    int
+	StringPoolSize int
+	// ValuePoolSizes 是每个 valuePool 的条目数，key 为 field 路径或 SharePoolId
+	ValuePoolSizes map[string]int
+	// SchemaBytes、StringPoolBytes、ValuePoolBytes 和 DataBytes 是 payload 中各部分的字节数，此外只有 valuePools 的个数和 magic。
+	// SchemaBytes 是 schema 或 schema id 的字节数，只有 Version 大于 0 的 Definition 编码的 payload 才有，ValuePoolBytes 包含池子的名字和标记，不池化的池子也在其中
+	SchemaBytes     int
+	StringPoolBytes int
+	ValuePoolBytes  map[string]int
+	DataBytes       int
+}
+
+func newDecodeStats() DecodeStats {
+	return DecodeStats{ValuePoolSizes: make(map[string]int), ValuePoolBytes: make(map[string]int)}
+}
+
+// Decoder 根据一个 Definition 把 cprval 解码为 Value，leb128、stringPool 设置需要和 Encoder 一致。
+// 创建之后只读，可以被多个 goroutine 同时使用
+type Decoder struct {
+	entry *model.Entry
+	def   *model.Definition
+	opts  options
+
+	// 每个带 Fields 的 Definition 按 FieldOrder 排好的 field 名，和 Encoder 的编码顺序一致
+	sortedKeys map[*model.Definition][]string
+	// Definition 与 OTLP 的 trace 结构一致时，DecodeTraces 直接写入 ptrace.Traces
+	directTraces bool
+	childNames   childNames
+	// generated 是 cprvalgen 为 def 生成的专用解码代码，没有或未开启时为 nil
+	generated *generatedCodec
+	// schema 和 entry.Fingerprint 用于判断 payload 是否由相同结构的 Definition 编码，writers 是按其他版本的 Definition 创建的 Decoder
+	schema  []byte
+	writers writerDecoders
+	// tree 是开启 WithParentReferences 时 parentSpanId 回引用的编码计划
+	tree *spanTree
+	// groups 是开启 WithTraceGrouping 时 span 按 traceId 分组的编码计划
+	groups *traceGroups
+}
+
+// NewDecoder 为没有注册到 model.Registry 的 Definition 创建 Decoder，def 需要已经检查过
+func NewDecoder(def *model.Definition, opts ...Option) *Decoder {
+	return newDecoder(definitionEntry(def), newOptions(opts))
+}
+
+// NewEntryDecoder 根据 Registry 中的 Entry 创建 Decoder
+func NewEntryDecoder(entry *model.Entry, opts ...Option) *Decoder {
+	return newDecoder(entry, newOptions(opts))
+}
+
+func newDecoder(entry *model.Entry, opts options) *Decoder {
+	def := entry.Definition
+	d := &Decoder{
+		entry:      entry,
+		def:        def,
+		opts:       opts,
+		sortedKeys: make(map[*model.Definition][]string),
+	}
+	planSortedKeys(def, d.sortedKeys)
+	d.childNames = make(childNames)
+	planChildNames(def, "", d.childNames)
+	d.directTraces = tracesSchema.supportsDirect(def)
+	d.tree = planSpanTree(def, d.opts)
+	d.groups = planTraceGroups(def, d.opts)
+	if d.tree == nil && d.groups == nil {
+		d.generated = lookupGenerated(entry.Fingerprint, d.opts)
+	}
+	d.schema = planSchema(def, d.opts)
+	return d
+}
+
+// Entry 返回 Decoder 使用的 Entry
+func (d *Decoder) Entry() *model.Entry {
+	return d.entry
+}
+
+// decodeState 是一次 Decode 过程中的可变状态，每次 Decode 单独创建
+type decodeState struct {
+	*Decoder
+	// 作为时间戳等状态的容器
+	status     map[string]any
+	stringPool []string
+	valuePools map[string][]model.Value
+	// unpooled 是 header 中标记为不池化的池子，其中的值在引用处直接内联编码
+	unpooled map[string]bool
+	reader   *DataReader
+	lim      *limiter
+	// spans 是 parentSpanId 回引用使用的已经解码的 span
+	spans decodedSpans
+	// traceGroup 是按 traceId 分组时当前分组的 traceId
+	traceGroup model.Value
+}
+
+// Decode 从 io.Reader 读取整个 payload 并解码
+func (d *Decoder) Decode(in io.Reader) (model.Value, DecodeStats, error) {
+	lim := newLimiter(d.opts.limits)
+	stats := newDecodeStats()
+	result, err := d.decode(in, lim, &stats)
+	stats.DecodedSize = lim.decodedSize
+	return result, stats, err
+}
+
+func (d *Decoder) decode(in io.Reader, lim *limiter, stats *DecodeStats) (model.Value, error) {
+	data, err := d.readPayload(in, lim, stats)
+	if err != nil {
+		return nil, err
+	}
+	w, data, err := d.forPayload(data, lim, stats)
+	if err != nil {
+		return nil, err
+	}
+	value, err := w.decodeData(data, lim, stats)
+	if err != nil {
+		return nil, err
+	}
+	return projectValue(value, w.def, d.def), nil
+}
+
+// decodeData 解码去掉 schema 之后的 payload
+func (d *Decoder) decodeData(data []byte, lim *limiter, stats *DecodeStats) (model.Value, error) {
+	s, err := d.decodeHeader(data, lim, stats)
+	if err != nil {
+		return nil, err
+	}
+	if d.generated != nil {
+		return d.generated.decode(newGenDecodeState(d.generated, s))
+	}
+	return s.innerDecode(d.def, "", true)
+}
+
+// readPayload 读取整个 payload 并检查大小
+func (d *Decoder) readPayload(in io.Reader, lim *limiter, stats *DecodeStats) ([]byte, error) {
+	if lim.limits.MaxBodySize > 0 {
+		// 多读一个 byte 用于判断是否超限
+		in = io.LimitReader(in, int64(lim.limits.MaxBodySize)+1)
+	}
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return nil, err
+	}
+	stats.CompressedSize = len(data)
+	return data, lim.checkBodySize(len(data))
+}
+
+// decodeHeader 解码 stringPool、valuePools 和 magic，返回停在数据部分开头的 decodeState
+func (d *Decoder) decodeHeader(data []byte, lim *limiter, stats *DecodeStats) (*decodeState, error) {
+	def := d.def
+	logger := d.opts.logger
+	reader := NewDataReader(data)
+	s := &decodeState{
+		Decoder:    d,
+		status:     make(map[string]any),
+		valuePools: make(map[string][]model.Value),
+		unpooled:   make(map[string]bool),
+		reader:     reader,
+		lim:        lim,
+		spans:      make(decodedSpans),
+	}
+	// decode stringPool
+	stringPoolSize, err := s.readInt()
+	if err != nil {
+		return nil, err
+	}
+	err = lim.checkPoolEntries("stringPool", stringPoolSize)
+	if err != nil {
+		return nil, err
+	}
+	// 每个 string 至少占用一个 byte 的长度前缀
+	if stringPoolSize > reader.Len() {
+		return nil, errors.New("not enough data for stringPool")
+	}
+	s.stringPool = make([]string, 0, stringPoolSize)
+	stats.StringPoolSize = stringPoolSize
+	for i := 0; i < stringPoolSize; i++ {
+		stringLen, err := s.readInt()
+		if err != nil {
+			return nil, err
+		}
+		string, err := reader.ReadString(stringLen)
+		if err != nil {
+			return nil, err
+		}
+		s.stringPool = append(s.stringPool, string)
+		// fmt.Println(string)
+	}
+	logger.Debug("Decoded stringPool", zap.Int("size", stringPoolSize))
+	stats.StringPoolBytes = len(data) - reader.Len()
+	// decode valuePools
+	valuePools := s.valuePools
+	valuePoolsCount, err := s.readInt()
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < valuePoolsCount; i++ {
+		poolStart := reader.Len()
+		fieldNameLen, err := s.readInt()
+		if err != nil {
+			return nil, err
+		}
+		fieldName, err := reader.ReadString(fieldNameLen)
+		if err != nil {
+			return nil, err
+		}
+		fieldDef := model.FieldStringToDefinition(fieldName, def)
+		if fieldDef == nil {
+			return nil, errors.New("unknown valuePool field: " + fieldName)
+		}
+		pooled, err := reader.ReadBoolean()
+		if err != nil {
+			return nil, err
+		}
+		if !pooled {
+			s.unpooled[fieldName] = true
+			stats.ValuePoolBytes[fieldName] = poolStart - reader.Len()
+			logger.Debug("valuePool is not pooled, values encoded inline", zap.String("field", fieldName))
+			continue
+		}
+		valuePools[fieldName] = []model.Value{}
+		lim.poolEntrySizes[fieldName] = []int{}
+		valuePoolSize, err := s.readInt()
+		if err != nil {
+			return nil, err
+		}
+		err = lim.checkPoolEntries(fieldName, valuePoolSize)
+		if err != nil {
+			return nil, err
+		}
+		stats.ValuePoolSizes[fieldName] = valuePoolSize
+		for j := 0; j < valuePoolSize; j++ {
+			// decode bytes to valuePools[fieldName]
+			decodedBefore := lim.decodedSize
+			value, err := s.innerDecode(fieldDef, fieldName, false)
+			if err != nil {
+				return nil, err
+			}
+			valuePools[fieldName] = append(valuePools[fieldName], value)
+			lim.poolEntrySizes[fieldName] = append(lim.poolEntrySizes[fieldName], lim.decodedSize-decodedBefore)
+		}
+		stats.ValuePoolBytes[fieldName] = poolStart - reader.Len()
+		logger.Debug("Decoded valuePool", zap.String("field", fieldName), zap.Int("size", valuePoolSize))
+	}
+	magic, err := reader.ReadString(6)
+	if err != nil {
+		return nil, err
+	}
+	if magic != "cprval" {
+		return nil, errors.New("magic error")
+	}
+	stats.DataBytes = reader.Len()
+	return s, nil
+}
+
+// readInt 按 leb128 设置读取整数，和 Encoder.encodeInt 对应
+func (s *decodeState) readInt() (int, error) {
+	if s.opts.leb128Enabled {
+		return s.reader.ReadLeb128Int()
+	}
+	return s.reader.ReadInt()
+}
+
+// readPoolReference 读取一个池索引并返回池中对应的值，遇到 inlineMarker 或池子不池化时按 def 解码内联值
+func (s *decodeState) readPoolReference(def *model.Definition, myName string) (model.Value, error) {
+	poolId := poolIdOf(def, myName)
+	if s.unpooled[poolId] {
+		return s.innerDecode(def, myName, false)
+	}
+	index, err := s.readInt()
+	if err != nil {
+		return nil, err
+	}
+	if index == inlineMarker {
+		return s.innerDecode(def, myName, false)
+	}
+	valuePool := s.valuePools[poolId]
+	if index < 0 || index >= len(valuePool) {
+		return nil, fmt.Errorf("index %d out of range of valuePool %q with %d entries", index, poolId, len(valuePool))
+	}
+	err = s.lim.addPoolReference(poolId, index)
+	if err != nil {
+		return nil, err
+	}
+	return valuePool[index], nil
+}
+
+// readStringPoolReference 读取一个 stringPool 索引并返回对应的 string
+func (s *decodeState) readStringPoolReference() (string, error) {
+	stringPool := s.stringPool
+	index, err := s.readInt()
+	if err != nil {
+		return "", err
+	}
+	if index < 0 || index >= len(stringPool) {
+		return "", fmt.Errorf("index %d out of range of stringPool with %d entries", index, len(stringPool))
+	}
+	err = s.lim.addDecoded(len(stringPool[index]))
+	if err != nil {
+		return "", err
+	}
+	return stringPool[index], nil
+}
+
+// usePool 标记本身是否可以使用 valuePools
+func (s *decodeState) innerDecode(def *model.Definition, myName string, usePool bool) (model.Value, error) {
+	reader := s.reader
+	lim := s.lim
+	var result model.Value
+	// 池子里的是不带 null 标记的
+	if def.Nullable && usePool {
+		exist, err := reader.ReadBoolean()
+		if err != nil {
+			return nil, err
+		}
+		if !exist {
+			// fmt.Println("get null")
+			// fmt.Println(string(reader.data))
+			return nil, nil
+		}
+	}
+	// Integer、Boolean、Double 不会入池
+	if (def.Pooled || def.SharePooled) && usePool && def.Type != model.Integer && def.Type != model.Boolean && def.Type != model.Double {
+		return s.readPoolReference(def, myName)
+	}
+	switch def.Type {
+	case model.Integer:
+		intv, err := s.readInt()
+		if err != nil {
+			return nil, err
+		}
+		// 包含差分编码的元素及其父元素不应该入池，否则解码起来搞不清楚顺序
+		if def.DiffEncode {
+			if prev, exist := s.status[myName]; exist {
+				intv = intv + prev.(int)
+			}
+			s.status[myName] = intv
+		}
+		// fmt.Println("intv:", intv)
+		result = &model.IntegerValue{Data: intv}
+		err = lim.addDecoded(8)
+		if err != nil {
+			return nil, err
+		}
+	case model.Boolean:
+		boolv, err := reader.ReadBoolean()
+		if err != nil {
+			return nil, err
+		}
+		// fmt.Println("boolv:", boolv)
+		result = &model.BooleanValue{Data: boolv}
+		err = lim.addDecoded(1)
+		if err != nil {
+			return nil, err
+		}
+	case model.Double:
+		dbv, err := reader.ReadFloat()
+		if err != nil {
+			return nil, err
+		}
+		result = &model.DoubleValue{Data: dbv}
+		err = lim.addDecoded(8)
+		if err != nil {
+			return nil, err
+		}
+	case model.Bytes:
+		len, err := s.readBytesLength(def)
+		if err != nil {
+			return nil, err
+		}
+		bv, err := reader.ReadBytes(len)
+		if err != nil {
+			return nil, err
+		}
+		// fmt.Println("bv:", bv)
+		result = &model.BytesValue{Data: bv}
+		err = lim.addDecoded(len)
+		if err != nil {
+			return nil, err
+		}
+	case model.String:
+		len, err := s.readInt()
+		if err != nil {
+			return nil, err
+		}
+		strv, err := reader.ReadString(len)
+		if err != nil {
+			return nil, err
+		}
+		// fmt.Println("strv:", strv)
+		result = &model.StringValue{Data: strv}
+		err = lim.addDecoded(len)
+		if err != nil {
+			return nil, err
+		}
+	case model.Object:
+		err := lim.enter()
+		if err != nil {
+			return nil, err
+		}
+		if def.Fields == nil {
+			// 对 attributes 自由解码
+			objv, err := s.innerFreeMapDecode()
+			if err != nil {
+				return nil, err
+			}
+			// fmt.Println("objv(free):", objv)
+			result = &model.ObjectValue{Data: objv}
+		} else {
+			if len(myName) > 0 {
+				myName = myName + " "
+			}
+			objv := make(map[string]model.Value)
+			distance := 0
+			for _, fieldName := range s.sortedKeys[def] {
+				if s.groups.isTraceId(def, fieldName) {
+					objv[fieldName] = s.traceGroup
+					continue
+				}
+				if s.tree.isParent(def, fieldName) {
+					distance, err = s.readInt()
+					if err != nil {
+						return nil, err
+					}
+					if distance != 0 {
+						continue
+					}
+				}
+				fieldValue, err := s.innerDecode(def.Fields[fieldName], myName+fieldName, true)
+				if err != nil {
+					return nil, err
+				}
+				objv[fieldName] = fieldValue
+			}
+			if s.tree.isSpan(def) {
+				traceId := bytesOf(objv["traceId"])
+				if distance != 0 {
+					parentSpanId, err := s.spans.resolve(traceId, distance)
+					if err != nil {
+						return nil, err
+					}
+					objv["parentSpanId"] = &model.BytesValue{Data: parentSpanId}
+				}
+				s.spans.add(traceId, bytesOf(objv["spanId"]))
+			}
+			// fmt.Println("objv:", objv)
+			result = &model.ObjectValue{Data: objv}
+		}
+		lim.leave()
+	case model.Array:
+		err := lim.enter()
+		if err != nil {
+			return nil, err
+		}
+		length, err := s.readInt()
+		if err != nil {
+			return nil, err
+		}
+		err = lim.checkArrayLength(myName, length)
+		if err != nil {
+			return nil, err
+		}
+		if len(myName) > 0 {
+			myName = myName + " "
+		}
+		var arrv []model.Value
+		if s.groups.isSpans(def) {
+			arrv, err = s.decodeTraceGroups(def, myName+"item", length)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			for i := 0; i < length; i++ {
+				item, err := s.innerDecode(def.ItemDefinition, myName+"item", true)
+				if err != nil {
+					return nil, err
+				}
+				arrv = append(arrv, item)
+			}
+		}
+		// fmt.Println("arrv:", arrv)
+		result = &model.ArrayValue{Data: arrv}
+		lim.leave()
+	}
+	return result, nil
+}
+
+// readBytesLength 读取 bytes 的长度，FixedLength 的 bytes 没有写长度
+func (s *decodeState) readBytesLength(def *model.Definition) (int, error) {
+	if def.FixedLength > 0 {
+		return def.FixedLength, nil
+	}
+	return s.readInt()
+}
+
+func (s *decodeState) innerFreeMapDecode() (map[string]model.Value, error) {
+	reader := s.reader
+	lim := s.lim
+	result := make(map[string]model.Value)
+	freeMapSize, err := s.readInt()
+	if err != nil {
+		return nil, err
+	}
+	err = lim.checkArrayLength("free map", freeMapSize)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < freeMapSize; i++ {
+		key, err := s.readStringPoolReference()
+		if err != nil {
+			return nil, err
+		}
+		// 读取 null 标记位
+		exist, err := reader.ReadBoolean()
+		if err != nil {
+			return nil, err
+		}
+		if !exist {
+			result[key] = nil
+		} else {
+			value, err := s.innerFreeValueDecode()
+			if err != nil {
+				return nil, err
+			}
+			result[key] = value
+		}
+	}
+	return result, nil
+}
+
+func (s *decodeState) innerFreeValueDecode() (model.Value, error) {
+	reader := s.reader
+	lim := s.lim
+	valueTypeInt, err := s.readInt()
+	if err != nil {
+		return nil, err
+	}
+	valueType := model.ValueType(valueTypeInt)
+	switch valueType {
+	case model.Integer:
+		intv, err := s.readInt()
+		if err != nil {
+			return nil, err
+		}
+		return &model.IntegerValue{Data: intv}, lim.addDecoded(8)
+	case model.Boolean:
+		boolv, err := reader.ReadBoolean()
+		if err != nil {
+			return nil, err
+		}
+		return &model.BooleanValue{Data: boolv}, lim.addDecoded(1)
+	case model.Double:
+		dbv, err := reader.ReadFloat()
+		if err != nil {
+			return nil, err
+		}
+		return &model.DoubleValue{Data: dbv}, lim.addDecoded(8)
+	case model.Bytes:
+		len, err := s.readInt()
+		if err != nil {
+			return nil, err
+		}
+		bv, err := reader.ReadBytes(len)
+		if err != nil {
+			return nil, err
+		}
+		return &model.BytesValue{Data: bv}, lim.addDecoded(len)
+	case model.String:
+		if !s.opts.stringPoolEnabled {
+			len, err := s.readInt()
+			if err != nil {
+				return nil, err
+			}
+			strv, err := reader.ReadString(len)
+			if err != nil {
+				return nil, err
+			}
+			return &model.StringValue{Data: strv}, lim.addDecoded(len)
+		}
+		strv, err := s.readStringPoolReference()
+		if err != nil {
+			return nil, err
+		}
+		return &model.StringValue{Data: strv}, nil
+	case model.Object:
+		err := lim.enter()
+		if err != nil {
+			return nil, err
+		}
+		objv, err := s.innerFreeMapDecode()
+		if err != nil {
+			return nil, err
+		}
+		lim.leave()
+		return &model.ObjectValue{Data: objv}, nil
+	case model.Array:
+		err := lim.enter()
+		if err != nil {
+			return nil, err
+		}
+		var arrv []model.Value
+		len, err := s.readInt()
+		if err != nil {
+			return nil, err
+		}
+		err = lim.checkArrayLength("free array", len)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < len; i++ {
+			value, err := s.innerFreeValueDecode()
+			if err != nil {
+				return nil, err
+			}
+			arrv = append(arrv, value)
+		}
+		lim.leave()
+		return &model.ArrayValue{Data: arrv}, nil
+	default:
+		return nil, errors.New("unknown value type in free value: " + strconv.Itoa(valueTypeInt))
+	}
+}
@@ -0,0 +1,160 @@
+package codec
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/beet233/compressotelcollector/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// appendLeb128 只处理非负数，足够用来手工构造测试 payload
+func appendLeb128(buf []byte, val int) []byte {
+	for {
+		b := byte(val & 0x7F)
+		val >>= 7
+		if val == 0 && b&0x40 == 0 {
+			return append(buf, b)
+		}
+		buf = append(buf, b|0x80)
+	}
+}
+
+// pooledStringsPayload 构造一个只有一个 string 池条目、数据部分引用该条目 refs 次的 payload
+func pooledStringsPayload(str string, refs int) []byte {
+	var buf []byte
+	buf = appendLeb128(buf, 0) // stringPool
+	buf = appendLeb128(buf, 1) // valuePools count
+	buf = appendLeb128(buf, len("item"))
+	buf = append(buf, "item"...)
+	buf = append(buf, 1) // pooled
+	buf = appendLeb128(buf, 1)
+	buf = appendLeb128(buf, len(str))
+	buf = append(buf, str...)
+	buf = append(buf, "cprval"...)
+	buf = appendLeb128(buf, refs)
+	for i := 0; i < refs; i++ {
+		buf = appendLeb128(buf, 0)
+	}
+	return buf
+}
+
+func TestDecodeLimits(t *testing.T) {
+	intArrayDef := &model.Definition{Type: model.Array, ItemDefinition: &model.Definition{Type: model.Integer}}
+	pooledStringArrayDef := &model.Definition{Type: model.Array, ItemDefinition: &model.Definition{Type: model.String, Pooled: true}}
+	freeMapDef := &model.Definition{Type: model.Object}
+
+	var nested []byte
+	nested = appendLeb128(nested, 1) // stringPool
+	nested = appendLeb128(nested, 1)
+	nested = append(nested, 'k')
+	nested = appendLeb128(nested, 0) // valuePools count
+	nested = append(nested, "cprval"...)
+	for i := 0; i < 100; i++ {
+		// map size 1, key 0, 非 null, 类型 Object
+		nested = append(nested, 1, 0, 1, byte(model.Object))
+	}
+	nested = append(nested, 0)
+
+	var hugeArray []byte
+	hugeArray = appendLeb128(hugeArray, 0)
+	hugeArray = appendLeb128(hugeArray, 0)
+	hugeArray = append(hugeArray, "cprval"...)
+	hugeArray = appendLeb128(hugeArray, 1<<30)
+
+	tests := []struct {
+		name    string
+		def     *model.Definition
+		payload []byte
+		limits  DecodeLimits
+		wantErr error
+	}{
+		{
+			name:    "body too large",
+			def:     intArrayDef,
+			payload: hugeArray,
+			limits:  DecodeLimits{MaxBodySize: 4},
+			wantErr: ErrPayloadTooLarge,
+		},
+		{
+			name:    "string pool too large",
+			def:     intArrayDef,
+			payload: appendLeb128(nil, 1000),
+			limits:  DecodeLimits{MaxPoolEntries: 10},
+			wantErr: ErrLimitExceeded,
+		},
+		{
+			name:    "array too long",
+			def:     intArrayDef,
+			payload: hugeArray,
+			limits:  DecodeLimits{MaxArrayLength: 1000},
+			wantErr: ErrLimitExceeded,
+		},
+		{
+			name:    "nesting too deep",
+			def:     freeMapDef,
+			payload: nested,
+			limits:  DecodeLimits{MaxDepth: 32},
+			wantErr: ErrLimitExceeded,
+		},
+		{
+			name:    "pool references expand too much",
+			def:     pooledStringArrayDef,
+			payload: pooledStringsPayload(strings.Repeat("x", 100), 50),
+			limits:  DecodeLimits{MaxDecodedSize: 1000},
+			wantErr: ErrPayloadTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := NewDecoder(tt.def, WithLimits(tt.limits)).Decode(bytes.NewReader(tt.payload))
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestDecodeWithinLimits(t *testing.T) {
+	def := &model.Definition{Type: model.Array, ItemDefinition: &model.Definition{Type: model.String, Pooled: true}}
+	value, stats, err := NewDecoder(def).Decode(bytes.NewReader(pooledStringsPayload("abc", 50)))
+	require.NoError(t, err)
+	arrv := value.(*model.ArrayValue).Data
+	require.Len(t, arrv, 50)
+	assert.Equal(t, "abc", arrv[49].(*model.StringValue).Data)
+	assert.Equal(t, map[string]int{"item": 1}, stats.ValuePoolSizes)
+	assert.Equal(t, 3+50*3, stats.DecodedSize)
+}
+
+func TestDecodePoolIndexOutOfRange(t *testing.T) {
+	def := &model.Definition{Type: model.Array, ItemDefinition: &model.Definition{Type: model.String, Pooled: true}}
+	payload := pooledStringsPayload("abc", 1)
+	// 把唯一的引用改成不存在的索引
+	payload[len(payload)-1] = 5
+	_, _, err := NewDecoder(def).Decode(bytes.NewReader(payload))
+	assert.Error(t, err)
+}
+
+func TestDecodeStatsSections(t *testing.T) {
+	def, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	encodeStats, err := NewEncoder(def).EncodeTraces(newRichTraces(30), &buf)
+	require.NoError(t, err)
+	_, stats, err := NewDecoder(def).Decode(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+
+	// 各部分加上 valuePools 的个数和 magic 正好是整个 payload，trace 模型有 Version，包括 schema id
+	assert.Greater(t, stats.SchemaBytes, 0)
+	total := stats.SchemaBytes + stats.StringPoolBytes + 1 + len("cprval") + stats.DataBytes
+	for _, size := range stats.ValuePoolBytes {
+		total += size
+	}
+	assert.Equal(t, stats.CompressedSize, total)
+	assert.Len(t, stats.ValuePoolBytes, len(stats.ValuePoolSizes)+len(encodeStats.UnpooledPools))
+	for _, poolId := range encodeStats.UnpooledPools {
+		// 不池化的池子只有名字和标记
+		assert.Equal(t, 1+len(poolId)+1, stats.ValuePoolBytes[poolId], poolId)
+	}
+}
@@ -0,0 +1,863 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"github.com/beet233/compressotelcollector/model"
+	"io"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+const (
+	initialCompressedBufferSize = 1024
+	typeConflictErrMsg          = "value & definition type conflict"
+	notNullableErrMsg           = "value is not nullable"
+	fixedLengthErrMsg           = "bytes length does not match FixedLength"
+)
+
+// Encoder 根据一个 Definition 把 Value 编码为 cprval。
+// 创建之后只读，可以被多个 goroutine 同时使用，每次 Encode 的池子等可变状态都在 encodeState 中
+type Encoder struct {
+	entry *model.Entry
+	def   *model.Definition
+	opts  options
+
+	// 预先计算好的编码计划：每个带 Fields 的 Definition 按 FieldOrder 排好的 field 名，以及 valuePools 的拓扑顺序
+	sortedKeys        map[*model.Definition][]string
+	topologicalFields []string
+	// poolLimits 是每个有上限的池子的最大条目数
+	poolLimits map[string]int
+	// directTraces 表示 def 和 OTLP 的结构一致，EncodeTraces 可以直接遍历 ptrace.Traces
+	directTraces bool
+	childNames   childNames
+	// generated 是 cprvalgen 为 def 生成的专用编码代码，没有或未开启时为 nil
+	generated *generatedCodec
+	// header 是 def 有 Version 时写在 payload 开头的 schema id 或 schema
+	header []byte
+	// tree 是开启 WithParentReferences 时 parentSpanId 回引用的编码计划
+	tree *spanTree
+	// groups 是开启 WithTraceGrouping 时 span 按 traceId 分组的编码计划
+	groups *traceGroups
+
+	// 用于存放 *bytes.Buffer 实例，编码池中的值时使用
+	bufferPool sync.Pool
+}
+
+// NewEncoder 为没有注册到 model.Registry 的 Definition 创建 Encoder，def 需要已经检查过
+func NewEncoder(def *model.Definition, opts ...Option) *Encoder {
+	return NewEntryEncoder(definitionEntry(def), opts...)
+}
+
+// NewEntryEncoder 根据 Registry 中的 Entry 创建 Encoder，使用 Entry 预先计算好的拓扑顺序和指纹
+func NewEntryEncoder(entry *model.Entry, opts ...Option) *Encoder {
+	o := newOptions(opts)
+	def := entry.Definition
+	e := &Encoder{
+		entry:             entry,
+		def:               def,
+		opts:              o,
+		sortedKeys:        make(map[*model.Definition][]string),
+		topologicalFields: entry.TopologicalFields,
+		poolLimits:        planPoolLimits(def, o.poolLimits),
+		directTraces:      tracesSchema.supportsDirect(def),
+		bufferPool: sync.Pool{
+			New: func() interface{} {
+				// 池中没有对象时，自动创建一个 Buffer 并返回。
+				return new(bytes.Buffer)
+			},
+		},
+	}
+	planSortedKeys(def, e.sortedKeys)
+	e.childNames = make(childNames)
+	planChildNames(def, "", e.childNames)
+	e.tree = planSpanTree(def, e.opts)
+	e.groups = planTraceGroups(def, e.opts)
+	if e.tree == nil && e.groups == nil {
+		// 生成的代码不支持 parentSpanId 回引用和按 traceId 分组
+		e.generated = lookupGenerated(entry.Fingerprint, e.opts)
+	}
+	e.header = planHeader(entry, e.opts)
+	return e
+}
+
+// Entry 返回 Encoder 使用的 Entry
+func (e *Encoder) Entry() *model.Entry {
+	return e.entry
+}
+
+// definitionEntry 为没有注册的 Definition 创建 Entry，不检查 Definition
+func definitionEntry(def *model.Definition) *model.Entry {
+	fingerprint, _ := model.Fingerprint(def)
+	return &model.Entry{
+		Version:           def.Version,
+		Definition:        def,
+		TopologicalFields: model.GetTopologicalFields(def),
+		Fingerprint:       fingerprint,
+	}
+}
+
+// encodeState 是一次 Encode 过程中的可变状态，每次 Encode 单独创建
+type encodeState struct {
+	// 作为时间戳等状态的容器
+	status           map[string]any
+	valuePools       map[string]*HashMap
+	valueEncodePools map[string]map[int]*bytes.Buffer
+	stringPool       map[string]int
+	// pooledBytes 是 ptrace 直接编码路径使用的池，以值编码后的 bytes 判断是否已经入池
+	pooledBytes map[string]map[string]int
+	// overflowLog 按顺序记录每次池满之后内联编码的 poolId
+	overflowLog []string
+	// unpooled 是本次编码中整体内联编码、不使用池子的 poolId，由自适应池化根据上一遍编码的统计决定
+	unpooled map[string]bool
+	// collectReferences 为 true 时在 references 中按顺序记录每次写入的池索引，用于自适应池化的统计
+	collectReferences bool
+	references        []poolReference
+	// marks 是 ptrace 直接编码路径中尚未确定是否丢弃的编码开始时 overflowLog 和 references 的记录数
+	marks []encodeMark
+	// spans 是 parentSpanId 回引用使用的已经编码的 span
+	spans encodedSpans
+}
+
+// EncodeStats 是一次 Encode 的统计信息，用于自观测
+type EncodeStats struct {
+	StringPoolSize int
+	// ValuePoolSizes 是每个 valuePool 的条目数，key 为 field 路径或 SharePoolId
+	ValuePoolSizes map[string]int
+	// PoolOverflows 是每个池子满了之后内联编码的值的个数，只包含发生过内联编码的池子
+	PoolOverflows map[string]int
+	// UnpooledPools 是自适应池化决定本次不池化、整体内联编码的池子，按 valuePools 的拓扑顺序排列
+	UnpooledPools []string
+}
+
+// Encode 将 Value 根据 Definition 进行编码，和字典一起编入 io.Writer
+func (e *Encoder) Encode(val model.Value, out io.Writer) (EncodeStats, error) {
+	return e.encodeAdaptive(func(state *encodeState, buf *bytes.Buffer) error {
+		if e.generated != nil {
+			g := newGenEncodeState(e, e.generated, state)
+			err := e.generated.encode(g, val, buf)
+			g.flush(e.generated)
+			return err
+		}
+		return e.innerEncode(val, e.def, "", state, buf)
+	}, out)
+}
+
+func (e *Encoder) newEncodeState() *encodeState {
+	return &encodeState{
+		status:           make(map[string]any),
+		valuePools:       make(map[string]*HashMap),
+		valueEncodePools: make(map[string]map[int]*bytes.Buffer),
+		stringPool:       make(map[string]int),
+		pooledBytes:      make(map[string]map[string]int),
+		spans:            make(encodedSpans),
+	}
+}
+
+// finish 把 header、stringPool 和 valuePools 编码在 dataBuffer 之前，一起写入 out
+// valuePools 的条目数以 valueEncodePools 为准，Value 和 ptrace 两条编码路径都会填充它
+func (e *Encoder) finish(state *encodeState, dataBuffer *bytes.Buffer, out io.Writer) (stats EncodeStats, err error) {
+	valueEncodePools := state.valueEncodePools
+	stringPool := state.stringPool
+	logger := e.opts.logger
+	stats.StringPoolSize = len(stringPool)
+	stats.ValuePoolSizes = make(map[string]int, len(valueEncodePools))
+	for poolId, encodePool := range valueEncodePools {
+		stats.ValuePoolSizes[poolId] = len(encodePool)
+		logger.Debug("Encoded valuePool", zap.String("field", poolId), zap.Int("size", len(encodePool)))
+	}
+	stats.PoolOverflows = make(map[string]int)
+	for _, poolId := range state.overflowLog {
+		stats.PoolOverflows[poolId]++
+	}
+	for poolId, overflows := range stats.PoolOverflows {
+		logger.Debug("valuePool is full, values encoded inline", zap.String("field", poolId), zap.Int("values", overflows))
+	}
+	logger.Debug("Encoded stringPool", zap.Int("size", len(stringPool)))
+	// 编码 valuePools 以及 stringPool 进 metaBuffer
+	metaBuffer := bytes.NewBuffer(make([]byte, 0, initialCompressedBufferSize))
+	metaBuffer.Write(e.header)
+	// 解析需要的是 index -> value，所以编码进去的应该是 reverse map
+	// 先编码 stringPool
+	strings := sortMapByValue(stringPool)
+	err = e.encodeInt(len(strings), metaBuffer)
+	if err != nil {
+		return stats, err
+	}
+	for i := 0; i < len(strings); i++ {
+		err = e.encodeInt(len(strings[i]), metaBuffer)
+		if err != nil {
+			return stats, err
+		}
+		_, err = metaBuffer.WriteString(strings[i])
+		if err != nil {
+			return stats, err
+		}
+	}
+
+	// 编码 valuePools 顺序，不池化的池子只有名字和标记
+	err = e.encodeInt(len(valueEncodePools)+len(state.unpooled), metaBuffer)
+	if err != nil {
+		return stats, err
+	}
+	for _, field := range e.topologicalFields {
+		encodePool, exist := valueEncodePools[field]
+		if state.unpooled[field] {
+			stats.UnpooledPools = append(stats.UnpooledPools, field)
+			logger.Debug("valuePool is not worth pooling, values encoded inline", zap.String("field", field))
+			err = e.encodeInt(len(field), metaBuffer)
+			if err != nil {
+				return stats, err
+			}
+			_, err = metaBuffer.WriteString(field)
+			if err != nil {
+				return stats, err
+			}
+			err = WriteBoolean(metaBuffer, false)
+			if err != nil {
+				return stats, err
+			}
+		} else if exist {
+			err = e.encodeInt(len(field), metaBuffer)
+			if err != nil {
+				return stats, err
+			}
+			_, err = metaBuffer.WriteString(field)
+			if err != nil {
+				return stats, err
+			}
+			err = WriteBoolean(metaBuffer, true)
+			if err != nil {
+				return stats, err
+			}
+			err = e.encodeInt(len(encodePool), metaBuffer)
+			if err != nil {
+				return stats, err
+			}
+			for i := 0; i < len(encodePool); i++ {
+				// 不需要 bytes 的 len，bytes 本身是可根据 def 解析的
+				_, err = metaBuffer.Write(encodePool[i].Bytes())
+				if err != nil {
+					return stats, err
+				}
+			}
+		}
+	}
+
+	_, err = out.Write(metaBuffer.Bytes())
+	if err != nil {
+		return
+	}
+	_, err = out.Write(dataBuffer.Bytes())
+	if err != nil {
+		return
+	}
+	return stats, nil
+}
+
+// releaseBuffers 把编码池中值时用到的 buffer 还给 bufferPool
+func (e *Encoder) releaseBuffers(state *encodeState) {
+	for _, encodePool := range state.valueEncodePools {
+		for _, buffer := range encodePool {
+			buffer.Reset()
+			e.bufferPool.Put(buffer)
+		}
+	}
+}
+
+// 传承层级 myName 作为 valuePools 的 key，如 "resourceSpans item resource attributes" 中间用一个空格
+func (e *Encoder) innerEncode(val model.Value, def *model.Definition, myName string, state *encodeState, buf *bytes.Buffer) (err error) {
+
+	if def.Nullable {
+		if val == nil || isNullValue(val) {
+			// 编个 bit 0，由于 golang 即使 boolean 也是用一整个 byte 的，所以只好如此
+			err := WriteBoolean(buf, false)
+			if err != nil {
+				return err
+			}
+			return nil
+		} else {
+			// 编个 bit 1
+			err := WriteBoolean(buf, true)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if val == nil {
+		return errors.New(notNullableErrMsg)
+	}
+	if val.GetType() != def.Type {
+		return errors.New(typeConflictErrMsg)
+	}
+	switch val.(type) {
+	case *model.IntegerValue:
+		intv := val.(*model.IntegerValue).Data
+		if def.DiffEncode {
+			if _, exist := state.status[myName]; !exist {
+				state.status[myName] = intv
+				err := e.encodeInt(intv, buf)
+				if err != nil {
+					return err
+				}
+			} else {
+				err := e.encodeInt(intv-state.status[myName].(int), buf)
+				if err != nil {
+					return err
+				}
+				state.status[myName] = intv
+			}
+		} else {
+			err := e.encodeInt(intv, buf)
+			if err != nil {
+				return err
+			}
+		}
+	case *model.BooleanValue:
+		err := WriteBoolean(buf, val.(*model.BooleanValue).Data)
+		if err != nil {
+			return err
+		}
+	case *model.DoubleValue:
+		err := WriteFloat(buf, val.(*model.DoubleValue).Data)
+		if err != nil {
+			return err
+		}
+	case *model.BytesValue:
+		if def.FixedLength > 0 && len(val.(*model.BytesValue).Data) != def.FixedLength {
+			return errors.New(fixedLengthErrMsg)
+		}
+
+		needEncode := false
+		inline := false
+
+		if def.Pooled || def.SharePooled {
+			poolId := myName
+			if def.SharePooled {
+				poolId = def.SharePoolId
+			}
+			if _, ok := state.valuePools[poolId]; !ok {
+				state.valuePools[poolId] = NewHashMap()
+			}
+			myPool := state.valuePools[poolId]
+			if _, ok := myPool.Get(val); !ok {
+				if e.poolFull(state, poolId, myPool.Size()) {
+					// 池子已满，不入池，内联编码
+					inline = true
+				} else {
+					myPool.Put(val, myPool.Size())
+				}
+				needEncode = true
+			}
+		} else {
+			needEncode = true
+		}
+
+		// 池中已有的值不需要编码，只在需要编码时取 tempBuffer，否则它既不入池也不会被还回 bufferPool
+		var tempBuffer *bytes.Buffer
+		if needEncode {
+			tempBuffer = e.bufferPool.Get().(*bytes.Buffer)
+			// FixedLength 的 bytes 不写长度
+			if def.FixedLength == 0 {
+				err := e.encodeInt(len(val.(*model.BytesValue).Data), tempBuffer)
+				if err != nil {
+					return err
+				}
+			}
+			_, err := tempBuffer.Write(val.(*model.BytesValue).Data)
+			if err != nil {
+				return err
+			}
+		}
+
+		if (def.Pooled || def.SharePooled) && !inline {
+			poolId := myName
+			if def.SharePooled {
+				poolId = def.SharePoolId
+			}
+			index, _ := state.valuePools[poolId].Get(val)
+			err := e.writeReference(state, poolId, index, buf)
+			if err != nil {
+				return err
+			}
+			// 存储 tempBuffer 的结果到 map
+			if needEncode {
+				if _, ok := state.valueEncodePools[poolId]; !ok {
+					state.valueEncodePools[poolId] = make(map[int]*bytes.Buffer)
+				}
+				state.valueEncodePools[poolId][index] = tempBuffer
+			}
+		} else {
+			if inline {
+				err := e.writeInline(state, poolIdOf(def, myName), buf)
+				if err != nil {
+					return err
+				}
+			}
+			_, err := buf.Write(tempBuffer.Bytes())
+			if err != nil {
+				return err
+			}
+			tempBuffer.Reset()
+			e.bufferPool.Put(tempBuffer)
+		}
+
+	case *model.StringValue:
+		// strv := val.(*model.StringValue).Data
+		// if MyConfig.StringPoolEnabled {
+		// 	if _, ok := stringPool[strv]; !ok {
+		// 		stringPool[strv] = len(stringPool)
+		// 	}
+		// 	err := e.encodeInt(stringPool[strv], buf)
+		// 	if err != nil {
+		// 		return err
+		// 	}
+		// } else {
+		// 	err := e.encodeInt(len(strv), buf)
+		// 	if err != nil {
+		// 		return err
+		// 	}
+		// 	_, err = buf.WriteString(strv)
+		// 	if err != nil {
+		// 		return err
+		// 	}
+		// }
+
+		needEncode := false
+		inline := false
+
+		if def.Pooled || def.SharePooled {
+			poolId := myName
+			if def.SharePooled {
+				poolId = def.SharePoolId
+			}
+			if _, ok := state.valuePools[poolId]; !ok {
+				state.valuePools[poolId] = NewHashMap()
+			}
+			myPool := state.valuePools[poolId]
+			if _, ok := myPool.Get(val); !ok {
+				if e.poolFull(state, poolId, myPool.Size()) {
+					// 池子已满，不入池，内联编码
+					inline = true
+				} else {
+					myPool.Put(val, myPool.Size())
+				}
+				needEncode = true
+			}
+		} else {
+			needEncode = true
+		}
+
+		var tempBuffer *bytes.Buffer
+		if needEncode {
+			tempBuffer = e.bufferPool.Get().(*bytes.Buffer)
+			err := e.encodeInt(len(val.(*model.StringValue).Data), tempBuffer)
+			if err != nil {
+				return err
+			}
+			_, err = tempBuffer.WriteString(val.(*model.StringValue).Data)
+			if err != nil {
+				return err
+			}
+		}
+
+		if (def.Pooled || def.SharePooled) && !inline {
+			poolId := myName
+			if def.SharePooled {
+				poolId = def.SharePoolId
+			}
+			index, _ := state.valuePools[poolId].Get(val)
+			err := e.writeReference(state, poolId, index, buf)
+			if err != nil {
+				return err
+			}
+			// 存储 tempBuffer 的结果到 map
+			if needEncode {
+				if _, ok := state.valueEncodePools[poolId]; !ok {
+					state.valueEncodePools[poolId] = make(map[int]*bytes.Buffer)
+				}
+				state.valueEncodePools[poolId][index] = tempBuffer
+			}
+		} else {
+			if inline {
+				err := e.writeInline(state, poolIdOf(def, myName), buf)
+				if err != nil {
+					return err
+				}
+			}
+			_, err := buf.Write(tempBuffer.Bytes())
+			if err != nil {
+				return err
+			}
+			tempBuffer.Reset()
+			e.bufferPool.Put(tempBuffer)
+		}
+	case *model.ObjectValue:
+
+		needEncode := false
+		inline := false
+		if def.Pooled || def.SharePooled {
+			poolId := myName
+			if def.SharePooled {
+				poolId = def.SharePoolId
+			}
+			if _, ok := state.valuePools[poolId]; !ok {
+				state.valuePools[poolId] = NewHashMap()
+			}
+			myPool := state.valuePools[poolId]
+			if _, ok := myPool.Get(val); !ok {
+				if e.poolFull(state, poolId, myPool.Size()) {
+					// 池子已满，不入池，内联编码
+					inline = true
+				} else {
+					myPool.Put(val, myPool.Size())
+				}
+				// 如果池化且第一次加入池子，则需要编码
+				needEncode = true
+			}
+		} else {
+			// 如果没池化则需要编码
+			needEncode = true
+		}
+
+		var tempBuffer *bytes.Buffer
+		if needEncode {
+			tempBuffer = e.bufferPool.Get().(*bytes.Buffer)
+			objv := val.(*model.ObjectValue).Data
+			// if len(myName) >= len("attributes") && myName[len(myName)-len("attributes"):] == "attributes" {
+			if def.Fields == nil {
+				err := e.innerFreeMapEncode(objv, state, tempBuffer)
+				if err != nil {
+					return err
+				}
+			} else {
+				if len(myName) > 0 {
+					myName = myName + " "
+				}
+				// 这里有问题，编码各个 field 的顺序是随机的....
+				// for fieldName, fieldDef := range def.Fields {
+				// 	innerVal := objv[fieldName]
+				// 	err := innerEncode(innerVal, fieldDef, myName+fieldName, valuePools, valueEncodePools, stringPool, tempBuffer)
+				// 	if err != nil {
+				// 		return err
+				// 	}
+				// }
+				// 改成按字典序吧
+				for _, fieldName := range e.sortedKeys[def] {
+					fieldDef := def.Fields[fieldName]
+					innerVal := objv[fieldName]
+					if e.groups.isTraceId(def, fieldName) {
+						continue
+					}
+					if e.tree.isParent(def, fieldName) {
+						distance := state.spans.distance(bytesOf(objv["traceId"]), bytesOf(innerVal))
+						err := e.encodeInt(distance, tempBuffer)
+						if err != nil {
+							return err
+						}
+						if distance > 0 {
+							continue
+						}
+					}
+					err := e.innerEncode(innerVal, fieldDef, myName+fieldName, state, tempBuffer)
+					if err != nil {
+						return err
+					}
+				}
+				if e.tree.isSpan(def) {
+					state.spans.add(bytesOf(objv["traceId"]), bytesOf(objv["spanId"]))
+				}
+
+				if len(myName) > 0 {
+					myName = myName[:len(myName)-1]
+				}
+			}
+		}
+
+		if (def.Pooled || def.SharePooled) && !inline {
+			poolId := myName
+			if def.SharePooled {
+				poolId = def.SharePoolId
+			}
+			index, _ := state.valuePools[poolId].Get(val)
+			err := e.writeReference(state, poolId, index, buf)
+			if err != nil {
+				return err
+			}
+			// 存储 tempBuffer 的结果到 map
+			if needEncode {
+				if _, ok := state.valueEncodePools[poolId]; !ok {
+					state.valueEncodePools[poolId] = make(map[int]*bytes.Buffer)
+				}
+				state.valueEncodePools[poolId][index] = tempBuffer
+			}
+		} else {
+			if inline {
+				err := e.writeInline(state, poolIdOf(def, myName), buf)
+				if err != nil {
+					return err
+				}
+			}
+			_, err := buf.Write(tempBuffer.Bytes())
+			if err != nil {
+				return err
+			}
+			tempBuffer.Reset()
+			e.bufferPool.Put(tempBuffer)
+		}
+	case *model.ArrayValue:
+
+		needEncode := false
+		inline := false
+		if def.Pooled || def.SharePooled {
+			poolId := myName
+			if def.SharePooled {
+				poolId = def.SharePoolId
+			}
+			if _, ok := state.valuePools[poolId]; !ok {
+				state.valuePools[poolId] = NewHashMap()
+			}
+			myPool := state.valuePools[poolId]
+			if _, ok := myPool.Get(val); !ok {
+				if e.poolFull(state, poolId, myPool.Size()) {
+					// 池子已满，不入池，内联编码
+					inline = true
+				} else {
+					myPool.Put(val, myPool.Size())
+				}
+				// 如果池化且第一次加入池子，则需要编码
+				needEncode = true
+			}
+		} else {
+			// 如果没池化则需要编码
+			needEncode = true
+		}
+
+		var tempBuffer *bytes.Buffer
+		if needEncode {
+			tempBuffer = e.bufferPool.Get().(*bytes.Buffer)
+			arrv := val.(*model.ArrayValue).Data
+			err := e.encodeInt(len(arrv), tempBuffer)
+			if err != nil {
+				return err
+			}
+			if len(myName) > 0 {
+				myName = myName + " "
+			}
+			if e.groups.isSpans(def) {
+				err := e.encodeTraceGroups(arrv, def, myName+"item", state, tempBuffer)
+				if err != nil {
+					return err
+				}
+			} else {
+				for _, item := range arrv {
+					err := e.innerEncode(item, def.ItemDefinition, myName+"item", state, tempBuffer)
+					if err != nil {
+						return err
+					}
+				}
+			}
+			if len(myName) > 0 {
+				myName = myName[:len(myName)-1]
+			}
+		}
+
+		if (def.Pooled || def.SharePooled) && !inline {
+			poolId := myName
+			if def.SharePooled {
+				poolId = def.SharePoolId
+			}
+			index, _ := state.valuePools[poolId].Get(val)
+			err := e.writeReference(state, poolId, index, buf)
+			if err != nil {
+				return err
+			}
+			// 存储 tempBuffer 的结果到 map
+			if needEncode {
+				if _, ok := state.valueEncodePools[poolId]; !ok {
+					state.valueEncodePools[poolId] = make(map[int]*bytes.Buffer)
+				}
+				state.valueEncodePools[poolId][index] = tempBuffer
+			}
+		} else {
+			if inline {
+				err := e.writeInline(state, poolIdOf(def, myName), buf)
+				if err != nil {
+					return err
+				}
+			}
+			_, err := buf.Write(tempBuffer.Bytes())
+			if err != nil {
+				return err
+			}
+			tempBuffer.Reset()
+			e.bufferPool.Put(tempBuffer)
+		}
+	}
+	return nil
+}
+
+// 将自由的 map （其实只有 attributes 及其内部）编码进 buf，过程中 string 同样需要处理入池
+func (e *Encoder) innerFreeMapEncode(freeMap map[string]model.Value, state *encodeState, buf *bytes.Buffer) error {
+	stringPool := &state.stringPool
+	// freeMap 需要有 size，而有 def 的不需要
+	err := e.encodeInt(len(freeMap), buf)
+	if err != nil {
+		return err
+	}
+	// 按 key 的字典序遍历，保证同样的数据总是编出同样的结果
+	for _, key := range getSortedValueKeys(freeMap) {
+		value := freeMap[key]
+		if _, exist := (*stringPool)[key]; !exist {
+			(*stringPool)[key] = len(*stringPool)
+		}
+		err := e.encodeInt((*stringPool)[key], buf)
+		if err != nil {
+			return err
+		}
+		// 一个是否为 null 的标记位
+		if value == nil {
+			err := WriteBoolean(buf, false)
+			if err != nil {
+				return err
+			}
+		} else {
+			err := WriteBoolean(buf, true)
+			if err != nil {
+				return err
+			}
+			err = e.encodeInt(int(value.GetType()), buf)
+			if err != nil {
+				return err
+			}
+			err = e.innerFreeValueEncode(value, state, buf)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (e *Encoder) innerFreeValueEncode(value model.Value, state *encodeState, buf *bytes.Buffer) error {
+	stringPool := &state.stringPool
+	switch value.(type) {
+	case *model.IntegerValue:
+		err := e.encodeInt(value.(*model.IntegerValue).Data, buf)
+		if err != nil {
+			return err
+		}
+	case *model.BooleanValue:
+		err := WriteBoolean(buf, value.(*model.BooleanValue).Data)
+		if err != nil {
+			return err
+		}
+	case *model.DoubleValue:
+		err := WriteFloat(buf, value.(*model.DoubleValue).Data)
+		if err != nil {
+			return err
+		}
+	case *model.BytesValue:
+		err := e.encodeInt(len(value.(*model.BytesValue).Data), buf)
+		if err != nil {
+			return err
+		}
+		_, err = buf.Write(value.(*model.BytesValue).Data)
+		if err != nil {
+			return err
+		}
+	case *model.StringValue:
+		strv := value.(*model.StringValue).Data
+		if e.opts.stringPoolEnabled {
+			if _, ok := (*stringPool)[strv]; !ok {
+				(*stringPool)[strv] = len(*stringPool)
+			}
+			err := e.encodeInt((*stringPool)[strv], buf)
+			if err != nil {
+				return err
+			}
+		} else {
+			err := e.encodeInt(len(strv), buf)
+			if err != nil {
+				return err
+			}
+			_, err = buf.WriteString(strv)
+			if err != nil {
+				return err
+			}
+		}
+	case *model.ObjectValue:
+		objv := value.(*model.ObjectValue).Data
+		err := e.innerFreeMapEncode(objv, state, buf)
+		if err != nil {
+			return err
+		}
+	case *model.ArrayValue:
+		arrv := value.(*model.ArrayValue).Data
+		err := e.encodeInt(len(arrv), buf)
+		if err != nil {
+			return err
+		}
+		// 编码数组内元素的类型
+		// if len(arrv) > 0 {
+		// 	err := e.encodeInt(int(arrv[0].GetType()), buf)
+		// 	if err != nil {
+		// 		return err
+		// 	}
+		// }
+		for i := 0; i < len(arrv); i++ {
+			err := e.encodeInt(int(arrv[i].GetType()), buf)
+			if err != nil {
+				return err
+			}
+			err = e.innerFreeValueEncode(arrv[i], state, buf)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// val can't be nil
+func isNullValue(val model.Value) bool {
+	switch val.(type) {
+	case *model.IntegerValue:
+		return val.(*model.IntegerValue).Data == 0
+	case *model.BytesValue:
+		return len(val.(*model.BytesValue).Data) == 0
+	case *model.StringValue:
+		return len(val.(*model.StringValue).Data) == 0
+	case *model.ObjectValue:
+		return len(val.(*model.ObjectValue).Data) == 0
+	case *model.ArrayValue:
+		return len(val.(*model.ArrayValue).Data) == 0
+	}
+	return false
+}
+
+// encodeInt 按 leb128 设置写入整数，和 decodeState.readInt 对应
+func (e *Encoder) encodeInt(val int, buf *bytes.Buffer) error {
+	if e.opts.leb128Enabled {
+		return WriteLeb128Int(buf, val)
+	}
+	return WriteInt(buf, val)
+}
+
+// 遍历 map 来重新构建一个 map[int]string，再从 0~size-1 取结果，复杂度只有 O(N)
+func sortMapByValue(inputMap map[string]int) []string {
+
+	var sortedKeys []string
+
+	tempMap := make(map[int]string)
+	for key, value := range inputMap {
+		tempMap[value] = key
+	}
+
+	for i := 0; i < len(tempMap); i++ {
+		sortedKeys = append(sortedKeys, tempMap[i])
+	}
+
+	return sortedKeys
+}
@@ -0,0 +1,157 @@
+package codec
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/beet233/compressotelcollector/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+// newLoadTestTraces 构造带有多个 resource、attributes 以及重复值的 traces，让各个池子都有内容
+func newLoadTestTraces(seed int) ptrace.Traces {
+	td := ptrace.NewTraces()
+	for r := 0; r < 3; r++ {
+		rs := td.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", fmt.Sprintf("service-%d", r))
+		rs.Resource().Attributes().PutInt("seed", int64(seed))
+		spans := rs.ScopeSpans().AppendEmpty().Spans()
+		for i := 0; i < 20; i++ {
+			span := spans.AppendEmpty()
+			span.SetTraceID([16]byte{byte(seed), byte(r), 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, byte(i)})
+			span.SetSpanID([8]byte{byte(seed), byte(r), 3, 4, 5, 6, 7, byte(i)})
+			span.SetName(fmt.Sprintf("span-%d", i%4))
+			span.Attributes().PutStr("http.method", "GET")
+			span.Attributes().PutInt("http.status_code", 200)
+			span.Attributes().PutBool("error", i%5 == 0)
+		}
+	}
+	return td
+}
+
+func TestEncoderDeterministic(t *testing.T) {
+	def, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(t, err)
+	encoder := NewEncoder(def, WithLeb128(true), WithStringPool(true))
+	value := TracesToValue(newLoadTestTraces(1))
+
+	var first bytes.Buffer
+	_, err = encoder.Encode(value, &first)
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		var again bytes.Buffer
+		_, err = encoder.Encode(value, &again)
+		require.NoError(t, err)
+		assert.Equal(t, first.Bytes(), again.Bytes())
+	}
+}
+
+// TestEncoderConcurrent 让多个 goroutine 共用一个 Encoder，结果必须和顺序编码的一致，配合 go test -race 使用
+func TestEncoderConcurrent(t *testing.T) {
+	def, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(t, err)
+	encoder := NewEncoder(def, WithLeb128(true), WithStringPool(true))
+
+	const inputs = 8
+	values := make([]model.Value, inputs)
+	expected := make([][]byte, inputs)
+	for i := range values {
+		values[i] = TracesToValue(newLoadTestTraces(i))
+		var buf bytes.Buffer
+		_, err = encoder.Encode(values[i], &buf)
+		require.NoError(t, err)
+		expected[i] = buf.Bytes()
+	}
+
+	const workers = 16
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				index := (w + i) % inputs
+				var buf bytes.Buffer
+				if _, err := encoder.Encode(values[index], &buf); err != nil {
+					errs <- err
+					return
+				}
+				if !bytes.Equal(expected[index], buf.Bytes()) {
+					errs <- fmt.Errorf("worker %d got different output for input %d", w, index)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+}
+
+func TestEntryCodec(t *testing.T) {
+	def, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(t, err)
+	registry := model.NewRegistry()
+	traces, err := registry.Register(model.Traces, def)
+	require.NoError(t, err)
+	logs, err := registry.Register(model.Logs, roundTripDefinition)
+	require.NoError(t, err)
+
+	encoder, decoder := NewEntryEncoder(traces), NewEntryDecoder(traces)
+	assert.Same(t, traces, encoder.Entry())
+	assert.Same(t, traces, decoder.Entry())
+	assert.NotNil(t, encoder.generated)
+	assert.NotNil(t, decoder.generated)
+	td := newRichTraces(10)
+	var buf bytes.Buffer
+	_, err = encoder.EncodeTraces(td, &buf)
+	require.NoError(t, err)
+	got, _, err := decoder.DecodeTraces(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, 0, model.ValueComparator(TracesToValue(td), TracesToValue(got)))
+
+	// 同一个进程中不同信号的 Entry 互不影响
+	assert.Nil(t, NewEntryEncoder(logs).generated)
+	assert.Equal(t, logs.TopologicalFields, NewEntryEncoder(logs).topologicalFields)
+	assert.Equal(t, NewEncoder(roundTripDefinition).Entry().Fingerprint, logs.Fingerprint)
+}
+
+// 池中已有的值不从 bufferPool 取 buffer，取出的 buffer 不会泄漏给 GC
+func TestRepeatedPooledValuesTakeNoBuffers(t *testing.T) {
+	def := &model.Definition{Type: model.Array, ItemDefinition: &model.Definition{Type: model.Object, Fields: map[string]*model.Definition{
+		"name":  {Type: model.String, Pooled: true},
+		"id":    {Type: model.Bytes, Pooled: true},
+		"tags":  {Type: model.Array, Pooled: true, ItemDefinition: &model.Definition{Type: model.String}},
+		"owner": {Type: model.Object, Pooled: true, Fields: map[string]*model.Definition{"team": {Type: model.String}}},
+	}}}
+	items := make([]any, 0)
+	for i := 0; i < 1000; i++ {
+		items = append(items, map[string]any{
+			"name":  "checkout",
+			"id":    []byte{1, 2, 3},
+			"tags":  []any{"a", "b"},
+			"owner": map[string]any{"team": "payments"},
+		})
+	}
+	value := model.AnyToValue(items)
+
+	encoder := NewEncoder(def, WithAdaptivePooling(false))
+	created := 0
+	encoder.bufferPool.New = func() interface{} {
+		created++
+		return new(bytes.Buffer)
+	}
+	var buf bytes.Buffer
+	_, err := encoder.Encode(value, &buf)
+	require.NoError(t, err)
+	// 每个池子只有一个值，不论重复多少次都只需要几个 buffer
+	assert.Less(t, created, 20)
+}
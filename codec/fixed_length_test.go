@@ -0,0 +1,114 @@
+package codec
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/beet233/compressotelcollector/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fixedLengthDefinition 覆盖共享池、池化和内联三种 FixedLength 的 bytes
+func fixedLengthDefinition(fixed bool) *model.Definition {
+	length := func(n int) int {
+		if fixed {
+			return n
+		}
+		return 0
+	}
+	return &model.Definition{Type: model.Object, Fields: map[string]*model.Definition{
+		"items": {Type: model.Array, ItemDefinition: &model.Definition{Type: model.Object, Fields: map[string]*model.Definition{
+			"id":     {Type: model.Bytes, SharePooled: true, SharePoolId: "id", FixedLength: length(4)},
+			"parent": {Type: model.Bytes, Nullable: true, SharePooled: true, SharePoolId: "parent", FixedLength: length(4)},
+			"digest": {Type: model.Bytes, FixedLength: length(2)},
+		}}},
+	}}
+}
+
+func fixedLengthValue(parent []byte) model.Value {
+	var items []any
+	for i := 0; i < 10; i++ {
+		items = append(items, map[string]any{
+			"id":     []byte{0, 0, byte(i % 3), 1},
+			"parent": parent,
+			"digest": []byte{byte(i), 0xff},
+		})
+	}
+	return model.AnyToValue(map[string]any{"items": items})
+}
+
+func TestFixedLengthBytes(t *testing.T) {
+	value := fixedLengthValue([]byte{9, 9, 9, 9})
+	for _, opts := range [][]Option{nil, {WithLeb128(false)}} {
+		// 自适应池化可能让两者的池化方式不同，比较大小时关闭
+		sizeOpts := append([]Option{WithAdaptivePooling(false)}, opts...)
+		var fixed, variable bytes.Buffer
+		_, err := NewEncoder(fixedLengthDefinition(true), sizeOpts...).Encode(value, &fixed)
+		require.NoError(t, err)
+		_, err = NewEncoder(fixedLengthDefinition(false), sizeOpts...).Encode(value, &variable)
+		require.NoError(t, err)
+		// 3 个 id、1 个 parent 和 10 个 digest 都省去了长度
+		lengthSize := 1
+		if len(opts) > 0 {
+			lengthSize = 8
+		}
+		assert.Equal(t, variable.Len()-14*lengthSize, fixed.Len())
+
+		decoded, _, err := NewDecoder(fixedLengthDefinition(true), opts...).Decode(bytes.NewReader(fixed.Bytes()))
+		require.NoError(t, err)
+		assert.Equal(t, value.Hash(), decoded.Hash())
+
+		root, err := NewDecoder(fixedLengthDefinition(true), opts...).Inspect(bytes.NewReader(fixed.Bytes()))
+		require.NoError(t, err)
+		checkInspectNode(t, root)
+	}
+
+	// 空的 parent 是 null，不检查长度
+	var buf bytes.Buffer
+	_, err := NewEncoder(fixedLengthDefinition(true)).Encode(fixedLengthValue(nil), &buf)
+	require.NoError(t, err)
+}
+
+func TestFixedLengthMismatch(t *testing.T) {
+	encoder := NewEncoder(fixedLengthDefinition(true))
+	for name, value := range map[string]model.Value{
+		"pooled too short": fixedLengthValue([]byte{1, 2, 3}),
+		"inline too long": model.AnyToValue(map[string]any{"items": []any{
+			map[string]any{"id": []byte{1, 2, 3, 4}, "digest": []byte{1, 2, 3}},
+		}}),
+		"not nullable": model.AnyToValue(map[string]any{"items": []any{
+			map[string]any{"id": []byte{}, "digest": []byte{1, 2}},
+		}}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := encoder.Encode(value, &bytes.Buffer{})
+			assert.EqualError(t, err, fixedLengthErrMsg)
+		})
+	}
+}
+
+func TestFixedLengthTraces(t *testing.T) {
+	def, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(t, err)
+	assert.Equal(t, 16, def.Fields["resourceSpans"].ItemDefinition.Fields["scopeSpans"].ItemDefinition.Fields["spans"].ItemDefinition.Fields["traceId"].FixedLength)
+	td := newRichTraces(30)
+	for _, opts := range [][]Option{nil, {WithGenerated(false)}} {
+		var buf bytes.Buffer
+		_, err = NewEncoder(def, opts...).EncodeTraces(td, &buf)
+		require.NoError(t, err)
+		got, _, err := NewDecoder(def, opts...).DecodeTraces(bytes.NewReader(buf.Bytes()))
+		require.NoError(t, err)
+		assert.Equal(t, 0, model.ValueComparator(TracesToValue(td), TracesToValue(got)))
+	}
+
+	// FixedLength 和 OTLP 中 ID 的长度不一致时直接编码也会报错
+	wrong, err := model.GetDefinitionFromJSON(defaultTraceModelJSON(t))
+	require.NoError(t, err)
+	wrong.Fields["resourceSpans"].ItemDefinition.Fields["scopeSpans"].ItemDefinition.Fields["spans"].ItemDefinition.Fields["traceId"].FixedLength = 8
+	encoder := NewEncoder(wrong)
+	require.True(t, encoder.directTraces)
+	_, err = encoder.EncodeTraces(td, &bytes.Buffer{})
+	assert.EqualError(t, err, fixedLengthErrMsg)
+}
@@ -0,0 +1,183 @@
+package codec
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/beet233/compressotelcollector/model"
+)
+
+//go:generate go run ./cmd/cprvalgen -def ../model/trace.json -name trace -out trace_gen.go
+
+// generatedCodec 是 cprvalgen 为一个 Definition 生成的专用编解码函数。
+// 生成的代码中 field 顺序、池子编号和 myName 路径都在生成时确定，编码结果和通用实现逐 byte 一致
+type generatedCodec struct {
+	// fingerprint 是生成时 Definition 的 model.Fingerprint，只有结构完全相同的 Definition 才会使用生成的代码
+	fingerprint string
+	// poolIds 按编号列出所有 valuePool 的 id，生成的代码以编号访问池子
+	poolIds []string
+	// diffFields 是 DiffEncode 字段的个数，每个字段在 genEncodeState.diffs 中占一个位置
+	diffFields int
+	encode     func(g *genEncodeState, val model.Value, buf *bytes.Buffer) error
+	decode     func(g *genDecodeState) (model.Value, error)
+}
+
+var (
+	generatedCodecsLock sync.RWMutex
+	generatedCodecs     = make(map[string]*generatedCodec)
+)
+
+// registerGenerated 由生成代码的 init 调用
+func registerGenerated(c *generatedCodec) {
+	generatedCodecsLock.Lock()
+	defer generatedCodecsLock.Unlock()
+	generatedCodecs[c.fingerprint] = c
+}
+
+// lookupGenerated 返回指纹为 fingerprint 的 Definition 对应的生成代码，没有时返回 nil
+func lookupGenerated(fingerprint string, opts options) *generatedCodec {
+	if !opts.generatedEnabled || fingerprint == "" {
+		return nil
+	}
+	generatedCodecsLock.RLock()
+	defer generatedCodecsLock.RUnlock()
+	return generatedCodecs[fingerprint]
+}
+
+// genEncodeState 是生成的编码代码使用的状态，valuePools 以编号索引
+type genEncodeState struct {
+	*encodeState
+	e           *Encoder
+	c           *generatedCodec
+	pools       []*HashMap
+	encodePools [][]*bytes.Buffer
+	diffs       []int
+	diffExist   []bool
+}
+
+func newGenEncodeState(e *Encoder, c *generatedCodec, state *encodeState) *genEncodeState {
+	return &genEncodeState{
+		encodeState: state,
+		e:           e,
+		c:           c,
+		pools:       make([]*HashMap, len(c.poolIds)),
+		encodePools: make([][]*bytes.Buffer, len(c.poolIds)),
+		diffs:       make([]int, c.diffFields),
+		diffExist:   make([]bool, c.diffFields),
+	}
+}
+
+// poolIndex 返回 val 在编号为 pool 的池子中的索引，isNew 表示 val 第一次出现，需要编码
+// 池子已满或本次不池化时 val 不入池，返回 inlineMarker
+func (g *genEncodeState) poolIndex(pool int, val model.Value) (index int, isNew bool) {
+	myPool := g.pools[pool]
+	if myPool == nil {
+		myPool = NewHashMap()
+		g.pools[pool] = myPool
+	}
+	if index, ok := myPool.Get(val); ok {
+		return index, false
+	}
+	index = myPool.Size()
+	if g.e.poolFull(g.encodeState, g.c.poolIds[pool], index) {
+		return inlineMarker, true
+	}
+	myPool.Put(val, index)
+	return index, true
+}
+
+// endPooled 在新值编码进 tmp 之后调用，记录入池的编码结果并写入索引，未入池的值写入 inlineMarker 和 tmp
+func (g *genEncodeState) endPooled(pool int, index int, tmp *bytes.Buffer, buf *bytes.Buffer) error {
+	if index == inlineMarker {
+		err := g.e.writeInline(g.encodeState, g.c.poolIds[pool], buf)
+		if err == nil {
+			_, err = buf.Write(tmp.Bytes())
+		}
+		tmp.Reset()
+		g.e.bufferPool.Put(tmp)
+		return err
+	}
+	for len(g.encodePools[pool]) <= index {
+		g.encodePools[pool] = append(g.encodePools[pool], nil)
+	}
+	g.encodePools[pool][index] = tmp
+	return g.reference(pool, index, buf)
+}
+
+// reference 写入编号为 pool 的池子中的索引
+func (g *genEncodeState) reference(pool int, index int, buf *bytes.Buffer) error {
+	return g.e.writeReference(g.encodeState, g.c.poolIds[pool], index, buf)
+}
+
+// diff 返回 DiffEncode 字段需要写入的值，并记录当前值
+func (g *genEncodeState) diff(field int, intv int) int {
+	if !g.diffExist[field] {
+		g.diffExist[field] = true
+		g.diffs[field] = intv
+		return intv
+	}
+	delta := intv - g.diffs[field]
+	g.diffs[field] = intv
+	return delta
+}
+
+// flush 把编号索引的池子转为 encodeState.valueEncodePools，之后由 finish 统一写出
+func (g *genEncodeState) flush(c *generatedCodec) {
+	for pool, encodePool := range g.encodePools {
+		if len(encodePool) == 0 {
+			continue
+		}
+		m := make(map[int]*bytes.Buffer, len(encodePool))
+		for index, buffer := range encodePool {
+			m[index] = buffer
+		}
+		g.valueEncodePools[c.poolIds[pool]] = m
+	}
+}
+
+// genDecodeState 是生成的解码代码使用的状态，valuePools 以编号索引
+type genDecodeState struct {
+	*decodeState
+	pools          [][]model.Value
+	poolEntrySizes [][]int
+	unpooled       []bool
+}
+
+func newGenDecodeState(c *generatedCodec, s *decodeState) *genDecodeState {
+	g := &genDecodeState{
+		decodeState:    s,
+		pools:          make([][]model.Value, len(c.poolIds)),
+		poolEntrySizes: make([][]int, len(c.poolIds)),
+		unpooled:       make([]bool, len(c.poolIds)),
+	}
+	for pool, poolId := range c.poolIds {
+		g.unpooled[pool] = s.unpooled[poolId]
+		g.pools[pool] = s.valuePools[poolId]
+		g.poolEntrySizes[pool] = s.lim.poolEntrySizes[poolId]
+	}
+	return g
+}
+
+// readPooled 与 readPoolReference 对应，以编号访问池子，遇到 inlineMarker 或池子不池化时由 inline 解码内联值
+func (g *genDecodeState) readPooled(pool int, poolId string, inline func(g *genDecodeState) (model.Value, error)) (model.Value, error) {
+	if g.unpooled[pool] {
+		return inline(g)
+	}
+	index, err := g.readInt()
+	if err != nil {
+		return nil, err
+	}
+	if index == inlineMarker {
+		return inline(g)
+	}
+	valuePool := g.pools[pool]
+	if index < 0 || index >= len(valuePool) {
+		return nil, fmt.Errorf("index %d out of range of valuePool %q with %d entries", index, poolId, len(valuePool))
+	}
+	err = g.lim.addDecoded(g.poolEntrySizes[pool][index])
+	if err != nil {
+		return nil, err
+	}
+	return valuePool[index], nil
+}
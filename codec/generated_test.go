@@ -0,0 +1,163 @@
+package codec
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/beet233/compressotelcollector/codec/internal/gen"
+	"github.com/beet233/compressotelcollector/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestGeneratedUpToDate(t *testing.T) {
+	def, err := model.GetDefinitionFromFile("../model/trace.json")
+	require.NoError(t, err)
+	src, err := gen.Generate(def, "trace", "trace.json")
+	require.NoError(t, err)
+	current, err := os.ReadFile("trace_gen.go")
+	require.NoError(t, err)
+	assert.Equal(t, string(src), string(current), "trace_gen.go is stale, run go generate")
+}
+
+func TestGeneratedSelection(t *testing.T) {
+	def, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(t, err)
+	assert.NotNil(t, NewEncoder(def).generated)
+	assert.NotNil(t, NewDecoder(def).generated)
+	assert.Nil(t, NewEncoder(def, WithGenerated(false)).generated)
+	assert.Nil(t, NewDecoder(def, WithGenerated(false)).generated)
+
+	// 结构不同的 Definition 没有生成的代码
+	changed, err := model.GetDefinitionFromJSON(defaultTraceModelJSON(t))
+	require.NoError(t, err)
+	changed.Fields["resourceSpans"].ItemDefinition.Fields["schemaUrl"].Pooled = false
+	assert.Nil(t, NewEncoder(changed).generated)
+	assert.Nil(t, NewDecoder(changed).generated)
+	assert.Nil(t, NewEncoder(roundTripDefinition).generated)
+}
+
+func TestGeneratedMatchesGeneric(t *testing.T) {
+	def, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(t, err)
+	optionSets := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "default"},
+		{name: "fixed int", opts: []Option{WithLeb128(false)}},
+		{name: "no string pool", opts: []Option{WithStringPool(false)}},
+		{name: "pool limits", opts: []Option{WithPoolLimits(PoolLimits{Default: 3, Pools: map[string]int{"traceId": 1}})}},
+		{name: "adaptive pooling", opts: []Option{WithAdaptivePooling(true)}},
+	}
+	inputs := map[string]model.Value{
+		"empty":     TracesToValue(newRichTraces(0)),
+		"rich":      TracesToValue(newRichTraces(30)),
+		"load test": TracesToValue(newLoadTestTraces(3)),
+	}
+	for _, o := range optionSets {
+		generated := NewEncoder(def, o.opts...)
+		generic := NewEncoder(def, append(o.opts, WithGenerated(false))...)
+		generatedDecoder := NewDecoder(def, o.opts...)
+		genericDecoder := NewDecoder(def, append(o.opts, WithGenerated(false))...)
+		for inputName, value := range inputs {
+			t.Run(o.name+"/"+inputName, func(t *testing.T) {
+				var expected bytes.Buffer
+				expectedStats, err := generic.Encode(value, &expected)
+				require.NoError(t, err)
+				var got bytes.Buffer
+				gotStats, err := generated.Encode(value, &got)
+				require.NoError(t, err)
+				assert.Equal(t, expected.Bytes(), got.Bytes())
+				assert.Equal(t, expectedStats, gotStats)
+
+				expectedValue, expectedDecodeStats, err := genericDecoder.Decode(bytes.NewReader(expected.Bytes()))
+				require.NoError(t, err)
+				gotValue, gotDecodeStats, err := generatedDecoder.Decode(bytes.NewReader(expected.Bytes()))
+				require.NoError(t, err)
+				assert.Equal(t, 0, model.ValueComparator(expectedValue, gotValue))
+				assert.Equal(t, expectedDecodeStats, gotDecodeStats)
+			})
+		}
+	}
+}
+
+func TestGeneratedErrorsMatchGeneric(t *testing.T) {
+	def, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(t, err)
+	generated := NewEncoder(def)
+	generic := NewEncoder(def, WithGenerated(false))
+
+	invalid := map[string]model.Value{
+		"type conflict": model.AnyToValue(map[string]any{"resourceSpans": "not an array"}),
+		"not nullable":  model.AnyToValue(map[string]any{"resourceSpans": []any{map[string]any{"scopeSpans": []any{}}}}),
+		"fixed length":  TracesToValue(newRichTraces(3)),
+	}
+	spans := invalid["fixed length"].(*model.ObjectValue).Data["resourceSpans"].(*model.ArrayValue).Data[0].(*model.ObjectValue).Data["scopeSpans"].(*model.ArrayValue).Data[0].(*model.ObjectValue).Data["spans"]
+	spans.(*model.ArrayValue).Data[0].(*model.ObjectValue).Data["spanId"] = &model.BytesValue{Data: []byte{1, 2, 3}}
+	for name, value := range invalid {
+		t.Run(name, func(t *testing.T) {
+			_, expectedErr := generic.Encode(value, &bytes.Buffer{})
+			require.Error(t, expectedErr)
+			_, err := generated.Encode(value, &bytes.Buffer{})
+			assert.Equal(t, expectedErr, err)
+		})
+	}
+
+	var encoded bytes.Buffer
+	_, err = generic.Encode(TracesToValue(newRichTraces(10)), &encoded)
+	require.NoError(t, err)
+	for _, limits := range []DecodeLimits{{MaxDecodedSize: 1024}, {MaxDepth: 3}, {MaxArrayLength: 5}} {
+		_, expectedStats, expectedErr := NewDecoder(def, WithLimits(limits), WithGenerated(false)).Decode(bytes.NewReader(encoded.Bytes()))
+		require.Error(t, expectedErr)
+		_, gotStats, err := NewDecoder(def, WithLimits(limits)).Decode(bytes.NewReader(encoded.Bytes()))
+		assert.Equal(t, expectedErr, err)
+		assert.Equal(t, expectedStats, gotStats)
+	}
+	truncated := encoded.Bytes()[:encoded.Len()-5]
+	_, _, expectedErr := NewDecoder(def, WithGenerated(false)).Decode(bytes.NewReader(truncated))
+	require.Error(t, expectedErr)
+	_, _, err = NewDecoder(def).Decode(bytes.NewReader(truncated))
+	assert.Equal(t, expectedErr, err)
+}
+
+func benchmarkEncode(b *testing.B, opts ...Option) {
+	def, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(b, err)
+	encoder := NewEncoder(def, opts...)
+	value := TracesToValue(newRichTraces(200))
+	var buf bytes.Buffer
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		_, err := encoder.Encode(value, &buf)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkDecode(b *testing.B, opts ...Option) {
+	def, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(b, err)
+	var encoded bytes.Buffer
+	_, err = NewEncoder(def).EncodeTraces(newRichTraces(200), &encoded)
+	require.NoError(b, err)
+	decoder := NewDecoder(def, opts...)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _, err := decoder.Decode(bytes.NewReader(encoded.Bytes()))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeGeneric(b *testing.B)   { benchmarkEncode(b, WithGenerated(false)) }
+func BenchmarkEncodeGenerated(b *testing.B) { benchmarkEncode(b) }
+func BenchmarkDecodeGeneric(b *testing.B)   { benchmarkDecode(b, WithGenerated(false)) }
+func BenchmarkDecodeGenerated(b *testing.B) { benchmarkDecode(b) }
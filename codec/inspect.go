@@ -0,0 +1,730 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/beet233/compressotelcollector/model"
+)
+
+// InspectNode 的 Kind
+const (
+	InspectPayload = "payload"
+	// InspectSchema 是 Version 大于 0 的 Definition 写在 payload 开头的 Definition，Value 是它的 Version
+	InspectSchema = "schema"
+	// InspectSchemaId 是 payload 开头只有 Version 和指纹的 schema id，Value 是它的 Version
+	InspectSchemaId   = "schemaId"
+	InspectStringPool = "stringPool"
+	InspectValuePool  = "valuePool"
+	// InspectUnpooled 是 header 中标记为不池化的池子，只有名字
+	InspectUnpooled = "unpooled"
+	InspectMagic    = "magic"
+	InspectData     = "data"
+	// InspectTrailing 是数据部分解析完之后剩余的字节
+	InspectTrailing = "trailing"
+	InspectValue    = "value"
+	InspectNull     = "null"
+	// InspectReference 是一个池索引，Pool 和 Index 指向被引用的条目
+	InspectReference = "reference"
+	// InspectInline 是池化 field 上内联编码的值，池子已满或不池化，唯一的子节点是值本身
+	InspectInline = "inline"
+	// InspectSpanReference 是 WithParentReferences 时 parentSpanId 的回引用，Value 是往回数的 span 个数，
+	// 为 0 时父 span 不在同一批数据中，唯一的子节点是 parentSpanId 本身
+	InspectSpanReference = "spanReference"
+	// InspectTraceGroup 是 WithTraceGrouping 时 traceId 相同的一组 span，Value 是 span 个数，子节点依次是 traceId、位置和各个 span
+	InspectTraceGroup = "traceGroup"
+	// InspectSpanPositions 是 WithRestoreSpanOrder 时组内各个 span 在原来的 spans 中的位置，Value 是位置的列表
+	InspectSpanPositions = "spanPositions"
+)
+
+// InspectNode 是 payload 中解析出来的一段，Offset 和 Length 是它在 payload 中的字节范围
+type InspectNode struct {
+	Kind string `json:"kind"`
+	// Name 是 field 名、free map 的 key 或池中条目的下标
+	Name string `json:"name,omitempty"`
+	// Path 是 valuePool 的 field 路径或 SharePoolId
+	Path   string `json:"path,omitempty"`
+	Type   string `json:"type,omitempty"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+	// Value 是基本类型的值，Bytes 以 hex 表示；Array、free map 和池子是元素个数
+	Value any `json:"value,omitempty"`
+	// Delta 是差分编码的 Integer 实际写入的差值
+	Delta *int `json:"delta,omitempty"`
+	// Pool 和 Index 是引用的池子和下标，stringPool 中的 string 的 Pool 为 "stringPool"
+	Pool     string         `json:"pool,omitempty"`
+	Index    *int           `json:"index,omitempty"`
+	Children []*InspectNode `json:"children,omitempty"`
+}
+
+// Inspect 按 Definition 逐字节解析 payload，返回 header、各个池子和数据部分组成的树，用于排查无法解码的 payload。
+// 解析失败时返回已经解析出的部分和带有出错位置的 error
+func (d *Decoder) Inspect(in io.Reader) (*InspectNode, error) {
+	root := &InspectNode{Kind: InspectPayload}
+	lim := newLimiter(d.opts.limits)
+	// 和 Decode 一样最多读取 MaxBodySize+1 个 byte，超限时不会把整个 body 读入内存
+	data, err := d.readPayload(in, lim, &DecodeStats{})
+	root.Length = len(data)
+	if err != nil {
+		return root, err
+	}
+	i := &inspector{
+		Decoder:   d,
+		data:      data,
+		reader:    NewDataReader(data),
+		lim:       lim,
+		status:    make(map[string]int),
+		poolSizes: make(map[string]int),
+		unpooled:  make(map[string]bool),
+	}
+	err = i.inspectSchema(root)
+	if err == nil {
+		err = i.inspect(root)
+	}
+	if err != nil {
+		return root, fmt.Errorf("offset %d: %w", i.offset(), err)
+	}
+	return root, nil
+}
+
+type inspector struct {
+	*Decoder
+	data       []byte
+	reader     *DataReader
+	lim        *limiter
+	status     map[string]int
+	stringPool []string
+	poolSizes  map[string]int
+	unpooled   map[string]bool
+}
+
+// bytesLength 读取 bytes 的长度，FixedLength 的 bytes 没有写长度
+func (i *inspector) bytesLength(def *model.Definition) (int, error) {
+	if def.FixedLength > 0 {
+		return def.FixedLength, nil
+	}
+	return i.readInt()
+}
+
+func (i *inspector) offset() int {
+	return len(i.data) - i.reader.Len()
+}
+
+func (i *inspector) readInt() (int, error) {
+	if i.opts.leb128Enabled {
+		return i.reader.ReadLeb128Int()
+	}
+	return i.reader.ReadInt()
+}
+
+// begin 在 parent 下创建一个从当前位置开始的节点，解析完成或出错时由 end 确定长度
+func (i *inspector) begin(parent *InspectNode, kind string, name string) *InspectNode {
+	n := &InspectNode{Kind: kind, Name: name, Offset: i.offset()}
+	parent.Children = append(parent.Children, n)
+	return n
+}
+
+func (i *inspector) end(n *InspectNode) {
+	n.Length = i.offset() - n.Offset
+}
+
+func (i *inspector) inspect(root *InspectNode) error {
+	stringPool := i.begin(root, InspectStringPool, "")
+	err := i.inspectStringPool(stringPool)
+	i.end(stringPool)
+	if err != nil {
+		return err
+	}
+	count, err := i.readInt()
+	if err != nil {
+		return err
+	}
+	for p := 0; p < count; p++ {
+		pool := i.begin(root, InspectValuePool, "")
+		err := i.inspectValuePool(pool)
+		i.end(pool)
+		if err != nil {
+			return err
+		}
+	}
+	magic := i.begin(root, InspectMagic, "")
+	value, err := i.reader.ReadString(6)
+	i.end(magic)
+	if err != nil {
+		return err
+	}
+	magic.Value = value
+	if value != "cprval" {
+		return errors.New("magic error")
+	}
+	data := i.begin(root, InspectData, "")
+	err = i.inspectValue(data, i.def, "", "", true)
+	i.end(data)
+	if err != nil {
+		return err
+	}
+	if i.reader.Len() > 0 {
+		trailing := i.begin(root, InspectTrailing, "")
+		_, _ = i.reader.ReadBytes(i.reader.Len())
+		i.end(trailing)
+	}
+	return nil
+}
+
+// inspectSchema 解析 payload 开头的 schema 或 schema id，之后的部分按 writer 的 Definition 解析
+func (i *inspector) inspectSchema(root *InspectNode) error {
+	kind := InspectSchema
+	if bytes.HasPrefix(i.data, []byte(schemaIdMagic)) {
+		kind = InspectSchemaId
+	} else if !bytes.HasPrefix(i.data, []byte(schemaMagic)) {
+		return nil
+	}
+	w, version, rest, err := i.payloadWriter(i.data, i.lim)
+	if err != nil {
+		return err
+	}
+	n := i.begin(root, kind, "")
+	_, _ = i.reader.ReadBytes(len(i.data) - len(rest))
+	i.end(n)
+	n.Value = version
+	i.Decoder = w
+	return nil
+}
+
+func (i *inspector) inspectStringPool(n *InspectNode) error {
+	size, err := i.readInt()
+	if err != nil {
+		return err
+	}
+	n.Value = size
+	err = i.lim.checkPoolEntries(InspectStringPool, size)
+	if err != nil {
+		return err
+	}
+	for index := 0; index < size; index++ {
+		entry := i.begin(n, InspectValue, strconv.Itoa(index))
+		entry.Type = model.String.String()
+		length, err := i.readInt()
+		if err == nil {
+			var str string
+			str, err = i.reader.ReadString(length)
+			entry.Value = str
+			i.stringPool = append(i.stringPool, str)
+		}
+		i.end(entry)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *inspector) inspectValuePool(n *InspectNode) error {
+	length, err := i.readInt()
+	if err != nil {
+		return err
+	}
+	poolId, err := i.reader.ReadString(length)
+	if err != nil {
+		return err
+	}
+	n.Path = poolId
+	def := model.FieldStringToDefinition(poolId, i.def)
+	if def == nil {
+		return errors.New("unknown valuePool field: " + poolId)
+	}
+	n.Type = def.Type.String()
+	pooled, err := i.reader.ReadBoolean()
+	if err != nil {
+		return err
+	}
+	if !pooled {
+		n.Kind = InspectUnpooled
+		i.unpooled[poolId] = true
+		return nil
+	}
+	size, err := i.readInt()
+	if err != nil {
+		return err
+	}
+	n.Value = size
+	err = i.lim.checkPoolEntries(poolId, size)
+	if err != nil {
+		return err
+	}
+	for index := 0; index < size; index++ {
+		err := i.inspectValue(n, def, poolId, strconv.Itoa(index), false)
+		if err != nil {
+			return err
+		}
+		// 和 Decoder 一样，条目解析完之后才能被引用
+		i.poolSizes[poolId] = index + 1
+	}
+	return nil
+}
+
+// inspectValue 与 decodeState.innerDecode 对应，在 parent 下添加一个节点
+func (i *inspector) inspectValue(parent *InspectNode, def *model.Definition, myName string, name string, usePool bool) error {
+	n := i.begin(parent, InspectValue, name)
+	defer i.end(n)
+	n.Type = def.Type.String()
+	if def.Nullable && usePool {
+		exist, err := i.reader.ReadBoolean()
+		if err != nil {
+			return err
+		}
+		if !exist {
+			n.Kind = InspectNull
+			return nil
+		}
+	}
+	if (def.Pooled || def.SharePooled) && usePool && def.Type != model.Integer && def.Type != model.Boolean && def.Type != model.Double {
+		poolId := poolIdOf(def, myName)
+		n.Pool = poolId
+		if i.unpooled[poolId] {
+			n.Kind = InspectInline
+			return i.inspectValue(n, def, myName, "", false)
+		}
+		index, err := i.readInt()
+		if err != nil {
+			return err
+		}
+		if index == inlineMarker {
+			n.Kind = InspectInline
+			return i.inspectValue(n, def, myName, "", false)
+		}
+		n.Kind = InspectReference
+		n.Index = &index
+		if index < 0 || index >= i.poolSizes[poolId] {
+			return fmt.Errorf("index %d out of range of valuePool %q with %d entries", index, poolId, i.poolSizes[poolId])
+		}
+		return nil
+	}
+	switch def.Type {
+	case model.Integer:
+		intv, err := i.readInt()
+		if err != nil {
+			return err
+		}
+		if def.DiffEncode {
+			delta := intv
+			if prev, exist := i.status[myName]; exist {
+				intv = intv + prev
+			}
+			i.status[myName] = intv
+			n.Delta = &delta
+		}
+		n.Value = intv
+	case model.Boolean:
+		boolv, err := i.reader.ReadBoolean()
+		if err != nil {
+			return err
+		}
+		n.Value = boolv
+	case model.Double:
+		dbv, err := i.reader.ReadFloat()
+		if err != nil {
+			return err
+		}
+		n.Value = floatValue(dbv)
+	case model.Bytes:
+		length, err := i.bytesLength(def)
+		if err != nil {
+			return err
+		}
+		bv, err := i.reader.ReadBytes(length)
+		if err != nil {
+			return err
+		}
+		n.Value = hex.EncodeToString(bv)
+	case model.String:
+		length, err := i.readInt()
+		if err != nil {
+			return err
+		}
+		strv, err := i.reader.ReadString(length)
+		if err != nil {
+			return err
+		}
+		n.Value = strv
+	case model.Object:
+		err := i.lim.enter()
+		if err != nil {
+			return err
+		}
+		if def.Fields == nil {
+			err = i.inspectFreeMap(n)
+		} else {
+			for _, fieldName := range i.sortedKeys[def] {
+				if i.groups.isTraceId(def, fieldName) {
+					continue
+				}
+				if i.tree.isParent(def, fieldName) {
+					err = i.inspectSpanReference(n, def.Fields[fieldName], childName(myName, fieldName), fieldName)
+				} else {
+					err = i.inspectValue(n, def.Fields[fieldName], childName(myName, fieldName), fieldName, true)
+				}
+				if err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+		i.lim.leave()
+	case model.Array:
+		err := i.lim.enter()
+		if err != nil {
+			return err
+		}
+		length, err := i.readInt()
+		if err != nil {
+			return err
+		}
+		n.Value = length
+		err = i.lim.checkArrayLength(myName, length)
+		if err != nil {
+			return err
+		}
+		if i.groups.isSpans(def) {
+			err = i.inspectTraceGroups(n, def, childName(myName, "item"), length)
+			if err != nil {
+				return err
+			}
+		} else {
+			for index := 0; index < length; index++ {
+				err := i.inspectValue(n, def.ItemDefinition, childName(myName, "item"), strconv.Itoa(index), true)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		i.lim.leave()
+	}
+	return nil
+}
+
+// inspectSpanReference 在 parent 下添加 parentSpanId 的回引用节点
+func (i *inspector) inspectSpanReference(parent *InspectNode, def *model.Definition, myName string, name string) error {
+	n := i.begin(parent, InspectSpanReference, name)
+	defer i.end(n)
+	distance, err := i.readInt()
+	if err != nil {
+		return err
+	}
+	n.Value = distance
+	if distance < 0 {
+		return fmt.Errorf("negative parentSpanId reference %d", distance)
+	}
+	if distance == 0 {
+		return i.inspectValue(n, def, myName, "", true)
+	}
+	return nil
+}
+
+// inspectTraceGroups 与 decodeState.readTraceGroups 对应，在 spans 的节点 n 下添加各个分组，span 的名字是它在原来的 spans 中的位置
+func (i *inspector) inspectTraceGroups(n *InspectNode, def *model.Definition, itemName string, length int) error {
+	count, err := i.readInt()
+	if err != nil {
+		return err
+	}
+	err = checkGroupCount(count, length)
+	if err != nil {
+		return err
+	}
+	var restored *spanPositions
+	if i.groups.restoreOrder {
+		restored = newSpanPositions(length)
+	}
+	remaining := length
+	for g := 0; g < count; g++ {
+		size, err := i.inspectTraceGroup(n, def, itemName, strconv.Itoa(g), length-remaining, remaining, restored)
+		if err != nil {
+			return err
+		}
+		remaining -= size
+	}
+	if remaining != 0 {
+		return fmt.Errorf("trace groups cover %d of %d spans", length-remaining, length)
+	}
+	return nil
+}
+
+// inspectTraceGroup 添加一个分组的节点，返回组内 span 的个数，start 是分组后组内第一个 span 的下标
+func (i *inspector) inspectTraceGroup(parent *InspectNode, def *model.Definition, itemName string, name string, start int, remaining int, restored *spanPositions) (int, error) {
+	n := i.begin(parent, InspectTraceGroup, name)
+	defer i.end(n)
+	err := i.inspectValue(n, i.groups.traceIdDefinition(), childName(itemName, "traceId"), "traceId", true)
+	if err != nil {
+		return 0, err
+	}
+	size, err := i.readInt()
+	if err != nil {
+		return 0, err
+	}
+	n.Value = size
+	err = checkGroupSize(size, remaining)
+	if err != nil {
+		return 0, err
+	}
+	positions := make([]int, size)
+	if restored != nil {
+		p := i.begin(n, InspectSpanPositions, "")
+		positions, err = restored.read(i.readInt, size)
+		p.Value = positions
+		i.end(p)
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		for k := range positions {
+			positions[k] = start + k
+		}
+	}
+	for _, position := range positions {
+		err := i.inspectValue(n, def.ItemDefinition, itemName, strconv.Itoa(position), true)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return size, nil
+}
+
+// inspectFreeMap 与 decodeState.innerFreeMapDecode 对应，n 是 map 本身的节点
+func (i *inspector) inspectFreeMap(n *InspectNode) error {
+	size, err := i.readInt()
+	if err != nil {
+		return err
+	}
+	n.Value = size
+	err = i.lim.checkArrayLength("free map", size)
+	if err != nil {
+		return err
+	}
+	for index := 0; index < size; index++ {
+		entry := i.begin(n, InspectValue, "")
+		err := i.inspectFreeEntry(entry)
+		i.end(entry)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *inspector) inspectFreeEntry(entry *InspectNode) error {
+	key, _, err := i.readStringPoolReference()
+	if err != nil {
+		return err
+	}
+	entry.Name = key
+	exist, err := i.reader.ReadBoolean()
+	if err != nil {
+		return err
+	}
+	if !exist {
+		entry.Kind = InspectNull
+		return nil
+	}
+	return i.inspectFreeValue(entry)
+}
+
+// inspectFreeValue 与 decodeState.innerFreeValueDecode 对应，从类型标记开始解析 n 的值
+func (i *inspector) inspectFreeValue(n *InspectNode) error {
+	valueTypeInt, err := i.readInt()
+	if err != nil {
+		return err
+	}
+	valueType := model.ValueType(valueTypeInt)
+	n.Type = valueType.String()
+	switch valueType {
+	case model.Integer:
+		intv, err := i.readInt()
+		if err != nil {
+			return err
+		}
+		n.Value = intv
+	case model.Boolean:
+		boolv, err := i.reader.ReadBoolean()
+		if err != nil {
+			return err
+		}
+		n.Value = boolv
+	case model.Double:
+		dbv, err := i.reader.ReadFloat()
+		if err != nil {
+			return err
+		}
+		n.Value = floatValue(dbv)
+	case model.Bytes:
+		length, err := i.readInt()
+		if err != nil {
+			return err
+		}
+		bv, err := i.reader.ReadBytes(length)
+		if err != nil {
+			return err
+		}
+		n.Value = hex.EncodeToString(bv)
+	case model.String:
+		if !i.opts.stringPoolEnabled {
+			length, err := i.readInt()
+			if err != nil {
+				return err
+			}
+			strv, err := i.reader.ReadString(length)
+			if err != nil {
+				return err
+			}
+			n.Value = strv
+			return nil
+		}
+		strv, index, err := i.readStringPoolReference()
+		if err != nil {
+			return err
+		}
+		n.Value = strv
+		n.Pool = InspectStringPool
+		n.Index = &index
+	case model.Object:
+		err := i.lim.enter()
+		if err != nil {
+			return err
+		}
+		err = i.inspectFreeMap(n)
+		if err != nil {
+			return err
+		}
+		i.lim.leave()
+	case model.Array:
+		err := i.lim.enter()
+		if err != nil {
+			return err
+		}
+		length, err := i.readInt()
+		if err != nil {
+			return err
+		}
+		n.Value = length
+		err = i.lim.checkArrayLength("free array", length)
+		if err != nil {
+			return err
+		}
+		for index := 0; index < length; index++ {
+			item := i.begin(n, InspectValue, strconv.Itoa(index))
+			err := i.inspectFreeValue(item)
+			i.end(item)
+			if err != nil {
+				return err
+			}
+		}
+		i.lim.leave()
+	default:
+		return errors.New("unknown value type in free value: " + strconv.Itoa(valueTypeInt))
+	}
+	return nil
+}
+
+func (i *inspector) readStringPoolReference() (string, int, error) {
+	index, err := i.readInt()
+	if err != nil {
+		return "", 0, err
+	}
+	if index < 0 || index >= len(i.stringPool) {
+		return "", index, fmt.Errorf("index %d out of range of stringPool with %d entries", index, len(i.stringPool))
+	}
+	return i.stringPool[index], index, nil
+}
+
+// floatValue 让 NaN 和 Inf 也能输出为 JSON
+func floatValue(f float64) any {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return f
+}
+
+// WriteText 以缩进的树输出 n，每行开头是节点的 offset 和 length
+func (n *InspectNode) WriteText(w io.Writer) error {
+	return n.writeText(w, 0)
+}
+
+func (n *InspectNode) writeText(w io.Writer, depth int) error {
+	_, err := fmt.Fprintf(w, "%08d %6d  %s%s\n", n.Offset, n.Length, strings.Repeat("  ", depth), n.describe())
+	if err != nil {
+		return err
+	}
+	for _, child := range n.Children {
+		err := child.writeText(w, depth+1)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *InspectNode) describe() string {
+	var b strings.Builder
+	if n.Name != "" {
+		b.WriteString(n.Name)
+		b.WriteString(": ")
+	}
+	switch n.Kind {
+	case InspectStringPool:
+		fmt.Fprintf(&b, "stringPool (%v entries)", n.Value)
+	case InspectValuePool:
+		fmt.Fprintf(&b, "valuePool %q %s (%v entries)", n.Path, n.Type, n.Value)
+	case InspectUnpooled:
+		fmt.Fprintf(&b, "valuePool %q %s (not pooled)", n.Path, n.Type)
+	case InspectSchema:
+		fmt.Fprintf(&b, "schema version %v", n.Value)
+	case InspectSchemaId:
+		fmt.Fprintf(&b, "schema id version %v", n.Value)
+	case InspectMagic:
+		fmt.Fprintf(&b, "magic %q", n.Value)
+	case InspectNull:
+		b.WriteString("null")
+	case InspectReference:
+		fmt.Fprintf(&b, "%s -> valuePool %q [%d]", n.Type, n.Pool, *n.Index)
+	case InspectInline:
+		fmt.Fprintf(&b, "%s inline, valuePool %q", n.Type, n.Pool)
+	case InspectTraceGroup:
+		fmt.Fprintf(&b, "trace group (%v spans)", n.Value)
+	case InspectSpanPositions:
+		fmt.Fprintf(&b, "span positions %v", n.Value)
+	case InspectSpanReference:
+		if n.Value == 0 {
+			b.WriteString("span not in batch")
+		} else {
+			fmt.Fprintf(&b, "span %v back in trace", n.Value)
+		}
+	case InspectValue:
+		b.WriteString(n.Type)
+		switch {
+		case n.Type == model.Array.String():
+			fmt.Fprintf(&b, " (%v items)", n.Value)
+		case n.Type == model.Object.String() && n.Value != nil:
+			fmt.Fprintf(&b, " (%v keys)", n.Value)
+		case n.Type == model.String.String():
+			fmt.Fprintf(&b, " = %q", n.Value)
+		case n.Value != nil:
+			fmt.Fprintf(&b, " = %v", n.Value)
+		}
+		if n.Delta != nil {
+			fmt.Fprintf(&b, " (delta %d)", *n.Delta)
+		}
+		if n.Pool != "" {
+			fmt.Fprintf(&b, " (%s [%d])", n.Pool, *n.Index)
+		}
+	default:
+		b.WriteString(n.Kind)
+	}
+	return b.String()
+}
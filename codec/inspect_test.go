@@ -0,0 +1,155 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/beet233/compressotelcollector/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestInspectPooledStrings(t *testing.T) {
+	def := &model.Definition{Type: model.Array, ItemDefinition: &model.Definition{Type: model.String, Pooled: true}}
+	payload := pooledStringsPayload("abc", 2)
+	root, err := NewDecoder(def).Inspect(bytes.NewReader(payload))
+	require.NoError(t, err)
+	require.Len(t, root.Children, 4)
+	stringPool, pool, magic, data := root.Children[0], root.Children[1], root.Children[2], root.Children[3]
+
+	assert.Equal(t, InspectStringPool, stringPool.Kind)
+	assert.Equal(t, 0, stringPool.Offset)
+	assert.Equal(t, 1, stringPool.Length)
+
+	// valuePools 的个数占 1 个 byte
+	assert.Equal(t, InspectValuePool, pool.Kind)
+	assert.Equal(t, "item", pool.Path)
+	assert.Equal(t, 2, pool.Offset)
+	assert.Equal(t, 1, pool.Value)
+	require.Len(t, pool.Children, 1)
+	assert.Equal(t, "abc", pool.Children[0].Value)
+
+	assert.Equal(t, "cprval", magic.Value)
+	assert.Equal(t, len(payload)-3-6, magic.Offset)
+
+	require.Len(t, data.Children, 1)
+	array := data.Children[0]
+	assert.Equal(t, 2, array.Value)
+	require.Len(t, array.Children, 2)
+	for index, ref := range array.Children {
+		assert.Equal(t, InspectReference, ref.Kind)
+		assert.Equal(t, "item", ref.Pool)
+		assert.Equal(t, 0, *ref.Index)
+		assert.Equal(t, len(payload)-2+index, ref.Offset)
+		assert.Equal(t, 1, ref.Length)
+	}
+}
+
+func TestInspectCorrupted(t *testing.T) {
+	def := &model.Definition{Type: model.Array, ItemDefinition: &model.Definition{Type: model.String, Pooled: true}}
+
+	payload := pooledStringsPayload("abc", 2)
+	payload[len(payload)-1] = 5
+	root, err := NewDecoder(def).Inspect(bytes.NewReader(payload))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "out of range")
+	// 出错之前的部分仍然可以看到
+	refs := root.Children[3].Children[0].Children
+	require.Len(t, refs, 2)
+	assert.Equal(t, 0, *refs[0].Index)
+	assert.Equal(t, 5, *refs[1].Index)
+
+	payload = pooledStringsPayload("abc", 2)
+	copy(payload[len(payload)-9:], "cprvax")
+	root, err = NewDecoder(def).Inspect(bytes.NewReader(payload))
+	require.Error(t, err)
+	require.Len(t, root.Children, 3)
+	assert.Equal(t, "cprvax", root.Children[2].Value)
+}
+
+// countingReader 记录已经读出的 byte 数
+type countingReader struct {
+	in   io.Reader
+	read int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.in.Read(p)
+	r.read += n
+	return n, err
+}
+
+func TestInspectBodyTooLarge(t *testing.T) {
+	def := &model.Definition{Type: model.Array, ItemDefinition: &model.Definition{Type: model.String, Pooled: true}}
+	in := &countingReader{in: bytes.NewReader(make([]byte, 1<<20))}
+	_, err := NewDecoder(def, WithLimits(DecodeLimits{MaxBodySize: 16})).Inspect(in)
+	assert.ErrorIs(t, err, ErrPayloadTooLarge)
+	// 超限之后不再继续读取
+	assert.Equal(t, 17, in.read)
+}
+
+// checkInspectNode 检查每个节点的子节点按顺序排列且都在节点的范围之内
+func checkInspectNode(t *testing.T, n *InspectNode) {
+	next := n.Offset
+	for _, child := range n.Children {
+		assert.GreaterOrEqual(t, child.Offset, next, child.Kind)
+		next = child.Offset + child.Length
+		checkInspectNode(t, child)
+	}
+	assert.LessOrEqual(t, next, n.Offset+n.Length, n.Kind)
+}
+
+func TestInspectTraces(t *testing.T) {
+	def, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(t, err)
+	for _, tc := range []struct {
+		opts []Option
+		// valuePools 的个数占用的 byte 数
+		countSize int
+	}{
+		{nil, 1},
+		{[]Option{WithLeb128(false), WithStringPool(false)}, 8},
+		{[]Option{WithPoolLimits(PoolLimits{Default: 2})}, 1},
+	} {
+		opts := tc.opts
+		var buf bytes.Buffer
+		encodeStats, err := NewEncoder(def, opts...).EncodeTraces(newRichTraces(30), &buf)
+		require.NoError(t, err)
+		_, decodeStats, err := NewDecoder(def, opts...).Decode(bytes.NewReader(buf.Bytes()))
+		require.NoError(t, err)
+		root, err := NewDecoder(def, opts...).Inspect(bytes.NewReader(buf.Bytes()))
+		require.NoError(t, err)
+		checkInspectNode(t, root)
+
+		// 顶层的各部分加上 valuePools 的个数正好是整个 payload
+		total := 0
+		pools := make(map[string]int)
+		for _, child := range root.Children {
+			assert.NotEqual(t, InspectTrailing, child.Kind)
+			total += child.Length
+			switch child.Kind {
+			case InspectStringPool:
+				assert.Equal(t, decodeStats.StringPoolBytes, child.Length)
+			case InspectValuePool, InspectUnpooled:
+				pools[child.Path] = child.Length
+			case InspectData:
+				assert.Equal(t, decodeStats.DataBytes, child.Length)
+			}
+		}
+		assert.Equal(t, decodeStats.ValuePoolBytes, pools)
+		assert.Equal(t, len(buf.Bytes()), total+tc.countSize)
+
+		var text bytes.Buffer
+		require.NoError(t, root.WriteText(&text))
+		for _, poolId := range encodeStats.UnpooledPools {
+			assert.Contains(t, text.String(), "valuePool \""+poolId+"\"")
+		}
+		assert.True(t, strings.HasPrefix(text.String(), "00000000"))
+		_, err = json.Marshal(root)
+		assert.NoError(t, err)
+	}
+}
@@ -0,0 +1,347 @@
+// Package gen 根据 Definition 生成 codec 包内使用的专用编解码代码，由 cmd/cprvalgen 调用
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+	"unicode"
+
+	"github.com/beet233/compressotelcollector/model"
+)
+
+// Generate 为 def 生成 codec 包的 Go 源码，name 决定生成函数的前缀，source 只用于文件头的注释
+func Generate(def *model.Definition, name string, source string) ([]byte, error) {
+	fingerprint, err := model.Fingerprint(def)
+	if err != nil {
+		return nil, err
+	}
+	g := &generator{
+		prefix:    camel(name),
+		poolIndex: make(map[string]int),
+		diffIndex: make(map[string]int),
+		funcNames: make(map[string]bool),
+	}
+	g.planPools(def, "")
+	root := g.plan(def, "")
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by cprvalgen from %s. DO NOT EDIT.\n\n", source)
+	buf.WriteString("package codec\n\n")
+	buf.WriteString("import (\n\t\"bytes\"\n\t\"errors\"\n\n\t\"github.com/beet233/compressotelcollector/model\"\n)\n\n")
+	buf.WriteString("func init() {\n\tregisterGenerated(&generatedCodec{\n")
+	fmt.Fprintf(&buf, "\t\tfingerprint: %q,\n", fingerprint)
+	buf.WriteString("\t\tpoolIds: []string{\n")
+	for _, poolId := range g.poolIds {
+		fmt.Fprintf(&buf, "\t\t\t%q,\n", poolId)
+	}
+	buf.WriteString("\t\t},\n")
+	fmt.Fprintf(&buf, "\t\tdiffFields: %d,\n", len(g.diffIndex))
+	fmt.Fprintf(&buf, "\t\tencode: %s,\n", root.encodeName)
+	fmt.Fprintf(&buf, "\t\tdecode: %s,\n", root.decodeName)
+	buf.WriteString("\t})\n}\n")
+	for _, n := range g.nodes {
+		g.writeEncode(&buf, n)
+	}
+	for _, n := range g.nodes {
+		g.writeDecode(&buf, n)
+	}
+	return format.Source(buf.Bytes())
+}
+
+type generator struct {
+	prefix string
+	// poolIds 按编号排列，poolIndex 为 poolId 到编号的映射
+	poolIds   []string
+	poolIndex map[string]int
+	// diffIndex 为 DiffEncode 字段的 myName 到 genEncodeState.diffs 下标的映射
+	diffIndex map[string]int
+	funcNames map[string]bool
+	nodes     []*node
+}
+
+// node 是 Definition 树上的一个节点，每个节点生成一对编解码函数
+type node struct {
+	def        *model.Definition
+	myName     string
+	encodeName string
+	decodeName string
+	// fields 为 Object 按字典序排列的子节点，item 为 Array 的子节点
+	fieldNames []string
+	fields     []*node
+	item       *node
+}
+
+// planPools 按和 Encoder 相同的遍历顺序给池子编号
+func (g *generator) planPools(def *model.Definition, myName string) {
+	if def == nil {
+		return
+	}
+	if poolId, pooled := poolIdOf(def, myName); pooled {
+		if _, exist := g.poolIndex[poolId]; !exist {
+			g.poolIndex[poolId] = len(g.poolIds)
+			g.poolIds = append(g.poolIds, poolId)
+		}
+	}
+	switch def.Type {
+	case model.Object:
+		for _, fieldName := range def.FieldOrder() {
+			g.planPools(def.Fields[fieldName], childName(myName, fieldName))
+		}
+	case model.Array:
+		g.planPools(def.ItemDefinition, childName(myName, "item"))
+	}
+}
+
+func (g *generator) plan(def *model.Definition, myName string) *node {
+	base := g.funcName(myName)
+	n := &node{def: def, myName: myName, encodeName: "encode" + base, decodeName: "decode" + base}
+	g.nodes = append(g.nodes, n)
+	if def.Type == model.Integer && def.DiffEncode {
+		g.diffIndex[myName] = len(g.diffIndex)
+	}
+	switch def.Type {
+	case model.Object:
+		for _, fieldName := range def.FieldOrder() {
+			n.fieldNames = append(n.fieldNames, fieldName)
+			n.fields = append(n.fields, g.plan(def.Fields[fieldName], childName(myName, fieldName)))
+		}
+	case model.Array:
+		n.item = g.plan(def.ItemDefinition, childName(myName, "item"))
+	}
+	return n
+}
+
+// funcName 由 myName 得到不重复的函数名后缀，如 "resourceSpans item" 得到 TraceResourceSpansItem
+func (g *generator) funcName(myName string) string {
+	base := g.prefix
+	for _, word := range strings.Fields(myName) {
+		base += camel(word)
+	}
+	result := base
+	for i := 2; g.funcNames[result]; i++ {
+		result = fmt.Sprintf("%s%d", base, i)
+	}
+	g.funcNames[result] = true
+	return result
+}
+
+func (g *generator) writeEncode(buf *bytes.Buffer, n *node) {
+	def := n.def
+	fmt.Fprintf(buf, "\n// %s 编码%s\n", n.encodeName, describe(n.myName))
+	fmt.Fprintf(buf, "func %s(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {\n", n.encodeName)
+	if def.Nullable {
+		buf.WriteString("\tif val == nil || isNullValue(val) {\n\t\treturn WriteBoolean(buf, false)\n\t}\n")
+		buf.WriteString("\tif err := WriteBoolean(buf, true); err != nil {\n\t\treturn err\n\t}\n")
+	} else {
+		buf.WriteString("\tif val == nil {\n\t\treturn errors.New(notNullableErrMsg)\n\t}\n")
+	}
+	fmt.Fprintf(buf, "\tv, ok := val.(*model.%s)\n\tif !ok {\n\t\treturn errors.New(typeConflictErrMsg)\n\t}\n", valueTypeName(def.Type))
+	switch def.Type {
+	case model.Integer:
+		if diff, exist := g.diffIndex[n.myName]; exist {
+			fmt.Fprintf(buf, "\treturn g.e.encodeInt(g.diff(%d, v.Data), buf)\n}\n", diff)
+		} else {
+			buf.WriteString("\treturn g.e.encodeInt(v.Data, buf)\n}\n")
+		}
+		return
+	case model.Boolean:
+		buf.WriteString("\treturn WriteBoolean(buf, v.Data)\n}\n")
+		return
+	case model.Double:
+		buf.WriteString("\treturn WriteFloat(buf, v.Data)\n}\n")
+		return
+	}
+
+	if def.FixedLength > 0 {
+		fmt.Fprintf(buf, "\tif len(v.Data) != %d {\n\t\treturn errors.New(fixedLengthErrMsg)\n\t}\n", def.FixedLength)
+	}
+	poolId, pooled := poolIdOf(def, n.myName)
+	pool := g.poolIndex[poolId]
+	if pooled {
+		fmt.Fprintf(buf, "\tindex, isNew := g.poolIndex(%d, val)\n", pool)
+		fmt.Fprintf(buf, "\tif !isNew {\n\t\treturn g.reference(%d, index, buf)\n\t}\n", pool)
+		buf.WriteString("\tout := g.e.bufferPool.Get().(*bytes.Buffer)\n")
+	} else {
+		buf.WriteString("\tout := buf\n")
+	}
+	switch def.Type {
+	case model.Bytes, model.String:
+		// FixedLength 的 bytes 不写长度
+		if def.FixedLength == 0 {
+			buf.WriteString("\tif err := g.e.encodeInt(len(v.Data), out); err != nil {\n\t\treturn err\n\t}\n")
+		}
+		if def.Type == model.Bytes {
+			buf.WriteString("\tif _, err := out.Write(v.Data); err != nil {\n\t\treturn err\n\t}\n")
+		} else {
+			buf.WriteString("\tif _, err := out.WriteString(v.Data); err != nil {\n\t\treturn err\n\t}\n")
+		}
+	case model.Object:
+		if def.Fields == nil {
+			buf.WriteString("\tif err := g.e.innerFreeMapEncode(v.Data, g.encodeState, out); err != nil {\n\t\treturn err\n\t}\n")
+		}
+		for i, field := range n.fields {
+			fmt.Fprintf(buf, "\tif err := %s(g, v.Data[%q], out); err != nil {\n\t\treturn err\n\t}\n", field.encodeName, n.fieldNames[i])
+		}
+		if def.Fields != nil && len(n.fields) == 0 {
+			buf.WriteString("\t_, _ = v, out\n")
+		}
+	case model.Array:
+		buf.WriteString("\tif err := g.e.encodeInt(len(v.Data), out); err != nil {\n\t\treturn err\n\t}\n")
+		fmt.Fprintf(buf, "\tfor _, item := range v.Data {\n\t\tif err := %s(g, item, out); err != nil {\n\t\t\treturn err\n\t\t}\n\t}\n", n.item.encodeName)
+	}
+	if pooled {
+		fmt.Fprintf(buf, "\treturn g.endPooled(%d, index, out, buf)\n}\n", pool)
+	} else {
+		buf.WriteString("\treturn nil\n}\n")
+	}
+}
+
+func (g *generator) writeDecode(buf *bytes.Buffer, n *node) {
+	def := n.def
+	fmt.Fprintf(buf, "\n// %s 解码%s\n", n.decodeName, describe(n.myName))
+	fmt.Fprintf(buf, "func %s(g *genDecodeState) (model.Value, error) {\n", n.decodeName)
+	if def.Nullable {
+		buf.WriteString("\texist, err := g.reader.ReadBoolean()\n\tif err != nil {\n\t\treturn nil, err\n\t}\n")
+		buf.WriteString("\tif !exist {\n\t\treturn nil, nil\n\t}\n")
+	}
+	if poolId, pooled := poolIdOf(def, n.myName); pooled {
+		// 池子已满时值内联编码在池索引的位置，由 Inline 函数解码
+		inlineName := n.decodeName + "Inline"
+		fmt.Fprintf(buf, "\treturn g.readPooled(%d, %q, %s)\n}\n", g.poolIndex[poolId], poolId, inlineName)
+		fmt.Fprintf(buf, "\n// %s 解码池满之后内联编码的%s\n", inlineName, describe(n.myName))
+		fmt.Fprintf(buf, "func %s(g *genDecodeState) (model.Value, error) {\n", inlineName)
+		g.writeDecodeValue(buf, n, ":=")
+		return
+	}
+	// err 是否已经由 nullable 标记的读取声明
+	declare := ":="
+	if def.Nullable {
+		declare = "="
+	}
+	g.writeDecodeValue(buf, n, declare)
+}
+
+// writeDecodeValue 写出 null 标记和池索引之后的值本身的解码，declare 表示 err 是否需要声明
+func (g *generator) writeDecodeValue(buf *bytes.Buffer, n *node, declare string) {
+	def := n.def
+	switch def.Type {
+	case model.Integer:
+		buf.WriteString("\tintv, err := g.readInt()\n\tif err != nil {\n\t\treturn nil, err\n\t}\n")
+		if def.DiffEncode {
+			fmt.Fprintf(buf, "\tif prev, exist := g.status[%q]; exist {\n\t\tintv = intv + prev.(int)\n\t}\n", n.myName)
+			fmt.Fprintf(buf, "\tg.status[%q] = intv\n", n.myName)
+		}
+		buf.WriteString("\tif err := g.lim.addDecoded(8); err != nil {\n\t\treturn nil, err\n\t}\n")
+		buf.WriteString("\treturn &model.IntegerValue{Data: intv}, nil\n}\n")
+	case model.Boolean:
+		buf.WriteString("\tboolv, err := g.reader.ReadBoolean()\n\tif err != nil {\n\t\treturn nil, err\n\t}\n")
+		buf.WriteString("\tif err := g.lim.addDecoded(1); err != nil {\n\t\treturn nil, err\n\t}\n")
+		buf.WriteString("\treturn &model.BooleanValue{Data: boolv}, nil\n}\n")
+	case model.Double:
+		buf.WriteString("\tdbv, err := g.reader.ReadFloat()\n\tif err != nil {\n\t\treturn nil, err\n\t}\n")
+		buf.WriteString("\tif err := g.lim.addDecoded(8); err != nil {\n\t\treturn nil, err\n\t}\n")
+		buf.WriteString("\treturn &model.DoubleValue{Data: dbv}, nil\n}\n")
+	case model.Bytes, model.String:
+		if def.FixedLength > 0 {
+			fmt.Fprintf(buf, "\tlength := %d\n", def.FixedLength)
+		} else {
+			buf.WriteString("\tlength, err := g.readInt()\n\tif err != nil {\n\t\treturn nil, err\n\t}\n")
+		}
+		if def.Type == model.Bytes {
+			buf.WriteString("\tbv, err := g.reader.ReadBytes(length)\n\tif err != nil {\n\t\treturn nil, err\n\t}\n")
+		} else {
+			buf.WriteString("\tstrv, err := g.reader.ReadString(length)\n\tif err != nil {\n\t\treturn nil, err\n\t}\n")
+		}
+		buf.WriteString("\tif err := g.lim.addDecoded(length); err != nil {\n\t\treturn nil, err\n\t}\n")
+		if def.Type == model.Bytes {
+			buf.WriteString("\treturn &model.BytesValue{Data: bv}, nil\n}\n")
+		} else {
+			buf.WriteString("\treturn &model.StringValue{Data: strv}, nil\n}\n")
+		}
+	case model.Object:
+		fmt.Fprintf(buf, "\terr %s g.lim.enter()\n\tif err != nil {\n\t\treturn nil, err\n\t}\n", declare)
+		if def.Fields == nil {
+			buf.WriteString("\tobjv, err := g.innerFreeMapDecode()\n\tif err != nil {\n\t\treturn nil, err\n\t}\n")
+		} else {
+			fmt.Fprintf(buf, "\tobjv := make(map[string]model.Value, %d)\n", len(n.fields))
+			for i, field := range n.fields {
+				fmt.Fprintf(buf, "\tobjv[%q], err = %s(g)\n\tif err != nil {\n\t\treturn nil, err\n\t}\n", n.fieldNames[i], field.decodeName)
+			}
+		}
+		buf.WriteString("\tg.lim.leave()\n\treturn &model.ObjectValue{Data: objv}, nil\n}\n")
+	case model.Array:
+		fmt.Fprintf(buf, "\terr %s g.lim.enter()\n\tif err != nil {\n\t\treturn nil, err\n\t}\n", declare)
+		buf.WriteString("\tlength, err := g.readInt()\n\tif err != nil {\n\t\treturn nil, err\n\t}\n")
+		fmt.Fprintf(buf, "\tif err := g.lim.checkArrayLength(%q, length); err != nil {\n\t\treturn nil, err\n\t}\n", n.myName)
+		buf.WriteString("\tvar arrv []model.Value\n\tif length > 0 {\n\t\tarrv = make([]model.Value, 0, g.capacityHint(length))\n\t}\n")
+		fmt.Fprintf(buf, "\tfor i := 0; i < length; i++ {\n\t\titem, err := %s(g)\n\t\tif err != nil {\n\t\t\treturn nil, err\n\t\t}\n\t\tarrv = append(arrv, item)\n\t}\n", n.item.decodeName)
+		buf.WriteString("\tg.lim.leave()\n\treturn &model.ArrayValue{Data: arrv}, nil\n}\n")
+	}
+}
+
+func describe(myName string) string {
+	if myName == "" {
+		return "根节点"
+	}
+	return fmt.Sprintf(" %q", myName)
+}
+
+// poolIdOf 返回节点使用的 poolId，Integer、Boolean、Double 不会入池
+func poolIdOf(def *model.Definition, myName string) (string, bool) {
+	if !def.Pooled && !def.SharePooled {
+		return "", false
+	}
+	if def.Type == model.Integer || def.Type == model.Boolean || def.Type == model.Double {
+		return "", false
+	}
+	if def.SharePooled {
+		return def.SharePoolId, true
+	}
+	return myName, true
+}
+
+func childName(myName string, fieldName string) string {
+	if len(myName) > 0 {
+		return myName + " " + fieldName
+	}
+	return fieldName
+}
+
+func valueTypeName(t model.ValueType) string {
+	switch t {
+	case model.Integer:
+		return "IntegerValue"
+	case model.Boolean:
+		return "BooleanValue"
+	case model.Double:
+		return "DoubleValue"
+	case model.Bytes:
+		return "BytesValue"
+	case model.String:
+		return "StringValue"
+	case model.Object:
+		return "ObjectValue"
+	default:
+		return "ArrayValue"
+	}
+}
+
+// camel 把 "service.name" 之类的名字转为 ServiceName，只保留字母和数字
+func camel(word string) string {
+	var result strings.Builder
+	upper := true
+	for _, r := range word {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		result.WriteRune(r)
+	}
+	return result.String()
+}
@@ -0,0 +1,459 @@
+// Package tune 根据一批真实的 trace 数据为 Definition 选择 Pooled、SharePooled 和 DiffEncode。
+// 每个候选 Definition 都用 codec.Encoder 实际编码并经过 zstd 压缩，以压缩后的总字节数打分
+package tune
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/beet233/compressotelcollector/codec"
+	"github.com/beet233/compressotelcollector/model"
+	"github.com/klauspost/compress/zstd"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+const (
+	choiceInline = "inline"
+	choicePooled = "pooled"
+	choicePlain  = "plain"
+	choiceDiff   = "diff"
+	// choiceSharePrefix 之后是 SharePoolId，如 "share:spanId"
+	choiceSharePrefix = "share:"
+)
+
+// Options 是调优的配置
+type Options struct {
+	// Codec 是打分时 Encoder 使用的配置，应该和实际部署的 exporter 一致
+	Codec []codec.Option
+	// Rounds 是对所有字段依次尝试的轮数，后面的轮次会在前面选择的基础上重新尝试，默认 1
+	Rounds int
+}
+
+// PathStats 是一个 field 路径在样本中的统计
+type PathStats struct {
+	Path string
+	Type model.ValueType
+	// Count 是非 null 值出现的次数
+	Count int
+	// Distinct 是不同值的个数
+	Distinct int
+}
+
+// Choice 是一个 field 路径的一种取值，Size 是采用它时整个样本编码并 zstd 压缩后的字节数
+type Choice struct {
+	Path   string
+	Name   string
+	Size   int
+	Chosen bool
+}
+
+// Report 记录调优过程中每个 field 的统计和每种取值的预期大小
+type Report struct {
+	Batches  int
+	BaseSize int
+	BestSize int
+	Paths    []PathStats
+	Choices  []Choice
+}
+
+// Tune 从 base 出发，逐个 field 尝试所有合法的取值，保留使 corpus 编码后最小的，返回调优后的 Definition。
+// base 不会被修改
+func Tune(base *model.Definition, corpus []ptrace.Traces, opts Options) (*model.Definition, *Report, error) {
+	if len(corpus) == 0 {
+		return nil, nil, errors.New("empty corpus")
+	}
+	if opts.Rounds <= 0 {
+		opts.Rounds = 1
+	}
+	zstdEncoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer zstdEncoder.Close()
+	t := &tuner{opts: opts, corpus: corpus, zstdEncoder: zstdEncoder}
+
+	stats := collectStats(base, corpus)
+	report := &Report{Batches: len(corpus)}
+	for _, path := range stats.paths {
+		report.Paths = append(report.Paths, *stats.byPath[path])
+	}
+
+	current := base.Clone()
+	currentSize, err := t.score(current)
+	if err != nil {
+		return nil, nil, fmt.Errorf("base Definition: %w", err)
+	}
+	report.BaseSize = currentSize
+	for round := 0; round < opts.Rounds; round++ {
+		// 每一轮只保留最后一次的结果
+		report.Choices = report.Choices[:0]
+		for _, path := range stats.paths {
+			currentChoice := choiceOf(current, path)
+			choices := []Choice{{Path: path, Name: currentChoice, Size: currentSize}}
+			best := 0
+			applied := []candidate{{name: currentChoice}}
+			for _, c := range candidates(current, path, stats) {
+				// 样本中没有出现过的 field 无论怎么选大小都不变
+				if c.name == currentChoice || stats.byPath[path].Count == 0 {
+					continue
+				}
+				next := current.Clone()
+				if !apply(next, path, c) || model.ValidateDefinition(next) != nil {
+					continue
+				}
+				size, err := t.score(next)
+				if err != nil {
+					continue
+				}
+				choices = append(choices, Choice{Path: path, Name: c.name, Size: size})
+				applied = append(applied, c)
+				if size < choices[best].Size {
+					best = len(choices) - 1
+				}
+			}
+			if best != 0 {
+				apply(current, path, applied[best])
+				currentSize = choices[best].Size
+			}
+			choices[best].Chosen = true
+			report.Choices = append(report.Choices, choices...)
+		}
+	}
+	report.BestSize = currentSize
+	return current, report, nil
+}
+
+type tuner struct {
+	opts        Options
+	corpus      []ptrace.Traces
+	zstdEncoder *zstd.Encoder
+}
+
+// score 返回 def 编码整个 corpus 并 zstd 压缩后的总字节数
+func (t *tuner) score(def *model.Definition) (int, error) {
+	encoder := codec.NewEncoder(def, t.opts.Codec...)
+	var buf bytes.Buffer
+	var compressed []byte
+	total := 0
+	for _, td := range t.corpus {
+		buf.Reset()
+		_, err := encoder.EncodeTraces(td, &buf)
+		if err != nil {
+			return 0, err
+		}
+		compressed = t.zstdEncoder.EncodeAll(buf.Bytes(), compressed[:0])
+		total += len(compressed)
+	}
+	return total, nil
+}
+
+// corpusStats 是样本中每个 field 路径的统计，paths 按 Definition 的先序遍历排列，不包含根
+type corpusStats struct {
+	paths  []string
+	byPath map[string]*PathStats
+	// values 是每个路径上出现过的值的哈希，用于判断两个路径是否适合共享池子
+	values map[string]map[uint64]struct{}
+}
+
+func collectStats(def *model.Definition, corpus []ptrace.Traces) *corpusStats {
+	stats := &corpusStats{
+		byPath: make(map[string]*PathStats),
+		values: make(map[string]map[uint64]struct{}),
+	}
+	walkDefinition(def, "", func(def *model.Definition, path string) {
+		if path == "" {
+			return
+		}
+		stats.paths = append(stats.paths, path)
+		stats.byPath[path] = &PathStats{Path: path, Type: def.Type}
+		stats.values[path] = make(map[uint64]struct{})
+	})
+	for _, td := range corpus {
+		stats.add(def, "", codec.TracesToValue(td))
+	}
+	for path, values := range stats.values {
+		stats.byPath[path].Distinct = len(values)
+	}
+	return stats
+}
+
+func (s *corpusStats) add(def *model.Definition, path string, val model.Value) {
+	if def == nil || val == nil || val.GetType() != def.Type {
+		return
+	}
+	// 空值按 null 编码，不参与统计
+	if path != "" && !isZero(val) {
+		s.byPath[path].Count++
+		s.values[path][val.Hash()] = struct{}{}
+	}
+	switch v := val.(type) {
+	case *model.ObjectValue:
+		if def.Fields == nil {
+			return
+		}
+		for fieldName, fieldDef := range def.Fields {
+			s.add(fieldDef, childName(path, fieldName), v.Data[fieldName])
+		}
+	case *model.ArrayValue:
+		for _, item := range v.Data {
+			s.add(def.ItemDefinition, childName(path, "item"), item)
+		}
+	}
+}
+
+func isZero(val model.Value) bool {
+	switch v := val.(type) {
+	case *model.IntegerValue:
+		return v.Data == 0
+	case *model.BytesValue:
+		return len(v.Data) == 0
+	case *model.StringValue:
+		return len(v.Data) == 0
+	case *model.ObjectValue:
+		return len(v.Data) == 0
+	case *model.ArrayValue:
+		return len(v.Data) == 0
+	}
+	return false
+}
+
+// overlaps 判断两个路径上是否出现过相同的值
+func (s *corpusStats) overlaps(a string, b string) bool {
+	small, large := s.values[a], s.values[b]
+	if len(small) > len(large) {
+		small, large = large, small
+	}
+	for hash := range small {
+		if _, ok := large[hash]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// candidate 是 path 上可以尝试的一种取值，新建共享池时 partner 是一起加入共享池的另一个路径
+type candidate struct {
+	name    string
+	partner string
+}
+
+// candidates 返回 path 上可以尝试的取值
+func candidates(def *model.Definition, path string, stats *corpusStats) []candidate {
+	fieldDef := model.FieldStringToDefinition(path, def)
+	switch fieldDef.Type {
+	case model.Integer:
+		return []candidate{{name: choicePlain}, {name: choiceDiff}}
+	case model.Boolean, model.Double:
+		return nil
+	}
+	result := []candidate{{name: choiceInline}, {name: choicePooled}}
+	seen := make(map[string]bool)
+	walkDefinition(def, "", func(other *model.Definition, otherPath string) {
+		if otherPath == path || other.Type != fieldDef.Type {
+			return
+		}
+		c := candidate{name: choiceSharePrefix + other.SharePoolId}
+		if !other.SharePooled {
+			if !stats.overlaps(path, otherPath) {
+				return
+			}
+			// 和另一个路径有相同的值时，尝试以另一个路径的最后一段为名新建一个共享池
+			c = candidate{name: choiceSharePrefix + lastSegment(otherPath), partner: otherPath}
+		}
+		if !seen[c.name] {
+			seen[c.name] = true
+			result = append(result, c)
+		}
+	})
+	return result
+}
+
+// choiceOf 返回 path 当前的取值
+func choiceOf(def *model.Definition, path string) string {
+	fieldDef := model.FieldStringToDefinition(path, def)
+	switch {
+	case fieldDef.Type == model.Integer && fieldDef.DiffEncode:
+		return choiceDiff
+	case fieldDef.Type == model.Integer || fieldDef.Type == model.Boolean || fieldDef.Type == model.Double:
+		return choicePlain
+	case fieldDef.SharePooled:
+		return choiceSharePrefix + fieldDef.SharePoolId
+	case fieldDef.Pooled:
+		return choicePooled
+	}
+	return choiceInline
+}
+
+// apply 把 path 设置为 c 对应的取值，取值会让差分编码的值落入池中时返回 false
+func apply(def *model.Definition, path string, c candidate) bool {
+	fieldDef := model.FieldStringToDefinition(path, def)
+	switch {
+	case c.name == choicePlain:
+		fieldDef.DiffEncode = false
+		return true
+	case c.name == choiceDiff:
+		fieldDef.DiffEncode = true
+		// 包含差分编码的元素及其父元素不能入池，否则解码时搞不清楚顺序
+		return !pooledAncestor(def, path)
+	case c.name == choiceInline:
+		fieldDef.Pooled, fieldDef.SharePooled, fieldDef.SharePoolId = false, false, ""
+		return true
+	case c.name == choicePooled:
+		fieldDef.Pooled, fieldDef.SharePooled, fieldDef.SharePoolId = true, false, ""
+		return !hasDiffEncode(fieldDef)
+	case strings.HasPrefix(c.name, choiceSharePrefix):
+		id := strings.TrimPrefix(c.name, choiceSharePrefix)
+		fieldDef.Pooled, fieldDef.SharePooled, fieldDef.SharePoolId = false, true, id
+		if c.partner != "" {
+			partnerDef := model.FieldStringToDefinition(c.partner, def)
+			partnerDef.Pooled, partnerDef.SharePooled, partnerDef.SharePoolId = false, true, id
+			if hasDiffEncode(partnerDef) {
+				return false
+			}
+		}
+		return !hasDiffEncode(fieldDef)
+	}
+	return false
+}
+
+func hasDiffEncode(def *model.Definition) bool {
+	found := false
+	walkDefinition(def, "", func(def *model.Definition, path string) {
+		found = found || def.DiffEncode
+	})
+	return found
+}
+
+func pooledAncestor(def *model.Definition, path string) bool {
+	segments := strings.Split(path, " ")
+	current := def
+	for _, segment := range segments[:len(segments)-1] {
+		if segment == "item" {
+			current = current.ItemDefinition
+		} else {
+			current = current.Fields[segment]
+		}
+		if current.Pooled || current.SharePooled {
+			return true
+		}
+	}
+	return false
+}
+
+// walkDefinition 先序遍历 def，Object 的 field 按字典序
+func walkDefinition(def *model.Definition, path string, visit func(def *model.Definition, path string)) {
+	if def == nil {
+		return
+	}
+	visit(def, path)
+	switch def.Type {
+	case model.Object:
+		fieldNames := make([]string, 0, len(def.Fields))
+		for fieldName := range def.Fields {
+			fieldNames = append(fieldNames, fieldName)
+		}
+		sort.Strings(fieldNames)
+		for _, fieldName := range fieldNames {
+			walkDefinition(def.Fields[fieldName], childName(path, fieldName), visit)
+		}
+	case model.Array:
+		walkDefinition(def.ItemDefinition, childName(path, "item"), visit)
+	}
+}
+
+func childName(path string, fieldName string) string {
+	if len(path) > 0 {
+		return path + " " + fieldName
+	}
+	return fieldName
+}
+
+func lastSegment(path string) string {
+	return path[strings.LastIndex(path, " ")+1:]
+}
+
+// definitionJSON 和 trace.json 的书写习惯一致：Integer、Boolean、Double 不写 Pooled，共享池只写 SharePooled 和 SharePoolId，其他为零值的字段省略
+type definitionJSON struct {
+	Type           string
+	Nullable       bool
+	Pooled         *bool                      `json:",omitempty"`
+	SharePooled    bool                       `json:",omitempty"`
+	SharePoolId    string                     `json:",omitempty"`
+	DiffEncode     bool                       `json:",omitempty"`
+	MaxPoolEntries int                        `json:",omitempty"`
+	FixedLength    int                        `json:",omitempty"`
+	Tag            int                        `json:",omitempty"`
+	Version        int                        `json:",omitempty"`
+	Fields         map[string]*definitionJSON `json:",omitempty"`
+	ItemDefinition *definitionJSON            `json:",omitempty"`
+}
+
+func toDefinitionJSON(def *model.Definition) *definitionJSON {
+	if def == nil {
+		return nil
+	}
+	result := &definitionJSON{
+		Type:           def.TypeName(),
+		Nullable:       def.Nullable,
+		SharePooled:    def.SharePooled,
+		SharePoolId:    def.SharePoolId,
+		DiffEncode:     def.DiffEncode,
+		MaxPoolEntries: def.MaxPoolEntries,
+		FixedLength:    def.FixedLength,
+		Tag:            def.Tag,
+		Version:        def.Version,
+		ItemDefinition: toDefinitionJSON(def.ItemDefinition),
+	}
+	if !def.SharePooled && def.Type != model.Integer && def.Type != model.Boolean && def.Type != model.Double {
+		pooled := def.Pooled
+		result.Pooled = &pooled
+	}
+	if def.Fields != nil {
+		result.Fields = make(map[string]*definitionJSON, len(def.Fields))
+		for fieldName, fieldDef := range def.Fields {
+			result.Fields[fieldName] = toDefinitionJSON(fieldDef)
+		}
+	}
+	return result
+}
+
+// MarshalDefinition 把 def 输出为和 trace.json 相同风格的 JSON
+func MarshalDefinition(def *model.Definition) ([]byte, error) {
+	src, err := json.MarshalIndent(toDefinitionJSON(def), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(src, '\n'), nil
+}
+
+// Write 以表格输出报告，每个 field 一行，列出每种取值的预期大小，* 标记选中的取值
+func (r *Report) Write(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "batches: %d\nbase size: %d bytes\ntuned size: %d bytes\n\n", r.Batches, r.BaseSize, r.BestSize)
+	if err != nil {
+		return err
+	}
+	choices := make(map[string][]Choice)
+	for _, choice := range r.Choices {
+		choices[choice.Path] = append(choices[choice.Path], choice)
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PATH\tTYPE\tCOUNT\tDISTINCT\tCHOICES")
+	for _, stats := range r.Paths {
+		var cells []string
+		for _, choice := range choices[stats.Path] {
+			cell := fmt.Sprintf("%s=%d", choice.Name, choice.Size)
+			if choice.Chosen {
+				cell += "*"
+			}
+			cells = append(cells, cell)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\n", stats.Path, stats.Type, stats.Count, stats.Distinct, strings.Join(cells, " "))
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,154 @@
+package tune
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beet233/compressotelcollector/codec"
+	"github.com/beet233/compressotelcollector/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+const spanPath = "resourceSpans item scopeSpans item spans item "
+
+// newCorpus 构造 span 名字重复、开始时间递增的样本
+func newCorpus(batches int) []ptrace.Traces {
+	corpus := make([]ptrace.Traces, 0, batches)
+	start := time.Unix(1700000000, 0)
+	for b := 0; b < batches; b++ {
+		td := ptrace.NewTraces()
+		rs := td.ResourceSpans().AppendEmpty()
+		rs.Resource().Attributes().PutStr("service.name", "checkout")
+		spans := rs.ScopeSpans().AppendEmpty().Spans()
+		for i := 0; i < 100; i++ {
+			span := spans.AppendEmpty()
+			span.SetTraceID([16]byte{byte(b), byte(i / 10)})
+			span.SetSpanID([8]byte{byte(b), byte(i)})
+			if i%10 != 0 {
+				span.SetParentSpanID([8]byte{byte(b), byte(i - i%10)})
+			}
+			span.SetName(fmt.Sprintf("GET /api/v1/checkout/items/%d", i%3))
+			ts := start.Add(time.Duration(b*100+i) * time.Millisecond)
+			span.SetStartTimestamp(pcommon.NewTimestampFromTime(ts))
+			span.SetEndTimestamp(pcommon.NewTimestampFromTime(ts.Add(3 * time.Millisecond)))
+		}
+		corpus = append(corpus, td)
+	}
+	return corpus
+}
+
+func TestTune(t *testing.T) {
+	traceModel, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(t, err)
+	base := traceModel.Clone()
+	model.FieldStringToDefinition(spanPath+"name", base).Pooled = false
+	model.FieldStringToDefinition(spanPath+"startTimeUnixNano", base).DiffEncode = false
+	corpus := newCorpus(3)
+
+	tuned, report, err := Tune(base, corpus, Options{})
+	require.NoError(t, err)
+	// base 不变
+	assert.False(t, model.FieldStringToDefinition(spanPath+"name", base).Pooled)
+	require.NoError(t, model.ValidateDefinition(tuned))
+	assert.LessOrEqual(t, report.BestSize, report.BaseSize)
+	assert.Equal(t, 3, report.Batches)
+	assert.NotEqual(t, choiceInline, choiceOf(tuned, spanPath+"name"))
+	assert.Equal(t, choiceDiff, choiceOf(tuned, spanPath+"startTimeUnixNano"))
+
+	// 每个 field 恰好选中一种取值，选中的取值和调优结果一致
+	chosen := make(map[string]string)
+	for _, choice := range report.Choices {
+		if choice.Chosen {
+			_, exist := chosen[choice.Path]
+			assert.False(t, exist, choice.Path)
+			chosen[choice.Path] = choice.Name
+		}
+	}
+	assert.Len(t, chosen, len(report.Paths))
+	for path, name := range chosen {
+		assert.Equal(t, choiceOf(tuned, path), name, path)
+	}
+
+	// 调优后的 Definition 可以正常编解码
+	var buf bytes.Buffer
+	_, err = codec.NewEncoder(tuned).EncodeTraces(corpus[0], &buf)
+	require.NoError(t, err)
+	got, _, err := codec.NewDecoder(tuned).DecodeTraces(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, 0, model.ValueComparator(codec.TracesToValue(corpus[0]), codec.TracesToValue(got)))
+
+	var out strings.Builder
+	require.NoError(t, report.Write(&out))
+	assert.Contains(t, out.String(), spanPath+"name")
+	assert.Contains(t, out.String(), "tuned size")
+}
+
+func TestApplyKeepsDiffEncodeOutOfPools(t *testing.T) {
+	def := &model.Definition{Type: model.Object, Fields: map[string]*model.Definition{
+		"events": {Type: model.Array, ItemDefinition: &model.Definition{Type: model.Object, Fields: map[string]*model.Definition{
+			"time": {Type: model.Integer, DiffEncode: true},
+			"name": {Type: model.String},
+		}}},
+	}}
+	assert.False(t, apply(def.Clone(), "events item", candidate{name: choicePooled}))
+	assert.False(t, apply(def.Clone(), "events", candidate{name: choicePooled}))
+	assert.True(t, apply(def.Clone(), "events item name", candidate{name: choicePooled}))
+
+	pooled := def.Clone()
+	pooled.Fields["events"].ItemDefinition.Fields["time"].DiffEncode = false
+	pooled.Fields["events"].ItemDefinition.Pooled = true
+	assert.False(t, apply(pooled, "events item time", candidate{name: choiceDiff}))
+}
+
+func TestCandidatesShareOverlappingPaths(t *testing.T) {
+	traceModel, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(t, err)
+	def := traceModel.Clone()
+	parent := model.FieldStringToDefinition(spanPath+"parentSpanId", def)
+	parent.SharePooled, parent.SharePoolId = false, ""
+	stats := collectStats(def, newCorpus(1))
+
+	var names []string
+	for _, c := range candidates(def, spanPath+"parentSpanId", stats) {
+		names = append(names, c.name)
+	}
+	// parentSpanId 中的值都是 spanId，可以重新加入 spanId 共享池
+	assert.Contains(t, names, choiceSharePrefix+"spanId")
+	assert.Contains(t, names, choicePooled)
+}
+
+func TestMarshalDefinition(t *testing.T) {
+	traceModel, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(t, err)
+	src, err := MarshalDefinition(traceModel)
+	require.NoError(t, err)
+	def, err := model.GetDefinitionFromJSON(src)
+	require.NoError(t, err)
+	expected, err := model.Fingerprint(traceModel)
+	require.NoError(t, err)
+	got, err := model.Fingerprint(def)
+	require.NoError(t, err)
+	assert.Equal(t, expected, got)
+	assert.NotContains(t, string(src), `"SharePoolId": ""`)
+	assert.Contains(t, string(src), `"Type": "map"`)
+}
+
+func TestMarshalDefinitionKeepsVersion(t *testing.T) {
+	def, err := model.GetDefinitionFromJSON([]byte(`{"Version": 3, "Type": "object", "Fields": {
+		"name": {"Type": "string", "Pooled": true, "Tag": 2},
+		"count": {"Type": "int", "Tag": 1}
+	}}`))
+	require.NoError(t, err)
+	src, err := MarshalDefinition(def)
+	require.NoError(t, err)
+	again, err := model.GetDefinitionFromJSON(src)
+	require.NoError(t, err)
+	assert.Equal(t, def, again)
+}
@@ -0,0 +1,34 @@
+package codec
+
+import (
+	"sort"
+
+	"github.com/beet233/compressotelcollector/model"
+)
+
+// planSortedKeys 预先为 def 及其所有子 Definition 计算 field 的编解码顺序，结果写入 sortedKeys
+func planSortedKeys(def *model.Definition, sortedKeys map[*model.Definition][]string) {
+	if def == nil {
+		return
+	}
+	switch def.Type {
+	case model.Object:
+		if def.Fields != nil {
+			sortedKeys[def] = def.FieldOrder()
+			for _, fieldDef := range def.Fields {
+				planSortedKeys(fieldDef, sortedKeys)
+			}
+		}
+	case model.Array:
+		planSortedKeys(def.ItemDefinition, sortedKeys)
+	}
+}
+
+func getSortedValueKeys(m map[string]model.Value) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,104 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrPayloadTooLarge 表示请求体或解码后的数据量超过了限制，对应 413
+	ErrPayloadTooLarge = errors.New("payload too large")
+	// ErrLimitExceeded 表示 payload 声明的结构（池大小、数组长度、嵌套深度）超过了限制，对应 400
+	ErrLimitExceeded = errors.New("decode limit exceeded")
+)
+
+// DecodeLimits 约束一次 Decode 可以消耗的资源，任何一项为 0 表示不限制
+type DecodeLimits struct {
+	// MaxBodySize 是压缩 payload 本身的最大字节数
+	MaxBodySize int `mapstructure:"max_body_size"`
+	// MaxPoolEntries 是 stringPool 以及每个 valuePool 的最大条目数
+	MaxPoolEntries int `mapstructure:"max_pool_entries"`
+	// MaxArrayLength 是任意数组（包括 attributes 中的自由数组）的最大长度
+	MaxArrayLength int `mapstructure:"max_array_length"`
+	// MaxDepth 是 Object/Array 的最大嵌套深度
+	MaxDepth int `mapstructure:"max_depth"`
+	// MaxDecodedSize 是解码后数据的估算字节数上限，池引用会按被引用值的大小重复计入
+	MaxDecodedSize int `mapstructure:"max_decoded_size"`
+}
+
+// DefaultDecodeLimits 是 Decoder 默认使用的限制
+func DefaultDecodeLimits() DecodeLimits {
+	return DecodeLimits{
+		MaxBodySize:    20 << 20,
+		MaxPoolEntries: 1 << 20,
+		MaxArrayLength: 1 << 20,
+		MaxDepth:       64,
+		MaxDecodedSize: 512 << 20,
+	}
+}
+
+// limiter 记录一次 Decode 过程中已经消耗的资源
+type limiter struct {
+	limits      DecodeLimits
+	depth       int
+	decodedSize int
+	// 每个 valuePool 中各条目解码后的大小，引用池中的值时按此计入 decodedSize
+	poolEntrySizes map[string][]int
+}
+
+func newLimiter(limits DecodeLimits) *limiter {
+	return &limiter{limits: limits, poolEntrySizes: make(map[string][]int)}
+}
+
+func (l *limiter) checkBodySize(size int) error {
+	if l.limits.MaxBodySize > 0 && size > l.limits.MaxBodySize {
+		return fmt.Errorf("%w: body exceeds %d bytes", ErrPayloadTooLarge, l.limits.MaxBodySize)
+	}
+	return nil
+}
+
+func (l *limiter) checkPoolEntries(poolId string, size int) error {
+	if size < 0 {
+		return fmt.Errorf("negative size %d of pool %q", size, poolId)
+	}
+	if l.limits.MaxPoolEntries > 0 && size > l.limits.MaxPoolEntries {
+		return fmt.Errorf("%w: pool %q declares %d entries, max %d", ErrLimitExceeded, poolId, size, l.limits.MaxPoolEntries)
+	}
+	return nil
+}
+
+func (l *limiter) checkArrayLength(myName string, length int) error {
+	if length < 0 {
+		return fmt.Errorf("negative length %d of array %q", length, myName)
+	}
+	if l.limits.MaxArrayLength > 0 && length > l.limits.MaxArrayLength {
+		return fmt.Errorf("%w: array %q declares %d items, max %d", ErrLimitExceeded, myName, length, l.limits.MaxArrayLength)
+	}
+	return nil
+}
+
+// enter 在进入一层 Object/Array 时调用，和 leave 成对使用
+func (l *limiter) enter() error {
+	l.depth++
+	if l.limits.MaxDepth > 0 && l.depth > l.limits.MaxDepth {
+		return fmt.Errorf("%w: nesting depth exceeds %d", ErrLimitExceeded, l.limits.MaxDepth)
+	}
+	return nil
+}
+
+func (l *limiter) leave() {
+	l.depth--
+}
+
+func (l *limiter) addDecoded(size int) error {
+	l.decodedSize += size
+	if l.limits.MaxDecodedSize > 0 && l.decodedSize > l.limits.MaxDecodedSize {
+		return fmt.Errorf("%w: decoded data exceeds %d bytes", ErrPayloadTooLarge, l.limits.MaxDecodedSize)
+	}
+	return nil
+}
+
+// addPoolReference 计入一次对 valuePool 条目的引用
+func (l *limiter) addPoolReference(poolId string, index int) error {
+	return l.addDecoded(l.poolEntrySizes[poolId][index])
+}
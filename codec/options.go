@@ -0,0 +1,134 @@
+package codec
+
+import (
+	"github.com/beet233/compressotelcollector/model"
+	"go.uber.org/zap"
+)
+
+// options 是 Encoder 和 Decoder 共用的配置，两端的 leb128、stringPool、parentReferences、traceGrouping、restoreSpanOrder 设置必须一致才能互相解析
+type options struct {
+	leb128Enabled     bool
+	stringPoolEnabled bool
+	logger            *zap.Logger
+	limits            DecodeLimits
+	poolLimits        PoolLimits
+	generatedEnabled  bool
+	adaptivePooling   bool
+	parentReferences  bool
+	traceGrouping     bool
+	restoreSpanOrder  bool
+	embeddedSchema    bool
+	writers           *model.Registry
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		leb128Enabled:     true,
+		stringPoolEnabled: true,
+		logger:            zap.NewNop(),
+		limits:            DefaultDecodeLimits(),
+		generatedEnabled:  true,
+		restoreSpanOrder:  true,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Option 用于配置 Encoder 和 Decoder
+type Option func(*options)
+
+// WithLeb128 设置整数是否使用 sleb128 变长编码，否则使用 8 byte 小端编码，默认开启
+func WithLeb128(enabled bool) Option {
+	return func(o *options) {
+		o.leb128Enabled = enabled
+	}
+}
+
+// WithStringPool 设置 attributes 中的 string 值是否进入 stringPool，否则直接写入长度和内容，默认开启
+func WithStringPool(enabled bool) Option {
+	return func(o *options) {
+		o.stringPoolEnabled = enabled
+	}
+}
+
+// WithLogger 设置输出 debug 日志使用的 logger，默认不输出
+func WithLogger(logger *zap.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithLimits 设置 Decoder 的资源限制，默认为 DefaultDecodeLimits，对 Encoder 无效
+func WithLimits(limits DecodeLimits) Option {
+	return func(o *options) {
+		o.limits = limits
+	}
+}
+
+// WithPoolLimits 设置 Encoder 中 valuePool 的最大条目数，默认只使用 Definition 中的 MaxPoolEntries，对 Decoder 无效
+func WithPoolLimits(limits PoolLimits) Option {
+	return func(o *options) {
+		o.poolLimits = limits
+	}
+}
+
+// WithGenerated 设置 Definition 有 cprvalgen 生成的专用编解码代码时是否使用，否则总是使用通用的实现，默认开启
+func WithGenerated(enabled bool) Option {
+	return func(o *options) {
+		o.generatedEnabled = enabled
+	}
+}
+
+// WithAdaptivePooling 设置 Encoder 是否根据每批数据的统计决定每个池子是否池化，池化不能让 payload 变小的池子整体内联编码，
+// 池子嵌套时会重新统计再编码，最多编码几遍，写出其中最小的 payload，不会比全部池化更大。
+// 决定以标记写入 header，Decoder 总是按标记解码。每批数据至少多编码一遍，耗时和分配大约翻倍，
+// 而多数数据只能省下很少的 byte（见 BenchmarkAdaptivePooling），默认关闭
+func WithAdaptivePooling(enabled bool) Option {
+	return func(o *options) {
+		o.adaptivePooling = enabled
+	}
+}
+
+// WithParentReferences 设置 span 的 parentSpanId 是否在父 span 位于同一批数据的同一个 trace 中时写为往回数的位置，
+// 否则仍然通过 spanId 的池子编码。Definition 需要和 OTLP 的 span 结构一致且 span 及其上层都不池化，否则不生效；
+// 开启时不使用 cprvalgen 生成的代码。回引用以整数写入，关闭 leb128 时通常不能减小 payload，默认关闭
+func WithParentReferences(enabled bool) Option {
+	return func(o *options) {
+		o.parentReferences = enabled
+	}
+}
+
+// WithTraceGrouping 设置每个 scopeSpans 中的 span 是否按 traceId 分组编码，每组只写一次 traceId。
+// 和 WithParentReferences 一样要求 span 及其上层都不池化，开启时不使用 cprvalgen 生成的代码，默认关闭
+func WithTraceGrouping(enabled bool) Option {
+	return func(o *options) {
+		o.traceGrouping = enabled
+	}
+}
+
+// WithRestoreSpanOrder 设置按 traceId 分组编码时是否写入 span 原来的位置，使解码结果保持原来的顺序，
+// 关闭时解码结果为分组后的顺序，payload 更小。只在 WithTraceGrouping 开启时生效，默认开启
+func WithRestoreSpanOrder(enabled bool) Option {
+	return func(o *options) {
+		o.restoreSpanOrder = enabled
+	}
+}
+
+// WithEmbeddedSchema 设置 Version 大于 0 的 Definition 编码时是否在 payload 开头写入完整的 Definition，
+// 否则只写入 Version 和指纹的前几个 byte，reader 需要通过 WithWriters 找到 writer 的 Definition。
+// Decoder 总是两种都能解析，对 Decoder 无效，默认关闭
+func WithEmbeddedSchema(enabled bool) Option {
+	return func(o *options) {
+		o.embeddedSchema = enabled
+	}
+}
+
+// WithWriters 设置 Decoder 查找 writer Definition 的 Registry，payload 只带 Version 和指纹时，
+// 按 Decoder 的 Entry 的名字和 payload 中的 Version 查找，指纹需要一致。对 Encoder 无效，默认只认识 Decoder 自己的 Definition
+func WithWriters(registry *model.Registry) Option {
+	return func(o *options) {
+		o.writers = registry
+	}
+}
@@ -0,0 +1,80 @@
+package codec
+
+import (
+	"bytes"
+
+	"github.com/beet233/compressotelcollector/model"
+)
+
+// inlineMarker 代替池索引写入，表示值所在的池子已满，值紧跟其后内联编码
+const inlineMarker = -1
+
+// PoolLimits 约束 Encoder 中每个 valuePool 的最大条目数，池子满了之后的新值不再入池，而是内联编码
+type PoolLimits struct {
+	// Default 对所有池子生效，0 表示不限制
+	Default int `mapstructure:"default"`
+	// Pools 按 poolId（field 路径或 SharePoolId）单独设置，优先于 Definition 中的 MaxPoolEntries 和 Default
+	Pools map[string]int `mapstructure:"pools"`
+}
+
+// planPoolLimits 为 def 中的每个池子确定最大条目数，优先级为 limits.Pools、Definition.MaxPoolEntries、limits.Default
+// 不限制的池子不出现在结果中
+func planPoolLimits(def *model.Definition, limits PoolLimits) map[string]int {
+	result := make(map[string]int)
+	var dfs func(def *model.Definition, myName string)
+	dfs = func(def *model.Definition, myName string) {
+		if def == nil {
+			return
+		}
+		if def.Pooled || def.SharePooled {
+			poolId := poolIdOf(def, myName)
+			limit := limits.Default
+			if def.MaxPoolEntries > 0 {
+				limit = def.MaxPoolEntries
+			}
+			if poolLimit, ok := limits.Pools[poolId]; ok {
+				limit = poolLimit
+			}
+			if limit > 0 {
+				result[poolId] = limit
+			}
+		}
+		switch def.Type {
+		case model.Object:
+			for fieldName, fieldDef := range def.Fields {
+				dfs(fieldDef, childName(myName, fieldName))
+			}
+		case model.Array:
+			dfs(def.ItemDefinition, childName(myName, "item"))
+		}
+	}
+	dfs(def, "")
+	return result
+}
+
+// poolIdOf 返回池化字段使用的池子，共享池使用 SharePoolId，否则为 field 路径
+func poolIdOf(def *model.Definition, myName string) string {
+	if def.SharePooled {
+		return def.SharePoolId
+	}
+	return myName
+}
+
+// poolFull 判断有 size 个条目的池子是否还能放入新值，本次编码不池化的池子总是满的
+func (e *Encoder) poolFull(state *encodeState, poolId string, size int) bool {
+	if state.unpooled[poolId] {
+		return true
+	}
+	limit, ok := e.poolLimits[poolId]
+	return ok && size >= limit
+}
+
+// writeInline 写入 inlineMarker，并记录一次池满之后的内联编码，调用方随后写入值本身
+// 不池化的池子在 header 中已经标记，值直接内联编码，不需要 inlineMarker
+func (e *Encoder) writeInline(state *encodeState, poolId string, buf *bytes.Buffer) error {
+	if state.unpooled[poolId] {
+		return nil
+	}
+	state.overflowLog = append(state.overflowLog, poolId)
+	return e.encodeInt(inlineMarker, buf)
+}
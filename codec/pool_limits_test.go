@@ -0,0 +1,81 @@
+package codec
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/beet233/compressotelcollector/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestPlanPoolLimits(t *testing.T) {
+	def := &model.Definition{Type: model.Object, Fields: map[string]*model.Definition{
+		"a": {Type: model.String, Pooled: true, MaxPoolEntries: 5},
+		"b": {Type: model.String, Pooled: true},
+		"c": {Type: model.Array, ItemDefinition: &model.Definition{Type: model.Bytes, SharePooled: true, SharePoolId: "id"}},
+		"d": {Type: model.String, Pooled: true, MaxPoolEntries: 5},
+	}}
+	assert.Equal(t, map[string]int{"a": 5, "d": 5}, planPoolLimits(def, PoolLimits{}))
+	assert.Equal(t, map[string]int{"a": 5, "b": 2, "id": 2, "d": 5}, planPoolLimits(def, PoolLimits{Default: 2}))
+	// 配置中按 poolId 的设置优先，0 表示不限制
+	assert.Equal(t, map[string]int{"a": 1, "b": 2, "id": 7}, planPoolLimits(def, PoolLimits{Default: 2, Pools: map[string]int{"a": 1, "id": 7, "d": 0}}))
+}
+
+func TestPoolLimitsRoundTrip(t *testing.T) {
+	def := &model.Definition{Type: model.Object, Fields: map[string]*model.Definition{
+		"items": {Type: model.Array, ItemDefinition: &model.Definition{Type: model.Object, Fields: map[string]*model.Definition{
+			"requestId":  {Type: model.String, Pooled: true, MaxPoolEntries: 2},
+			"attributes": {Type: model.Object, Nullable: true, Pooled: true, MaxPoolEntries: 1},
+			"tags":       {Type: model.Array, Nullable: true, Pooled: true, MaxPoolEntries: 1, ItemDefinition: &model.Definition{Type: model.String}},
+		}}},
+	}}
+	items := make([]any, 0)
+	for i := 0; i < 6; i++ {
+		items = append(items, map[string]any{
+			"requestId":  []string{"r0", "r1", "r2", "r3", "r0", "r2"}[i],
+			"attributes": map[string]any{"i": int64(i % 3)},
+			"tags":       []any{"t", []string{"x", "y"}[i%2]},
+		})
+	}
+	value := model.AnyToValue(map[string]any{"items": items})
+
+	var buf bytes.Buffer
+	// 关闭自适应池化，否则这些小池子会整体内联
+	stats, err := NewEncoder(def, WithAdaptivePooling(false)).Encode(value, &buf)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{
+		"items item requestId":  2,
+		"items item attributes": 1,
+		"items item tags":       1,
+	}, stats.ValuePoolSizes)
+	// r2、r3 和第二次出现的 r2 不在池中；attributes 和 tags 只有第一个值入池
+	assert.Equal(t, map[string]int{
+		"items item requestId":  3,
+		"items item attributes": 4,
+		"items item tags":       3,
+	}, stats.PoolOverflows)
+
+	decoded, decodeStats, err := NewDecoder(def).Decode(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, 0, model.ValueComparator(value, decoded))
+	assert.Equal(t, stats.ValuePoolSizes, decodeStats.ValuePoolSizes)
+}
+
+func TestPoolLimitsReduceTracePools(t *testing.T) {
+	def, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(t, err)
+	td := newRichTraces(200)
+	var buf bytes.Buffer
+	stats, err := NewEncoder(def, WithPoolLimits(PoolLimits{Default: 4}), WithAdaptivePooling(false)).EncodeTraces(td, &buf)
+	require.NoError(t, err)
+	for poolId, size := range stats.ValuePoolSizes {
+		assert.LessOrEqual(t, size, 4, poolId)
+	}
+	assert.NotEmpty(t, stats.PoolOverflows)
+
+	got, _, err := NewDecoder(def).DecodeTraces(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, 0, model.ValueComparator(TracesToValue(td), TracesToValue(got)))
+}
@@ -0,0 +1,398 @@
+package codec
+
+import (
+	"github.com/beet233/compressotelcollector/model"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// TracesToValue 把 ptrace.Traces 转化为 trace Definition 对应的 model.Value
+func TracesToValue(td ptrace.Traces) model.Value {
+	tracesValue := model.ObjectValue{Data: map[string]model.Value{}}
+	resourceSpansValue := model.ArrayValue{Data: []model.Value{}}
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		resourceSpanValue := model.ObjectValue{Data: map[string]model.Value{}}
+		resourceSpan := td.ResourceSpans().At(i)
+		resourceValue := model.ObjectValue{Data: map[string]model.Value{}}
+		resource := resourceSpan.Resource()
+		resourceValue.Data["attributes"] = model.AnyToValue(resource.Attributes().AsRaw())
+		resourceValue.Data["droppedAttributesCount"] = &model.IntegerValue{Data: int(resource.DroppedAttributesCount())}
+		resourceSpanValue.Data["resource"] = &resourceValue
+		scopeSpansValue := model.ArrayValue{Data: []model.Value{}}
+		for j := 0; j < resourceSpan.ScopeSpans().Len(); j++ {
+			scopeSpanValue := model.ObjectValue{Data: map[string]model.Value{}}
+			scopeSpan := resourceSpan.ScopeSpans().At(j)
+			scopeValue := model.ObjectValue{Data: map[string]model.Value{}}
+			scope := scopeSpan.Scope()
+			scopeValue.Data["name"] = &model.StringValue{Data: scope.Name()}
+			scopeValue.Data["version"] = &model.StringValue{Data: scope.Version()}
+			scopeValue.Data["attributes"] = model.AnyToValue(scope.Attributes().AsRaw())
+			scopeValue.Data["droppedAttributesCount"] = &model.IntegerValue{Data: int(scope.DroppedAttributesCount())}
+			scopeSpanValue.Data["scope"] = &scopeValue
+			spansValue := model.ArrayValue{Data: []model.Value{}}
+			for k := 0; k < scopeSpan.Spans().Len(); k++ {
+				spanValue := model.ObjectValue{Data: map[string]model.Value{}}
+				span := scopeSpan.Spans().At(k)
+				traceId := span.TraceID()
+				traceIdBytes := traceId[:]
+				spanValue.Data["traceId"] = &model.BytesValue{Data: traceIdBytes}
+				spanId := span.SpanID()
+				spanIdBytes := spanId[:]
+				spanValue.Data["spanId"] = &model.BytesValue{Data: spanIdBytes}
+				spanValue.Data["traceState"] = &model.StringValue{Data: span.TraceState().AsRaw()}
+				parentSpanId := span.ParentSpanID()
+				parentSpanIdBytes := parentSpanId[:]
+				spanValue.Data["parentSpanId"] = &model.BytesValue{Data: parentSpanIdBytes}
+				spanValue.Data["name"] = &model.StringValue{Data: span.Name()}
+				spanValue.Data["kind"] = &model.IntegerValue{Data: int(span.Kind())}
+				spanValue.Data["startTimeUnixNano"] = &model.IntegerValue{Data: int(span.StartTimestamp().AsTime().UnixNano())}
+				spanValue.Data["endTimeUnixNano"] = &model.IntegerValue{Data: int(span.EndTimestamp().AsTime().UnixNano())}
+				spanValue.Data["attributes"] = model.AnyToValue(span.Attributes().AsRaw())
+				spanValue.Data["droppedAttributesCount"] = &model.IntegerValue{Data: int(span.DroppedAttributesCount())}
+				eventsValue := model.ArrayValue{Data: []model.Value{}}
+				for m := 0; m < span.Events().Len(); m++ {
+					eventValue := model.ObjectValue{Data: map[string]model.Value{}}
+					event := span.Events().At(m)
+					eventValue.Data["timeUnixNano"] = &model.IntegerValue{Data: int(event.Timestamp().AsTime().UnixNano())}
+					eventValue.Data["name"] = &model.StringValue{Data: event.Name()}
+					eventValue.Data["attributes"] = model.AnyToValue(event.Attributes().AsRaw())
+					eventValue.Data["droppedAttributesCount"] = &model.IntegerValue{Data: int(event.DroppedAttributesCount())}
+					eventsValue.Data = append(eventsValue.Data, &eventValue)
+				}
+				spanValue.Data["events"] = &eventsValue
+				spanValue.Data["droppedEventsCount"] = &model.IntegerValue{Data: int(span.DroppedEventsCount())}
+				linksValue := model.ArrayValue{Data: []model.Value{}}
+				for m := 0; m < span.Links().Len(); m++ {
+					linkValue := model.ObjectValue{Data: map[string]model.Value{}}
+					link := span.Links().At(m)
+					traceId := link.TraceID()
+					traceIdBytes := traceId[:]
+					linkValue.Data["traceId"] = &model.BytesValue{Data: traceIdBytes}
+					spanId := link.SpanID()
+					spanIdBytes := spanId[:]
+					linkValue.Data["spanId"] = &model.BytesValue{Data: spanIdBytes}
+					linkValue.Data["traceState"] = &model.StringValue{Data: link.TraceState().AsRaw()}
+					linkValue.Data["attributes"] = model.AnyToValue(link.Attributes().AsRaw())
+					linkValue.Data["droppedAttributesCount"] = &model.IntegerValue{Data: int(link.DroppedAttributesCount())}
+					linksValue.Data = append(linksValue.Data, &linkValue)
+				}
+				spanValue.Data["links"] = &linksValue
+				spanValue.Data["droppedLinksCount"] = &model.IntegerValue{Data: int(span.DroppedLinksCount())}
+				statusValue := model.ObjectValue{Data: map[string]model.Value{}}
+				status := span.Status()
+				statusValue.Data["message"] = &model.StringValue{Data: status.Message()}
+				statusValue.Data["code"] = &model.IntegerValue{Data: int(status.Code())}
+				spanValue.Data["status"] = &statusValue
+				spansValue.Data = append(spansValue.Data, &spanValue)
+			}
+			scopeSpanValue.Data["spans"] = &spansValue
+			scopeSpanValue.Data["schemaUrl"] = &model.StringValue{Data: scopeSpan.SchemaUrl()}
+			scopeSpansValue.Data = append(scopeSpansValue.Data, &scopeSpanValue)
+		}
+		resourceSpanValue.Data["scopeSpans"] = &scopeSpansValue
+		resourceSpanValue.Data["schemaUrl"] = &model.StringValue{Data: resourceSpan.SchemaUrl()}
+		resourceSpansValue.Data = append(resourceSpansValue.Data, &resourceSpanValue)
+	}
+	tracesValue.Data["resourceSpans"] = &resourceSpansValue
+	return &tracesValue
+}
+
+// ValueToTraces 把 trace Definition 对应的 model.Value 转化为 ptrace.Traces，attributes 按 key 的字典序插入
+func ValueToTraces(value model.Value) ptrace.Traces {
+	td := ptrace.NewTraces()
+	tracesVal, ok := value.(*model.ObjectValue)
+	if !ok {
+		return td
+	}
+	if resourceSpansVal, ok := tracesVal.Data["resourceSpans"].(*model.ArrayValue); ok {
+		valueToResourceSpansSlice(resourceSpansVal, td.ResourceSpans())
+	}
+	return td
+}
+
+func valueToResourceSpansSlice(value model.Value, dest ptrace.ResourceSpansSlice) {
+	arrv, ok := value.(*model.ArrayValue)
+	if !ok {
+		return
+	}
+	dest.EnsureCapacity(len(arrv.Data))
+	for _, item := range arrv.Data {
+		valueToResourceSpans(item, dest.AppendEmpty())
+	}
+}
+
+func valueToResourceSpans(value model.Value, dest ptrace.ResourceSpans) {
+	objv, ok := value.(*model.ObjectValue)
+	if !ok {
+		return
+	}
+	for key, v := range objv.Data {
+		switch key {
+		case "resource":
+			valueToResource(v, dest.Resource())
+		case "scopeSpans":
+			valueToScopeSpansSlice(v, dest.ScopeSpans())
+		case "schemaUrl":
+			dest.SetSchemaUrl(stringOf(v))
+		}
+	}
+}
+
+func valueToResource(value model.Value, dest pcommon.Resource) {
+	objv, ok := value.(*model.ObjectValue)
+	if !ok {
+		return
+	}
+	for key, v := range objv.Data {
+		switch key {
+		case "attributes":
+			valueToAttributes(v, dest.Attributes())
+		case "droppedAttributesCount":
+			dest.SetDroppedAttributesCount(uint32(intOf(v)))
+		}
+	}
+}
+
+func valueToScopeSpansSlice(value model.Value, dest ptrace.ScopeSpansSlice) {
+	arrv, ok := value.(*model.ArrayValue)
+	if !ok {
+		return
+	}
+	dest.EnsureCapacity(len(arrv.Data))
+	for _, item := range arrv.Data {
+		valueToScopeSpans(item, dest.AppendEmpty())
+	}
+}
+
+func valueToScopeSpans(value model.Value, dest ptrace.ScopeSpans) {
+	objv, ok := value.(*model.ObjectValue)
+	if !ok {
+		return
+	}
+	for key, v := range objv.Data {
+		switch key {
+		case "scope":
+			valueToScope(v, dest.Scope())
+		case "spans":
+			valueToSpanSlice(v, dest.Spans())
+		case "schemaUrl":
+			dest.SetSchemaUrl(stringOf(v))
+		}
+	}
+}
+
+func valueToScope(value model.Value, dest pcommon.InstrumentationScope) {
+	objv, ok := value.(*model.ObjectValue)
+	if !ok {
+		return
+	}
+	for key, v := range objv.Data {
+		switch key {
+		case "name":
+			dest.SetName(stringOf(v))
+		case "version":
+			dest.SetVersion(stringOf(v))
+		case "attributes":
+			valueToAttributes(v, dest.Attributes())
+		case "droppedAttributesCount":
+			dest.SetDroppedAttributesCount(uint32(intOf(v)))
+		}
+	}
+}
+
+func valueToSpanSlice(value model.Value, dest ptrace.SpanSlice) {
+	arrv, ok := value.(*model.ArrayValue)
+	if !ok {
+		return
+	}
+	dest.EnsureCapacity(len(arrv.Data))
+	for _, item := range arrv.Data {
+		valueToSpan(item, dest.AppendEmpty())
+	}
+}
+
+func valueToSpan(value model.Value, dest ptrace.Span) {
+	objv, ok := value.(*model.ObjectValue)
+	if !ok {
+		return
+	}
+	for key, v := range objv.Data {
+		switch key {
+		case "traceId":
+			dest.SetTraceID(traceIDOf(bytesOf(v)))
+		case "spanId":
+			dest.SetSpanID(spanIDOf(bytesOf(v)))
+		case "traceState":
+			dest.TraceState().FromRaw(stringOf(v))
+		case "parentSpanId":
+			dest.SetParentSpanID(spanIDOf(bytesOf(v)))
+		case "name":
+			dest.SetName(stringOf(v))
+		case "kind":
+			dest.SetKind(ptrace.SpanKind(intOf(v)))
+		case "startTimeUnixNano":
+			dest.SetStartTimestamp(pcommon.Timestamp(intOf(v)))
+		case "endTimeUnixNano":
+			dest.SetEndTimestamp(pcommon.Timestamp(intOf(v)))
+		case "attributes":
+			valueToAttributes(v, dest.Attributes())
+		case "droppedAttributesCount":
+			dest.SetDroppedAttributesCount(uint32(intOf(v)))
+		case "events":
+			valueToSpanEventSlice(v, dest.Events())
+		case "droppedEventsCount":
+			dest.SetDroppedEventsCount(uint32(intOf(v)))
+		case "links":
+			valueToSpanLinkSlice(v, dest.Links())
+		case "droppedLinksCount":
+			dest.SetDroppedLinksCount(uint32(intOf(v)))
+		case "status":
+			valueToStatus(v, dest.Status())
+		}
+	}
+}
+
+func valueToSpanEventSlice(value model.Value, dest ptrace.SpanEventSlice) {
+	arrv, ok := value.(*model.ArrayValue)
+	if !ok {
+		return
+	}
+	dest.EnsureCapacity(len(arrv.Data))
+	for _, item := range arrv.Data {
+		valueToSpanEvent(item, dest.AppendEmpty())
+	}
+}
+
+func valueToSpanEvent(value model.Value, dest ptrace.SpanEvent) {
+	objv, ok := value.(*model.ObjectValue)
+	if !ok {
+		return
+	}
+	for key, v := range objv.Data {
+		switch key {
+		case "timeUnixNano":
+			dest.SetTimestamp(pcommon.Timestamp(intOf(v)))
+		case "name":
+			dest.SetName(stringOf(v))
+		case "attributes":
+			valueToAttributes(v, dest.Attributes())
+		case "droppedAttributesCount":
+			dest.SetDroppedAttributesCount(uint32(intOf(v)))
+		}
+	}
+}
+
+func valueToSpanLinkSlice(value model.Value, dest ptrace.SpanLinkSlice) {
+	arrv, ok := value.(*model.ArrayValue)
+	if !ok {
+		return
+	}
+	dest.EnsureCapacity(len(arrv.Data))
+	for _, item := range arrv.Data {
+		valueToSpanLink(item, dest.AppendEmpty())
+	}
+}
+
+func valueToSpanLink(value model.Value, dest ptrace.SpanLink) {
+	objv, ok := value.(*model.ObjectValue)
+	if !ok {
+		return
+	}
+	for key, v := range objv.Data {
+		switch key {
+		case "traceId":
+			dest.SetTraceID(traceIDOf(bytesOf(v)))
+		case "spanId":
+			dest.SetSpanID(spanIDOf(bytesOf(v)))
+		case "traceState":
+			dest.TraceState().FromRaw(stringOf(v))
+		case "attributes":
+			valueToAttributes(v, dest.Attributes())
+		case "droppedAttributesCount":
+			dest.SetDroppedAttributesCount(uint32(intOf(v)))
+		}
+	}
+}
+
+func valueToStatus(value model.Value, dest ptrace.Status) {
+	objv, ok := value.(*model.ObjectValue)
+	if !ok {
+		return
+	}
+	for key, v := range objv.Data {
+		switch key {
+		case "message":
+			dest.SetMessage(stringOf(v))
+		case "code":
+			dest.SetCode(ptrace.StatusCode(intOf(v)))
+		}
+	}
+}
+
+// valueToAttributes 把自由 map 的 Value 写入 dest，按 key 的字典序插入，不经过 AsRaw/FromRaw 的 map[string]any
+func valueToAttributes(value model.Value, dest pcommon.Map) {
+	objv, ok := value.(*model.ObjectValue)
+	if !ok {
+		return
+	}
+	dest.EnsureCapacity(len(objv.Data))
+	for _, key := range getSortedValueKeys(objv.Data) {
+		valueToPcommon(objv.Data[key], dest.PutEmpty(key))
+	}
+}
+
+func valueToPcommon(value model.Value, dest pcommon.Value) {
+	switch v := value.(type) {
+	case *model.IntegerValue:
+		dest.SetInt(int64(v.Data))
+	case *model.BooleanValue:
+		dest.SetBool(v.Data)
+	case *model.DoubleValue:
+		dest.SetDouble(v.Data)
+	case *model.BytesValue:
+		dest.SetEmptyBytes().FromRaw(v.Data)
+	case *model.StringValue:
+		dest.SetStr(v.Data)
+	case *model.ObjectValue:
+		valueToAttributes(v, dest.SetEmptyMap())
+	case *model.ArrayValue:
+		slice := dest.SetEmptySlice()
+		slice.EnsureCapacity(len(v.Data))
+		for _, item := range v.Data {
+			valueToPcommon(item, slice.AppendEmpty())
+		}
+	}
+}
+
+func intOf(value model.Value) int {
+	if intv, ok := value.(*model.IntegerValue); ok {
+		return intv.Data
+	}
+	return 0
+}
+
+func stringOf(value model.Value) string {
+	if strv, ok := value.(*model.StringValue); ok {
+		return strv.Data
+	}
+	return ""
+}
+
+func bytesOf(value model.Value) []byte {
+	if bv, ok := value.(*model.BytesValue); ok {
+		return bv.Data
+	}
+	return nil
+}
+
+func traceIDOf(bv []byte) pcommon.TraceID {
+	var traceId pcommon.TraceID
+	copy(traceId[:], bv)
+	return traceId
+}
+
+func spanIDOf(bv []byte) pcommon.SpanID {
+	var spanId pcommon.SpanID
+	copy(spanId[:], bv)
+	return spanId
+}
@@ -0,0 +1,838 @@
+package codec
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/beet233/compressotelcollector/model"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// DecodeTraces 将 cprval 解码为 ptrace.Traces，结果和 ValueToTraces(Decode(in)) 一致。
+// 数据部分直接写入 ptrace.Traces，只有池中的值还会先解码为 model.Value；Definition 与 OTLP 的结构不一致时退回 Decode。
+// payload 由其他版本的 Definition 编码时，先解码为 model.Value 转换为 d 的结构，再转为 ptrace.Traces
+func (d *Decoder) DecodeTraces(in io.Reader) (ptrace.Traces, DecodeStats, error) {
+	if !d.directTraces {
+		value, stats, err := d.Decode(in)
+		if err != nil {
+			return ptrace.NewTraces(), stats, err
+		}
+		return ValueToTraces(value), stats, nil
+	}
+	lim := newLimiter(d.opts.limits)
+	stats := newDecodeStats()
+	td, err := d.decodeTraces(in, lim, &stats)
+	stats.DecodedSize = lim.decodedSize
+	if err != nil {
+		return ptrace.NewTraces(), stats, err
+	}
+	return td, stats, nil
+}
+
+func (d *Decoder) decodeTraces(in io.Reader, lim *limiter, stats *DecodeStats) (ptrace.Traces, error) {
+	data, err := d.readPayload(in, lim, stats)
+	if err != nil {
+		return ptrace.Traces{}, err
+	}
+	w, data, err := d.forPayload(data, lim, stats)
+	if err != nil {
+		return ptrace.Traces{}, err
+	}
+	if w != d {
+		value, err := w.decodeData(data, lim, stats)
+		if err != nil {
+			return ptrace.Traces{}, err
+		}
+		return ValueToTraces(projectValue(value, w.def, d.def)), nil
+	}
+	s, err := d.decodeHeader(data, lim, stats)
+	if err != nil {
+		return ptrace.Traces{}, err
+	}
+	td := ptrace.NewTraces()
+	return td, s.decodeTracesDirect(d.def, td)
+}
+
+// present 读取 nullable 标记，不可为 null 的字段总是存在
+func (s *decodeState) present(def *model.Definition) (bool, error) {
+	if !def.Nullable {
+		return true, nil
+	}
+	return s.reader.ReadBoolean()
+}
+
+// pooledReference 对池化的 Bytes、String、Object、Array 读取池索引，返回池中的值或内联编码的值
+func (s *decodeState) pooledReference(def *model.Definition, myName string) (model.Value, bool, error) {
+	if !def.Pooled && !def.SharePooled {
+		return nil, false, nil
+	}
+	value, err := s.readPoolReference(def, myName)
+	return value, true, err
+}
+
+func (s *decodeState) decodeIntDirect(def *model.Definition, myName string) (int, error) {
+	present, err := s.present(def)
+	if err != nil || !present {
+		return 0, err
+	}
+	intv, err := s.readInt()
+	if err != nil {
+		return 0, err
+	}
+	if def.DiffEncode {
+		if prev, exist := s.status[myName]; exist {
+			intv = intv + prev.(int)
+		}
+		s.status[myName] = intv
+	}
+	return intv, s.lim.addDecoded(8)
+}
+
+func (s *decodeState) decodeStringDirect(def *model.Definition, myName string) (string, error) {
+	present, err := s.present(def)
+	if err != nil || !present {
+		return "", err
+	}
+	value, pooled, err := s.pooledReference(def, myName)
+	if pooled {
+		if err != nil {
+			return "", err
+		}
+		strv, ok := value.(*model.StringValue)
+		if !ok {
+			return "", fmt.Errorf("valuePool of %q does not hold strings", myName)
+		}
+		return strv.Data, nil
+	}
+	len, err := s.readInt()
+	if err != nil {
+		return "", err
+	}
+	strv, err := s.reader.ReadString(len)
+	if err != nil {
+		return "", err
+	}
+	return strv, s.lim.addDecoded(len)
+}
+
+func (s *decodeState) decodeBytesDirect(def *model.Definition, myName string) ([]byte, error) {
+	present, err := s.present(def)
+	if err != nil || !present {
+		return nil, err
+	}
+	value, pooled, err := s.pooledReference(def, myName)
+	if pooled {
+		if err != nil {
+			return nil, err
+		}
+		bv, ok := value.(*model.BytesValue)
+		if !ok {
+			return nil, fmt.Errorf("valuePool of %q does not hold bytes", myName)
+		}
+		return bv.Data, nil
+	}
+	len, err := s.readBytesLength(def)
+	if err != nil {
+		return nil, err
+	}
+	bv, err := s.reader.ReadBytes(len)
+	if err != nil {
+		return nil, err
+	}
+	return bv, s.lim.addDecoded(len)
+}
+
+// skipDirect 解码 Definition 中有但 OTLP 中没有的字段，结果丢弃
+func (s *decodeState) skipDirect(def *model.Definition, myName string) error {
+	_, err := s.innerDecode(def, myName, true)
+	return err
+}
+
+func (s *decodeState) decodeAttributesDirect(def *model.Definition, myName string, dest pcommon.Map) error {
+	present, err := s.present(def)
+	if err != nil || !present {
+		return err
+	}
+	value, pooled, err := s.pooledReference(def, myName)
+	if pooled {
+		if err != nil {
+			return err
+		}
+		valueToAttributes(value, dest)
+		return nil
+	}
+	err = s.lim.enter()
+	if err != nil {
+		return err
+	}
+	err = s.freeMapDirect(dest)
+	if err != nil {
+		return err
+	}
+	s.lim.leave()
+	return nil
+}
+
+// freeMapDirect 与 innerFreeMapDecode 对应
+func (s *decodeState) freeMapDirect(dest pcommon.Map) error {
+	freeMapSize, err := s.readInt()
+	if err != nil {
+		return err
+	}
+	err = s.lim.checkArrayLength("free map", freeMapSize)
+	if err != nil {
+		return err
+	}
+	dest.EnsureCapacity(s.capacityHint(freeMapSize))
+	for i := 0; i < freeMapSize; i++ {
+		key, err := s.readStringPoolReference()
+		if err != nil {
+			return err
+		}
+		// 读取 null 标记位
+		exist, err := s.reader.ReadBoolean()
+		if err != nil {
+			return err
+		}
+		value := dest.PutEmpty(key)
+		if exist {
+			err = s.freeValueDirect(value)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// freeValueDirect 与 innerFreeValueDecode 对应
+func (s *decodeState) freeValueDirect(dest pcommon.Value) error {
+	valueTypeInt, err := s.readInt()
+	if err != nil {
+		return err
+	}
+	reader := s.reader
+	lim := s.lim
+	switch model.ValueType(valueTypeInt) {
+	case model.Integer:
+		intv, err := s.readInt()
+		if err != nil {
+			return err
+		}
+		dest.SetInt(int64(intv))
+		return lim.addDecoded(8)
+	case model.Boolean:
+		boolv, err := reader.ReadBoolean()
+		if err != nil {
+			return err
+		}
+		dest.SetBool(boolv)
+		return lim.addDecoded(1)
+	case model.Double:
+		dbv, err := reader.ReadFloat()
+		if err != nil {
+			return err
+		}
+		dest.SetDouble(dbv)
+		return lim.addDecoded(8)
+	case model.Bytes:
+		len, err := s.readInt()
+		if err != nil {
+			return err
+		}
+		bv, err := reader.ReadBytes(len)
+		if err != nil {
+			return err
+		}
+		dest.SetEmptyBytes().FromRaw(bv)
+		return lim.addDecoded(len)
+	case model.String:
+		if !s.opts.stringPoolEnabled {
+			len, err := s.readInt()
+			if err != nil {
+				return err
+			}
+			strv, err := reader.ReadString(len)
+			if err != nil {
+				return err
+			}
+			dest.SetStr(strv)
+			return lim.addDecoded(len)
+		}
+		strv, err := s.readStringPoolReference()
+		if err != nil {
+			return err
+		}
+		dest.SetStr(strv)
+		return nil
+	case model.Object:
+		err := lim.enter()
+		if err != nil {
+			return err
+		}
+		err = s.freeMapDirect(dest.SetEmptyMap())
+		if err != nil {
+			return err
+		}
+		lim.leave()
+		return nil
+	case model.Array:
+		err := lim.enter()
+		if err != nil {
+			return err
+		}
+		len, err := s.readInt()
+		if err != nil {
+			return err
+		}
+		err = lim.checkArrayLength("free array", len)
+		if err != nil {
+			return err
+		}
+		slice := dest.SetEmptySlice()
+		for i := 0; i < len; i++ {
+			err = s.freeValueDirect(slice.AppendEmpty())
+			if err != nil {
+				return err
+			}
+		}
+		lim.leave()
+		return nil
+	default:
+		return fmt.Errorf("unknown value type in free value: %d", valueTypeInt)
+	}
+}
+
+// beginArrayDirect 读取数组的 nullable 标记和长度，池化的数组返回池中的值，由调用方转化
+func (s *decodeState) beginArrayDirect(def *model.Definition, myName string) (length int, pooledValue model.Value, done bool, err error) {
+	present, err := s.present(def)
+	if err != nil || !present {
+		return 0, nil, true, err
+	}
+	value, pooled, err := s.pooledReference(def, myName)
+	if pooled {
+		return 0, value, true, err
+	}
+	err = s.lim.enter()
+	if err != nil {
+		return 0, nil, true, err
+	}
+	length, err = s.readInt()
+	if err != nil {
+		return 0, nil, true, err
+	}
+	err = s.lim.checkArrayLength(myName, length)
+	if err != nil {
+		return 0, nil, true, err
+	}
+	return length, nil, false, nil
+}
+
+// capacityHint 返回预分配的容量，长度超过剩余数据时不预分配，避免按恶意的长度分配内存
+func (s *decodeState) capacityHint(length int) int {
+	if length > s.reader.Len() {
+		return 0
+	}
+	return length
+}
+
+// beginObjectDirect 读取对象的 nullable 标记，池化的对象返回池中的值，由调用方转化
+func (s *decodeState) beginObjectDirect(def *model.Definition, myName string) (pooledValue model.Value, done bool, err error) {
+	present, err := s.present(def)
+	if err != nil || !present {
+		return nil, true, err
+	}
+	value, pooled, err := s.pooledReference(def, myName)
+	if pooled {
+		return value, true, err
+	}
+	return nil, false, s.lim.enter()
+}
+
+func (s *decodeState) decodeTracesDirect(def *model.Definition, td ptrace.Traces) error {
+	pooledValue, done, err := s.beginObjectDirect(def, "")
+	if done {
+		if err == nil && pooledValue != nil {
+			if resourceSpansVal, ok := pooledValue.(*model.ObjectValue).Data["resourceSpans"]; ok {
+				valueToResourceSpansSlice(resourceSpansVal, td.ResourceSpans())
+			}
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	for _, fieldName := range s.sortedKeys[def] {
+		fieldDef := def.Fields[fieldName]
+		fieldMyName := s.childNames.child("", fieldName)
+		switch fieldName {
+		case "resourceSpans":
+			err = s.decodeResourceSpansSliceDirect(fieldDef, fieldMyName, td.ResourceSpans())
+		default:
+			err = s.skipDirect(fieldDef, fieldMyName)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	s.lim.leave()
+	return nil
+}
+
+func (s *decodeState) decodeResourceSpansSliceDirect(def *model.Definition, myName string, dest ptrace.ResourceSpansSlice) error {
+	length, pooledValue, done, err := s.beginArrayDirect(def, myName)
+	if done {
+		if err == nil && pooledValue != nil {
+			valueToResourceSpansSlice(pooledValue, dest)
+		}
+		return err
+	}
+	dest.EnsureCapacity(s.capacityHint(length))
+	itemName := s.childNames.child(myName, "item")
+	for i := 0; i < length; i++ {
+		err = s.decodeResourceSpansDirect(def.ItemDefinition, itemName, dest.AppendEmpty())
+		if err != nil {
+			return err
+		}
+	}
+	s.lim.leave()
+	return nil
+}
+
+func (s *decodeState) decodeResourceSpansDirect(def *model.Definition, myName string, dest ptrace.ResourceSpans) error {
+	pooledValue, done, err := s.beginObjectDirect(def, myName)
+	if done {
+		if err == nil && pooledValue != nil {
+			valueToResourceSpans(pooledValue, dest)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	for _, fieldName := range s.sortedKeys[def] {
+		fieldDef := def.Fields[fieldName]
+		fieldMyName := s.childNames.child(myName, fieldName)
+		switch fieldName {
+		case "resource":
+			err = s.decodeResourceDirect(fieldDef, fieldMyName, dest.Resource())
+		case "scopeSpans":
+			err = s.decodeScopeSpansSliceDirect(fieldDef, fieldMyName, dest.ScopeSpans())
+		case "schemaUrl":
+			var strv string
+			strv, err = s.decodeStringDirect(fieldDef, fieldMyName)
+			dest.SetSchemaUrl(strv)
+		default:
+			err = s.skipDirect(fieldDef, fieldMyName)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	s.lim.leave()
+	return nil
+}
+
+func (s *decodeState) decodeResourceDirect(def *model.Definition, myName string, dest pcommon.Resource) error {
+	pooledValue, done, err := s.beginObjectDirect(def, myName)
+	if done {
+		if err == nil && pooledValue != nil {
+			valueToResource(pooledValue, dest)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	for _, fieldName := range s.sortedKeys[def] {
+		fieldDef := def.Fields[fieldName]
+		fieldMyName := s.childNames.child(myName, fieldName)
+		switch fieldName {
+		case "attributes":
+			err = s.decodeAttributesDirect(fieldDef, fieldMyName, dest.Attributes())
+		case "droppedAttributesCount":
+			var intv int
+			intv, err = s.decodeIntDirect(fieldDef, fieldMyName)
+			dest.SetDroppedAttributesCount(uint32(intv))
+		default:
+			err = s.skipDirect(fieldDef, fieldMyName)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	s.lim.leave()
+	return nil
+}
+
+func (s *decodeState) decodeScopeSpansSliceDirect(def *model.Definition, myName string, dest ptrace.ScopeSpansSlice) error {
+	length, pooledValue, done, err := s.beginArrayDirect(def, myName)
+	if done {
+		if err == nil && pooledValue != nil {
+			valueToScopeSpansSlice(pooledValue, dest)
+		}
+		return err
+	}
+	dest.EnsureCapacity(s.capacityHint(length))
+	itemName := s.childNames.child(myName, "item")
+	for i := 0; i < length; i++ {
+		err = s.decodeScopeSpansDirect(def.ItemDefinition, itemName, dest.AppendEmpty())
+		if err != nil {
+			return err
+		}
+	}
+	s.lim.leave()
+	return nil
+}
+
+func (s *decodeState) decodeScopeSpansDirect(def *model.Definition, myName string, dest ptrace.ScopeSpans) error {
+	pooledValue, done, err := s.beginObjectDirect(def, myName)
+	if done {
+		if err == nil && pooledValue != nil {
+			valueToScopeSpans(pooledValue, dest)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	for _, fieldName := range s.sortedKeys[def] {
+		fieldDef := def.Fields[fieldName]
+		fieldMyName := s.childNames.child(myName, fieldName)
+		switch fieldName {
+		case "scope":
+			err = s.decodeScopeDirect(fieldDef, fieldMyName, dest.Scope())
+		case "spans":
+			err = s.decodeSpanSliceDirect(fieldDef, fieldMyName, dest.Spans())
+		case "schemaUrl":
+			var strv string
+			strv, err = s.decodeStringDirect(fieldDef, fieldMyName)
+			dest.SetSchemaUrl(strv)
+		default:
+			err = s.skipDirect(fieldDef, fieldMyName)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	s.lim.leave()
+	return nil
+}
+
+func (s *decodeState) decodeScopeDirect(def *model.Definition, myName string, dest pcommon.InstrumentationScope) error {
+	pooledValue, done, err := s.beginObjectDirect(def, myName)
+	if done {
+		if err == nil && pooledValue != nil {
+			valueToScope(pooledValue, dest)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	for _, fieldName := range s.sortedKeys[def] {
+		fieldDef := def.Fields[fieldName]
+		fieldMyName := s.childNames.child(myName, fieldName)
+		switch fieldName {
+		case "name":
+			var strv string
+			strv, err = s.decodeStringDirect(fieldDef, fieldMyName)
+			dest.SetName(strv)
+		case "version":
+			var strv string
+			strv, err = s.decodeStringDirect(fieldDef, fieldMyName)
+			dest.SetVersion(strv)
+		case "attributes":
+			err = s.decodeAttributesDirect(fieldDef, fieldMyName, dest.Attributes())
+		case "droppedAttributesCount":
+			var intv int
+			intv, err = s.decodeIntDirect(fieldDef, fieldMyName)
+			dest.SetDroppedAttributesCount(uint32(intv))
+		default:
+			err = s.skipDirect(fieldDef, fieldMyName)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	s.lim.leave()
+	return nil
+}
+
+func (s *decodeState) decodeSpanSliceDirect(def *model.Definition, myName string, dest ptrace.SpanSlice) error {
+	length, pooledValue, done, err := s.beginArrayDirect(def, myName)
+	if done {
+		if err == nil && pooledValue != nil {
+			valueToSpanSlice(pooledValue, dest)
+		}
+		return err
+	}
+	dest.EnsureCapacity(s.capacityHint(length))
+	itemName := s.childNames.child(myName, "item")
+	if s.groups.isSpans(def) {
+		err = s.decodeTraceGroupsDirect(def, itemName, length, dest)
+		if err != nil {
+			return err
+		}
+		s.lim.leave()
+		return nil
+	}
+	for i := 0; i < length; i++ {
+		err = s.decodeSpanDirect(def.ItemDefinition, itemName, dest.AppendEmpty())
+		if err != nil {
+			return err
+		}
+	}
+	s.lim.leave()
+	return nil
+}
+
+func (s *decodeState) decodeSpanDirect(def *model.Definition, myName string, dest ptrace.Span) error {
+	pooledValue, done, err := s.beginObjectDirect(def, myName)
+	if done {
+		if err == nil && pooledValue != nil {
+			valueToSpan(pooledValue, dest)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	// traceId、spanId 和 parentSpanId 的回引用用于还原 parentSpanId，与 Decode 一样以解码出的 bytes 为准
+	var traceId, spanId []byte
+	distance := 0
+	for _, fieldName := range s.sortedKeys[def] {
+		fieldDef := def.Fields[fieldName]
+		fieldMyName := s.childNames.child(myName, fieldName)
+		var intv int
+		var strv string
+		var bv []byte
+		switch fieldName {
+		case "traceId":
+			if s.groups.isTraceId(def, fieldName) {
+				traceId = bytesOf(s.traceGroup)
+			} else {
+				traceId, err = s.decodeBytesDirect(fieldDef, fieldMyName)
+			}
+			dest.SetTraceID(traceIDOf(traceId))
+		case "spanId":
+			spanId, err = s.decodeBytesDirect(fieldDef, fieldMyName)
+			dest.SetSpanID(spanIDOf(spanId))
+		case "traceState":
+			strv, err = s.decodeStringDirect(fieldDef, fieldMyName)
+			dest.TraceState().FromRaw(strv)
+		case "parentSpanId":
+			if s.tree.isParent(def, fieldName) {
+				distance, err = s.readInt()
+				if err != nil || distance != 0 {
+					break
+				}
+			}
+			bv, err = s.decodeBytesDirect(fieldDef, fieldMyName)
+			dest.SetParentSpanID(spanIDOf(bv))
+		case "name":
+			strv, err = s.decodeStringDirect(fieldDef, fieldMyName)
+			dest.SetName(strv)
+		case "kind":
+			intv, err = s.decodeIntDirect(fieldDef, fieldMyName)
+			dest.SetKind(ptrace.SpanKind(intv))
+		case "startTimeUnixNano":
+			intv, err = s.decodeIntDirect(fieldDef, fieldMyName)
+			dest.SetStartTimestamp(pcommon.Timestamp(intv))
+		case "endTimeUnixNano":
+			intv, err = s.decodeIntDirect(fieldDef, fieldMyName)
+			dest.SetEndTimestamp(pcommon.Timestamp(intv))
+		case "attributes":
+			err = s.decodeAttributesDirect(fieldDef, fieldMyName, dest.Attributes())
+		case "droppedAttributesCount":
+			intv, err = s.decodeIntDirect(fieldDef, fieldMyName)
+			dest.SetDroppedAttributesCount(uint32(intv))
+		case "events":
+			err = s.decodeSpanEventSliceDirect(fieldDef, fieldMyName, dest.Events())
+		case "droppedEventsCount":
+			intv, err = s.decodeIntDirect(fieldDef, fieldMyName)
+			dest.SetDroppedEventsCount(uint32(intv))
+		case "links":
+			err = s.decodeSpanLinkSliceDirect(fieldDef, fieldMyName, dest.Links())
+		case "droppedLinksCount":
+			intv, err = s.decodeIntDirect(fieldDef, fieldMyName)
+			dest.SetDroppedLinksCount(uint32(intv))
+		case "status":
+			err = s.decodeStatusDirect(fieldDef, fieldMyName, dest.Status())
+		default:
+			err = s.skipDirect(fieldDef, fieldMyName)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if s.tree.isSpan(def) {
+		if distance != 0 {
+			parentSpanId, err := s.spans.resolve(traceId, distance)
+			if err != nil {
+				return err
+			}
+			dest.SetParentSpanID(spanIDOf(parentSpanId))
+		}
+		s.spans.add(traceId, spanId)
+	}
+	s.lim.leave()
+	return nil
+}
+
+func (s *decodeState) decodeSpanEventSliceDirect(def *model.Definition, myName string, dest ptrace.SpanEventSlice) error {
+	length, pooledValue, done, err := s.beginArrayDirect(def, myName)
+	if done {
+		if err == nil && pooledValue != nil {
+			valueToSpanEventSlice(pooledValue, dest)
+		}
+		return err
+	}
+	dest.EnsureCapacity(s.capacityHint(length))
+	itemName := s.childNames.child(myName, "item")
+	for i := 0; i < length; i++ {
+		err = s.decodeSpanEventDirect(def.ItemDefinition, itemName, dest.AppendEmpty())
+		if err != nil {
+			return err
+		}
+	}
+	s.lim.leave()
+	return nil
+}
+
+func (s *decodeState) decodeSpanEventDirect(def *model.Definition, myName string, dest ptrace.SpanEvent) error {
+	pooledValue, done, err := s.beginObjectDirect(def, myName)
+	if done {
+		if err == nil && pooledValue != nil {
+			valueToSpanEvent(pooledValue, dest)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	for _, fieldName := range s.sortedKeys[def] {
+		fieldDef := def.Fields[fieldName]
+		fieldMyName := s.childNames.child(myName, fieldName)
+		var intv int
+		var strv string
+		switch fieldName {
+		case "timeUnixNano":
+			intv, err = s.decodeIntDirect(fieldDef, fieldMyName)
+			dest.SetTimestamp(pcommon.Timestamp(intv))
+		case "name":
+			strv, err = s.decodeStringDirect(fieldDef, fieldMyName)
+			dest.SetName(strv)
+		case "attributes":
+			err = s.decodeAttributesDirect(fieldDef, fieldMyName, dest.Attributes())
+		case "droppedAttributesCount":
+			intv, err = s.decodeIntDirect(fieldDef, fieldMyName)
+			dest.SetDroppedAttributesCount(uint32(intv))
+		default:
+			err = s.skipDirect(fieldDef, fieldMyName)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	s.lim.leave()
+	return nil
+}
+
+func (s *decodeState) decodeSpanLinkSliceDirect(def *model.Definition, myName string, dest ptrace.SpanLinkSlice) error {
+	length, pooledValue, done, err := s.beginArrayDirect(def, myName)
+	if done {
+		if err == nil && pooledValue != nil {
+			valueToSpanLinkSlice(pooledValue, dest)
+		}
+		return err
+	}
+	dest.EnsureCapacity(s.capacityHint(length))
+	itemName := s.childNames.child(myName, "item")
+	for i := 0; i < length; i++ {
+		err = s.decodeSpanLinkDirect(def.ItemDefinition, itemName, dest.AppendEmpty())
+		if err != nil {
+			return err
+		}
+	}
+	s.lim.leave()
+	return nil
+}
+
+func (s *decodeState) decodeSpanLinkDirect(def *model.Definition, myName string, dest ptrace.SpanLink) error {
+	pooledValue, done, err := s.beginObjectDirect(def, myName)
+	if done {
+		if err == nil && pooledValue != nil {
+			valueToSpanLink(pooledValue, dest)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	for _, fieldName := range s.sortedKeys[def] {
+		fieldDef := def.Fields[fieldName]
+		fieldMyName := s.childNames.child(myName, fieldName)
+		var intv int
+		var strv string
+		var bv []byte
+		switch fieldName {
+		case "traceId":
+			bv, err = s.decodeBytesDirect(fieldDef, fieldMyName)
+			dest.SetTraceID(traceIDOf(bv))
+		case "spanId":
+			bv, err = s.decodeBytesDirect(fieldDef, fieldMyName)
+			dest.SetSpanID(spanIDOf(bv))
+		case "traceState":
+			strv, err = s.decodeStringDirect(fieldDef, fieldMyName)
+			dest.TraceState().FromRaw(strv)
+		case "attributes":
+			err = s.decodeAttributesDirect(fieldDef, fieldMyName, dest.Attributes())
+		case "droppedAttributesCount":
+			intv, err = s.decodeIntDirect(fieldDef, fieldMyName)
+			dest.SetDroppedAttributesCount(uint32(intv))
+		default:
+			err = s.skipDirect(fieldDef, fieldMyName)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	s.lim.leave()
+	return nil
+}
+
+func (s *decodeState) decodeStatusDirect(def *model.Definition, myName string, dest ptrace.Status) error {
+	pooledValue, done, err := s.beginObjectDirect(def, myName)
+	if done {
+		if err == nil && pooledValue != nil {
+			valueToStatus(pooledValue, dest)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	for _, fieldName := range s.sortedKeys[def] {
+		fieldDef := def.Fields[fieldName]
+		fieldMyName := s.childNames.child(myName, fieldName)
+		var intv int
+		var strv string
+		switch fieldName {
+		case "message":
+			strv, err = s.decodeStringDirect(fieldDef, fieldMyName)
+			dest.SetMessage(strv)
+		case "code":
+			intv, err = s.decodeIntDirect(fieldDef, fieldMyName)
+			dest.SetCode(ptrace.StatusCode(intv))
+		default:
+			err = s.skipDirect(fieldDef, fieldMyName)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	s.lim.leave()
+	return nil
+}
@@ -0,0 +1,147 @@
+package codec
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/beet233/compressotelcollector/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+func TestDecodeTracesMatchesDecode(t *testing.T) {
+	traceModel, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(t, err)
+
+	// Definition 中多一个 OTLP 没有的字段，直接解码时跳过
+	withExtraField, err := model.GetDefinitionFromJSON(defaultTraceModelJSON(t))
+	require.NoError(t, err)
+	spanDef := withExtraField.Fields["resourceSpans"].ItemDefinition.Fields["scopeSpans"].ItemDefinition.Fields["spans"].ItemDefinition
+	spanDef.Fields["extra"] = &model.Definition{Type: model.String, Nullable: true}
+
+	// attributes 带有 Fields 时不能直接解码，退回 Value 路径
+	withDefinedAttributes, err := model.GetDefinitionFromJSON(defaultTraceModelJSON(t))
+	require.NoError(t, err)
+	withDefinedAttributes.Fields["resourceSpans"].ItemDefinition.Fields["resource"].Fields["attributes"] = &model.Definition{
+		Type: model.Object, Nullable: true, Fields: map[string]*model.Definition{
+			"service.name": {Type: model.String, Nullable: true, Pooled: true},
+		}}
+
+	defs := []struct {
+		name   string
+		def    *model.Definition
+		direct bool
+		// attributes 只保留定义过的 key 时和原始数据不一致
+		lossless bool
+	}{
+		{name: "trace model", def: traceModel, direct: true, lossless: true},
+		{name: "extra field", def: withExtraField, direct: true, lossless: true},
+		{name: "defined attributes", def: withDefinedAttributes, direct: false},
+	}
+	optionSets := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "default"},
+		{name: "fixed int", opts: []Option{WithLeb128(false)}},
+		{name: "no string pool", opts: []Option{WithStringPool(false)}},
+		{name: "pool limits", opts: []Option{WithPoolLimits(PoolLimits{Default: 3, Pools: map[string]int{"traceId": 1}})}},
+		{name: "adaptive pooling", opts: []Option{WithAdaptivePooling(true)}},
+	}
+	inputs := map[string]ptrace.Traces{
+		"empty":     ptrace.NewTraces(),
+		"rich":      newRichTraces(30),
+		"load test": newLoadTestTraces(3),
+	}
+	marshaler := &ptrace.ProtoMarshaler{}
+	for _, d := range defs {
+		for _, o := range optionSets {
+			encoder := NewEncoder(d.def, o.opts...)
+			decoder := NewDecoder(d.def, o.opts...)
+			assert.Equal(t, d.direct, decoder.directTraces)
+			for inputName, td := range inputs {
+				t.Run(d.name+"/"+o.name+"/"+inputName, func(t *testing.T) {
+					var encoded bytes.Buffer
+					_, err := encoder.EncodeTraces(td, &encoded)
+					require.NoError(t, err)
+
+					value, expectedStats, err := decoder.Decode(bytes.NewReader(encoded.Bytes()))
+					require.NoError(t, err)
+					got, gotStats, err := decoder.DecodeTraces(bytes.NewReader(encoded.Bytes()))
+					require.NoError(t, err)
+					assert.Equal(t, expectedStats, gotStats)
+
+					expected, err := marshaler.MarshalTraces(ValueToTraces(value))
+					require.NoError(t, err)
+					actual, err := marshaler.MarshalTraces(got)
+					require.NoError(t, err)
+					assert.Equal(t, expected, actual)
+
+					if d.lossless {
+						assert.Equal(t, 0, model.ValueComparator(TracesToValue(td), TracesToValue(got)))
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestDecodeTracesErrorsMatchDecode(t *testing.T) {
+	def, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(t, err)
+	var encoded bytes.Buffer
+	_, err = NewEncoder(def).EncodeTraces(newRichTraces(10), &encoded)
+	require.NoError(t, err)
+
+	decoder := NewDecoder(def, WithLimits(DecodeLimits{MaxDecodedSize: 1024}))
+	_, expectedStats, expectedErr := decoder.Decode(bytes.NewReader(encoded.Bytes()))
+	require.ErrorIs(t, expectedErr, ErrPayloadTooLarge)
+	td, gotStats, err := decoder.DecodeTraces(bytes.NewReader(encoded.Bytes()))
+	assert.Equal(t, expectedErr, err)
+	assert.Equal(t, expectedStats, gotStats)
+	assert.Equal(t, 0, td.SpanCount())
+
+	// 截断的数据在两条路径上都应该报错
+	truncated := encoded.Bytes()[:encoded.Len()-5]
+	_, _, expectedErr = NewDecoder(def).Decode(bytes.NewReader(truncated))
+	require.Error(t, expectedErr)
+	_, _, err = NewDecoder(def).DecodeTraces(bytes.NewReader(truncated))
+	assert.Equal(t, expectedErr, err)
+}
+
+func BenchmarkDecodeValue(b *testing.B) {
+	def, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(b, err)
+	var encoded bytes.Buffer
+	_, err = NewEncoder(def).EncodeTraces(newRichTraces(200), &encoded)
+	require.NoError(b, err)
+	decoder := NewDecoder(def)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		value, _, err := decoder.Decode(bytes.NewReader(encoded.Bytes()))
+		if err != nil {
+			b.Fatal(err)
+		}
+		ValueToTraces(value)
+	}
+}
+
+func BenchmarkDecodeTraces(b *testing.B) {
+	def, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(b, err)
+	var encoded bytes.Buffer
+	_, err = NewEncoder(def).EncodeTraces(newRichTraces(200), &encoded)
+	require.NoError(b, err)
+	decoder := NewDecoder(def)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _, err := decoder.DecodeTraces(bytes.NewReader(encoded.Bytes()))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,806 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sort"
+
+	"github.com/beet233/compressotelcollector/model"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// ptraceSchema 描述 ptrace 直接编码认识的 OTLP 字段，Definition 中同名字段的类型需要与之一致
+type ptraceSchema struct {
+	typ    model.ValueType
+	fields map[string]*ptraceSchema
+	item   *ptraceSchema
+	// free 表示 attributes，Definition 中必须是没有 Fields 的自由 map
+	free bool
+}
+
+var (
+	integerSchema    = &ptraceSchema{typ: model.Integer}
+	stringSchema     = &ptraceSchema{typ: model.String}
+	bytesSchema      = &ptraceSchema{typ: model.Bytes}
+	attributesSchema = &ptraceSchema{typ: model.Object, free: true}
+
+	tracesSchema = &ptraceSchema{typ: model.Object, fields: map[string]*ptraceSchema{
+		"resourceSpans": {typ: model.Array, item: &ptraceSchema{typ: model.Object, fields: map[string]*ptraceSchema{
+			"resource": {typ: model.Object, fields: map[string]*ptraceSchema{
+				"attributes":             attributesSchema,
+				"droppedAttributesCount": integerSchema,
+			}},
+			"scopeSpans": {typ: model.Array, item: &ptraceSchema{typ: model.Object, fields: map[string]*ptraceSchema{
+				"scope": {typ: model.Object, fields: map[string]*ptraceSchema{
+					"name":                   stringSchema,
+					"version":                stringSchema,
+					"attributes":             attributesSchema,
+					"droppedAttributesCount": integerSchema,
+				}},
+				"spans": {typ: model.Array, item: &ptraceSchema{typ: model.Object, fields: map[string]*ptraceSchema{
+					"traceId":                bytesSchema,
+					"spanId":                 bytesSchema,
+					"traceState":             stringSchema,
+					"parentSpanId":           bytesSchema,
+					"name":                   stringSchema,
+					"kind":                   integerSchema,
+					"startTimeUnixNano":      integerSchema,
+					"endTimeUnixNano":        integerSchema,
+					"attributes":             attributesSchema,
+					"droppedAttributesCount": integerSchema,
+					"events": {typ: model.Array, item: &ptraceSchema{typ: model.Object, fields: map[string]*ptraceSchema{
+						"timeUnixNano":           integerSchema,
+						"name":                   stringSchema,
+						"attributes":             attributesSchema,
+						"droppedAttributesCount": integerSchema,
+					}}},
+					"droppedEventsCount": integerSchema,
+					"links": {typ: model.Array, item: &ptraceSchema{typ: model.Object, fields: map[string]*ptraceSchema{
+						"traceId":                bytesSchema,
+						"spanId":                 bytesSchema,
+						"traceState":             stringSchema,
+						"attributes":             attributesSchema,
+						"droppedAttributesCount": integerSchema,
+					}}},
+					"droppedLinksCount": integerSchema,
+					"status": {typ: model.Object, fields: map[string]*ptraceSchema{
+						"message": stringSchema,
+						"code":    integerSchema,
+					}},
+				}}},
+				"schemaUrl": stringSchema,
+			}}},
+			"schemaUrl": stringSchema,
+		}}},
+	}}
+)
+
+// supportsDirect 判断 def 能否由 ptrace 直接编码，Definition 中多出的字段按 nil 处理，类型不一致时不支持
+func (schema *ptraceSchema) supportsDirect(def *model.Definition) bool {
+	if def == nil || def.Type != schema.typ {
+		return false
+	}
+	switch schema.typ {
+	case model.Object:
+		if schema.free {
+			return def.Fields == nil
+		}
+		if def.Fields == nil {
+			return false
+		}
+		for fieldName, fieldDef := range def.Fields {
+			if fieldSchema, ok := schema.fields[fieldName]; ok && !fieldSchema.supportsDirect(fieldDef) {
+				return false
+			}
+		}
+	case model.Array:
+		return schema.item.supportsDirect(def.ItemDefinition)
+	}
+	return true
+}
+
+// EncodeTraces 将 ptrace.Traces 编码为 cprval，结果和 Encode(TracesToValue(td)) 逐字节一致。
+// 按 Definition 直接遍历 td，不构造 model.Value 树；Definition 与 OTLP 的结构不一致时退回 Encode
+func (e *Encoder) EncodeTraces(td ptrace.Traces, out io.Writer) (EncodeStats, error) {
+	if !e.directTraces {
+		return e.Encode(TracesToValue(td), out)
+	}
+	return e.encodeAdaptive(func(state *encodeState, buf *bytes.Buffer) error {
+		return e.encodeTracesDirect(state, e.def, td, buf)
+	}, out)
+}
+
+// childName 和 innerEncode 一样拼接 valuePools 的 key
+func childName(myName string, fieldName string) string {
+	if len(myName) > 0 {
+		return myName + " " + fieldName
+	}
+	return fieldName
+}
+
+// childNames 是预先按 Definition 拼接好的 childName，key 为 myName 和 fieldName。
+// 直接编解码 ptrace 时每个 span 的每个 field 都要取一次，拼接的分配占了大部分
+type childNames map[[2]string]string
+
+func planChildNames(def *model.Definition, myName string, names childNames) {
+	switch def.Type {
+	case model.Object:
+		for fieldName, fieldDef := range def.Fields {
+			name := childName(myName, fieldName)
+			names[[2]string{myName, fieldName}] = name
+			planChildNames(fieldDef, name, names)
+		}
+	case model.Array:
+		name := childName(myName, "item")
+		names[[2]string{myName, "item"}] = name
+		planChildNames(def.ItemDefinition, name, names)
+	}
+}
+
+// child 返回 childName(myName, fieldName)，不在 Definition 中的名字临时拼接
+func (names childNames) child(myName string, fieldName string) string {
+	if name, exist := names[[2]string{myName, fieldName}]; exist {
+		return name
+	}
+	return childName(myName, fieldName)
+}
+
+// beginDirect 写入 nullable 标记，返回值应该编码进的 buffer。
+// null 时 done 为 true；池化时返回从 bufferPool 取出的 tmp，编码完成后交给 endDirect
+func (e *Encoder) beginDirect(state *encodeState, def *model.Definition, typ model.ValueType, isNull bool, buf *bytes.Buffer) (target *bytes.Buffer, tmp *bytes.Buffer, done bool, err error) {
+	if def.Nullable {
+		if isNull {
+			return nil, nil, true, WriteBoolean(buf, false)
+		}
+		err = WriteBoolean(buf, true)
+		if err != nil {
+			return nil, nil, true, err
+		}
+	}
+	if def.Type != typ {
+		return nil, nil, true, errors.New(typeConflictErrMsg)
+	}
+	if def.Pooled || def.SharePooled {
+		// 记录编码 tmp 之前的内联编码数和索引数，tmp 和池中的值重复而被丢弃时撤销其中的记录
+		state.marks = append(state.marks, encodeMark{overflows: len(state.overflowLog), references: len(state.references)})
+		tmp = e.bufferPool.Get().(*bytes.Buffer)
+		return tmp, tmp, false, nil
+	}
+	return buf, nil, false, nil
+}
+
+// endDirect 把池化值的编码结果 tmp 入池并写入索引，编码相同的值共用一个索引，池子已满时内联写入 tmp
+// 与 Value 路径的 HashMap 不同的是这里按编码结果判断相等，两者只在 attributes 含有 NaN 时有区别
+func (e *Encoder) endDirect(state *encodeState, def *model.Definition, myName string, tmp *bytes.Buffer, buf *bytes.Buffer) error {
+	if tmp == nil {
+		return nil
+	}
+	poolId := poolIdOf(def, myName)
+	mark := state.marks[len(state.marks)-1]
+	state.marks = state.marks[:len(state.marks)-1]
+	pool, ok := state.pooledBytes[poolId]
+	if !ok {
+		pool = make(map[string]int)
+		state.pooledBytes[poolId] = pool
+	}
+	index, exist := pool[string(tmp.Bytes())]
+	if exist {
+		state.overflowLog = state.overflowLog[:mark.overflows]
+		state.references = state.references[:mark.references]
+		tmp.Reset()
+		e.bufferPool.Put(tmp)
+	} else if e.poolFull(state, poolId, len(pool)) {
+		// 池子已满，不入池，内联编码
+		err := e.writeInline(state, poolId, buf)
+		if err == nil {
+			_, err = buf.Write(tmp.Bytes())
+		}
+		tmp.Reset()
+		e.bufferPool.Put(tmp)
+		return err
+	} else {
+		index = len(pool)
+		pool[string(tmp.Bytes())] = index
+		if _, ok := state.valueEncodePools[poolId]; !ok {
+			state.valueEncodePools[poolId] = make(map[int]*bytes.Buffer)
+		}
+		state.valueEncodePools[poolId][index] = tmp
+	}
+	return e.writeReference(state, poolId, index, buf)
+}
+
+// encodeNilDirect 处理 Definition 中有但 OTLP 中没有的字段
+func (e *Encoder) encodeNilDirect(def *model.Definition, buf *bytes.Buffer) error {
+	if def.Nullable {
+		return WriteBoolean(buf, false)
+	}
+	return errors.New(notNullableErrMsg)
+}
+
+func (e *Encoder) encodeIntDirect(state *encodeState, def *model.Definition, myName string, intv int, buf *bytes.Buffer) error {
+	if def.Nullable {
+		if intv == 0 {
+			return WriteBoolean(buf, false)
+		}
+		err := WriteBoolean(buf, true)
+		if err != nil {
+			return err
+		}
+	}
+	if def.Type != model.Integer {
+		return errors.New(typeConflictErrMsg)
+	}
+	if def.DiffEncode {
+		prev, exist := state.status[myName]
+		state.status[myName] = intv
+		if exist {
+			intv -= prev.(int)
+		}
+	}
+	return e.encodeInt(intv, buf)
+}
+
+func (e *Encoder) encodeStringDirect(state *encodeState, def *model.Definition, myName string, strv string, buf *bytes.Buffer) error {
+	target, tmp, done, err := e.beginDirect(state, def, model.String, len(strv) == 0, buf)
+	if done {
+		return err
+	}
+	err = e.encodeInt(len(strv), target)
+	if err != nil {
+		return err
+	}
+	err = WriteString(target, strv)
+	if err != nil {
+		return err
+	}
+	return e.endDirect(state, def, myName, tmp, buf)
+}
+
+func (e *Encoder) encodeBytesDirect(state *encodeState, def *model.Definition, myName string, bv []byte, buf *bytes.Buffer) error {
+	target, tmp, done, err := e.beginDirect(state, def, model.Bytes, len(bv) == 0, buf)
+	if done {
+		return err
+	}
+	if def.FixedLength > 0 {
+		if len(bv) != def.FixedLength {
+			return errors.New(fixedLengthErrMsg)
+		}
+	} else {
+		err = e.encodeInt(len(bv), target)
+		if err != nil {
+			return err
+		}
+	}
+	err = WriteBytes(target, bv)
+	if err != nil {
+		return err
+	}
+	return e.endDirect(state, def, myName, tmp, buf)
+}
+
+func (e *Encoder) encodeAttributesDirect(state *encodeState, def *model.Definition, myName string, attrs pcommon.Map, buf *bytes.Buffer) error {
+	target, tmp, done, err := e.beginDirect(state, def, model.Object, attrs.Len() == 0, buf)
+	if done {
+		return err
+	}
+	err = e.freeMapDirect(state, attrs, target)
+	if err != nil {
+		return err
+	}
+	return e.endDirect(state, def, myName, tmp, buf)
+}
+
+type mapEntry struct {
+	key   string
+	value pcommon.Value
+}
+
+// sortedMapEntries 按 key 的字典序返回 m 中的键值对，重复的 key 和 AsRaw 一样以最后一个为准
+func sortedMapEntries(m pcommon.Map) []mapEntry {
+	entries := make([]mapEntry, 0, m.Len())
+	m.Range(func(k string, v pcommon.Value) bool {
+		entries = append(entries, mapEntry{key: k, value: v})
+		return true
+	})
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+	j := 0
+	for i := range entries {
+		if j > 0 && entries[j-1].key == entries[i].key {
+			entries[j-1] = entries[i]
+		} else {
+			entries[j] = entries[i]
+			j++
+		}
+	}
+	return entries[:j]
+}
+
+// freeValueType 返回 pcommon.Value 对应的 model.ValueType，Empty 对应 nil
+func freeValueType(v pcommon.Value) (model.ValueType, bool) {
+	switch v.Type() {
+	case pcommon.ValueTypeStr:
+		return model.String, true
+	case pcommon.ValueTypeInt:
+		return model.Integer, true
+	case pcommon.ValueTypeDouble:
+		return model.Double, true
+	case pcommon.ValueTypeBool:
+		return model.Boolean, true
+	case pcommon.ValueTypeMap:
+		return model.Object, true
+	case pcommon.ValueTypeSlice:
+		return model.Array, true
+	case pcommon.ValueTypeBytes:
+		return model.Bytes, true
+	}
+	return 0, false
+}
+
+// freeMapDirect 与 innerFreeMapEncode 对应
+func (e *Encoder) freeMapDirect(state *encodeState, m pcommon.Map, buf *bytes.Buffer) error {
+	entries := sortedMapEntries(m)
+	err := e.encodeInt(len(entries), buf)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if _, exist := state.stringPool[entry.key]; !exist {
+			state.stringPool[entry.key] = len(state.stringPool)
+		}
+		err := e.encodeInt(state.stringPool[entry.key], buf)
+		if err != nil {
+			return err
+		}
+		valueType, ok := freeValueType(entry.value)
+		if !ok {
+			err := WriteBoolean(buf, false)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		err = WriteBoolean(buf, true)
+		if err != nil {
+			return err
+		}
+		err = e.encodeInt(int(valueType), buf)
+		if err != nil {
+			return err
+		}
+		err = e.freeValueDirect(state, entry.value, buf)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// freeValueDirect 与 innerFreeValueEncode 对应
+func (e *Encoder) freeValueDirect(state *encodeState, value pcommon.Value, buf *bytes.Buffer) error {
+	switch value.Type() {
+	case pcommon.ValueTypeInt:
+		return e.encodeInt(int(value.Int()), buf)
+	case pcommon.ValueTypeBool:
+		return WriteBoolean(buf, value.Bool())
+	case pcommon.ValueTypeDouble:
+		return WriteFloat(buf, value.Double())
+	case pcommon.ValueTypeBytes:
+		bv := value.Bytes().AsRaw()
+		err := e.encodeInt(len(bv), buf)
+		if err != nil {
+			return err
+		}
+		return WriteBytes(buf, bv)
+	case pcommon.ValueTypeStr:
+		strv := value.Str()
+		if e.opts.stringPoolEnabled {
+			if _, ok := state.stringPool[strv]; !ok {
+				state.stringPool[strv] = len(state.stringPool)
+			}
+			return e.encodeInt(state.stringPool[strv], buf)
+		}
+		err := e.encodeInt(len(strv), buf)
+		if err != nil {
+			return err
+		}
+		return WriteString(buf, strv)
+	case pcommon.ValueTypeMap:
+		return e.freeMapDirect(state, value.Map(), buf)
+	case pcommon.ValueTypeSlice:
+		slice := value.Slice()
+		err := e.encodeInt(slice.Len(), buf)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < slice.Len(); i++ {
+			valueType, ok := freeValueType(slice.At(i))
+			if !ok {
+				return errors.New("empty value in attributes array")
+			}
+			err := e.encodeInt(int(valueType), buf)
+			if err != nil {
+				return err
+			}
+			err = e.freeValueDirect(state, slice.At(i), buf)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (e *Encoder) encodeTracesDirect(state *encodeState, def *model.Definition, td ptrace.Traces, buf *bytes.Buffer) error {
+	target, tmp, done, err := e.beginDirect(state, def, model.Object, false, buf)
+	if done {
+		return err
+	}
+	for _, fieldName := range e.sortedKeys[def] {
+		fieldDef := def.Fields[fieldName]
+		fieldMyName := e.childNames.child("", fieldName)
+		switch fieldName {
+		case "resourceSpans":
+			err = e.encodeResourceSpansSliceDirect(state, fieldDef, fieldMyName, td.ResourceSpans(), target)
+		default:
+			err = e.encodeNilDirect(fieldDef, target)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return e.endDirect(state, def, "", tmp, buf)
+}
+
+func (e *Encoder) encodeResourceSpansSliceDirect(state *encodeState, def *model.Definition, myName string, slice ptrace.ResourceSpansSlice, buf *bytes.Buffer) error {
+	target, tmp, done, err := e.beginDirect(state, def, model.Array, slice.Len() == 0, buf)
+	if done {
+		return err
+	}
+	err = e.encodeInt(slice.Len(), target)
+	if err != nil {
+		return err
+	}
+	itemName := e.childNames.child(myName, "item")
+	for i := 0; i < slice.Len(); i++ {
+		err = e.encodeResourceSpansDirect(state, def.ItemDefinition, itemName, slice.At(i), target)
+		if err != nil {
+			return err
+		}
+	}
+	return e.endDirect(state, def, myName, tmp, buf)
+}
+
+func (e *Encoder) encodeResourceSpansDirect(state *encodeState, def *model.Definition, myName string, rs ptrace.ResourceSpans, buf *bytes.Buffer) error {
+	target, tmp, done, err := e.beginDirect(state, def, model.Object, false, buf)
+	if done {
+		return err
+	}
+	for _, fieldName := range e.sortedKeys[def] {
+		fieldDef := def.Fields[fieldName]
+		fieldMyName := e.childNames.child(myName, fieldName)
+		switch fieldName {
+		case "resource":
+			err = e.encodeResourceDirect(state, fieldDef, fieldMyName, rs.Resource(), target)
+		case "scopeSpans":
+			err = e.encodeScopeSpansSliceDirect(state, fieldDef, fieldMyName, rs.ScopeSpans(), target)
+		case "schemaUrl":
+			err = e.encodeStringDirect(state, fieldDef, fieldMyName, rs.SchemaUrl(), target)
+		default:
+			err = e.encodeNilDirect(fieldDef, target)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return e.endDirect(state, def, myName, tmp, buf)
+}
+
+func (e *Encoder) encodeResourceDirect(state *encodeState, def *model.Definition, myName string, resource pcommon.Resource, buf *bytes.Buffer) error {
+	target, tmp, done, err := e.beginDirect(state, def, model.Object, false, buf)
+	if done {
+		return err
+	}
+	for _, fieldName := range e.sortedKeys[def] {
+		fieldDef := def.Fields[fieldName]
+		fieldMyName := e.childNames.child(myName, fieldName)
+		switch fieldName {
+		case "attributes":
+			err = e.encodeAttributesDirect(state, fieldDef, fieldMyName, resource.Attributes(), target)
+		case "droppedAttributesCount":
+			err = e.encodeIntDirect(state, fieldDef, fieldMyName, int(resource.DroppedAttributesCount()), target)
+		default:
+			err = e.encodeNilDirect(fieldDef, target)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return e.endDirect(state, def, myName, tmp, buf)
+}
+
+func (e *Encoder) encodeScopeSpansSliceDirect(state *encodeState, def *model.Definition, myName string, slice ptrace.ScopeSpansSlice, buf *bytes.Buffer) error {
+	target, tmp, done, err := e.beginDirect(state, def, model.Array, slice.Len() == 0, buf)
+	if done {
+		return err
+	}
+	err = e.encodeInt(slice.Len(), target)
+	if err != nil {
+		return err
+	}
+	itemName := e.childNames.child(myName, "item")
+	for i := 0; i < slice.Len(); i++ {
+		err = e.encodeScopeSpansDirect(state, def.ItemDefinition, itemName, slice.At(i), target)
+		if err != nil {
+			return err
+		}
+	}
+	return e.endDirect(state, def, myName, tmp, buf)
+}
+
+func (e *Encoder) encodeScopeSpansDirect(state *encodeState, def *model.Definition, myName string, ss ptrace.ScopeSpans, buf *bytes.Buffer) error {
+	target, tmp, done, err := e.beginDirect(state, def, model.Object, false, buf)
+	if done {
+		return err
+	}
+	for _, fieldName := range e.sortedKeys[def] {
+		fieldDef := def.Fields[fieldName]
+		fieldMyName := e.childNames.child(myName, fieldName)
+		switch fieldName {
+		case "scope":
+			err = e.encodeScopeDirect(state, fieldDef, fieldMyName, ss.Scope(), target)
+		case "spans":
+			err = e.encodeSpanSliceDirect(state, fieldDef, fieldMyName, ss.Spans(), target)
+		case "schemaUrl":
+			err = e.encodeStringDirect(state, fieldDef, fieldMyName, ss.SchemaUrl(), target)
+		default:
+			err = e.encodeNilDirect(fieldDef, target)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return e.endDirect(state, def, myName, tmp, buf)
+}
+
+func (e *Encoder) encodeScopeDirect(state *encodeState, def *model.Definition, myName string, scope pcommon.InstrumentationScope, buf *bytes.Buffer) error {
+	target, tmp, done, err := e.beginDirect(state, def, model.Object, false, buf)
+	if done {
+		return err
+	}
+	for _, fieldName := range e.sortedKeys[def] {
+		fieldDef := def.Fields[fieldName]
+		fieldMyName := e.childNames.child(myName, fieldName)
+		switch fieldName {
+		case "name":
+			err = e.encodeStringDirect(state, fieldDef, fieldMyName, scope.Name(), target)
+		case "version":
+			err = e.encodeStringDirect(state, fieldDef, fieldMyName, scope.Version(), target)
+		case "attributes":
+			err = e.encodeAttributesDirect(state, fieldDef, fieldMyName, scope.Attributes(), target)
+		case "droppedAttributesCount":
+			err = e.encodeIntDirect(state, fieldDef, fieldMyName, int(scope.DroppedAttributesCount()), target)
+		default:
+			err = e.encodeNilDirect(fieldDef, target)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return e.endDirect(state, def, myName, tmp, buf)
+}
+
+func (e *Encoder) encodeSpanSliceDirect(state *encodeState, def *model.Definition, myName string, slice ptrace.SpanSlice, buf *bytes.Buffer) error {
+	target, tmp, done, err := e.beginDirect(state, def, model.Array, slice.Len() == 0, buf)
+	if done {
+		return err
+	}
+	err = e.encodeInt(slice.Len(), target)
+	if err != nil {
+		return err
+	}
+	itemName := e.childNames.child(myName, "item")
+	if e.groups.isSpans(def) {
+		err = e.encodeTraceGroupsDirect(state, def, itemName, slice, target)
+		if err != nil {
+			return err
+		}
+		return e.endDirect(state, def, myName, tmp, buf)
+	}
+	for i := 0; i < slice.Len(); i++ {
+		err = e.encodeSpanDirect(state, def.ItemDefinition, itemName, slice.At(i), target)
+		if err != nil {
+			return err
+		}
+	}
+	return e.endDirect(state, def, myName, tmp, buf)
+}
+
+func (e *Encoder) encodeSpanDirect(state *encodeState, def *model.Definition, myName string, span ptrace.Span, buf *bytes.Buffer) error {
+	target, tmp, done, err := e.beginDirect(state, def, model.Object, false, buf)
+	if done {
+		return err
+	}
+	for _, fieldName := range e.sortedKeys[def] {
+		fieldDef := def.Fields[fieldName]
+		fieldMyName := e.childNames.child(myName, fieldName)
+		switch fieldName {
+		case "traceId":
+			if e.groups.isTraceId(def, fieldName) {
+				break
+			}
+			traceId := span.TraceID()
+			err = e.encodeBytesDirect(state, fieldDef, fieldMyName, traceId[:], target)
+		case "spanId":
+			spanId := span.SpanID()
+			err = e.encodeBytesDirect(state, fieldDef, fieldMyName, spanId[:], target)
+		case "traceState":
+			err = e.encodeStringDirect(state, fieldDef, fieldMyName, span.TraceState().AsRaw(), target)
+		case "parentSpanId":
+			parentSpanId := span.ParentSpanID()
+			if e.tree.isParent(def, fieldName) {
+				traceId := span.TraceID()
+				distance := state.spans.distance(traceId[:], parentSpanId[:])
+				err = e.encodeInt(distance, target)
+				if err != nil || distance > 0 {
+					break
+				}
+			}
+			err = e.encodeBytesDirect(state, fieldDef, fieldMyName, parentSpanId[:], target)
+		case "name":
+			err = e.encodeStringDirect(state, fieldDef, fieldMyName, span.Name(), target)
+		case "kind":
+			err = e.encodeIntDirect(state, fieldDef, fieldMyName, int(span.Kind()), target)
+		case "startTimeUnixNano":
+			err = e.encodeIntDirect(state, fieldDef, fieldMyName, int(span.StartTimestamp()), target)
+		case "endTimeUnixNano":
+			err = e.encodeIntDirect(state, fieldDef, fieldMyName, int(span.EndTimestamp()), target)
+		case "attributes":
+			err = e.encodeAttributesDirect(state, fieldDef, fieldMyName, span.Attributes(), target)
+		case "droppedAttributesCount":
+			err = e.encodeIntDirect(state, fieldDef, fieldMyName, int(span.DroppedAttributesCount()), target)
+		case "events":
+			err = e.encodeSpanEventSliceDirect(state, fieldDef, fieldMyName, span.Events(), target)
+		case "droppedEventsCount":
+			err = e.encodeIntDirect(state, fieldDef, fieldMyName, int(span.DroppedEventsCount()), target)
+		case "links":
+			err = e.encodeSpanLinkSliceDirect(state, fieldDef, fieldMyName, span.Links(), target)
+		case "droppedLinksCount":
+			err = e.encodeIntDirect(state, fieldDef, fieldMyName, int(span.DroppedLinksCount()), target)
+		case "status":
+			err = e.encodeStatusDirect(state, fieldDef, fieldMyName, span.Status(), target)
+		default:
+			err = e.encodeNilDirect(fieldDef, target)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if e.tree.isSpan(def) {
+		traceId, spanId := span.TraceID(), span.SpanID()
+		state.spans.add(traceId[:], spanId[:])
+	}
+	return e.endDirect(state, def, myName, tmp, buf)
+}
+
+func (e *Encoder) encodeSpanEventSliceDirect(state *encodeState, def *model.Definition, myName string, slice ptrace.SpanEventSlice, buf *bytes.Buffer) error {
+	target, tmp, done, err := e.beginDirect(state, def, model.Array, slice.Len() == 0, buf)
+	if done {
+		return err
+	}
+	err = e.encodeInt(slice.Len(), target)
+	if err != nil {
+		return err
+	}
+	itemName := e.childNames.child(myName, "item")
+	for i := 0; i < slice.Len(); i++ {
+		err = e.encodeSpanEventDirect(state, def.ItemDefinition, itemName, slice.At(i), target)
+		if err != nil {
+			return err
+		}
+	}
+	return e.endDirect(state, def, myName, tmp, buf)
+}
+
+func (e *Encoder) encodeSpanEventDirect(state *encodeState, def *model.Definition, myName string, event ptrace.SpanEvent, buf *bytes.Buffer) error {
+	target, tmp, done, err := e.beginDirect(state, def, model.Object, false, buf)
+	if done {
+		return err
+	}
+	for _, fieldName := range e.sortedKeys[def] {
+		fieldDef := def.Fields[fieldName]
+		fieldMyName := e.childNames.child(myName, fieldName)
+		switch fieldName {
+		case "timeUnixNano":
+			err = e.encodeIntDirect(state, fieldDef, fieldMyName, int(event.Timestamp()), target)
+		case "name":
+			err = e.encodeStringDirect(state, fieldDef, fieldMyName, event.Name(), target)
+		case "attributes":
+			err = e.encodeAttributesDirect(state, fieldDef, fieldMyName, event.Attributes(), target)
+		case "droppedAttributesCount":
+			err = e.encodeIntDirect(state, fieldDef, fieldMyName, int(event.DroppedAttributesCount()), target)
+		default:
+			err = e.encodeNilDirect(fieldDef, target)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return e.endDirect(state, def, myName, tmp, buf)
+}
+
+func (e *Encoder) encodeSpanLinkSliceDirect(state *encodeState, def *model.Definition, myName string, slice ptrace.SpanLinkSlice, buf *bytes.Buffer) error {
+	target, tmp, done, err := e.beginDirect(state, def, model.Array, slice.Len() == 0, buf)
+	if done {
+		return err
+	}
+	err = e.encodeInt(slice.Len(), target)
+	if err != nil {
+		return err
+	}
+	itemName := e.childNames.child(myName, "item")
+	for i := 0; i < slice.Len(); i++ {
+		err = e.encodeSpanLinkDirect(state, def.ItemDefinition, itemName, slice.At(i), target)
+		if err != nil {
+			return err
+		}
+	}
+	return e.endDirect(state, def, myName, tmp, buf)
+}
+
+func (e *Encoder) encodeSpanLinkDirect(state *encodeState, def *model.Definition, myName string, link ptrace.SpanLink, buf *bytes.Buffer) error {
+	target, tmp, done, err := e.beginDirect(state, def, model.Object, false, buf)
+	if done {
+		return err
+	}
+	for _, fieldName := range e.sortedKeys[def] {
+		fieldDef := def.Fields[fieldName]
+		fieldMyName := e.childNames.child(myName, fieldName)
+		switch fieldName {
+		case "traceId":
+			traceId := link.TraceID()
+			err = e.encodeBytesDirect(state, fieldDef, fieldMyName, traceId[:], target)
+		case "spanId":
+			spanId := link.SpanID()
+			err = e.encodeBytesDirect(state, fieldDef, fieldMyName, spanId[:], target)
+		case "traceState":
+			err = e.encodeStringDirect(state, fieldDef, fieldMyName, link.TraceState().AsRaw(), target)
+		case "attributes":
+			err = e.encodeAttributesDirect(state, fieldDef, fieldMyName, link.Attributes(), target)
+		case "droppedAttributesCount":
+			err = e.encodeIntDirect(state, fieldDef, fieldMyName, int(link.DroppedAttributesCount()), target)
+		default:
+			err = e.encodeNilDirect(fieldDef, target)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return e.endDirect(state, def, myName, tmp, buf)
+}
+
+func (e *Encoder) encodeStatusDirect(state *encodeState, def *model.Definition, myName string, status ptrace.Status, buf *bytes.Buffer) error {
+	target, tmp, done, err := e.beginDirect(state, def, model.Object, false, buf)
+	if done {
+		return err
+	}
+	for _, fieldName := range e.sortedKeys[def] {
+		fieldDef := def.Fields[fieldName]
+		fieldMyName := e.childNames.child(myName, fieldName)
+		switch fieldName {
+		case "message":
+			err = e.encodeStringDirect(state, fieldDef, fieldMyName, status.Message(), target)
+		case "code":
+			err = e.encodeIntDirect(state, fieldDef, fieldMyName, int(status.Code()), target)
+		default:
+			err = e.encodeNilDirect(fieldDef, target)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return e.endDirect(state, def, myName, tmp, buf)
+}
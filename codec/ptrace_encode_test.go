@@ -0,0 +1,222 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/beet233/compressotelcollector/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+// newRichTraces 构造覆盖 trace Definition 中所有字段的 traces，包括 events、links、嵌套的 attributes 和乱序的时间戳
+func newRichTraces(spans int) ptrace.Traces {
+	td := ptrace.NewTraces()
+	for r := 0; r < 2; r++ {
+		rs := td.ResourceSpans().AppendEmpty()
+		rs.SetSchemaUrl("https://opentelemetry.io/schemas/1.21.0")
+		rs.Resource().Attributes().PutStr("service.name", fmt.Sprintf("service-%d", r%2))
+		rs.Resource().Attributes().PutStr("host.name", "host")
+		for s := 0; s < 2; s++ {
+			ss := rs.ScopeSpans().AppendEmpty()
+			ss.Scope().SetName("github.com/beet233/instrumentation")
+			ss.Scope().SetVersion(fmt.Sprintf("v0.%d.0", s))
+			if s == 1 {
+				ss.Scope().Attributes().PutBool("scope.enabled", true)
+				ss.Scope().SetDroppedAttributesCount(1)
+			}
+			for i := 0; i < spans; i++ {
+				span := ss.Spans().AppendEmpty()
+				span.SetTraceID([16]byte{byte(r), 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, byte(i / 3)})
+				span.SetSpanID([8]byte{byte(r), byte(s), 3, 4, 5, 6, 7, byte(i)})
+				if i%3 != 0 {
+					span.SetParentSpanID([8]byte{byte(r), byte(s), 3, 4, 5, 6, 7, byte(i - 1)})
+				}
+				if i%4 == 0 {
+					span.TraceState().FromRaw("vendor=value")
+				}
+				span.SetName(fmt.Sprintf("operation-%d", i%5))
+				span.SetKind(ptrace.SpanKind(i % 3))
+				// 时间戳有前有后，差分编码会出现负数
+				start := 1_700_000_000_000_000_000 + int64(i%7)*1_000_000 - int64(i%2)*50_000_000
+				span.SetStartTimestamp(pcommon.Timestamp(start))
+				span.SetEndTimestamp(pcommon.Timestamp(start + int64(i)*1000))
+				attrs := span.Attributes()
+				attrs.PutStr("http.method", []string{"GET", "POST"}[i%2])
+				attrs.PutInt("http.status_code", int64(200+i%3*100))
+				attrs.PutDouble("ratio", float64(i)/4)
+				attrs.PutBool("error", i%5 == 0)
+				attrs.PutEmpty("empty")
+				attrs.PutEmptyBytes("payload").FromRaw([]byte{byte(i), 0})
+				nested := attrs.PutEmptyMap("nested")
+				nested.PutInt("negative", -int64(i))
+				nested.PutEmptySlice("list").FromRaw([]any{"a", int64(i), true, map[string]any{"k": "v"}})
+				if i%6 == 0 {
+					span.SetDroppedAttributesCount(2)
+				}
+				if i%2 == 0 {
+					event := span.Events().AppendEmpty()
+					event.SetName("exception")
+					event.SetTimestamp(pcommon.Timestamp(start + 10))
+					event.Attributes().PutStr("exception.message", "boom")
+					span.Events().AppendEmpty().SetName("retry")
+					span.SetDroppedEventsCount(uint32(i % 3))
+				}
+				if i%3 == 0 {
+					link := span.Links().AppendEmpty()
+					link.SetTraceID([16]byte{9, 9, 9, byte(i)})
+					link.SetSpanID([8]byte{9, 9, byte(i)})
+					link.TraceState().FromRaw("k=v")
+					link.Attributes().PutStr("link.kind", "follows")
+					span.SetDroppedLinksCount(1)
+				}
+				if i%5 == 0 {
+					span.Status().SetCode(ptrace.StatusCodeError)
+					span.Status().SetMessage("failed")
+				}
+			}
+		}
+	}
+	return td
+}
+
+// trace 模型中所有池子的名字都预先拼接好，直接编解码时不需要临时拼接
+func TestPlanChildNames(t *testing.T) {
+	def, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(t, err)
+	encoder := NewEncoder(def)
+	planned := make(map[string]bool, len(encoder.childNames))
+	for _, name := range encoder.childNames {
+		planned[name] = true
+	}
+	// SharePoolId 不是路径
+	shared := make(map[string]bool)
+	var collect func(def *model.Definition)
+	collect = func(def *model.Definition) {
+		if def == nil {
+			return
+		}
+		if def.SharePooled {
+			shared[def.SharePoolId] = true
+		}
+		for _, fieldDef := range def.Fields {
+			collect(fieldDef)
+		}
+		collect(def.ItemDefinition)
+	}
+	collect(def)
+	for _, poolId := range encoder.topologicalFields {
+		assert.True(t, planned[poolId] || shared[poolId], poolId)
+	}
+	assert.Equal(t, "resourceSpans item scopeSpans", encoder.childNames.child("resourceSpans item", "scopeSpans"))
+	assert.Equal(t, "free key", encoder.childNames.child("free", "key"))
+}
+
+func TestEncodeTracesMatchesEncode(t *testing.T) {
+	traceModel, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(t, err)
+
+	// Definition 中多一个 OTLP 没有的字段，直接编码按 nil 处理
+	withExtraField, err := model.GetDefinitionFromJSON(defaultTraceModelJSON(t))
+	require.NoError(t, err)
+	spanDef := withExtraField.Fields["resourceSpans"].ItemDefinition.Fields["scopeSpans"].ItemDefinition.Fields["spans"].ItemDefinition
+	spanDef.Fields["extra"] = &model.Definition{Type: model.String, Nullable: true}
+
+	// attributes 带有 Fields 时不能直接编码，退回 Value 路径
+	withDefinedAttributes, err := model.GetDefinitionFromJSON(defaultTraceModelJSON(t))
+	require.NoError(t, err)
+	withDefinedAttributes.Fields["resourceSpans"].ItemDefinition.Fields["resource"].Fields["attributes"] = &model.Definition{
+		Type: model.Object, Nullable: true, Fields: map[string]*model.Definition{
+			"service.name": {Type: model.String, Nullable: true, Pooled: true},
+		}}
+
+	defs := []struct {
+		name   string
+		def    *model.Definition
+		direct bool
+	}{
+		{name: "trace model", def: traceModel, direct: true},
+		{name: "extra field", def: withExtraField, direct: true},
+		{name: "defined attributes", def: withDefinedAttributes, direct: false},
+	}
+	optionSets := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "default"},
+		{name: "fixed int", opts: []Option{WithLeb128(false)}},
+		{name: "no string pool", opts: []Option{WithStringPool(false)}},
+		{name: "pool limits", opts: []Option{WithPoolLimits(PoolLimits{Default: 3, Pools: map[string]int{"traceId": 1}})}},
+		{name: "adaptive pooling", opts: []Option{WithAdaptivePooling(true)}},
+	}
+	inputs := map[string]ptrace.Traces{
+		"empty":     ptrace.NewTraces(),
+		"rich":      newRichTraces(30),
+		"load test": newLoadTestTraces(3),
+	}
+	for _, d := range defs {
+		for _, o := range optionSets {
+			encoder := NewEncoder(d.def, o.opts...)
+			assert.Equal(t, d.direct, encoder.directTraces)
+			for inputName, td := range inputs {
+				t.Run(d.name+"/"+o.name+"/"+inputName, func(t *testing.T) {
+					var expected bytes.Buffer
+					expectedStats, err := encoder.Encode(TracesToValue(td), &expected)
+					require.NoError(t, err)
+					var got bytes.Buffer
+					gotStats, err := encoder.EncodeTraces(td, &got)
+					require.NoError(t, err)
+					assert.Equal(t, expected.Bytes(), got.Bytes())
+					assert.Equal(t, expectedStats, gotStats)
+				})
+			}
+		}
+	}
+}
+
+func defaultTraceModelJSON(t *testing.T) []byte {
+	def, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(t, err)
+	raw, err := json.Marshal(def)
+	require.NoError(t, err)
+	return raw
+}
+
+func BenchmarkEncodeValue(b *testing.B) {
+	def, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(b, err)
+	encoder := NewEncoder(def)
+	td := newRichTraces(200)
+	var buf bytes.Buffer
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		_, err := encoder.Encode(TracesToValue(td), &buf)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeTraces(b *testing.B) {
+	def, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(b, err)
+	encoder := NewEncoder(def)
+	td := newRichTraces(200)
+	var buf bytes.Buffer
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		_, err := encoder.EncodeTraces(td, &buf)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
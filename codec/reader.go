@@ -0,0 +1,123 @@
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// DataReader 从 payload 中依次读取基本类型，每个 Read 方法都和 writer.go 中的一个 Write 函数对应
+type DataReader struct {
+	data []byte
+}
+
+func NewDataReader(data []byte) *DataReader {
+	return &DataReader{data: data}
+}
+
+// Len 返回还没有读取的字节数
+func (r *DataReader) Len() int {
+	return len(r.data)
+}
+
+// ReadString 读取 length 个字节作为 string，对应 WriteString
+func (r *DataReader) ReadString(length int) (string, error) {
+	if length < 0 {
+		return "", errors.New("negative string length")
+	}
+	if len(r.data) < length {
+		return "", errors.New("no data available")
+	}
+
+	str := string(r.data[:length])
+	r.data = r.data[length:]
+	return str, nil
+}
+
+// ReadInt 读取 8 byte 小端编码的整数，对应 WriteInt
+func (r *DataReader) ReadInt() (int, error) {
+	if len(r.data) < 8 {
+		return 0, errors.New("not enough data for int")
+	}
+
+	val := int(int64(binary.LittleEndian.Uint64(r.data[:8])))
+
+	r.data = r.data[8:]
+	return val, nil
+}
+
+// ReadLeb128Int 读取 sleb128 编码的整数，对应 WriteLeb128Int
+func (r *DataReader) ReadLeb128Int() (int, error) {
+	var result int = 0
+	shift := 0
+	var b byte
+	for i := 0; i < 8; i++ {
+		var err error
+		b, err = r.ReadByte()
+		if err != nil {
+			return 0, errors.New("not enough data for leb128 int")
+		}
+		result |= (int(b&0x7F) << shift)
+		shift += 7
+		if (b & 0x80) == 0 {
+			// 最后一个 byte 的第 7 位是符号位，负数需要把高位补 1
+			if (b & 0x40) != 0 {
+				result |= -1 << shift
+			}
+			return result, nil
+		}
+	}
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, errors.New("not enough data for leb128 int")
+	}
+	// 最后一 byte 没有标记位，完整的 8 bit 都是数据
+	result |= (int(b) << shift)
+	return result, nil
+}
+
+// ReadFloat 读取 8 byte 小端编码的 float64，对应 WriteFloat
+func (r *DataReader) ReadFloat() (float64, error) {
+	if len(r.data) < 8 {
+		return 0, errors.New("not enough data for float")
+	}
+
+	val := math.Float64frombits(binary.LittleEndian.Uint64(r.data[:8]))
+	r.data = r.data[8:]
+	return val, nil
+}
+
+// ReadBoolean 读取一个 byte 的 boolean，对应 WriteBoolean
+func (r *DataReader) ReadBoolean() (bool, error) {
+	if len(r.data) < 1 {
+		return false, errors.New("not enough data for boolean")
+	}
+
+	val := r.data[0] != 0
+	r.data = r.data[1:]
+	return val, nil
+}
+
+// ReadBytes 读取 length 个字节，返回的切片和 payload 共用内存，对应 WriteBytes
+func (r *DataReader) ReadBytes(length int) ([]byte, error) {
+	if length < 0 {
+		return nil, errors.New("negative bytes length")
+	}
+	if len(r.data) < length {
+		return nil, errors.New("no data available for bytes")
+	}
+
+	val := r.data[:length]
+	r.data = r.data[length:]
+	return val, nil
+}
+
+func (r *DataReader) ReadByte() (byte, error) {
+	if len(r.data) == 0 {
+		return 0, errors.New("no data available for byte")
+	}
+
+	val := r.data[0]
+	r.data = r.data[1:]
+	return val, nil
+}
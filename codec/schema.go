@@ -0,0 +1,441 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"github.com/beet233/compressotelcollector/model"
+)
+
+// Version 大于 0 的 Definition 编码的 payload 默认以 schemaIdMagic 开头，之后是 Version 和指纹的前 schemaIdLength 个 byte；
+// 开启 WithEmbeddedSchema 时以 schemaMagic 开头，之后是 schema 的长度和 writer 的 Definition，然后才是 stringPool。
+// Decoder 的 Definition 和 writer 的不同时，按 writer 的 Definition 解码，再按 model.MatchFields 转换为自己的结构，
+// 这样滚动升级期间新旧两个版本的 exporter 和 receiver 可以互相解析
+const (
+	schemaMagic    = "cprdef"
+	schemaIdMagic  = "cprsid"
+	schemaIdLength = 8
+)
+
+// schema 中 Definition 的布尔属性
+const (
+	schemaNullable = 1 << iota
+	schemaPooled
+	schemaSharePooled
+	schemaDiffEncode
+)
+
+// maxWriterDecoders 是 Decoder 缓存的 writer Decoder 的个数上限，滚动升级时通常只有一两个旧版本
+const maxWriterDecoders = 16
+
+// planHeader 返回 Encoder 写在 payload 开头的 schema id，开启 WithEmbeddedSchema 时是完整的 schema，def 没有 Version 时返回 nil
+func planHeader(entry *model.Entry, opts options) []byte {
+	if !opts.embeddedSchema {
+		id := planSchemaId(entry, opts)
+		if id == nil {
+			return nil
+		}
+		return append([]byte(schemaIdMagic), id...)
+	}
+	schema := planSchema(entry.Definition, opts)
+	if schema == nil {
+		return nil
+	}
+	buf := bytes.NewBufferString(schemaMagic)
+	_ = schemaIntEncoder(opts)(buf, len(schema))
+	buf.Write(schema)
+	return buf.Bytes()
+}
+
+func schemaIntEncoder(opts options) func(*bytes.Buffer, int) error {
+	if opts.leb128Enabled {
+		return WriteLeb128Int
+	}
+	return WriteInt
+}
+
+// planSchemaId 返回 entry 的 Version 和指纹的前 schemaIdLength 个 byte，没有 Version 时返回 nil
+func planSchemaId(entry *model.Entry, opts options) []byte {
+	if entry.Definition.Version <= 0 {
+		return nil
+	}
+	buf := &bytes.Buffer{}
+	_ = schemaIntEncoder(opts)(buf, entry.Definition.Version)
+	buf.Write(fingerprintId(entry.Fingerprint))
+	return buf.Bytes()
+}
+
+func fingerprintId(fingerprint string) []byte {
+	id := make([]byte, schemaIdLength)
+	raw, _ := hex.DecodeString(fingerprint)
+	copy(id, raw)
+	return id
+}
+
+// planSchema 返回 def 写入 payload 的 schema，def 没有 Version 时返回 nil
+func planSchema(def *model.Definition, opts options) []byte {
+	if def.Version <= 0 {
+		return nil
+	}
+	encodeInt := schemaIntEncoder(opts)
+	buf := &bytes.Buffer{}
+	_ = encodeInt(buf, def.Version)
+	encodeSchemaNode(def, encodeInt, buf)
+	return buf.Bytes()
+}
+
+func encodeSchemaNode(def *model.Definition, encodeInt func(*bytes.Buffer, int) error, buf *bytes.Buffer) {
+	flags := 0
+	if def.Nullable {
+		flags |= schemaNullable
+	}
+	if def.Pooled {
+		flags |= schemaPooled
+	}
+	if def.SharePooled {
+		flags |= schemaSharePooled
+	}
+	if def.DiffEncode {
+		flags |= schemaDiffEncode
+	}
+	_ = encodeInt(buf, int(def.Type))
+	_ = encodeInt(buf, flags)
+	if def.SharePooled {
+		_ = encodeInt(buf, len(def.SharePoolId))
+		buf.WriteString(def.SharePoolId)
+	}
+	_ = encodeInt(buf, def.MaxPoolEntries)
+	_ = encodeInt(buf, def.Tag)
+	switch def.Type {
+	case model.Bytes:
+		_ = encodeInt(buf, def.FixedLength)
+	case model.Object:
+		if def.Fields == nil {
+			// -1 表示没有 Fields 的 map
+			_ = encodeInt(buf, -1)
+			return
+		}
+		_ = encodeInt(buf, len(def.Fields))
+		for _, fieldName := range def.FieldOrder() {
+			_ = encodeInt(buf, len(fieldName))
+			buf.WriteString(fieldName)
+			encodeSchemaNode(def.Fields[fieldName], encodeInt, buf)
+		}
+	case model.Array:
+		encodeSchemaNode(def.ItemDefinition, encodeInt, buf)
+	}
+}
+
+// schemaDecoder 解析 payload 中的 schema，嵌套深度和 field 个数受 DecodeLimits 限制
+type schemaDecoder struct {
+	reader  *DataReader
+	readInt func() (int, error)
+	lim     *limiter
+}
+
+func (d *Decoder) decodeSchema(schema []byte, lim *limiter) (*model.Definition, error) {
+	reader := NewDataReader(schema)
+	readInt := reader.ReadInt
+	if d.opts.leb128Enabled {
+		readInt = reader.ReadLeb128Int
+	}
+	sd := &schemaDecoder{reader: reader, readInt: readInt, lim: lim}
+	version, err := readInt()
+	if err != nil {
+		return nil, err
+	}
+	def, err := sd.node()
+	if err != nil {
+		return nil, err
+	}
+	def.Version = version
+	if reader.Len() > 0 {
+		return nil, fmt.Errorf("%d trailing bytes after schema", reader.Len())
+	}
+	err = model.ValidateDefinition(def)
+	if err != nil {
+		return nil, fmt.Errorf("invalid writer Definition: %w", err)
+	}
+	return def, nil
+}
+
+func (sd *schemaDecoder) readString() (string, error) {
+	length, err := sd.readInt()
+	if err != nil {
+		return "", err
+	}
+	return sd.reader.ReadString(length)
+}
+
+func (sd *schemaDecoder) node() (*model.Definition, error) {
+	err := sd.lim.enter()
+	if err != nil {
+		return nil, err
+	}
+	defer sd.lim.leave()
+	valueType, err := sd.readInt()
+	if err != nil {
+		return nil, err
+	}
+	flags, err := sd.readInt()
+	if err != nil {
+		return nil, err
+	}
+	def := &model.Definition{
+		Type:        model.ValueType(valueType),
+		Nullable:    flags&schemaNullable != 0,
+		Pooled:      flags&schemaPooled != 0,
+		SharePooled: flags&schemaSharePooled != 0,
+		DiffEncode:  flags&schemaDiffEncode != 0,
+	}
+	if def.SharePooled {
+		def.SharePoolId, err = sd.readString()
+		if err != nil {
+			return nil, err
+		}
+	}
+	def.MaxPoolEntries, err = sd.readInt()
+	if err != nil {
+		return nil, err
+	}
+	def.Tag, err = sd.readInt()
+	if err != nil {
+		return nil, err
+	}
+	switch def.Type {
+	case model.Bytes:
+		def.FixedLength, err = sd.readInt()
+		if err != nil {
+			return nil, err
+		}
+	case model.Object:
+		count, err := sd.readInt()
+		if err != nil {
+			return nil, err
+		}
+		if count == -1 {
+			return def, nil
+		}
+		err = sd.lim.checkArrayLength("schema", count)
+		if err != nil {
+			return nil, err
+		}
+		def.Fields = make(map[string]*model.Definition, count)
+		for i := 0; i < count; i++ {
+			fieldName, err := sd.readString()
+			if err != nil {
+				return nil, err
+			}
+			def.Fields[fieldName], err = sd.node()
+			if err != nil {
+				return nil, err
+			}
+		}
+	case model.Array:
+		def.ItemDefinition, err = sd.node()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return def, nil
+}
+
+// splitSchema 去掉 payload 开头的 schema，返回 schema 和剩余的部分，没有 schema 时 schema 为 nil
+func (d *Decoder) splitSchema(data []byte) (schema []byte, rest []byte, err error) {
+	if !bytes.HasPrefix(data, []byte(schemaMagic)) {
+		return nil, data, nil
+	}
+	reader := NewDataReader(data[len(schemaMagic):])
+	length, err := d.readSchemaInt(reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	if length < 0 {
+		return nil, nil, fmt.Errorf("negative schema length %d", length)
+	}
+	schema, err = reader.ReadBytes(length)
+	if err != nil {
+		return nil, nil, err
+	}
+	return schema, data[len(data)-reader.Len():], nil
+}
+
+// splitSchemaId 去掉 payload 开头的 schema id，返回 schema id、其中的 Version 和指纹以及剩余的部分
+func (d *Decoder) splitSchemaId(data []byte) (id []byte, version int, fingerprint []byte, rest []byte, err error) {
+	reader := NewDataReader(data[len(schemaIdMagic):])
+	version, err = d.readSchemaInt(reader)
+	if err != nil {
+		return nil, 0, nil, nil, err
+	}
+	fingerprint, err = reader.ReadBytes(schemaIdLength)
+	if err != nil {
+		return nil, 0, nil, nil, err
+	}
+	rest = data[len(data)-reader.Len():]
+	return data[len(schemaIdMagic) : len(data)-len(rest)], version, fingerprint, rest, nil
+}
+
+// forPayload 读取 payload 开头的 schema，返回用于解码的 Decoder 和去掉 schema 之后的 payload。
+// 没有 schema 或 writer 的 Definition 和 d 的结构相同时返回 d 本身
+func (d *Decoder) forPayload(data []byte, lim *limiter, stats *DecodeStats) (*Decoder, []byte, error) {
+	w, _, rest, err := d.payloadWriter(data, lim)
+	if err != nil {
+		return nil, nil, err
+	}
+	stats.SchemaBytes = len(data) - len(rest)
+	return w, rest, nil
+}
+
+// payloadWriter 是 forPayload 的实现，另外返回 payload 中 writer 的 Version，没有 schema 时为 0
+func (d *Decoder) payloadWriter(data []byte, lim *limiter) (w *Decoder, version int, rest []byte, err error) {
+	if bytes.HasPrefix(data, []byte(schemaIdMagic)) {
+		id, version, fingerprint, rest, err := d.splitSchemaId(data)
+		if err != nil {
+			return nil, 0, nil, fmt.Errorf("schema id: %w", err)
+		}
+		if bytes.Equal(fingerprint, fingerprintId(d.entry.Fingerprint)) {
+			// 结构相同，最多只有 Version 不同
+			return d, version, rest, nil
+		}
+		w, err = d.writerDecoder(schemaIdMagic+string(id), func() (*model.Entry, error) {
+			return d.registeredWriter(version, fingerprint)
+		})
+		return w, version, rest, err
+	}
+	schema, rest, err := d.splitSchema(data)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("schema: %w", err)
+	}
+	if schema == nil {
+		return d, 0, rest, nil
+	}
+	version, err = d.readSchemaInt(NewDataReader(schema))
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("schema: %w", err)
+	}
+	if bytes.Equal(schema, d.schema) {
+		return d, version, rest, nil
+	}
+	w, err = d.writerDecoder(schemaMagic+string(schema), func() (*model.Entry, error) {
+		writerDef, err := d.decodeSchema(schema, lim)
+		if err != nil {
+			return nil, fmt.Errorf("schema: %w", err)
+		}
+		return definitionEntry(writerDef), nil
+	})
+	return w, version, rest, err
+}
+
+func (d *Decoder) readSchemaInt(reader *DataReader) (int, error) {
+	if d.opts.leb128Enabled {
+		return reader.ReadLeb128Int()
+	}
+	return reader.ReadInt()
+}
+
+// registeredWriter 在 WithWriters 的 Registry 中查找 schema id 对应的 writer Entry
+func (d *Decoder) registeredWriter(version int, fingerprint []byte) (*model.Entry, error) {
+	if d.opts.writers == nil {
+		return nil, fmt.Errorf("payload Definition version %d is unknown, register it with WithWriters or embed the schema in the payload", version)
+	}
+	writer, err := d.opts.writers.Get(d.entry.Name, version)
+	if err != nil {
+		return nil, fmt.Errorf("payload Definition: %w, register it with WithWriters or embed the schema in the payload", err)
+	}
+	if !bytes.Equal(fingerprintId(writer.Fingerprint), fingerprint) {
+		return nil, fmt.Errorf("payload Definition version %d does not match the registered one", version)
+	}
+	return writer, nil
+}
+
+// writerDecoders 缓存按 writer 的 Definition 创建的 Decoder，key 为 magic 加上 schema 或 schema id
+type writerDecoders struct {
+	lock     sync.Mutex
+	decoders map[string]*Decoder
+}
+
+// writerDecoder 返回按 writer 的 Definition 解码的 Decoder，缓存中没有时由 resolve 得到 writer 的 Entry
+func (d *Decoder) writerDecoder(key string, resolve func() (*model.Entry, error)) (*Decoder, error) {
+	d.writers.lock.Lock()
+	w, exist := d.writers.decoders[key]
+	d.writers.lock.Unlock()
+	if exist {
+		return w, nil
+	}
+	writer, err := resolve()
+	if err != nil {
+		return nil, err
+	}
+	if writer.Fingerprint == d.entry.Fingerprint {
+		// 只有 Version 不同
+		w = d
+	} else {
+		err = model.CheckCompatibility(writer.Definition, d.def)
+		if err != nil {
+			return nil, fmt.Errorf("payload Definition version %d is not compatible with version %d: %w", writer.Definition.Version, d.def.Version, err)
+		}
+		w = newDecoder(writer, d.opts)
+	}
+	d.writers.lock.Lock()
+	defer d.writers.lock.Unlock()
+	if d.writers.decoders == nil || len(d.writers.decoders) >= maxWriterDecoders {
+		d.writers.decoders = make(map[string]*Decoder)
+	}
+	d.writers.decoders[key] = w
+	return w, nil
+}
+
+// valueProjector 把按 writer 的 Definition 解码的 Value 转换为 reader 的结构：
+// 按 model.MatchFields 对应 field，reader 中没有的 field 丢弃，writer 中没有的 field 为 null
+type valueProjector struct {
+	matched map[*model.Definition]map[string]string
+}
+
+func projectValue(value model.Value, writer *model.Definition, reader *model.Definition) model.Value {
+	if writer == reader {
+		return value
+	}
+	p := &valueProjector{matched: make(map[*model.Definition]map[string]string)}
+	return p.project(value, writer, reader)
+}
+
+func (p *valueProjector) project(value model.Value, writer *model.Definition, reader *model.Definition) model.Value {
+	if value == nil {
+		return nil
+	}
+	switch reader.Type {
+	case model.Object:
+		objv, ok := value.(*model.ObjectValue)
+		if !ok || reader.Fields == nil {
+			return value
+		}
+		matched, exist := p.matched[reader]
+		if !exist {
+			matched = model.MatchFields(writer, reader)
+			p.matched[reader] = matched
+		}
+		result := make(map[string]model.Value, len(reader.Fields))
+		for fieldName, fieldDef := range reader.Fields {
+			writerName, exist := matched[fieldName]
+			if !exist {
+				result[fieldName] = nil
+				continue
+			}
+			result[fieldName] = p.project(objv.Data[writerName], writer.Fields[writerName], fieldDef)
+		}
+		return &model.ObjectValue{Data: result}
+	case model.Array:
+		arrv, ok := value.(*model.ArrayValue)
+		if !ok {
+			return value
+		}
+		var result []model.Value
+		for _, item := range arrv.Data {
+			result = append(result, p.project(item, writer.ItemDefinition, reader.ItemDefinition))
+		}
+		return &model.ArrayValue{Data: result}
+	}
+	return value
+}
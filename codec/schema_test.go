@@ -0,0 +1,260 @@
+package codec
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/beet233/compressotelcollector/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const (
+	itemsV1 = `{"Version": 1, "Type": "object", "Fields": {
+		"items": {"Type": "array", "Tag": 1, "ItemDefinition": {"Type": "object", "Fields": {
+			"name": {"Type": "string", "Pooled": true, "Tag": 1},
+			"count": {"Type": "int", "DiffEncode": true, "Tag": 2}
+		}}}
+	}}`
+	// itemsV2 把 name 改名为 title，新增可以为 null 的 note
+	itemsV2 = `{"Version": 2, "Type": "object", "Fields": {
+		"items": {"Type": "array", "Tag": 1, "ItemDefinition": {"Type": "object", "Fields": {
+			"title": {"Type": "string", "Pooled": true, "Tag": 1},
+			"count": {"Type": "int", "DiffEncode": true, "Tag": 2},
+			"note": {"Type": "string", "Nullable": true, "Tag": 3}
+		}}}
+	}}`
+	// itemsV3 新增不可为 null 的 required，读不了 v1 的 payload
+	itemsV3 = `{"Version": 3, "Type": "object", "Fields": {
+		"items": {"Type": "array", "Tag": 1, "ItemDefinition": {"Type": "object", "Fields": {
+			"title": {"Type": "string", "Pooled": true, "Tag": 1},
+			"count": {"Type": "int", "DiffEncode": true, "Tag": 2},
+			"required": {"Type": "bool", "Tag": 4}
+		}}}
+	}}`
+)
+
+func mustDefinition(t *testing.T, src string) *model.Definition {
+	def, err := model.GetDefinitionFromJSON([]byte(src))
+	require.NoError(t, err)
+	return def
+}
+
+func itemsValue(nameField string, extra map[string]model.Value, names ...string) model.Value {
+	var items []model.Value
+	for i, name := range names {
+		item := map[string]model.Value{
+			nameField: &model.StringValue{Data: name},
+			"count":   &model.IntegerValue{Data: 100 + i},
+		}
+		for key, value := range extra {
+			item[key] = value
+		}
+		items = append(items, &model.ObjectValue{Data: item})
+	}
+	return &model.ObjectValue{Data: map[string]model.Value{"items": &model.ArrayValue{Data: items}}}
+}
+
+// itemsRegistry 以 "items" 注册 itemsV1、itemsV2 和 itemsV3
+func itemsRegistry(t *testing.T) *model.Registry {
+	registry := model.NewRegistry()
+	for _, src := range []string{itemsV1, itemsV2, itemsV3} {
+		_, err := registry.Register("items", mustDefinition(t, src))
+		require.NoError(t, err)
+	}
+	return registry
+}
+
+func TestVersionedPayload(t *testing.T) {
+	def := mustDefinition(t, itemsV1)
+	value := itemsValue("name", nil, "a", "b", "a")
+	var buf bytes.Buffer
+	_, err := NewEncoder(def).Encode(value, &buf)
+	require.NoError(t, err)
+	// 默认只写 schema id：magic、1 byte 的 Version 和指纹
+	assert.True(t, bytes.HasPrefix(buf.Bytes(), []byte(schemaIdMagic)))
+	decoded, stats, err := NewDecoder(def).Decode(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, value.Hash(), decoded.Hash())
+	assert.Equal(t, len(schemaIdMagic)+1+schemaIdLength, stats.SchemaBytes)
+
+	buf.Reset()
+	_, err = NewEncoder(def, WithEmbeddedSchema(true)).Encode(value, &buf)
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(buf.Bytes(), []byte(schemaMagic)))
+	decoded, stats, err = NewDecoder(def).Decode(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, value.Hash(), decoded.Hash())
+	assert.Greater(t, stats.SchemaBytes, len(schemaIdMagic)+1+schemaIdLength)
+
+	// 没有 Version 的 Definition 不写 schema
+	def.Version = 0
+	for _, opts := range [][]Option{nil, {WithEmbeddedSchema(true)}} {
+		buf.Reset()
+		_, err = NewEncoder(def, opts...).Encode(value, &buf)
+		require.NoError(t, err)
+		assert.False(t, bytes.HasPrefix(buf.Bytes(), []byte(schemaMagic)))
+		assert.False(t, bytes.HasPrefix(buf.Bytes(), []byte(schemaIdMagic)))
+	}
+}
+
+func TestRollingUpgrade(t *testing.T) {
+	registry := itemsRegistry(t)
+	v1, err := registry.Get("items", 1)
+	require.NoError(t, err)
+	v2, err := registry.Get("items", 2)
+	require.NoError(t, err)
+	variants := map[string]struct {
+		encodeOpts []Option
+		decodeOpts []Option
+	}{
+		// writer 的 Definition 写在 payload 中
+		"embedded schema": {encodeOpts: []Option{WithEmbeddedSchema(true)}},
+		// payload 只有 schema id，reader 从 Registry 中找到 writer 的 Definition
+		"schema id": {decodeOpts: []Option{WithWriters(registry)}},
+	}
+	for name, variant := range variants {
+		for _, opts := range [][]Option{nil, {WithLeb128(false)}} {
+			t.Run(name, func(t *testing.T) {
+				encodeOpts := append(append([]Option{}, opts...), variant.encodeOpts...)
+				decodeOpts := append(append([]Option{}, opts...), variant.decodeOpts...)
+				// 新的 exporter，旧的 receiver：note 被跳过，title 按 Tag 读作 name
+				var buf bytes.Buffer
+				note := map[string]model.Value{"note": &model.StringValue{Data: "new"}}
+				_, err := NewEntryEncoder(v2, encodeOpts...).Encode(itemsValue("title", note, "a", "b"), &buf)
+				require.NoError(t, err)
+				decoded, _, err := NewEntryDecoder(v1, decodeOpts...).Decode(bytes.NewReader(buf.Bytes()))
+				require.NoError(t, err)
+				assert.Equal(t, itemsValue("name", nil, "a", "b").Hash(), decoded.Hash())
+
+				// 旧的 exporter，新的 receiver：note 为 null
+				buf.Reset()
+				_, err = NewEntryEncoder(v1, encodeOpts...).Encode(itemsValue("name", nil, "a", "b"), &buf)
+				require.NoError(t, err)
+				decoder := NewEntryDecoder(v2, decodeOpts...)
+				decoded, _, err = decoder.Decode(bytes.NewReader(buf.Bytes()))
+				require.NoError(t, err)
+				expected := itemsValue("title", map[string]model.Value{"note": nil}, "a", "b")
+				assert.Equal(t, expected.Hash(), decoded.Hash())
+
+				// 同一个 writer 的 Decoder 会被缓存
+				_, _, err = decoder.Decode(bytes.NewReader(buf.Bytes()))
+				require.NoError(t, err)
+				assert.Len(t, decoder.writers.decoders, 1)
+			})
+		}
+	}
+}
+
+func TestIncompatiblePayload(t *testing.T) {
+	registry := itemsRegistry(t)
+	v1, err := registry.Get("items", 1)
+	require.NoError(t, err)
+	v3, err := registry.Get("items", 3)
+	require.NoError(t, err)
+	var embedded, id bytes.Buffer
+	_, err = NewEntryEncoder(v1, WithEmbeddedSchema(true)).Encode(itemsValue("name", nil, "a"), &embedded)
+	require.NoError(t, err)
+	_, err = NewEntryEncoder(v1).Encode(itemsValue("name", nil, "a"), &id)
+	require.NoError(t, err)
+	for _, payload := range [][]byte{embedded.Bytes(), id.Bytes()} {
+		_, _, err = NewEntryDecoder(v3, WithWriters(registry)).Decode(bytes.NewReader(payload))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "items.item.required: missing in writer and not nullable")
+	}
+
+	// 没有 WithWriters 时不认识其他版本的 schema id
+	_, _, err = NewEntryDecoder(v3).Decode(bytes.NewReader(id.Bytes()))
+	assert.EqualError(t, err, "payload Definition version 1 is unknown, register it with WithWriters or embed the schema in the payload")
+	_, _, err = NewEntryDecoder(v3, WithWriters(model.NewRegistry())).Decode(bytes.NewReader(id.Bytes()))
+	assert.EqualError(t, err, "payload Definition: Definition items version 1 is not registered, register it with WithWriters or embed the schema in the payload")
+
+	// 注册的同版本 Definition 指纹不同
+	changed := mustDefinition(t, itemsV1)
+	changed.Fields["items"].ItemDefinition.Fields["name"].Pooled = false
+	other := model.NewRegistry()
+	_, err = other.Register("items", changed)
+	require.NoError(t, err)
+	_, _, err = NewEntryDecoder(v3, WithWriters(other)).Decode(bytes.NewReader(id.Bytes()))
+	assert.EqualError(t, err, "payload Definition version 1 does not match the registered one")
+
+	// 损坏的 schema
+	payload := append([]byte{}, embedded.Bytes()...)
+	payload[len(schemaMagic)] = 0x7f
+	_, _, err = NewDecoder(mustDefinition(t, itemsV1)).Decode(bytes.NewReader(payload))
+	assert.Error(t, err)
+	_, _, err = NewDecoder(mustDefinition(t, itemsV1)).Decode(bytes.NewReader(id.Bytes()[:len(schemaIdMagic)+3]))
+	assert.ErrorContains(t, err, "schema id: ")
+}
+
+func TestRollingUpgradeTraces(t *testing.T) {
+	v1, err := model.GetDefinitionFromJSON(defaultTraceModelJSON(t))
+	require.NoError(t, err)
+	v1.Version = 1
+	v2 := v1.Clone()
+	v2.Version = 2
+	spanDef := v2.Fields["resourceSpans"].ItemDefinition.Fields["scopeSpans"].ItemDefinition.Fields["spans"].ItemDefinition
+	spanDef.Fields["flags"] = &model.Definition{Type: model.Integer, Nullable: true}
+	require.NoError(t, model.ValidateDefinition(v2))
+
+	registry := model.NewRegistry()
+	for _, def := range []*model.Definition{v1, v2} {
+		_, err := registry.Register(model.Traces, def)
+		require.NoError(t, err)
+	}
+
+	td := newRichTraces(20)
+	for _, pair := range [][2]*model.Definition{{v2, v1}, {v1, v2}} {
+		writer, err := registry.Get(model.Traces, pair[0].Version)
+		require.NoError(t, err)
+		reader, err := registry.Get(model.Traces, pair[1].Version)
+		require.NoError(t, err)
+		for kind, encodeOpts := range map[string][]Option{InspectSchema: {WithEmbeddedSchema(true)}, InspectSchemaId: nil} {
+			var buf bytes.Buffer
+			_, err = NewEntryEncoder(writer, encodeOpts...).EncodeTraces(td, &buf)
+			require.NoError(t, err)
+			decoded, stats, err := NewEntryDecoder(reader, WithWriters(registry)).DecodeTraces(bytes.NewReader(buf.Bytes()))
+			require.NoError(t, err)
+			assert.Equal(t, 0, model.ValueComparator(TracesToValue(td), TracesToValue(decoded)))
+			assert.Greater(t, stats.SchemaBytes, 0)
+
+			root, err := NewEntryDecoder(reader, WithWriters(registry)).Inspect(bytes.NewReader(buf.Bytes()))
+			require.NoError(t, err)
+			assert.Equal(t, kind, root.Children[0].Kind)
+			assert.Equal(t, pair[0].Version, root.Children[0].Value)
+			checkInspectNode(t, root)
+		}
+	}
+
+	// 只有 Version 不同时直接使用自己的 Decoder，不需要注册 writer
+	v3 := v1.Clone()
+	v3.Version = 3
+	for _, encodeOpts := range [][]Option{nil, {WithEmbeddedSchema(true)}} {
+		var buf bytes.Buffer
+		_, err = NewEncoder(v3, encodeOpts...).EncodeTraces(td, &buf)
+		require.NoError(t, err)
+		decoder := NewDecoder(v1)
+		decoded, _, err := decoder.DecodeTraces(bytes.NewReader(buf.Bytes()))
+		require.NoError(t, err)
+		assert.Equal(t, 0, model.ValueComparator(TracesToValue(td), TracesToValue(decoded)))
+		for _, w := range decoder.writers.decoders {
+			assert.Same(t, decoder, w)
+		}
+	}
+}
+
+func TestVersionKeepsGenerated(t *testing.T) {
+	def, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(t, err)
+	versioned := def.Clone()
+	versioned.Version = 1
+	assert.NotNil(t, NewEncoder(versioned).generated)
+	td := newRichTraces(5)
+	var buf bytes.Buffer
+	_, err = NewEncoder(versioned).EncodeTraces(td, &buf)
+	require.NoError(t, err)
+	decoded, _, err := NewDecoder(versioned).DecodeTraces(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, 0, model.ValueComparator(TracesToValue(td), TracesToValue(decoded)))
+}
@@ -0,0 +1,121 @@
+package codec
+
+import (
+	"fmt"
+
+	"github.com/beet233/compressotelcollector/model"
+)
+
+// spansPath 是 span 的 Array 在 trace Definition 中的路径
+var spansPath = []string{"resourceSpans", "item", "scopeSpans", "item", "spans"}
+
+// unpooledSpans 返回 span 的 Array Definition，span 不是 Object 时返回 nil。
+// 需要按 span 的顺序编码时 span、它所在的 Array 及其上层都不能池化，否则 Decoder 在 header 中解码池子时 span 的顺序和编码时不同，同样返回 nil
+func unpooledSpans(def *model.Definition) *model.Definition {
+	spans := def
+	for _, name := range spansPath {
+		if spans == nil || spans.Pooled || spans.SharePooled {
+			return nil
+		}
+		if name == "item" {
+			spans = spans.ItemDefinition
+		} else {
+			spans = spans.Fields[name]
+		}
+	}
+	if spans == nil || spans.Type != model.Array || spans.Pooled || spans.SharePooled {
+		return nil
+	}
+	span := spans.ItemDefinition
+	if span == nil || span.Type != model.Object || span.Pooled || span.SharePooled {
+		return nil
+	}
+	return spans
+}
+
+// hasBytesFields 判断 def 的 fieldNames 是否都是 Bytes
+func hasBytesFields(def *model.Definition, fieldNames ...string) bool {
+	for _, fieldName := range fieldNames {
+		if fieldDef, ok := def.Fields[fieldName]; !ok || fieldDef.Type != model.Bytes {
+			return false
+		}
+	}
+	return true
+}
+
+// spanTree 是 parentSpanId 回引用的编码计划。开启 WithParentReferences 时，span 的 parentSpanId 之前写入一个整数：
+// 父 span 是同一批数据中同一个 trace 之前出现过的 span 时为它往回数的位置（1 为上一个），不再编码 parentSpanId 本身；
+// 否则为 0，之后按原来的方式编码 parentSpanId。Decoder 在整个 span 解码之后由 traceId 找到父 span 还原 parentSpanId
+type spanTree struct {
+	span *model.Definition
+}
+
+// planSpanTree 检查 def 能否使用 parentSpanId 回引用，不能或未开启时返回 nil
+func planSpanTree(def *model.Definition, opts options) *spanTree {
+	if !opts.parentReferences {
+		return nil
+	}
+	spans := unpooledSpans(def)
+	if spans == nil || !hasBytesFields(spans.ItemDefinition, "traceId", "spanId", "parentSpanId") {
+		return nil
+	}
+	return &spanTree{span: spans.ItemDefinition}
+}
+
+// isParent 判断 def 中的 fieldName 是否为按回引用编码的 parentSpanId
+func (t *spanTree) isParent(def *model.Definition, fieldName string) bool {
+	return t != nil && def == t.span && fieldName == "parentSpanId"
+}
+
+// isSpan 判断 def 是否为 span
+func (t *spanTree) isSpan(def *model.Definition) bool {
+	return t != nil && def == t.span
+}
+
+// encodedSpans 是 Encoder 中每个 trace 已经编码的 span，key 为 traceId
+type encodedSpans map[string]*encodedTrace
+
+type encodedTrace struct {
+	count int
+	// positions 是每个 spanId 最后一次出现的位置
+	positions map[string]int
+}
+
+// distance 返回 parentSpanId 往回数的位置，不在同一批数据中时返回 0
+func (spans encodedSpans) distance(traceId []byte, parentSpanId []byte) int {
+	trace, ok := spans[string(traceId)]
+	if !ok || len(parentSpanId) == 0 {
+		return 0
+	}
+	position, ok := trace.positions[string(parentSpanId)]
+	if !ok {
+		return 0
+	}
+	return trace.count - position
+}
+
+func (spans encodedSpans) add(traceId []byte, spanId []byte) {
+	trace, ok := spans[string(traceId)]
+	if !ok {
+		trace = &encodedTrace{positions: make(map[string]int)}
+		spans[string(traceId)] = trace
+	}
+	trace.positions[string(spanId)] = trace.count
+	trace.count++
+}
+
+// decodedSpans 是 Decoder 中每个 trace 已经解码的 span 的 spanId，key 为 traceId
+type decodedSpans map[string][][]byte
+
+// resolve 返回 traceId 中往回数第 distance 个 span 的 spanId
+func (spans decodedSpans) resolve(traceId []byte, distance int) ([]byte, error) {
+	trace := spans[string(traceId)]
+	if distance < 1 || distance > len(trace) {
+		return nil, fmt.Errorf("parentSpanId reference %d out of range of %d spans in the trace", distance, len(trace))
+	}
+	return trace[len(trace)-distance], nil
+}
+
+func (spans decodedSpans) add(traceId []byte, spanId []byte) {
+	spans[string(traceId)] = append(spans[string(traceId)], spanId)
+}
@@ -0,0 +1,158 @@
+package codec
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/beet233/compressotelcollector/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+func spanDefinitionOf(def *model.Definition) *model.Definition {
+	return def.Fields["resourceSpans"].ItemDefinition.Fields["scopeSpans"].ItemDefinition.Fields["spans"].ItemDefinition
+}
+
+// findSpanReference 返回第一个 Value 为 distance 的 spanReference 节点
+func findSpanReference(n *InspectNode, distance int) *InspectNode {
+	if n.Kind == InspectSpanReference && n.Value == distance {
+		return n
+	}
+	for _, child := range n.Children {
+		if found := findSpanReference(child, distance); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func TestParentReferences(t *testing.T) {
+	def, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(t, err)
+	td := newRichTraces(30)
+	// 父 span 不在同一批数据中的 span 按原来的方式编码
+	td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).SetParentSpanID([8]byte{7, 7, 7})
+	expected := TracesToValue(td)
+
+	for _, opts := range [][]Option{nil, {WithLeb128(false), WithStringPool(false)}} {
+		var plain bytes.Buffer
+		_, err = NewEncoder(def, opts...).EncodeTraces(td, &plain)
+		require.NoError(t, err)
+		// Decode 把空的值解码为 null，和不开启时的结果比较
+		plainValue, _, err := NewDecoder(def, opts...).Decode(bytes.NewReader(plain.Bytes()))
+		require.NoError(t, err)
+		leb128 := len(opts) == 0
+
+		opts = append([]Option{WithParentReferences(true)}, opts...)
+		encoder := NewEncoder(def, opts...)
+		require.NotNil(t, encoder.tree)
+		assert.Nil(t, encoder.generated)
+		var direct, generic bytes.Buffer
+		_, err = encoder.EncodeTraces(td, &direct)
+		require.NoError(t, err)
+		_, err = encoder.Encode(expected, &generic)
+		require.NoError(t, err)
+		assert.Equal(t, direct.Bytes(), generic.Bytes())
+		// 定长整数的回引用和池索引一样占 8 个 byte，只有 leb128 时 payload 更小
+		if leb128 {
+			assert.Less(t, direct.Len(), plain.Len())
+		}
+
+		decoder := NewDecoder(def, opts...)
+		got, _, err := decoder.DecodeTraces(bytes.NewReader(direct.Bytes()))
+		require.NoError(t, err)
+		assert.Equal(t, 0, model.ValueComparator(expected, TracesToValue(got)))
+		value, _, err := decoder.Decode(bytes.NewReader(direct.Bytes()))
+		require.NoError(t, err)
+		assert.Equal(t, 0, model.ValueComparator(plainValue, value))
+
+		root, err := decoder.Inspect(bytes.NewReader(direct.Bytes()))
+		require.NoError(t, err)
+		checkInspectNode(t, root)
+		assert.NotNil(t, findSpanReference(root, 0))
+		assert.NotNil(t, findSpanReference(root, 1))
+	}
+}
+
+func TestParentReferencesSameTraceOnly(t *testing.T) {
+	def, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(t, err)
+	td := ptrace.NewTraces()
+	spans := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	root := spans.AppendEmpty()
+	root.SetTraceID([16]byte{1})
+	root.SetSpanID([8]byte{1})
+	// spanId 相同但 traceId 不同，不能引用 root
+	other := spans.AppendEmpty()
+	other.SetTraceID([16]byte{2})
+	other.SetSpanID([8]byte{2})
+	other.SetParentSpanID([8]byte{1})
+	child := spans.AppendEmpty()
+	child.SetTraceID([16]byte{1})
+	child.SetSpanID([8]byte{3})
+	child.SetParentSpanID([8]byte{1})
+
+	var buf bytes.Buffer
+	_, err = NewEncoder(def, WithParentReferences(true)).EncodeTraces(td, &buf)
+	require.NoError(t, err)
+	decoder := NewDecoder(def, WithParentReferences(true))
+	inspected, err := decoder.Inspect(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	// other 的父 span 不在它的 trace 中，child 往回数第 1 个 span 是 root
+	assert.Nil(t, findSpanReference(inspected, 2))
+	assert.NotNil(t, findSpanReference(inspected, 1))
+	got, _, err := decoder.DecodeTraces(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, 0, model.ValueComparator(TracesToValue(td), TracesToValue(got)))
+}
+
+func TestParentReferencesOutOfRange(t *testing.T) {
+	def, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	_, err = NewEncoder(def, WithParentReferences(true)).EncodeTraces(newRichTraces(3), &buf)
+	require.NoError(t, err)
+	decoder := NewDecoder(def, WithParentReferences(true))
+	root, err := decoder.Inspect(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	// 第二个 span 引用了 trace 中唯一的前一个 span，改为往回数第 9 个
+	reference := findSpanReference(root, 1)
+	require.NotNil(t, reference)
+	data := bytes.Clone(buf.Bytes())
+	data[reference.Offset] = 9
+
+	_, _, err = decoder.DecodeTraces(bytes.NewReader(data))
+	assert.ErrorContains(t, err, "parentSpanId reference 9 out of range of 1 spans in the trace")
+	_, _, err = decoder.Decode(bytes.NewReader(data))
+	assert.ErrorContains(t, err, "parentSpanId reference 9 out of range of 1 spans in the trace")
+}
+
+func TestPlanSpanTree(t *testing.T) {
+	def, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(t, err)
+	assert.Nil(t, planSpanTree(def, newOptions(nil)))
+	tree := planSpanTree(def, newOptions([]Option{WithParentReferences(true)}))
+	require.NotNil(t, tree)
+	assert.Same(t, spanDefinitionOf(def), tree.span)
+	assert.True(t, tree.isParent(tree.span, "parentSpanId"))
+	assert.False(t, tree.isParent(tree.span, "spanId"))
+
+	// 池化的 span 在 header 中解码，顺序和编码时不同，不使用回引用
+	pooled := def.Clone()
+	spanDefinitionOf(pooled).Pooled = true
+	encoder := NewEncoder(pooled, WithParentReferences(true))
+	assert.Nil(t, encoder.tree)
+	var buf bytes.Buffer
+	_, err = encoder.EncodeTraces(newRichTraces(5), &buf)
+	require.NoError(t, err)
+	got, _, err := NewDecoder(pooled, WithParentReferences(true)).DecodeTraces(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, 0, model.ValueComparator(TracesToValue(newRichTraces(5)), TracesToValue(got)))
+
+	// parentSpanId 不是 bytes 时也不使用
+	stringParent := def.Clone()
+	spanDefinitionOf(stringParent).Fields["parentSpanId"] = &model.Definition{Type: model.String}
+	assert.Nil(t, planSpanTree(stringParent, newOptions([]Option{WithParentReferences(true)})))
+}
@@ -0,0 +1,2206 @@
+// Code generated by cprvalgen from trace.json. DO NOT EDIT.
+
+package codec
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/beet233/compressotelcollector/model"
+)
+
+func init() {
+	registerGenerated(&generatedCodec{
+		fingerprint: "4253ccbfd9d63b6dfe1b57e3584a6379972ac75ed0590a2d0bd5713287b342e6",
+		poolIds: []string{
+			"resourceSpans item resource",
+			"resourceSpans item resource attributes",
+			"resourceSpans item schemaUrl",
+			"resourceSpans item scopeSpans item schemaUrl",
+			"resourceSpans item scopeSpans item scope",
+			"resourceSpans item scopeSpans item scope attributes",
+			"resourceSpans item scopeSpans item scope name",
+			"resourceSpans item scopeSpans item scope version",
+			"resourceSpans item scopeSpans item spans item attributes",
+			"resourceSpans item scopeSpans item spans item events item attributes",
+			"resourceSpans item scopeSpans item spans item events item name",
+			"resourceSpans item scopeSpans item spans item links item",
+			"resourceSpans item scopeSpans item spans item links item attributes",
+			"spanId",
+			"traceId",
+			"traceState",
+			"resourceSpans item scopeSpans item spans item name",
+			"resourceSpans item scopeSpans item spans item status",
+			"resourceSpans item scopeSpans item spans item status message",
+		},
+		diffFields: 3,
+		encode:     encodeTrace,
+		decode:     decodeTrace,
+	})
+}
+
+// encodeTrace 编码根节点
+func encodeTrace(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil {
+		return errors.New(notNullableErrMsg)
+	}
+	v, ok := val.(*model.ObjectValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	out := buf
+	if err := encodeTraceResourceSpans(g, v.Data["resourceSpans"], out); err != nil {
+		return err
+	}
+	return nil
+}
+
+// encodeTraceResourceSpans 编码 "resourceSpans"
+func encodeTraceResourceSpans(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil || isNullValue(val) {
+		return WriteBoolean(buf, false)
+	}
+	if err := WriteBoolean(buf, true); err != nil {
+		return err
+	}
+	v, ok := val.(*model.ArrayValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	out := buf
+	if err := g.e.encodeInt(len(v.Data), out); err != nil {
+		return err
+	}
+	for _, item := range v.Data {
+		if err := encodeTraceResourceSpansItem(g, item, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeTraceResourceSpansItem 编码 "resourceSpans item"
+func encodeTraceResourceSpansItem(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil {
+		return errors.New(notNullableErrMsg)
+	}
+	v, ok := val.(*model.ObjectValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	out := buf
+	if err := encodeTraceResourceSpansItemResource(g, v.Data["resource"], out); err != nil {
+		return err
+	}
+	if err := encodeTraceResourceSpansItemSchemaUrl(g, v.Data["schemaUrl"], out); err != nil {
+		return err
+	}
+	if err := encodeTraceResourceSpansItemScopeSpans(g, v.Data["scopeSpans"], out); err != nil {
+		return err
+	}
+	return nil
+}
+
+// encodeTraceResourceSpansItemResource 编码 "resourceSpans item resource"
+func encodeTraceResourceSpansItemResource(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil {
+		return errors.New(notNullableErrMsg)
+	}
+	v, ok := val.(*model.ObjectValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	index, isNew := g.poolIndex(0, val)
+	if !isNew {
+		return g.reference(0, index, buf)
+	}
+	out := g.e.bufferPool.Get().(*bytes.Buffer)
+	if err := encodeTraceResourceSpansItemResourceAttributes(g, v.Data["attributes"], out); err != nil {
+		return err
+	}
+	if err := encodeTraceResourceSpansItemResourceDroppedAttributesCount(g, v.Data["droppedAttributesCount"], out); err != nil {
+		return err
+	}
+	return g.endPooled(0, index, out, buf)
+}
+
+// encodeTraceResourceSpansItemResourceAttributes 编码 "resourceSpans item resource attributes"
+func encodeTraceResourceSpansItemResourceAttributes(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil || isNullValue(val) {
+		return WriteBoolean(buf, false)
+	}
+	if err := WriteBoolean(buf, true); err != nil {
+		return err
+	}
+	v, ok := val.(*model.ObjectValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	index, isNew := g.poolIndex(1, val)
+	if !isNew {
+		return g.reference(1, index, buf)
+	}
+	out := g.e.bufferPool.Get().(*bytes.Buffer)
+	if err := g.e.innerFreeMapEncode(v.Data, g.encodeState, out); err != nil {
+		return err
+	}
+	return g.endPooled(1, index, out, buf)
+}
+
+// encodeTraceResourceSpansItemResourceDroppedAttributesCount 编码 "resourceSpans item resource droppedAttributesCount"
+func encodeTraceResourceSpansItemResourceDroppedAttributesCount(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil || isNullValue(val) {
+		return WriteBoolean(buf, false)
+	}
+	if err := WriteBoolean(buf, true); err != nil {
+		return err
+	}
+	v, ok := val.(*model.IntegerValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	return g.e.encodeInt(v.Data, buf)
+}
+
+// encodeTraceResourceSpansItemSchemaUrl 编码 "resourceSpans item schemaUrl"
+func encodeTraceResourceSpansItemSchemaUrl(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil || isNullValue(val) {
+		return WriteBoolean(buf, false)
+	}
+	if err := WriteBoolean(buf, true); err != nil {
+		return err
+	}
+	v, ok := val.(*model.StringValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	index, isNew := g.poolIndex(2, val)
+	if !isNew {
+		return g.reference(2, index, buf)
+	}
+	out := g.e.bufferPool.Get().(*bytes.Buffer)
+	if err := g.e.encodeInt(len(v.Data), out); err != nil {
+		return err
+	}
+	if _, err := out.WriteString(v.Data); err != nil {
+		return err
+	}
+	return g.endPooled(2, index, out, buf)
+}
+
+// encodeTraceResourceSpansItemScopeSpans 编码 "resourceSpans item scopeSpans"
+func encodeTraceResourceSpansItemScopeSpans(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil || isNullValue(val) {
+		return WriteBoolean(buf, false)
+	}
+	if err := WriteBoolean(buf, true); err != nil {
+		return err
+	}
+	v, ok := val.(*model.ArrayValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	out := buf
+	if err := g.e.encodeInt(len(v.Data), out); err != nil {
+		return err
+	}
+	for _, item := range v.Data {
+		if err := encodeTraceResourceSpansItemScopeSpansItem(g, item, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeTraceResourceSpansItemScopeSpansItem 编码 "resourceSpans item scopeSpans item"
+func encodeTraceResourceSpansItemScopeSpansItem(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil {
+		return errors.New(notNullableErrMsg)
+	}
+	v, ok := val.(*model.ObjectValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	out := buf
+	if err := encodeTraceResourceSpansItemScopeSpansItemSchemaUrl(g, v.Data["schemaUrl"], out); err != nil {
+		return err
+	}
+	if err := encodeTraceResourceSpansItemScopeSpansItemScope(g, v.Data["scope"], out); err != nil {
+		return err
+	}
+	if err := encodeTraceResourceSpansItemScopeSpansItemSpans(g, v.Data["spans"], out); err != nil {
+		return err
+	}
+	return nil
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemSchemaUrl 编码 "resourceSpans item scopeSpans item schemaUrl"
+func encodeTraceResourceSpansItemScopeSpansItemSchemaUrl(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil || isNullValue(val) {
+		return WriteBoolean(buf, false)
+	}
+	if err := WriteBoolean(buf, true); err != nil {
+		return err
+	}
+	v, ok := val.(*model.StringValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	index, isNew := g.poolIndex(3, val)
+	if !isNew {
+		return g.reference(3, index, buf)
+	}
+	out := g.e.bufferPool.Get().(*bytes.Buffer)
+	if err := g.e.encodeInt(len(v.Data), out); err != nil {
+		return err
+	}
+	if _, err := out.WriteString(v.Data); err != nil {
+		return err
+	}
+	return g.endPooled(3, index, out, buf)
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemScope 编码 "resourceSpans item scopeSpans item scope"
+func encodeTraceResourceSpansItemScopeSpansItemScope(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil {
+		return errors.New(notNullableErrMsg)
+	}
+	v, ok := val.(*model.ObjectValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	index, isNew := g.poolIndex(4, val)
+	if !isNew {
+		return g.reference(4, index, buf)
+	}
+	out := g.e.bufferPool.Get().(*bytes.Buffer)
+	if err := encodeTraceResourceSpansItemScopeSpansItemScopeAttributes(g, v.Data["attributes"], out); err != nil {
+		return err
+	}
+	if err := encodeTraceResourceSpansItemScopeSpansItemScopeDroppedAttributesCount(g, v.Data["droppedAttributesCount"], out); err != nil {
+		return err
+	}
+	if err := encodeTraceResourceSpansItemScopeSpansItemScopeName(g, v.Data["name"], out); err != nil {
+		return err
+	}
+	if err := encodeTraceResourceSpansItemScopeSpansItemScopeVersion(g, v.Data["version"], out); err != nil {
+		return err
+	}
+	return g.endPooled(4, index, out, buf)
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemScopeAttributes 编码 "resourceSpans item scopeSpans item scope attributes"
+func encodeTraceResourceSpansItemScopeSpansItemScopeAttributes(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil || isNullValue(val) {
+		return WriteBoolean(buf, false)
+	}
+	if err := WriteBoolean(buf, true); err != nil {
+		return err
+	}
+	v, ok := val.(*model.ObjectValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	index, isNew := g.poolIndex(5, val)
+	if !isNew {
+		return g.reference(5, index, buf)
+	}
+	out := g.e.bufferPool.Get().(*bytes.Buffer)
+	if err := g.e.innerFreeMapEncode(v.Data, g.encodeState, out); err != nil {
+		return err
+	}
+	return g.endPooled(5, index, out, buf)
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemScopeDroppedAttributesCount 编码 "resourceSpans item scopeSpans item scope droppedAttributesCount"
+func encodeTraceResourceSpansItemScopeSpansItemScopeDroppedAttributesCount(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil || isNullValue(val) {
+		return WriteBoolean(buf, false)
+	}
+	if err := WriteBoolean(buf, true); err != nil {
+		return err
+	}
+	v, ok := val.(*model.IntegerValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	return g.e.encodeInt(v.Data, buf)
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemScopeName 编码 "resourceSpans item scopeSpans item scope name"
+func encodeTraceResourceSpansItemScopeSpansItemScopeName(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil || isNullValue(val) {
+		return WriteBoolean(buf, false)
+	}
+	if err := WriteBoolean(buf, true); err != nil {
+		return err
+	}
+	v, ok := val.(*model.StringValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	index, isNew := g.poolIndex(6, val)
+	if !isNew {
+		return g.reference(6, index, buf)
+	}
+	out := g.e.bufferPool.Get().(*bytes.Buffer)
+	if err := g.e.encodeInt(len(v.Data), out); err != nil {
+		return err
+	}
+	if _, err := out.WriteString(v.Data); err != nil {
+		return err
+	}
+	return g.endPooled(6, index, out, buf)
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemScopeVersion 编码 "resourceSpans item scopeSpans item scope version"
+func encodeTraceResourceSpansItemScopeSpansItemScopeVersion(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil || isNullValue(val) {
+		return WriteBoolean(buf, false)
+	}
+	if err := WriteBoolean(buf, true); err != nil {
+		return err
+	}
+	v, ok := val.(*model.StringValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	index, isNew := g.poolIndex(7, val)
+	if !isNew {
+		return g.reference(7, index, buf)
+	}
+	out := g.e.bufferPool.Get().(*bytes.Buffer)
+	if err := g.e.encodeInt(len(v.Data), out); err != nil {
+		return err
+	}
+	if _, err := out.WriteString(v.Data); err != nil {
+		return err
+	}
+	return g.endPooled(7, index, out, buf)
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemSpans 编码 "resourceSpans item scopeSpans item spans"
+func encodeTraceResourceSpansItemScopeSpansItemSpans(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil || isNullValue(val) {
+		return WriteBoolean(buf, false)
+	}
+	if err := WriteBoolean(buf, true); err != nil {
+		return err
+	}
+	v, ok := val.(*model.ArrayValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	out := buf
+	if err := g.e.encodeInt(len(v.Data), out); err != nil {
+		return err
+	}
+	for _, item := range v.Data {
+		if err := encodeTraceResourceSpansItemScopeSpansItemSpansItem(g, item, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemSpansItem 编码 "resourceSpans item scopeSpans item spans item"
+func encodeTraceResourceSpansItemScopeSpansItemSpansItem(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil {
+		return errors.New(notNullableErrMsg)
+	}
+	v, ok := val.(*model.ObjectValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	out := buf
+	if err := encodeTraceResourceSpansItemScopeSpansItemSpansItemAttributes(g, v.Data["attributes"], out); err != nil {
+		return err
+	}
+	if err := encodeTraceResourceSpansItemScopeSpansItemSpansItemDroppedAttributesCount(g, v.Data["droppedAttributesCount"], out); err != nil {
+		return err
+	}
+	if err := encodeTraceResourceSpansItemScopeSpansItemSpansItemDroppedEventsCount(g, v.Data["droppedEventsCount"], out); err != nil {
+		return err
+	}
+	if err := encodeTraceResourceSpansItemScopeSpansItemSpansItemDroppedLinksCount(g, v.Data["droppedLinksCount"], out); err != nil {
+		return err
+	}
+	if err := encodeTraceResourceSpansItemScopeSpansItemSpansItemEndTimeUnixNano(g, v.Data["endTimeUnixNano"], out); err != nil {
+		return err
+	}
+	if err := encodeTraceResourceSpansItemScopeSpansItemSpansItemEvents(g, v.Data["events"], out); err != nil {
+		return err
+	}
+	if err := encodeTraceResourceSpansItemScopeSpansItemSpansItemKind(g, v.Data["kind"], out); err != nil {
+		return err
+	}
+	if err := encodeTraceResourceSpansItemScopeSpansItemSpansItemLinks(g, v.Data["links"], out); err != nil {
+		return err
+	}
+	if err := encodeTraceResourceSpansItemScopeSpansItemSpansItemName(g, v.Data["name"], out); err != nil {
+		return err
+	}
+	if err := encodeTraceResourceSpansItemScopeSpansItemSpansItemParentSpanId(g, v.Data["parentSpanId"], out); err != nil {
+		return err
+	}
+	if err := encodeTraceResourceSpansItemScopeSpansItemSpansItemSpanId(g, v.Data["spanId"], out); err != nil {
+		return err
+	}
+	if err := encodeTraceResourceSpansItemScopeSpansItemSpansItemStartTimeUnixNano(g, v.Data["startTimeUnixNano"], out); err != nil {
+		return err
+	}
+	if err := encodeTraceResourceSpansItemScopeSpansItemSpansItemStatus(g, v.Data["status"], out); err != nil {
+		return err
+	}
+	if err := encodeTraceResourceSpansItemScopeSpansItemSpansItemTraceId(g, v.Data["traceId"], out); err != nil {
+		return err
+	}
+	if err := encodeTraceResourceSpansItemScopeSpansItemSpansItemTraceState(g, v.Data["traceState"], out); err != nil {
+		return err
+	}
+	return nil
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemSpansItemAttributes 编码 "resourceSpans item scopeSpans item spans item attributes"
+func encodeTraceResourceSpansItemScopeSpansItemSpansItemAttributes(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil || isNullValue(val) {
+		return WriteBoolean(buf, false)
+	}
+	if err := WriteBoolean(buf, true); err != nil {
+		return err
+	}
+	v, ok := val.(*model.ObjectValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	index, isNew := g.poolIndex(8, val)
+	if !isNew {
+		return g.reference(8, index, buf)
+	}
+	out := g.e.bufferPool.Get().(*bytes.Buffer)
+	if err := g.e.innerFreeMapEncode(v.Data, g.encodeState, out); err != nil {
+		return err
+	}
+	return g.endPooled(8, index, out, buf)
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemSpansItemDroppedAttributesCount 编码 "resourceSpans item scopeSpans item spans item droppedAttributesCount"
+func encodeTraceResourceSpansItemScopeSpansItemSpansItemDroppedAttributesCount(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil || isNullValue(val) {
+		return WriteBoolean(buf, false)
+	}
+	if err := WriteBoolean(buf, true); err != nil {
+		return err
+	}
+	v, ok := val.(*model.IntegerValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	return g.e.encodeInt(v.Data, buf)
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemSpansItemDroppedEventsCount 编码 "resourceSpans item scopeSpans item spans item droppedEventsCount"
+func encodeTraceResourceSpansItemScopeSpansItemSpansItemDroppedEventsCount(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil || isNullValue(val) {
+		return WriteBoolean(buf, false)
+	}
+	if err := WriteBoolean(buf, true); err != nil {
+		return err
+	}
+	v, ok := val.(*model.IntegerValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	return g.e.encodeInt(v.Data, buf)
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemSpansItemDroppedLinksCount 编码 "resourceSpans item scopeSpans item spans item droppedLinksCount"
+func encodeTraceResourceSpansItemScopeSpansItemSpansItemDroppedLinksCount(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil || isNullValue(val) {
+		return WriteBoolean(buf, false)
+	}
+	if err := WriteBoolean(buf, true); err != nil {
+		return err
+	}
+	v, ok := val.(*model.IntegerValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	return g.e.encodeInt(v.Data, buf)
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemSpansItemEndTimeUnixNano 编码 "resourceSpans item scopeSpans item spans item endTimeUnixNano"
+func encodeTraceResourceSpansItemScopeSpansItemSpansItemEndTimeUnixNano(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil {
+		return errors.New(notNullableErrMsg)
+	}
+	v, ok := val.(*model.IntegerValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	return g.e.encodeInt(g.diff(0, v.Data), buf)
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemSpansItemEvents 编码 "resourceSpans item scopeSpans item spans item events"
+func encodeTraceResourceSpansItemScopeSpansItemSpansItemEvents(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil || isNullValue(val) {
+		return WriteBoolean(buf, false)
+	}
+	if err := WriteBoolean(buf, true); err != nil {
+		return err
+	}
+	v, ok := val.(*model.ArrayValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	out := buf
+	if err := g.e.encodeInt(len(v.Data), out); err != nil {
+		return err
+	}
+	for _, item := range v.Data {
+		if err := encodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItem(g, item, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItem 编码 "resourceSpans item scopeSpans item spans item events item"
+func encodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItem(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil {
+		return errors.New(notNullableErrMsg)
+	}
+	v, ok := val.(*model.ObjectValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	out := buf
+	if err := encodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItemAttributes(g, v.Data["attributes"], out); err != nil {
+		return err
+	}
+	if err := encodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItemDroppedAttributesCount(g, v.Data["droppedAttributesCount"], out); err != nil {
+		return err
+	}
+	if err := encodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItemName(g, v.Data["name"], out); err != nil {
+		return err
+	}
+	if err := encodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItemTimeUnixNano(g, v.Data["timeUnixNano"], out); err != nil {
+		return err
+	}
+	return nil
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItemAttributes 编码 "resourceSpans item scopeSpans item spans item events item attributes"
+func encodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItemAttributes(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil || isNullValue(val) {
+		return WriteBoolean(buf, false)
+	}
+	if err := WriteBoolean(buf, true); err != nil {
+		return err
+	}
+	v, ok := val.(*model.ObjectValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	index, isNew := g.poolIndex(9, val)
+	if !isNew {
+		return g.reference(9, index, buf)
+	}
+	out := g.e.bufferPool.Get().(*bytes.Buffer)
+	if err := g.e.innerFreeMapEncode(v.Data, g.encodeState, out); err != nil {
+		return err
+	}
+	return g.endPooled(9, index, out, buf)
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItemDroppedAttributesCount 编码 "resourceSpans item scopeSpans item spans item events item droppedAttributesCount"
+func encodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItemDroppedAttributesCount(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil || isNullValue(val) {
+		return WriteBoolean(buf, false)
+	}
+	if err := WriteBoolean(buf, true); err != nil {
+		return err
+	}
+	v, ok := val.(*model.IntegerValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	return g.e.encodeInt(v.Data, buf)
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItemName 编码 "resourceSpans item scopeSpans item spans item events item name"
+func encodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItemName(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil || isNullValue(val) {
+		return WriteBoolean(buf, false)
+	}
+	if err := WriteBoolean(buf, true); err != nil {
+		return err
+	}
+	v, ok := val.(*model.StringValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	index, isNew := g.poolIndex(10, val)
+	if !isNew {
+		return g.reference(10, index, buf)
+	}
+	out := g.e.bufferPool.Get().(*bytes.Buffer)
+	if err := g.e.encodeInt(len(v.Data), out); err != nil {
+		return err
+	}
+	if _, err := out.WriteString(v.Data); err != nil {
+		return err
+	}
+	return g.endPooled(10, index, out, buf)
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItemTimeUnixNano 编码 "resourceSpans item scopeSpans item spans item events item timeUnixNano"
+func encodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItemTimeUnixNano(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil || isNullValue(val) {
+		return WriteBoolean(buf, false)
+	}
+	if err := WriteBoolean(buf, true); err != nil {
+		return err
+	}
+	v, ok := val.(*model.IntegerValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	return g.e.encodeInt(g.diff(1, v.Data), buf)
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemSpansItemKind 编码 "resourceSpans item scopeSpans item spans item kind"
+func encodeTraceResourceSpansItemScopeSpansItemSpansItemKind(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil || isNullValue(val) {
+		return WriteBoolean(buf, false)
+	}
+	if err := WriteBoolean(buf, true); err != nil {
+		return err
+	}
+	v, ok := val.(*model.IntegerValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	return g.e.encodeInt(v.Data, buf)
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemSpansItemLinks 编码 "resourceSpans item scopeSpans item spans item links"
+func encodeTraceResourceSpansItemScopeSpansItemSpansItemLinks(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil || isNullValue(val) {
+		return WriteBoolean(buf, false)
+	}
+	if err := WriteBoolean(buf, true); err != nil {
+		return err
+	}
+	v, ok := val.(*model.ArrayValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	out := buf
+	if err := g.e.encodeInt(len(v.Data), out); err != nil {
+		return err
+	}
+	for _, item := range v.Data {
+		if err := encodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItem(g, item, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItem 编码 "resourceSpans item scopeSpans item spans item links item"
+func encodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItem(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil {
+		return errors.New(notNullableErrMsg)
+	}
+	v, ok := val.(*model.ObjectValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	index, isNew := g.poolIndex(11, val)
+	if !isNew {
+		return g.reference(11, index, buf)
+	}
+	out := g.e.bufferPool.Get().(*bytes.Buffer)
+	if err := encodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemAttributes(g, v.Data["attributes"], out); err != nil {
+		return err
+	}
+	if err := encodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemDroppedAttributesCount(g, v.Data["droppedAttributesCount"], out); err != nil {
+		return err
+	}
+	if err := encodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemSpanId(g, v.Data["spanId"], out); err != nil {
+		return err
+	}
+	if err := encodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemTraceId(g, v.Data["traceId"], out); err != nil {
+		return err
+	}
+	if err := encodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemTraceState(g, v.Data["traceState"], out); err != nil {
+		return err
+	}
+	return g.endPooled(11, index, out, buf)
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemAttributes 编码 "resourceSpans item scopeSpans item spans item links item attributes"
+func encodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemAttributes(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil || isNullValue(val) {
+		return WriteBoolean(buf, false)
+	}
+	if err := WriteBoolean(buf, true); err != nil {
+		return err
+	}
+	v, ok := val.(*model.ObjectValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	index, isNew := g.poolIndex(12, val)
+	if !isNew {
+		return g.reference(12, index, buf)
+	}
+	out := g.e.bufferPool.Get().(*bytes.Buffer)
+	if err := g.e.innerFreeMapEncode(v.Data, g.encodeState, out); err != nil {
+		return err
+	}
+	return g.endPooled(12, index, out, buf)
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemDroppedAttributesCount 编码 "resourceSpans item scopeSpans item spans item links item droppedAttributesCount"
+func encodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemDroppedAttributesCount(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil || isNullValue(val) {
+		return WriteBoolean(buf, false)
+	}
+	if err := WriteBoolean(buf, true); err != nil {
+		return err
+	}
+	v, ok := val.(*model.IntegerValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	return g.e.encodeInt(v.Data, buf)
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemSpanId 编码 "resourceSpans item scopeSpans item spans item links item spanId"
+func encodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemSpanId(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil || isNullValue(val) {
+		return WriteBoolean(buf, false)
+	}
+	if err := WriteBoolean(buf, true); err != nil {
+		return err
+	}
+	v, ok := val.(*model.BytesValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	if len(v.Data) != 8 {
+		return errors.New(fixedLengthErrMsg)
+	}
+	index, isNew := g.poolIndex(13, val)
+	if !isNew {
+		return g.reference(13, index, buf)
+	}
+	out := g.e.bufferPool.Get().(*bytes.Buffer)
+	if _, err := out.Write(v.Data); err != nil {
+		return err
+	}
+	return g.endPooled(13, index, out, buf)
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemTraceId 编码 "resourceSpans item scopeSpans item spans item links item traceId"
+func encodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemTraceId(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil {
+		return errors.New(notNullableErrMsg)
+	}
+	v, ok := val.(*model.BytesValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	if len(v.Data) != 16 {
+		return errors.New(fixedLengthErrMsg)
+	}
+	index, isNew := g.poolIndex(14, val)
+	if !isNew {
+		return g.reference(14, index, buf)
+	}
+	out := g.e.bufferPool.Get().(*bytes.Buffer)
+	if _, err := out.Write(v.Data); err != nil {
+		return err
+	}
+	return g.endPooled(14, index, out, buf)
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemTraceState 编码 "resourceSpans item scopeSpans item spans item links item traceState"
+func encodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemTraceState(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil || isNullValue(val) {
+		return WriteBoolean(buf, false)
+	}
+	if err := WriteBoolean(buf, true); err != nil {
+		return err
+	}
+	v, ok := val.(*model.StringValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	index, isNew := g.poolIndex(15, val)
+	if !isNew {
+		return g.reference(15, index, buf)
+	}
+	out := g.e.bufferPool.Get().(*bytes.Buffer)
+	if err := g.e.encodeInt(len(v.Data), out); err != nil {
+		return err
+	}
+	if _, err := out.WriteString(v.Data); err != nil {
+		return err
+	}
+	return g.endPooled(15, index, out, buf)
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemSpansItemName 编码 "resourceSpans item scopeSpans item spans item name"
+func encodeTraceResourceSpansItemScopeSpansItemSpansItemName(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil {
+		return errors.New(notNullableErrMsg)
+	}
+	v, ok := val.(*model.StringValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	index, isNew := g.poolIndex(16, val)
+	if !isNew {
+		return g.reference(16, index, buf)
+	}
+	out := g.e.bufferPool.Get().(*bytes.Buffer)
+	if err := g.e.encodeInt(len(v.Data), out); err != nil {
+		return err
+	}
+	if _, err := out.WriteString(v.Data); err != nil {
+		return err
+	}
+	return g.endPooled(16, index, out, buf)
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemSpansItemParentSpanId 编码 "resourceSpans item scopeSpans item spans item parentSpanId"
+func encodeTraceResourceSpansItemScopeSpansItemSpansItemParentSpanId(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil || isNullValue(val) {
+		return WriteBoolean(buf, false)
+	}
+	if err := WriteBoolean(buf, true); err != nil {
+		return err
+	}
+	v, ok := val.(*model.BytesValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	if len(v.Data) != 8 {
+		return errors.New(fixedLengthErrMsg)
+	}
+	index, isNew := g.poolIndex(13, val)
+	if !isNew {
+		return g.reference(13, index, buf)
+	}
+	out := g.e.bufferPool.Get().(*bytes.Buffer)
+	if _, err := out.Write(v.Data); err != nil {
+		return err
+	}
+	return g.endPooled(13, index, out, buf)
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemSpansItemSpanId 编码 "resourceSpans item scopeSpans item spans item spanId"
+func encodeTraceResourceSpansItemScopeSpansItemSpansItemSpanId(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil || isNullValue(val) {
+		return WriteBoolean(buf, false)
+	}
+	if err := WriteBoolean(buf, true); err != nil {
+		return err
+	}
+	v, ok := val.(*model.BytesValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	if len(v.Data) != 8 {
+		return errors.New(fixedLengthErrMsg)
+	}
+	index, isNew := g.poolIndex(13, val)
+	if !isNew {
+		return g.reference(13, index, buf)
+	}
+	out := g.e.bufferPool.Get().(*bytes.Buffer)
+	if _, err := out.Write(v.Data); err != nil {
+		return err
+	}
+	return g.endPooled(13, index, out, buf)
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemSpansItemStartTimeUnixNano 编码 "resourceSpans item scopeSpans item spans item startTimeUnixNano"
+func encodeTraceResourceSpansItemScopeSpansItemSpansItemStartTimeUnixNano(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil {
+		return errors.New(notNullableErrMsg)
+	}
+	v, ok := val.(*model.IntegerValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	return g.e.encodeInt(g.diff(2, v.Data), buf)
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemSpansItemStatus 编码 "resourceSpans item scopeSpans item spans item status"
+func encodeTraceResourceSpansItemScopeSpansItemSpansItemStatus(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil {
+		return errors.New(notNullableErrMsg)
+	}
+	v, ok := val.(*model.ObjectValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	index, isNew := g.poolIndex(17, val)
+	if !isNew {
+		return g.reference(17, index, buf)
+	}
+	out := g.e.bufferPool.Get().(*bytes.Buffer)
+	if err := encodeTraceResourceSpansItemScopeSpansItemSpansItemStatusCode(g, v.Data["code"], out); err != nil {
+		return err
+	}
+	if err := encodeTraceResourceSpansItemScopeSpansItemSpansItemStatusMessage(g, v.Data["message"], out); err != nil {
+		return err
+	}
+	return g.endPooled(17, index, out, buf)
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemSpansItemStatusCode 编码 "resourceSpans item scopeSpans item spans item status code"
+func encodeTraceResourceSpansItemScopeSpansItemSpansItemStatusCode(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil {
+		return errors.New(notNullableErrMsg)
+	}
+	v, ok := val.(*model.IntegerValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	return g.e.encodeInt(v.Data, buf)
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemSpansItemStatusMessage 编码 "resourceSpans item scopeSpans item spans item status message"
+func encodeTraceResourceSpansItemScopeSpansItemSpansItemStatusMessage(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil || isNullValue(val) {
+		return WriteBoolean(buf, false)
+	}
+	if err := WriteBoolean(buf, true); err != nil {
+		return err
+	}
+	v, ok := val.(*model.StringValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	index, isNew := g.poolIndex(18, val)
+	if !isNew {
+		return g.reference(18, index, buf)
+	}
+	out := g.e.bufferPool.Get().(*bytes.Buffer)
+	if err := g.e.encodeInt(len(v.Data), out); err != nil {
+		return err
+	}
+	if _, err := out.WriteString(v.Data); err != nil {
+		return err
+	}
+	return g.endPooled(18, index, out, buf)
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemSpansItemTraceId 编码 "resourceSpans item scopeSpans item spans item traceId"
+func encodeTraceResourceSpansItemScopeSpansItemSpansItemTraceId(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil {
+		return errors.New(notNullableErrMsg)
+	}
+	v, ok := val.(*model.BytesValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	if len(v.Data) != 16 {
+		return errors.New(fixedLengthErrMsg)
+	}
+	index, isNew := g.poolIndex(14, val)
+	if !isNew {
+		return g.reference(14, index, buf)
+	}
+	out := g.e.bufferPool.Get().(*bytes.Buffer)
+	if _, err := out.Write(v.Data); err != nil {
+		return err
+	}
+	return g.endPooled(14, index, out, buf)
+}
+
+// encodeTraceResourceSpansItemScopeSpansItemSpansItemTraceState 编码 "resourceSpans item scopeSpans item spans item traceState"
+func encodeTraceResourceSpansItemScopeSpansItemSpansItemTraceState(g *genEncodeState, val model.Value, buf *bytes.Buffer) error {
+	if val == nil || isNullValue(val) {
+		return WriteBoolean(buf, false)
+	}
+	if err := WriteBoolean(buf, true); err != nil {
+		return err
+	}
+	v, ok := val.(*model.StringValue)
+	if !ok {
+		return errors.New(typeConflictErrMsg)
+	}
+	index, isNew := g.poolIndex(15, val)
+	if !isNew {
+		return g.reference(15, index, buf)
+	}
+	out := g.e.bufferPool.Get().(*bytes.Buffer)
+	if err := g.e.encodeInt(len(v.Data), out); err != nil {
+		return err
+	}
+	if _, err := out.WriteString(v.Data); err != nil {
+		return err
+	}
+	return g.endPooled(15, index, out, buf)
+}
+
+// decodeTrace 解码根节点
+func decodeTrace(g *genDecodeState) (model.Value, error) {
+	err := g.lim.enter()
+	if err != nil {
+		return nil, err
+	}
+	objv := make(map[string]model.Value, 1)
+	objv["resourceSpans"], err = decodeTraceResourceSpans(g)
+	if err != nil {
+		return nil, err
+	}
+	g.lim.leave()
+	return &model.ObjectValue{Data: objv}, nil
+}
+
+// decodeTraceResourceSpans 解码 "resourceSpans"
+func decodeTraceResourceSpans(g *genDecodeState) (model.Value, error) {
+	exist, err := g.reader.ReadBoolean()
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, nil
+	}
+	err = g.lim.enter()
+	if err != nil {
+		return nil, err
+	}
+	length, err := g.readInt()
+	if err != nil {
+		return nil, err
+	}
+	if err := g.lim.checkArrayLength("resourceSpans", length); err != nil {
+		return nil, err
+	}
+	var arrv []model.Value
+	if length > 0 {
+		arrv = make([]model.Value, 0, g.capacityHint(length))
+	}
+	for i := 0; i < length; i++ {
+		item, err := decodeTraceResourceSpansItem(g)
+		if err != nil {
+			return nil, err
+		}
+		arrv = append(arrv, item)
+	}
+	g.lim.leave()
+	return &model.ArrayValue{Data: arrv}, nil
+}
+
+// decodeTraceResourceSpansItem 解码 "resourceSpans item"
+func decodeTraceResourceSpansItem(g *genDecodeState) (model.Value, error) {
+	err := g.lim.enter()
+	if err != nil {
+		return nil, err
+	}
+	objv := make(map[string]model.Value, 3)
+	objv["resource"], err = decodeTraceResourceSpansItemResource(g)
+	if err != nil {
+		return nil, err
+	}
+	objv["schemaUrl"], err = decodeTraceResourceSpansItemSchemaUrl(g)
+	if err != nil {
+		return nil, err
+	}
+	objv["scopeSpans"], err = decodeTraceResourceSpansItemScopeSpans(g)
+	if err != nil {
+		return nil, err
+	}
+	g.lim.leave()
+	return &model.ObjectValue{Data: objv}, nil
+}
+
+// decodeTraceResourceSpansItemResource 解码 "resourceSpans item resource"
+func decodeTraceResourceSpansItemResource(g *genDecodeState) (model.Value, error) {
+	return g.readPooled(0, "resourceSpans item resource", decodeTraceResourceSpansItemResourceInline)
+}
+
+// decodeTraceResourceSpansItemResourceInline 解码池满之后内联编码的 "resourceSpans item resource"
+func decodeTraceResourceSpansItemResourceInline(g *genDecodeState) (model.Value, error) {
+	err := g.lim.enter()
+	if err != nil {
+		return nil, err
+	}
+	objv := make(map[string]model.Value, 2)
+	objv["attributes"], err = decodeTraceResourceSpansItemResourceAttributes(g)
+	if err != nil {
+		return nil, err
+	}
+	objv["droppedAttributesCount"], err = decodeTraceResourceSpansItemResourceDroppedAttributesCount(g)
+	if err != nil {
+		return nil, err
+	}
+	g.lim.leave()
+	return &model.ObjectValue{Data: objv}, nil
+}
+
+// decodeTraceResourceSpansItemResourceAttributes 解码 "resourceSpans item resource attributes"
+func decodeTraceResourceSpansItemResourceAttributes(g *genDecodeState) (model.Value, error) {
+	exist, err := g.reader.ReadBoolean()
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, nil
+	}
+	return g.readPooled(1, "resourceSpans item resource attributes", decodeTraceResourceSpansItemResourceAttributesInline)
+}
+
+// decodeTraceResourceSpansItemResourceAttributesInline 解码池满之后内联编码的 "resourceSpans item resource attributes"
+func decodeTraceResourceSpansItemResourceAttributesInline(g *genDecodeState) (model.Value, error) {
+	err := g.lim.enter()
+	if err != nil {
+		return nil, err
+	}
+	objv, err := g.innerFreeMapDecode()
+	if err != nil {
+		return nil, err
+	}
+	g.lim.leave()
+	return &model.ObjectValue{Data: objv}, nil
+}
+
+// decodeTraceResourceSpansItemResourceDroppedAttributesCount 解码 "resourceSpans item resource droppedAttributesCount"
+func decodeTraceResourceSpansItemResourceDroppedAttributesCount(g *genDecodeState) (model.Value, error) {
+	exist, err := g.reader.ReadBoolean()
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, nil
+	}
+	intv, err := g.readInt()
+	if err != nil {
+		return nil, err
+	}
+	if err := g.lim.addDecoded(8); err != nil {
+		return nil, err
+	}
+	return &model.IntegerValue{Data: intv}, nil
+}
+
+// decodeTraceResourceSpansItemSchemaUrl 解码 "resourceSpans item schemaUrl"
+func decodeTraceResourceSpansItemSchemaUrl(g *genDecodeState) (model.Value, error) {
+	exist, err := g.reader.ReadBoolean()
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, nil
+	}
+	return g.readPooled(2, "resourceSpans item schemaUrl", decodeTraceResourceSpansItemSchemaUrlInline)
+}
+
+// decodeTraceResourceSpansItemSchemaUrlInline 解码池满之后内联编码的 "resourceSpans item schemaUrl"
+func decodeTraceResourceSpansItemSchemaUrlInline(g *genDecodeState) (model.Value, error) {
+	length, err := g.readInt()
+	if err != nil {
+		return nil, err
+	}
+	strv, err := g.reader.ReadString(length)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.lim.addDecoded(length); err != nil {
+		return nil, err
+	}
+	return &model.StringValue{Data: strv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpans 解码 "resourceSpans item scopeSpans"
+func decodeTraceResourceSpansItemScopeSpans(g *genDecodeState) (model.Value, error) {
+	exist, err := g.reader.ReadBoolean()
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, nil
+	}
+	err = g.lim.enter()
+	if err != nil {
+		return nil, err
+	}
+	length, err := g.readInt()
+	if err != nil {
+		return nil, err
+	}
+	if err := g.lim.checkArrayLength("resourceSpans item scopeSpans", length); err != nil {
+		return nil, err
+	}
+	var arrv []model.Value
+	if length > 0 {
+		arrv = make([]model.Value, 0, g.capacityHint(length))
+	}
+	for i := 0; i < length; i++ {
+		item, err := decodeTraceResourceSpansItemScopeSpansItem(g)
+		if err != nil {
+			return nil, err
+		}
+		arrv = append(arrv, item)
+	}
+	g.lim.leave()
+	return &model.ArrayValue{Data: arrv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItem 解码 "resourceSpans item scopeSpans item"
+func decodeTraceResourceSpansItemScopeSpansItem(g *genDecodeState) (model.Value, error) {
+	err := g.lim.enter()
+	if err != nil {
+		return nil, err
+	}
+	objv := make(map[string]model.Value, 3)
+	objv["schemaUrl"], err = decodeTraceResourceSpansItemScopeSpansItemSchemaUrl(g)
+	if err != nil {
+		return nil, err
+	}
+	objv["scope"], err = decodeTraceResourceSpansItemScopeSpansItemScope(g)
+	if err != nil {
+		return nil, err
+	}
+	objv["spans"], err = decodeTraceResourceSpansItemScopeSpansItemSpans(g)
+	if err != nil {
+		return nil, err
+	}
+	g.lim.leave()
+	return &model.ObjectValue{Data: objv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSchemaUrl 解码 "resourceSpans item scopeSpans item schemaUrl"
+func decodeTraceResourceSpansItemScopeSpansItemSchemaUrl(g *genDecodeState) (model.Value, error) {
+	exist, err := g.reader.ReadBoolean()
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, nil
+	}
+	return g.readPooled(3, "resourceSpans item scopeSpans item schemaUrl", decodeTraceResourceSpansItemScopeSpansItemSchemaUrlInline)
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSchemaUrlInline 解码池满之后内联编码的 "resourceSpans item scopeSpans item schemaUrl"
+func decodeTraceResourceSpansItemScopeSpansItemSchemaUrlInline(g *genDecodeState) (model.Value, error) {
+	length, err := g.readInt()
+	if err != nil {
+		return nil, err
+	}
+	strv, err := g.reader.ReadString(length)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.lim.addDecoded(length); err != nil {
+		return nil, err
+	}
+	return &model.StringValue{Data: strv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemScope 解码 "resourceSpans item scopeSpans item scope"
+func decodeTraceResourceSpansItemScopeSpansItemScope(g *genDecodeState) (model.Value, error) {
+	return g.readPooled(4, "resourceSpans item scopeSpans item scope", decodeTraceResourceSpansItemScopeSpansItemScopeInline)
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemScopeInline 解码池满之后内联编码的 "resourceSpans item scopeSpans item scope"
+func decodeTraceResourceSpansItemScopeSpansItemScopeInline(g *genDecodeState) (model.Value, error) {
+	err := g.lim.enter()
+	if err != nil {
+		return nil, err
+	}
+	objv := make(map[string]model.Value, 4)
+	objv["attributes"], err = decodeTraceResourceSpansItemScopeSpansItemScopeAttributes(g)
+	if err != nil {
+		return nil, err
+	}
+	objv["droppedAttributesCount"], err = decodeTraceResourceSpansItemScopeSpansItemScopeDroppedAttributesCount(g)
+	if err != nil {
+		return nil, err
+	}
+	objv["name"], err = decodeTraceResourceSpansItemScopeSpansItemScopeName(g)
+	if err != nil {
+		return nil, err
+	}
+	objv["version"], err = decodeTraceResourceSpansItemScopeSpansItemScopeVersion(g)
+	if err != nil {
+		return nil, err
+	}
+	g.lim.leave()
+	return &model.ObjectValue{Data: objv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemScopeAttributes 解码 "resourceSpans item scopeSpans item scope attributes"
+func decodeTraceResourceSpansItemScopeSpansItemScopeAttributes(g *genDecodeState) (model.Value, error) {
+	exist, err := g.reader.ReadBoolean()
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, nil
+	}
+	return g.readPooled(5, "resourceSpans item scopeSpans item scope attributes", decodeTraceResourceSpansItemScopeSpansItemScopeAttributesInline)
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemScopeAttributesInline 解码池满之后内联编码的 "resourceSpans item scopeSpans item scope attributes"
+func decodeTraceResourceSpansItemScopeSpansItemScopeAttributesInline(g *genDecodeState) (model.Value, error) {
+	err := g.lim.enter()
+	if err != nil {
+		return nil, err
+	}
+	objv, err := g.innerFreeMapDecode()
+	if err != nil {
+		return nil, err
+	}
+	g.lim.leave()
+	return &model.ObjectValue{Data: objv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemScopeDroppedAttributesCount 解码 "resourceSpans item scopeSpans item scope droppedAttributesCount"
+func decodeTraceResourceSpansItemScopeSpansItemScopeDroppedAttributesCount(g *genDecodeState) (model.Value, error) {
+	exist, err := g.reader.ReadBoolean()
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, nil
+	}
+	intv, err := g.readInt()
+	if err != nil {
+		return nil, err
+	}
+	if err := g.lim.addDecoded(8); err != nil {
+		return nil, err
+	}
+	return &model.IntegerValue{Data: intv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemScopeName 解码 "resourceSpans item scopeSpans item scope name"
+func decodeTraceResourceSpansItemScopeSpansItemScopeName(g *genDecodeState) (model.Value, error) {
+	exist, err := g.reader.ReadBoolean()
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, nil
+	}
+	return g.readPooled(6, "resourceSpans item scopeSpans item scope name", decodeTraceResourceSpansItemScopeSpansItemScopeNameInline)
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemScopeNameInline 解码池满之后内联编码的 "resourceSpans item scopeSpans item scope name"
+func decodeTraceResourceSpansItemScopeSpansItemScopeNameInline(g *genDecodeState) (model.Value, error) {
+	length, err := g.readInt()
+	if err != nil {
+		return nil, err
+	}
+	strv, err := g.reader.ReadString(length)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.lim.addDecoded(length); err != nil {
+		return nil, err
+	}
+	return &model.StringValue{Data: strv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemScopeVersion 解码 "resourceSpans item scopeSpans item scope version"
+func decodeTraceResourceSpansItemScopeSpansItemScopeVersion(g *genDecodeState) (model.Value, error) {
+	exist, err := g.reader.ReadBoolean()
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, nil
+	}
+	return g.readPooled(7, "resourceSpans item scopeSpans item scope version", decodeTraceResourceSpansItemScopeSpansItemScopeVersionInline)
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemScopeVersionInline 解码池满之后内联编码的 "resourceSpans item scopeSpans item scope version"
+func decodeTraceResourceSpansItemScopeSpansItemScopeVersionInline(g *genDecodeState) (model.Value, error) {
+	length, err := g.readInt()
+	if err != nil {
+		return nil, err
+	}
+	strv, err := g.reader.ReadString(length)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.lim.addDecoded(length); err != nil {
+		return nil, err
+	}
+	return &model.StringValue{Data: strv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpans 解码 "resourceSpans item scopeSpans item spans"
+func decodeTraceResourceSpansItemScopeSpansItemSpans(g *genDecodeState) (model.Value, error) {
+	exist, err := g.reader.ReadBoolean()
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, nil
+	}
+	err = g.lim.enter()
+	if err != nil {
+		return nil, err
+	}
+	length, err := g.readInt()
+	if err != nil {
+		return nil, err
+	}
+	if err := g.lim.checkArrayLength("resourceSpans item scopeSpans item spans", length); err != nil {
+		return nil, err
+	}
+	var arrv []model.Value
+	if length > 0 {
+		arrv = make([]model.Value, 0, g.capacityHint(length))
+	}
+	for i := 0; i < length; i++ {
+		item, err := decodeTraceResourceSpansItemScopeSpansItemSpansItem(g)
+		if err != nil {
+			return nil, err
+		}
+		arrv = append(arrv, item)
+	}
+	g.lim.leave()
+	return &model.ArrayValue{Data: arrv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItem 解码 "resourceSpans item scopeSpans item spans item"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItem(g *genDecodeState) (model.Value, error) {
+	err := g.lim.enter()
+	if err != nil {
+		return nil, err
+	}
+	objv := make(map[string]model.Value, 15)
+	objv["attributes"], err = decodeTraceResourceSpansItemScopeSpansItemSpansItemAttributes(g)
+	if err != nil {
+		return nil, err
+	}
+	objv["droppedAttributesCount"], err = decodeTraceResourceSpansItemScopeSpansItemSpansItemDroppedAttributesCount(g)
+	if err != nil {
+		return nil, err
+	}
+	objv["droppedEventsCount"], err = decodeTraceResourceSpansItemScopeSpansItemSpansItemDroppedEventsCount(g)
+	if err != nil {
+		return nil, err
+	}
+	objv["droppedLinksCount"], err = decodeTraceResourceSpansItemScopeSpansItemSpansItemDroppedLinksCount(g)
+	if err != nil {
+		return nil, err
+	}
+	objv["endTimeUnixNano"], err = decodeTraceResourceSpansItemScopeSpansItemSpansItemEndTimeUnixNano(g)
+	if err != nil {
+		return nil, err
+	}
+	objv["events"], err = decodeTraceResourceSpansItemScopeSpansItemSpansItemEvents(g)
+	if err != nil {
+		return nil, err
+	}
+	objv["kind"], err = decodeTraceResourceSpansItemScopeSpansItemSpansItemKind(g)
+	if err != nil {
+		return nil, err
+	}
+	objv["links"], err = decodeTraceResourceSpansItemScopeSpansItemSpansItemLinks(g)
+	if err != nil {
+		return nil, err
+	}
+	objv["name"], err = decodeTraceResourceSpansItemScopeSpansItemSpansItemName(g)
+	if err != nil {
+		return nil, err
+	}
+	objv["parentSpanId"], err = decodeTraceResourceSpansItemScopeSpansItemSpansItemParentSpanId(g)
+	if err != nil {
+		return nil, err
+	}
+	objv["spanId"], err = decodeTraceResourceSpansItemScopeSpansItemSpansItemSpanId(g)
+	if err != nil {
+		return nil, err
+	}
+	objv["startTimeUnixNano"], err = decodeTraceResourceSpansItemScopeSpansItemSpansItemStartTimeUnixNano(g)
+	if err != nil {
+		return nil, err
+	}
+	objv["status"], err = decodeTraceResourceSpansItemScopeSpansItemSpansItemStatus(g)
+	if err != nil {
+		return nil, err
+	}
+	objv["traceId"], err = decodeTraceResourceSpansItemScopeSpansItemSpansItemTraceId(g)
+	if err != nil {
+		return nil, err
+	}
+	objv["traceState"], err = decodeTraceResourceSpansItemScopeSpansItemSpansItemTraceState(g)
+	if err != nil {
+		return nil, err
+	}
+	g.lim.leave()
+	return &model.ObjectValue{Data: objv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemAttributes 解码 "resourceSpans item scopeSpans item spans item attributes"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemAttributes(g *genDecodeState) (model.Value, error) {
+	exist, err := g.reader.ReadBoolean()
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, nil
+	}
+	return g.readPooled(8, "resourceSpans item scopeSpans item spans item attributes", decodeTraceResourceSpansItemScopeSpansItemSpansItemAttributesInline)
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemAttributesInline 解码池满之后内联编码的 "resourceSpans item scopeSpans item spans item attributes"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemAttributesInline(g *genDecodeState) (model.Value, error) {
+	err := g.lim.enter()
+	if err != nil {
+		return nil, err
+	}
+	objv, err := g.innerFreeMapDecode()
+	if err != nil {
+		return nil, err
+	}
+	g.lim.leave()
+	return &model.ObjectValue{Data: objv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemDroppedAttributesCount 解码 "resourceSpans item scopeSpans item spans item droppedAttributesCount"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemDroppedAttributesCount(g *genDecodeState) (model.Value, error) {
+	exist, err := g.reader.ReadBoolean()
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, nil
+	}
+	intv, err := g.readInt()
+	if err != nil {
+		return nil, err
+	}
+	if err := g.lim.addDecoded(8); err != nil {
+		return nil, err
+	}
+	return &model.IntegerValue{Data: intv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemDroppedEventsCount 解码 "resourceSpans item scopeSpans item spans item droppedEventsCount"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemDroppedEventsCount(g *genDecodeState) (model.Value, error) {
+	exist, err := g.reader.ReadBoolean()
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, nil
+	}
+	intv, err := g.readInt()
+	if err != nil {
+		return nil, err
+	}
+	if err := g.lim.addDecoded(8); err != nil {
+		return nil, err
+	}
+	return &model.IntegerValue{Data: intv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemDroppedLinksCount 解码 "resourceSpans item scopeSpans item spans item droppedLinksCount"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemDroppedLinksCount(g *genDecodeState) (model.Value, error) {
+	exist, err := g.reader.ReadBoolean()
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, nil
+	}
+	intv, err := g.readInt()
+	if err != nil {
+		return nil, err
+	}
+	if err := g.lim.addDecoded(8); err != nil {
+		return nil, err
+	}
+	return &model.IntegerValue{Data: intv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemEndTimeUnixNano 解码 "resourceSpans item scopeSpans item spans item endTimeUnixNano"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemEndTimeUnixNano(g *genDecodeState) (model.Value, error) {
+	intv, err := g.readInt()
+	if err != nil {
+		return nil, err
+	}
+	if prev, exist := g.status["resourceSpans item scopeSpans item spans item endTimeUnixNano"]; exist {
+		intv = intv + prev.(int)
+	}
+	g.status["resourceSpans item scopeSpans item spans item endTimeUnixNano"] = intv
+	if err := g.lim.addDecoded(8); err != nil {
+		return nil, err
+	}
+	return &model.IntegerValue{Data: intv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemEvents 解码 "resourceSpans item scopeSpans item spans item events"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemEvents(g *genDecodeState) (model.Value, error) {
+	exist, err := g.reader.ReadBoolean()
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, nil
+	}
+	err = g.lim.enter()
+	if err != nil {
+		return nil, err
+	}
+	length, err := g.readInt()
+	if err != nil {
+		return nil, err
+	}
+	if err := g.lim.checkArrayLength("resourceSpans item scopeSpans item spans item events", length); err != nil {
+		return nil, err
+	}
+	var arrv []model.Value
+	if length > 0 {
+		arrv = make([]model.Value, 0, g.capacityHint(length))
+	}
+	for i := 0; i < length; i++ {
+		item, err := decodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItem(g)
+		if err != nil {
+			return nil, err
+		}
+		arrv = append(arrv, item)
+	}
+	g.lim.leave()
+	return &model.ArrayValue{Data: arrv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItem 解码 "resourceSpans item scopeSpans item spans item events item"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItem(g *genDecodeState) (model.Value, error) {
+	err := g.lim.enter()
+	if err != nil {
+		return nil, err
+	}
+	objv := make(map[string]model.Value, 4)
+	objv["attributes"], err = decodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItemAttributes(g)
+	if err != nil {
+		return nil, err
+	}
+	objv["droppedAttributesCount"], err = decodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItemDroppedAttributesCount(g)
+	if err != nil {
+		return nil, err
+	}
+	objv["name"], err = decodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItemName(g)
+	if err != nil {
+		return nil, err
+	}
+	objv["timeUnixNano"], err = decodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItemTimeUnixNano(g)
+	if err != nil {
+		return nil, err
+	}
+	g.lim.leave()
+	return &model.ObjectValue{Data: objv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItemAttributes 解码 "resourceSpans item scopeSpans item spans item events item attributes"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItemAttributes(g *genDecodeState) (model.Value, error) {
+	exist, err := g.reader.ReadBoolean()
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, nil
+	}
+	return g.readPooled(9, "resourceSpans item scopeSpans item spans item events item attributes", decodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItemAttributesInline)
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItemAttributesInline 解码池满之后内联编码的 "resourceSpans item scopeSpans item spans item events item attributes"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItemAttributesInline(g *genDecodeState) (model.Value, error) {
+	err := g.lim.enter()
+	if err != nil {
+		return nil, err
+	}
+	objv, err := g.innerFreeMapDecode()
+	if err != nil {
+		return nil, err
+	}
+	g.lim.leave()
+	return &model.ObjectValue{Data: objv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItemDroppedAttributesCount 解码 "resourceSpans item scopeSpans item spans item events item droppedAttributesCount"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItemDroppedAttributesCount(g *genDecodeState) (model.Value, error) {
+	exist, err := g.reader.ReadBoolean()
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, nil
+	}
+	intv, err := g.readInt()
+	if err != nil {
+		return nil, err
+	}
+	if err := g.lim.addDecoded(8); err != nil {
+		return nil, err
+	}
+	return &model.IntegerValue{Data: intv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItemName 解码 "resourceSpans item scopeSpans item spans item events item name"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItemName(g *genDecodeState) (model.Value, error) {
+	exist, err := g.reader.ReadBoolean()
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, nil
+	}
+	return g.readPooled(10, "resourceSpans item scopeSpans item spans item events item name", decodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItemNameInline)
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItemNameInline 解码池满之后内联编码的 "resourceSpans item scopeSpans item spans item events item name"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItemNameInline(g *genDecodeState) (model.Value, error) {
+	length, err := g.readInt()
+	if err != nil {
+		return nil, err
+	}
+	strv, err := g.reader.ReadString(length)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.lim.addDecoded(length); err != nil {
+		return nil, err
+	}
+	return &model.StringValue{Data: strv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItemTimeUnixNano 解码 "resourceSpans item scopeSpans item spans item events item timeUnixNano"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemEventsItemTimeUnixNano(g *genDecodeState) (model.Value, error) {
+	exist, err := g.reader.ReadBoolean()
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, nil
+	}
+	intv, err := g.readInt()
+	if err != nil {
+		return nil, err
+	}
+	if prev, exist := g.status["resourceSpans item scopeSpans item spans item events item timeUnixNano"]; exist {
+		intv = intv + prev.(int)
+	}
+	g.status["resourceSpans item scopeSpans item spans item events item timeUnixNano"] = intv
+	if err := g.lim.addDecoded(8); err != nil {
+		return nil, err
+	}
+	return &model.IntegerValue{Data: intv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemKind 解码 "resourceSpans item scopeSpans item spans item kind"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemKind(g *genDecodeState) (model.Value, error) {
+	exist, err := g.reader.ReadBoolean()
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, nil
+	}
+	intv, err := g.readInt()
+	if err != nil {
+		return nil, err
+	}
+	if err := g.lim.addDecoded(8); err != nil {
+		return nil, err
+	}
+	return &model.IntegerValue{Data: intv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemLinks 解码 "resourceSpans item scopeSpans item spans item links"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemLinks(g *genDecodeState) (model.Value, error) {
+	exist, err := g.reader.ReadBoolean()
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, nil
+	}
+	err = g.lim.enter()
+	if err != nil {
+		return nil, err
+	}
+	length, err := g.readInt()
+	if err != nil {
+		return nil, err
+	}
+	if err := g.lim.checkArrayLength("resourceSpans item scopeSpans item spans item links", length); err != nil {
+		return nil, err
+	}
+	var arrv []model.Value
+	if length > 0 {
+		arrv = make([]model.Value, 0, g.capacityHint(length))
+	}
+	for i := 0; i < length; i++ {
+		item, err := decodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItem(g)
+		if err != nil {
+			return nil, err
+		}
+		arrv = append(arrv, item)
+	}
+	g.lim.leave()
+	return &model.ArrayValue{Data: arrv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItem 解码 "resourceSpans item scopeSpans item spans item links item"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItem(g *genDecodeState) (model.Value, error) {
+	return g.readPooled(11, "resourceSpans item scopeSpans item spans item links item", decodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemInline)
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemInline 解码池满之后内联编码的 "resourceSpans item scopeSpans item spans item links item"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemInline(g *genDecodeState) (model.Value, error) {
+	err := g.lim.enter()
+	if err != nil {
+		return nil, err
+	}
+	objv := make(map[string]model.Value, 5)
+	objv["attributes"], err = decodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemAttributes(g)
+	if err != nil {
+		return nil, err
+	}
+	objv["droppedAttributesCount"], err = decodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemDroppedAttributesCount(g)
+	if err != nil {
+		return nil, err
+	}
+	objv["spanId"], err = decodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemSpanId(g)
+	if err != nil {
+		return nil, err
+	}
+	objv["traceId"], err = decodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemTraceId(g)
+	if err != nil {
+		return nil, err
+	}
+	objv["traceState"], err = decodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemTraceState(g)
+	if err != nil {
+		return nil, err
+	}
+	g.lim.leave()
+	return &model.ObjectValue{Data: objv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemAttributes 解码 "resourceSpans item scopeSpans item spans item links item attributes"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemAttributes(g *genDecodeState) (model.Value, error) {
+	exist, err := g.reader.ReadBoolean()
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, nil
+	}
+	return g.readPooled(12, "resourceSpans item scopeSpans item spans item links item attributes", decodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemAttributesInline)
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemAttributesInline 解码池满之后内联编码的 "resourceSpans item scopeSpans item spans item links item attributes"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemAttributesInline(g *genDecodeState) (model.Value, error) {
+	err := g.lim.enter()
+	if err != nil {
+		return nil, err
+	}
+	objv, err := g.innerFreeMapDecode()
+	if err != nil {
+		return nil, err
+	}
+	g.lim.leave()
+	return &model.ObjectValue{Data: objv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemDroppedAttributesCount 解码 "resourceSpans item scopeSpans item spans item links item droppedAttributesCount"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemDroppedAttributesCount(g *genDecodeState) (model.Value, error) {
+	exist, err := g.reader.ReadBoolean()
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, nil
+	}
+	intv, err := g.readInt()
+	if err != nil {
+		return nil, err
+	}
+	if err := g.lim.addDecoded(8); err != nil {
+		return nil, err
+	}
+	return &model.IntegerValue{Data: intv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemSpanId 解码 "resourceSpans item scopeSpans item spans item links item spanId"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemSpanId(g *genDecodeState) (model.Value, error) {
+	exist, err := g.reader.ReadBoolean()
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, nil
+	}
+	return g.readPooled(13, "spanId", decodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemSpanIdInline)
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemSpanIdInline 解码池满之后内联编码的 "resourceSpans item scopeSpans item spans item links item spanId"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemSpanIdInline(g *genDecodeState) (model.Value, error) {
+	length := 8
+	bv, err := g.reader.ReadBytes(length)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.lim.addDecoded(length); err != nil {
+		return nil, err
+	}
+	return &model.BytesValue{Data: bv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemTraceId 解码 "resourceSpans item scopeSpans item spans item links item traceId"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemTraceId(g *genDecodeState) (model.Value, error) {
+	return g.readPooled(14, "traceId", decodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemTraceIdInline)
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemTraceIdInline 解码池满之后内联编码的 "resourceSpans item scopeSpans item spans item links item traceId"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemTraceIdInline(g *genDecodeState) (model.Value, error) {
+	length := 16
+	bv, err := g.reader.ReadBytes(length)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.lim.addDecoded(length); err != nil {
+		return nil, err
+	}
+	return &model.BytesValue{Data: bv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemTraceState 解码 "resourceSpans item scopeSpans item spans item links item traceState"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemTraceState(g *genDecodeState) (model.Value, error) {
+	exist, err := g.reader.ReadBoolean()
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, nil
+	}
+	return g.readPooled(15, "traceState", decodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemTraceStateInline)
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemTraceStateInline 解码池满之后内联编码的 "resourceSpans item scopeSpans item spans item links item traceState"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemLinksItemTraceStateInline(g *genDecodeState) (model.Value, error) {
+	length, err := g.readInt()
+	if err != nil {
+		return nil, err
+	}
+	strv, err := g.reader.ReadString(length)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.lim.addDecoded(length); err != nil {
+		return nil, err
+	}
+	return &model.StringValue{Data: strv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemName 解码 "resourceSpans item scopeSpans item spans item name"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemName(g *genDecodeState) (model.Value, error) {
+	return g.readPooled(16, "resourceSpans item scopeSpans item spans item name", decodeTraceResourceSpansItemScopeSpansItemSpansItemNameInline)
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemNameInline 解码池满之后内联编码的 "resourceSpans item scopeSpans item spans item name"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemNameInline(g *genDecodeState) (model.Value, error) {
+	length, err := g.readInt()
+	if err != nil {
+		return nil, err
+	}
+	strv, err := g.reader.ReadString(length)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.lim.addDecoded(length); err != nil {
+		return nil, err
+	}
+	return &model.StringValue{Data: strv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemParentSpanId 解码 "resourceSpans item scopeSpans item spans item parentSpanId"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemParentSpanId(g *genDecodeState) (model.Value, error) {
+	exist, err := g.reader.ReadBoolean()
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, nil
+	}
+	return g.readPooled(13, "spanId", decodeTraceResourceSpansItemScopeSpansItemSpansItemParentSpanIdInline)
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemParentSpanIdInline 解码池满之后内联编码的 "resourceSpans item scopeSpans item spans item parentSpanId"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemParentSpanIdInline(g *genDecodeState) (model.Value, error) {
+	length := 8
+	bv, err := g.reader.ReadBytes(length)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.lim.addDecoded(length); err != nil {
+		return nil, err
+	}
+	return &model.BytesValue{Data: bv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemSpanId 解码 "resourceSpans item scopeSpans item spans item spanId"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemSpanId(g *genDecodeState) (model.Value, error) {
+	exist, err := g.reader.ReadBoolean()
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, nil
+	}
+	return g.readPooled(13, "spanId", decodeTraceResourceSpansItemScopeSpansItemSpansItemSpanIdInline)
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemSpanIdInline 解码池满之后内联编码的 "resourceSpans item scopeSpans item spans item spanId"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemSpanIdInline(g *genDecodeState) (model.Value, error) {
+	length := 8
+	bv, err := g.reader.ReadBytes(length)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.lim.addDecoded(length); err != nil {
+		return nil, err
+	}
+	return &model.BytesValue{Data: bv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemStartTimeUnixNano 解码 "resourceSpans item scopeSpans item spans item startTimeUnixNano"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemStartTimeUnixNano(g *genDecodeState) (model.Value, error) {
+	intv, err := g.readInt()
+	if err != nil {
+		return nil, err
+	}
+	if prev, exist := g.status["resourceSpans item scopeSpans item spans item startTimeUnixNano"]; exist {
+		intv = intv + prev.(int)
+	}
+	g.status["resourceSpans item scopeSpans item spans item startTimeUnixNano"] = intv
+	if err := g.lim.addDecoded(8); err != nil {
+		return nil, err
+	}
+	return &model.IntegerValue{Data: intv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemStatus 解码 "resourceSpans item scopeSpans item spans item status"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemStatus(g *genDecodeState) (model.Value, error) {
+	return g.readPooled(17, "resourceSpans item scopeSpans item spans item status", decodeTraceResourceSpansItemScopeSpansItemSpansItemStatusInline)
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemStatusInline 解码池满之后内联编码的 "resourceSpans item scopeSpans item spans item status"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemStatusInline(g *genDecodeState) (model.Value, error) {
+	err := g.lim.enter()
+	if err != nil {
+		return nil, err
+	}
+	objv := make(map[string]model.Value, 2)
+	objv["code"], err = decodeTraceResourceSpansItemScopeSpansItemSpansItemStatusCode(g)
+	if err != nil {
+		return nil, err
+	}
+	objv["message"], err = decodeTraceResourceSpansItemScopeSpansItemSpansItemStatusMessage(g)
+	if err != nil {
+		return nil, err
+	}
+	g.lim.leave()
+	return &model.ObjectValue{Data: objv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemStatusCode 解码 "resourceSpans item scopeSpans item spans item status code"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemStatusCode(g *genDecodeState) (model.Value, error) {
+	intv, err := g.readInt()
+	if err != nil {
+		return nil, err
+	}
+	if err := g.lim.addDecoded(8); err != nil {
+		return nil, err
+	}
+	return &model.IntegerValue{Data: intv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemStatusMessage 解码 "resourceSpans item scopeSpans item spans item status message"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemStatusMessage(g *genDecodeState) (model.Value, error) {
+	exist, err := g.reader.ReadBoolean()
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, nil
+	}
+	return g.readPooled(18, "resourceSpans item scopeSpans item spans item status message", decodeTraceResourceSpansItemScopeSpansItemSpansItemStatusMessageInline)
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemStatusMessageInline 解码池满之后内联编码的 "resourceSpans item scopeSpans item spans item status message"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemStatusMessageInline(g *genDecodeState) (model.Value, error) {
+	length, err := g.readInt()
+	if err != nil {
+		return nil, err
+	}
+	strv, err := g.reader.ReadString(length)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.lim.addDecoded(length); err != nil {
+		return nil, err
+	}
+	return &model.StringValue{Data: strv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemTraceId 解码 "resourceSpans item scopeSpans item spans item traceId"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemTraceId(g *genDecodeState) (model.Value, error) {
+	return g.readPooled(14, "traceId", decodeTraceResourceSpansItemScopeSpansItemSpansItemTraceIdInline)
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemTraceIdInline 解码池满之后内联编码的 "resourceSpans item scopeSpans item spans item traceId"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemTraceIdInline(g *genDecodeState) (model.Value, error) {
+	length := 16
+	bv, err := g.reader.ReadBytes(length)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.lim.addDecoded(length); err != nil {
+		return nil, err
+	}
+	return &model.BytesValue{Data: bv}, nil
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemTraceState 解码 "resourceSpans item scopeSpans item spans item traceState"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemTraceState(g *genDecodeState) (model.Value, error) {
+	exist, err := g.reader.ReadBoolean()
+	if err != nil {
+		return nil, err
+	}
+	if !exist {
+		return nil, nil
+	}
+	return g.readPooled(15, "traceState", decodeTraceResourceSpansItemScopeSpansItemSpansItemTraceStateInline)
+}
+
+// decodeTraceResourceSpansItemScopeSpansItemSpansItemTraceStateInline 解码池满之后内联编码的 "resourceSpans item scopeSpans item spans item traceState"
+func decodeTraceResourceSpansItemScopeSpansItemSpansItemTraceStateInline(g *genDecodeState) (model.Value, error) {
+	length, err := g.readInt()
+	if err != nil {
+		return nil, err
+	}
+	strv, err := g.reader.ReadString(length)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.lim.addDecoded(length); err != nil {
+		return nil, err
+	}
+	return &model.StringValue{Data: strv}, nil
+}
@@ -0,0 +1,305 @@
+package codec
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/beet233/compressotelcollector/model"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// traceGroups 是按 traceId 分组编码 span 的计划。开启 WithTraceGrouping 时每个 spans 在长度之后写入分组数，
+// 每组依次写入 traceId、span 个数、各个 span 在原来的 spans 中的位置（只在 WithRestoreSpanOrder 时写入，见 encodePositions）和不带 traceId 的各个 span。
+// 分组按 traceId 第一次出现的顺序排列，组内保持原来的顺序
+type traceGroups struct {
+	spans *model.Definition
+	// restoreOrder 时 Decoder 按写入的位置还原 span 原来的顺序，否则解码为分组后的顺序
+	restoreOrder bool
+}
+
+// planTraceGroups 检查 def 能否按 traceId 分组编码 span，不能或未开启时返回 nil
+func planTraceGroups(def *model.Definition, opts options) *traceGroups {
+	if !opts.traceGrouping {
+		return nil
+	}
+	spans := unpooledSpans(def)
+	if spans == nil || !hasBytesFields(spans.ItemDefinition, "traceId") {
+		return nil
+	}
+	return &traceGroups{spans: spans, restoreOrder: opts.restoreSpanOrder}
+}
+
+// isSpans 判断 def 是否为分组编码的 spans
+func (g *traceGroups) isSpans(def *model.Definition) bool {
+	return g != nil && def == g.spans
+}
+
+// isTraceId 判断 def 中的 fieldName 是否为写在分组上的 traceId
+func (g *traceGroups) isTraceId(def *model.Definition, fieldName string) bool {
+	return g != nil && def == g.spans.ItemDefinition && fieldName == "traceId"
+}
+
+func (g *traceGroups) traceIdDefinition() *model.Definition {
+	return g.spans.ItemDefinition.Fields["traceId"]
+}
+
+// groupByTraceId 把 length 个 span 的下标按 traceId 分组，traceIdOf 返回第 i 个 span 的 traceId
+func groupByTraceId(length int, traceIdOf func(i int) []byte) [][]int {
+	var groups [][]int
+	groupIndexes := make(map[string]int)
+	for i := 0; i < length; i++ {
+		traceId := string(traceIdOf(i))
+		index, ok := groupIndexes[traceId]
+		if !ok {
+			index = len(groups)
+			groupIndexes[traceId] = index
+			groups = append(groups, nil)
+		}
+		groups[index] = append(groups[index], i)
+	}
+	return groups
+}
+
+// encodePositions 写入组内除第一个以外各个 span 的位置。分组按 traceId 第一次出现的顺序排列，第一个 span 总是还没有出现过的最小的位置，
+// 组内的位置是递增的，写入和上一个位置的间隔减 1
+func (e *Encoder) encodePositions(indexes []int, buf *bytes.Buffer) error {
+	for k := 1; k < len(indexes); k++ {
+		err := e.encodeInt(indexes[k]-indexes[k-1]-1, buf)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkGroupSize 检查分组的 span 个数，remaining 是还没有分组的 span 个数
+func checkGroupSize(size int, remaining int) error {
+	if size < 1 || size > remaining {
+		return fmt.Errorf("trace group of %d spans out of range of %d remaining spans", size, remaining)
+	}
+	return nil
+}
+
+// checkGroupCount 检查分组数，length 是 span 的个数
+func checkGroupCount(count int, length int) error {
+	if count < 0 || count > length {
+		return fmt.Errorf("%d trace groups out of range of %d spans", count, length)
+	}
+	return nil
+}
+
+// spanPositions 是 restoreOrder 时已经出现过的 span 位置
+type spanPositions struct {
+	used []bool
+	// next 是还没有出现过的最小的位置
+	next int
+}
+
+func newSpanPositions(length int) *spanPositions {
+	return &spanPositions{used: make([]bool, length)}
+}
+
+// read 读取组内 size 个 span 的位置，与 encodePositions 对应
+func (p *spanPositions) read(readInt func() (int, error), size int) ([]int, error) {
+	positions := make([]int, size)
+	for k := range positions {
+		position := p.next
+		if k > 0 {
+			gap, err := readInt()
+			if err != nil {
+				return nil, err
+			}
+			// 先检查间隔再相加，很大的间隔相加之后会溢出为负数
+			if gap < 0 || gap >= len(p.used)-positions[k-1]-1 {
+				return nil, fmt.Errorf("span position gap %d after position %d out of range of %d spans", gap, positions[k-1], len(p.used))
+			}
+			position = positions[k-1] + gap + 1
+			if p.used[position] {
+				return nil, fmt.Errorf("span position %d appears in more than one trace group", position)
+			}
+		}
+		p.used[position] = true
+		positions[k] = position
+	}
+	for p.next < len(p.used) && p.used[p.next] {
+		p.next++
+	}
+	return positions, nil
+}
+
+// fieldOf 返回 Object 中的 field，val 不是 Object 时返回 nil，由 innerEncode 报告类型错误
+func fieldOf(val model.Value, fieldName string) model.Value {
+	if objv, ok := val.(*model.ObjectValue); ok {
+		return objv.Data[fieldName]
+	}
+	return nil
+}
+
+// encodeTraceGroups 按 traceId 分组编码 arrv，itemName 是 span 的名字
+func (e *Encoder) encodeTraceGroups(arrv []model.Value, def *model.Definition, itemName string, state *encodeState, buf *bytes.Buffer) error {
+	groups := groupByTraceId(len(arrv), func(i int) []byte {
+		return bytesOf(fieldOf(arrv[i], "traceId"))
+	})
+	err := e.encodeInt(len(groups), buf)
+	if err != nil {
+		return err
+	}
+	for _, indexes := range groups {
+		err := e.innerEncode(fieldOf(arrv[indexes[0]], "traceId"), e.groups.traceIdDefinition(), childName(itemName, "traceId"), state, buf)
+		if err != nil {
+			return err
+		}
+		err = e.encodeGroupHeader(indexes, buf)
+		if err != nil {
+			return err
+		}
+		for _, index := range indexes {
+			err := e.innerEncode(arrv[index], def.ItemDefinition, itemName, state, buf)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// encodeTraceGroupsDirect 与 encodeTraceGroups 对应，直接编码 ptrace.SpanSlice
+func (e *Encoder) encodeTraceGroupsDirect(state *encodeState, def *model.Definition, itemName string, slice ptrace.SpanSlice, buf *bytes.Buffer) error {
+	groups := groupByTraceId(slice.Len(), func(i int) []byte {
+		traceId := slice.At(i).TraceID()
+		return traceId[:]
+	})
+	err := e.encodeInt(len(groups), buf)
+	if err != nil {
+		return err
+	}
+	for _, indexes := range groups {
+		traceId := slice.At(indexes[0]).TraceID()
+		err := e.encodeBytesDirect(state, e.groups.traceIdDefinition(), e.childNames.child(itemName, "traceId"), traceId[:], buf)
+		if err != nil {
+			return err
+		}
+		err = e.encodeGroupHeader(indexes, buf)
+		if err != nil {
+			return err
+		}
+		for _, index := range indexes {
+			err := e.encodeSpanDirect(state, def.ItemDefinition, itemName, slice.At(index), buf)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// encodeGroupHeader 写入组内 span 的个数和 restoreOrder 时各个 span 的位置
+func (e *Encoder) encodeGroupHeader(indexes []int, buf *bytes.Buffer) error {
+	err := e.encodeInt(len(indexes), buf)
+	if err != nil || !e.groups.restoreOrder {
+		return err
+	}
+	return e.encodePositions(indexes, buf)
+}
+
+// decodeTraceGroups 与 encodeTraceGroups 对应，解码 length 个分组编码的 span
+func (s *decodeState) decodeTraceGroups(def *model.Definition, itemName string, length int) ([]model.Value, error) {
+	var arrv []model.Value
+	var restored *spanPositions
+	if s.groups.restoreOrder {
+		arrv = make([]model.Value, length)
+		restored = newSpanPositions(length)
+	}
+	err := s.readTraceGroups(itemName, length, restored, func(traceId model.Value, positions []int) error {
+		s.traceGroup = traceId
+		for _, position := range positions {
+			item, err := s.innerDecode(def.ItemDefinition, itemName, true)
+			if err != nil {
+				return err
+			}
+			if restored == nil {
+				arrv = append(arrv, item)
+			} else {
+				arrv[position] = item
+			}
+		}
+		return nil
+	})
+	return arrv, err
+}
+
+// decodeTraceGroupsDirect 与 encodeTraceGroupsDirect 对应，把 length 个分组编码的 span 解码到 dest
+func (s *decodeState) decodeTraceGroupsDirect(def *model.Definition, itemName string, length int, dest ptrace.SpanSlice) error {
+	var restored *spanPositions
+	if s.groups.restoreOrder {
+		restored = newSpanPositions(length)
+		for i := 0; i < length; i++ {
+			dest.AppendEmpty()
+		}
+	}
+	return s.readTraceGroups(itemName, length, restored, func(traceId model.Value, positions []int) error {
+		s.traceGroup = traceId
+		for _, position := range positions {
+			var span ptrace.Span
+			if restored == nil {
+				span = dest.AppendEmpty()
+			} else {
+				span = dest.At(position)
+			}
+			err := s.decodeSpanDirect(def.ItemDefinition, itemName, span)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// readTraceGroups 读取分组数和每组的 traceId、span 个数和位置，对每组调用 decodeGroup 解码组内的 span。
+// restored 为 nil 时不读取位置，positions 是分组后的下标
+func (s *decodeState) readTraceGroups(itemName string, length int, restored *spanPositions, decodeGroup func(traceId model.Value, positions []int) error) error {
+	count, err := s.readInt()
+	if err != nil {
+		return err
+	}
+	err = checkGroupCount(count, length)
+	if err != nil {
+		return err
+	}
+	remaining := length
+	for g := 0; g < count; g++ {
+		traceId, err := s.innerDecode(s.groups.traceIdDefinition(), childName(itemName, "traceId"), true)
+		if err != nil {
+			return err
+		}
+		size, err := s.readInt()
+		if err != nil {
+			return err
+		}
+		err = checkGroupSize(size, remaining)
+		if err != nil {
+			return err
+		}
+		var positions []int
+		if restored != nil {
+			positions, err = restored.read(s.readInt, size)
+			if err != nil {
+				return err
+			}
+		} else {
+			positions = make([]int, size)
+			for k := range positions {
+				positions[k] = length - remaining + k
+			}
+		}
+		remaining -= size
+		err = decodeGroup(traceId, positions)
+		if err != nil {
+			return err
+		}
+	}
+	if remaining != 0 {
+		return fmt.Errorf("trace groups cover %d of %d spans", length-remaining, length)
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package codec
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/beet233/compressotelcollector/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+)
+
+// newInterleavedTraces 返回 span 按 trace 交错排列的 traces，第 i 个 span 属于第 i%traces 个 trace
+func newInterleavedTraces(spans int, traces int) ptrace.Traces {
+	td := newRichTraces(spans)
+	for r := 0; r < td.ResourceSpans().Len(); r++ {
+		scopeSpans := td.ResourceSpans().At(r).ScopeSpans()
+		for s := 0; s < scopeSpans.Len(); s++ {
+			slice := scopeSpans.At(s).Spans()
+			for i := 0; i < slice.Len(); i++ {
+				slice.At(i).SetTraceID([16]byte{byte(r), 1, byte(i % traces)})
+			}
+		}
+	}
+	return td
+}
+
+// groupedTraces 返回 td 的拷贝，每个 scopeSpans 中的 span 按 traceId 第一次出现的顺序分组
+func groupedTraces(td ptrace.Traces) ptrace.Traces {
+	grouped := ptrace.NewTraces()
+	td.CopyTo(grouped)
+	for r := 0; r < grouped.ResourceSpans().Len(); r++ {
+		scopeSpans := grouped.ResourceSpans().At(r).ScopeSpans()
+		for s := 0; s < scopeSpans.Len(); s++ {
+			slice := scopeSpans.At(s).Spans()
+			groups := groupByTraceId(slice.Len(), func(i int) []byte {
+				traceId := slice.At(i).TraceID()
+				return traceId[:]
+			})
+			sorted := ptrace.NewSpanSlice()
+			for _, indexes := range groups {
+				for _, index := range indexes {
+					slice.At(index).CopyTo(sorted.AppendEmpty())
+				}
+			}
+			sorted.CopyTo(slice)
+		}
+	}
+	return grouped
+}
+
+func TestTraceGrouping(t *testing.T) {
+	def, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(t, err)
+	td := newInterleavedTraces(30, 4)
+	grouped := groupedTraces(td)
+	require.NotEqual(t, 0, model.ValueComparator(TracesToValue(td), TracesToValue(grouped)))
+
+	for _, restore := range []bool{true, false} {
+		expected := grouped
+		if restore {
+			expected = td
+		}
+		for _, opts := range [][]Option{nil, {WithLeb128(false), WithStringPool(false)}, {WithParentReferences(true)}} {
+			var plain bytes.Buffer
+			_, err = NewEncoder(def, opts...).EncodeTraces(td, &plain)
+			require.NoError(t, err)
+
+			opts = append([]Option{WithTraceGrouping(true), WithRestoreSpanOrder(restore)}, opts...)
+			encoder := NewEncoder(def, opts...)
+			require.NotNil(t, encoder.groups)
+			assert.Nil(t, encoder.generated)
+			var direct, generic bytes.Buffer
+			_, err = encoder.EncodeTraces(td, &direct)
+			require.NoError(t, err)
+			_, err = encoder.Encode(TracesToValue(td), &generic)
+			require.NoError(t, err)
+			assert.Equal(t, direct.Bytes(), generic.Bytes())
+			if !restore {
+				assert.Less(t, direct.Len(), plain.Len())
+			}
+
+			decoder := NewDecoder(def, opts...)
+			got, _, err := decoder.DecodeTraces(bytes.NewReader(direct.Bytes()))
+			require.NoError(t, err)
+			assert.Equal(t, 0, model.ValueComparator(TracesToValue(expected), TracesToValue(got)))
+			// Decode 和 DecodeTraces 的顺序一致
+			value, _, err := decoder.Decode(bytes.NewReader(direct.Bytes()))
+			require.NoError(t, err)
+			var reencoded bytes.Buffer
+			_, err = NewEncoder(def).Encode(value, &reencoded)
+			require.NoError(t, err)
+			var expectedBuf bytes.Buffer
+			_, err = NewEncoder(def).EncodeTraces(expected, &expectedBuf)
+			require.NoError(t, err)
+			assert.Equal(t, expectedBuf.Bytes(), reencoded.Bytes())
+
+			root, err := decoder.Inspect(bytes.NewReader(direct.Bytes()))
+			require.NoError(t, err)
+			checkInspectNode(t, root)
+			var b bytes.Buffer
+			require.NoError(t, root.WriteText(&b))
+			assert.Contains(t, b.String(), "trace group (")
+			if restore {
+				assert.Contains(t, b.String(), "span positions [0 4 8")
+			}
+		}
+	}
+}
+
+func TestSpanPositions(t *testing.T) {
+	// 两组 span 交错排列，第二组的第一个位置是还没有出现过的 1
+	positions := newSpanPositions(5)
+	gaps := []int{1, 1}
+	readGap := func() (int, error) {
+		gap := gaps[0]
+		gaps = gaps[1:]
+		return gap, nil
+	}
+	first, err := positions.read(readGap, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 2, 4}, first)
+	gaps = []int{1}
+	second, err := positions.read(readGap, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 3}, second)
+
+	positions = newSpanPositions(3)
+	gaps = []int{5}
+	_, err = positions.read(readGap, 2)
+	assert.EqualError(t, err, "span position gap 5 after position 0 out of range of 3 spans")
+	positions = newSpanPositions(3)
+	gaps = []int{1}
+	_, err = positions.read(readGap, 2)
+	require.NoError(t, err)
+	gaps = []int{0}
+	_, err = positions.read(readGap, 2)
+	assert.EqualError(t, err, "span position 2 appears in more than one trace group")
+
+	assert.EqualError(t, checkGroupCount(4, 3), "4 trace groups out of range of 3 spans")
+	assert.EqualError(t, checkGroupSize(0, 3), "trace group of 0 spans out of range of 3 remaining spans")
+}
+
+func TestTraceGroupingCorrupted(t *testing.T) {
+	def, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(t, err)
+	td := ptrace.NewTraces()
+	spans := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	for i := 0; i < 3; i++ {
+		span := spans.AppendEmpty()
+		span.SetTraceID([16]byte{byte(i % 2)})
+		span.SetSpanID([8]byte{byte(i)})
+	}
+	opts := []Option{WithTraceGrouping(true)}
+	var buf bytes.Buffer
+	_, err = NewEncoder(def, opts...).EncodeTraces(td, &buf)
+	require.NoError(t, err)
+	decoder := NewDecoder(def, opts...)
+	root, err := decoder.Inspect(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	group := findInspectNode(root, InspectTraceGroup)
+	require.NotNil(t, group)
+	assert.Equal(t, 2, group.Value)
+
+	// 第一组的 span 个数之后是第二个 span 的位置间隔，改为超出范围
+	positions := findInspectNode(group, InspectSpanPositions)
+	require.NotNil(t, positions)
+	data := bytes.Clone(buf.Bytes())
+	data[positions.Offset] = 9
+	_, _, err = decoder.DecodeTraces(bytes.NewReader(data))
+	assert.ErrorContains(t, err, "span position gap 9 after position 0 out of range of 3 spans")
+	_, _, err = decoder.Decode(bytes.NewReader(data))
+	assert.ErrorContains(t, err, "span position gap 9 after position 0 out of range of 3 spans")
+	_, err = decoder.Inspect(bytes.NewReader(data))
+	assert.ErrorContains(t, err, "span position gap 9 after position 0 out of range of 3 spans")
+
+	// 位置间隔改为相加之后会溢出的值
+	var gap bytes.Buffer
+	require.NoError(t, WriteLeb128Int(&gap, math.MaxInt))
+	data = append(bytes.Clone(buf.Bytes()[:positions.Offset]), gap.Bytes()...)
+	data = append(data, buf.Bytes()[positions.Offset+1:]...)
+	_, _, err = decoder.DecodeTraces(bytes.NewReader(data))
+	assert.ErrorContains(t, err, fmt.Sprintf("span position gap %d after position 0 out of range of 3 spans", math.MaxInt))
+	_, _, err = decoder.Decode(bytes.NewReader(data))
+	assert.ErrorContains(t, err, fmt.Sprintf("span position gap %d after position 0 out of range of 3 spans", math.MaxInt))
+	_, err = decoder.Inspect(bytes.NewReader(data))
+	assert.ErrorContains(t, err, fmt.Sprintf("span position gap %d after position 0 out of range of 3 spans", math.MaxInt))
+
+	// 第一组的 span 个数改为 3，第二组没有 span 可分
+	data = bytes.Clone(buf.Bytes())
+	data[positions.Offset-1] = 3
+	_, _, err = decoder.DecodeTraces(bytes.NewReader(data))
+	assert.Error(t, err)
+}
+
+// findInspectNode 返回第一个 Kind 为 kind 的节点
+func findInspectNode(n *InspectNode, kind string) *InspectNode {
+	if n.Kind == kind {
+		return n
+	}
+	for _, child := range n.Children {
+		if found := findInspectNode(child, kind); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func TestPlanTraceGroups(t *testing.T) {
+	def, err := model.LoadTraceModel(zap.NewNop())
+	require.NoError(t, err)
+	assert.Nil(t, planTraceGroups(def, newOptions(nil)))
+	groups := planTraceGroups(def, newOptions([]Option{WithTraceGrouping(true)}))
+	require.NotNil(t, groups)
+	assert.True(t, groups.restoreOrder)
+	assert.True(t, groups.isTraceId(spanDefinitionOf(def), "traceId"))
+
+	// 池化的 span 不分组，编码结果和不开启时一样
+	pooled := def.Clone()
+	spanDefinitionOf(pooled).Pooled = true
+	encoder := NewEncoder(pooled, WithTraceGrouping(true))
+	assert.Nil(t, encoder.groups)
+	var grouped, plain bytes.Buffer
+	_, err = encoder.EncodeTraces(newRichTraces(5), &grouped)
+	require.NoError(t, err)
+	_, err = NewEncoder(pooled, WithGenerated(false)).EncodeTraces(newRichTraces(5), &plain)
+	require.NoError(t, err)
+	assert.Equal(t, plain.Bytes(), grouped.Bytes())
+}
@@ -0,0 +1,83 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// WriteString 写入 string 的内容，不包含长度，对应 DataReader.ReadString
+func WriteString(buf *bytes.Buffer, val string) error {
+	_, err := buf.WriteString(val)
+	return err
+}
+
+// WriteInt 以 8 byte 小端编码写入整数，对应 DataReader.ReadInt
+func WriteInt(buf *bytes.Buffer, val int) error {
+	var tmp [8]byte
+	binary.LittleEndian.PutUint64(tmp[:], uint64(val))
+	_, err := buf.Write(tmp[:])
+	return err
+}
+
+// WriteLeb128Int 以 sleb128 编码写入整数，最多 9 byte，对应 DataReader.ReadLeb128Int
+func WriteLeb128Int(buf *bytes.Buffer, val int) error {
+	// 这里使用的是有符号的 sleb128，对于负数可以省略前置的 11111111，对于正数省略前置的 00000000
+	more := true
+	i := 0
+	for i < 8 && more {
+		// 取出val的最低7位
+		byteVal := byte(val & 0x7F)
+		// 去掉已处理的7位
+		val >>= 7
+
+		// 设置继续位(最高位): 如果val非零或字节的符号位与val的符号位不一致
+		// 当val为正数且byteVal的第7位为1，或val为负数且byteVal的第7位为0，需要设置继续位，说明后面还有字节
+		// 就是说符号位要留一个，不能全删了，不然正负就改变了
+		// 继续位的判断依据了负数在内存中的符号扩展特性
+		shouldContinue := (val != 0 && val != -1)                            // 检查是否还有更多位需要编码
+		needsSignExtension := ((byteVal & 0x40) != 0) != ((val & 0x40) != 0) // 检查符号位扩展是否需要
+
+		more = shouldContinue || needsSignExtension
+		if more {
+			byteVal |= 0x80 // 如果有更多的字节，则将byteVal的第8位设置为1
+		}
+		// 将处理后的字节写入到缓冲区
+		if err := buf.WriteByte(byteVal); err != nil {
+			return err
+		}
+		i += 1
+	}
+
+	// 如果需要最高位的 8 bit，那就直接 copy，不用取 7 bit 了
+	if i == 8 && more {
+		err := buf.WriteByte(byte(val & 0xFF))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteFloat 以 8 byte 小端编码写入 float64，对应 DataReader.ReadFloat
+func WriteFloat(buf *bytes.Buffer, val float64) error {
+	var tmp [8]byte
+	binary.LittleEndian.PutUint64(tmp[:], math.Float64bits(val))
+	_, err := buf.Write(tmp[:])
+	return err
+}
+
+// WriteBoolean 以一个 byte 写入 boolean，对应 DataReader.ReadBoolean
+func WriteBoolean(buf *bytes.Buffer, val bool) error {
+	if val {
+		return buf.WriteByte(1)
+	}
+	return buf.WriteByte(0)
+}
+
+// WriteBytes 写入 bytes 的内容，不包含长度，对应 DataReader.ReadBytes
+func WriteBytes(buf *bytes.Buffer, val []byte) error {
+	_, err := buf.Write(val)
+	return err
+}
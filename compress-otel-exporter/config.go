@@ -2,24 +2,65 @@ package compressotelexporter
 
 import (
 	"fmt"
+
+	"github.com/beet233/compressotelcollector/codec"
+)
+
+const (
+	compressionNone = "none"
+	compressionZstd = "zstd"
+	compressionGzip = "gzip"
 )
 
 // Config defines configuration for your exporter.
 type config struct {
+	// Leb128Enabled 和 StringPoolEnabled 默认开启，需要和 receiver 的配置一致
 	Leb128Enabled     bool   `mapstructure:"leb128_enabled"`
 	StringPoolEnabled bool   `mapstructure:"string_pool_enabled"`
 	TargetReceiverUrl string `mapstructure:"target_receiver_url"`
+	// Compression 是 cprval 外层的通用压缩，可选 none、zstd、gzip，通过 Content-Encoding 告知 receiver
+	Compression string `mapstructure:"compression"`
+	// DumpDir 不为空时，把每批数据的 proto、json、cprval 及其 zstd/gzip 压缩结果和编码时的 CPU profile 写入该目录，用于离线对比压缩效果
+	DumpDir string `mapstructure:"dump_dir"`
+	// PoolLimits 限制每个 valuePool 的条目数，池子满了之后新值直接内联编码，不配置时不限制
+	PoolLimits codec.PoolLimits `mapstructure:"pool_limits"`
+	// AdaptivePoolingEnabled 开启时每批数据单独决定每个池子是否池化，池化不能减小 payload 的池子整体内联编码。
+	// 每批数据至少多编码一遍，编码耗时大约翻倍，默认关闭
+	AdaptivePoolingEnabled bool `mapstructure:"adaptive_pooling_enabled"`
+	// ParentReferencesEnabled 开启时父 span 在同一批数据中的 parentSpanId 编码为回引用，需要和 receiver 的配置一致
+	ParentReferencesEnabled bool `mapstructure:"parent_references_enabled"`
+	// TraceGroupingEnabled 开启时每个 scopeSpans 中的 span 按 traceId 分组编码，RestoreSpanOrder 开启时 receiver 还原 span 原来的顺序，
+	// 关闭时 payload 更小但 span 为分组后的顺序。两者都需要和 receiver 的配置一致
+	TraceGroupingEnabled bool `mapstructure:"trace_grouping_enabled"`
+	RestoreSpanOrder     bool `mapstructure:"restore_span_order"`
+	// TraceModelVersion 是使用的 trace 模型版本，加载的 trace 模型不是这个版本时创建失败，0 表示使用加载到的版本
+	TraceModelVersion int `mapstructure:"trace_model_version"`
+	// EmbeddedSchemaEnabled 开启时 payload 带有完整的 trace 模型，receiver 不需要配置这个版本的模型就能解码；
+	// 默认只带模型的版本和指纹，receiver 需要在 writer_trace_models 中配置其他版本的模型
+	EmbeddedSchemaEnabled bool `mapstructure:"embedded_schema_enabled"`
 }
 
 // var _ component.Config = (*config)(nil)
-var MyConfig *config = &config{}
 
 // Validate the configuration for errors to implement the configvalidator interface.
 // You can skip this if you do not want to validate your config
 func (c *config) Validate() error {
-	fmt.Println("Leb128Enabled: ", c.Leb128Enabled)
-	fmt.Println("StringPoolEnabled: ", c.StringPoolEnabled)
-	fmt.Println("TargetReceiverUrl: ", c.TargetReceiverUrl)
+	switch c.Compression {
+	case compressionNone, compressionZstd, compressionGzip:
+	default:
+		return fmt.Errorf("unsupported compression %q", c.Compression)
+	}
+	if c.PoolLimits.Default < 0 {
+		return fmt.Errorf("pool_limits.default must not be negative, got %d", c.PoolLimits.Default)
+	}
+	for poolId, limit := range c.PoolLimits.Pools {
+		if limit < 0 {
+			return fmt.Errorf("pool_limits.pools.%s must not be negative, got %d", poolId, limit)
+		}
+	}
+	if c.TraceModelVersion < 0 {
+		return fmt.Errorf("trace_model_version must not be negative, got %d", c.TraceModelVersion)
+	}
 	return nil
 
 }
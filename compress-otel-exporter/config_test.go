@@ -3,6 +3,7 @@ package compressotelexporter
 import (
 	"testing"
 
+	"github.com/beet233/compressotelcollector/codec"
 	"github.com/stretchr/testify/assert"
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/confmap"
@@ -15,6 +16,28 @@ func TestCreateUnmarshalConfig(t *testing.T) {
 	assert.Equal(t, factory.CreateDefaultConfig(), cfg)
 }
 
+func TestUnmarshalPoolLimits(t *testing.T) {
+	cfg := NewFactory().CreateDefaultConfig().(*config)
+	conf := confmap.NewFromStringMap(map[string]any{
+		"pool_limits": map[string]any{
+			"default": 1000,
+			"pools":   map[string]any{"traceId": 10},
+		},
+	})
+	assert.NoError(t, component.UnmarshalConfig(conf, cfg))
+	assert.Equal(t, codec.PoolLimits{Default: 1000, Pools: map[string]int{"traceId": 10}}, cfg.PoolLimits)
+	assert.NoError(t, cfg.Validate())
+}
+
 func TestValidate(t *testing.T) {
+	cfg := NewFactory().CreateDefaultConfig().(*config)
+	assert.NoError(t, cfg.Validate())
 
+	cfg.PoolLimits = codec.PoolLimits{Default: -1}
+	assert.Error(t, cfg.Validate())
+	cfg.PoolLimits = codec.PoolLimits{Pools: map[string]int{"traceId": -1}}
+	assert.Error(t, cfg.Validate())
+	cfg.PoolLimits = codec.PoolLimits{}
+	cfg.TraceModelVersion = -1
+	assert.Error(t, cfg.Validate())
 }
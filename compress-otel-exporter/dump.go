@@ -0,0 +1,103 @@
+package compressotelexporter
+
+import (
+	"bytes"
+	"github.com/klauspost/compress/zstd"
+	gzip "github.com/klauspost/pgzip"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"strconv"
+	"time"
+)
+
+// dumpTraces 把一批数据的各种编码结果写入 dir，文件名以同一个纳秒时间戳开头：
+// _out_proto、_out_proto_zstd、_out_proto_gzip、_out_json 是对照组，
+// _out、_out_zstd、_out_gzip 是 cprval 及其外层压缩结果，_pprof 是 cprval 编码时的 CPU profile
+func (e *tracesExporter) dumpTraces(td ptrace.Traces) error {
+	prefix := filepath.Join(e.config.DumpDir, strconv.FormatInt(time.Now().UnixNano(), 10))
+
+	protoMarshaler := ptrace.ProtoMarshaler{}
+	protoBuf, err := protoMarshaler.MarshalTraces(td)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(prefix+"_out_proto", protoBuf, 0o644)
+	if err != nil {
+		return err
+	}
+	err = dumpCompressed(prefix+"_out_proto", protoBuf)
+	if err != nil {
+		return err
+	}
+
+	jsonMarshaler := ptrace.JSONMarshaler{}
+	jsonBuf, err := jsonMarshaler.MarshalTraces(td)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(prefix+"_out_json", jsonBuf, 0o644)
+	if err != nil {
+		return err
+	}
+
+	profile, err := os.Create(prefix + "_pprof")
+	if err != nil {
+		return err
+	}
+	defer profile.Close()
+	// 同一时间只能有一个 CPU profile，并发导出时拿不到就算了
+	if pprof.StartCPUProfile(profile) == nil {
+		defer pprof.StopCPUProfile()
+	}
+	var cprvalBuf bytes.Buffer
+	_, err = e.encoder.EncodeTraces(td, &cprvalBuf)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(prefix+"_out", cprvalBuf.Bytes(), 0o644)
+	if err != nil {
+		return err
+	}
+	return dumpCompressed(prefix+"_out", cprvalBuf.Bytes())
+}
+
+// dumpCompressed 把 data 的 zstd、gzip 压缩结果分别写入 name_zstd 和 name_gzip
+func dumpCompressed(name string, data []byte) error {
+	var zstdBuf bytes.Buffer
+	zw, err := zstd.NewWriter(&zstdBuf, zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		return err
+	}
+	_, err = zw.Write(data)
+	if err != nil {
+		return err
+	}
+	// 关闭 writer 用以完成压缩.
+	err = zw.Close()
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(name+"_zstd", zstdBuf.Bytes(), 0o644)
+	if err != nil {
+		return err
+	}
+
+	var gzipBuf bytes.Buffer
+	gz := gzip.NewWriter(&gzipBuf)
+	err = gz.SetConcurrency(1<<20, 1)
+	if err != nil {
+		return err
+	}
+	_, err = gz.Write(data)
+	if err != nil {
+		return err
+	}
+	// 关闭 writer 用以完成压缩.
+	err = gz.Close()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name+"_gzip", gzipBuf.Bytes(), 0o644)
+}
@@ -3,6 +3,7 @@ package compressotelexporter
 import (
 	"context"
 	"go.opentelemetry.io/collector/exporter/exporterhelper"
+	"go.uber.org/zap"
 
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/exporter"
@@ -35,7 +36,13 @@ func NewFactory() exporter.Factory {
 
 func createDefaultConfig() component.Config {
 
-	return &config{}
+	return &config{
+		// 和 codec 的默认值以及 receiver 的默认配置一致，默认配置的 exporter 和 receiver 可以直接配合使用
+		Leb128Enabled:     true,
+		StringPoolEnabled: true,
+		Compression:       compressionNone,
+		RestoreSpanOrder:  true,
+	}
 }
 
 // createTracesExporter creates a trace exporter based on this config.
@@ -45,17 +52,27 @@ func createTracesExporter(
 	cfg component.Config,
 ) (exporter.Traces, error) {
 
-	// 赋给全局 config，主要是 pushTraces 本身并不携带 config 信息
-	MyConfig.Leb128Enabled = cfg.(*config).Leb128Enabled
-	MyConfig.StringPoolEnabled = cfg.(*config).StringPoolEnabled
-	MyConfig.TargetReceiverUrl = cfg.(*config).TargetReceiverUrl
+	set.Logger.Info("Creating compress exporter",
+		zap.Bool("leb128_enabled", cfg.(*config).Leb128Enabled),
+		zap.Bool("string_pool_enabled", cfg.(*config).StringPoolEnabled),
+		zap.String("target_receiver_url", cfg.(*config).TargetReceiverUrl),
+		zap.String("compression", cfg.(*config).Compression),
+		zap.Any("pool_limits", cfg.(*config).PoolLimits),
+		zap.Bool("adaptive_pooling_enabled", cfg.(*config).AdaptivePoolingEnabled),
+		zap.Bool("parent_references_enabled", cfg.(*config).ParentReferencesEnabled),
+		zap.Bool("trace_grouping_enabled", cfg.(*config).TraceGroupingEnabled),
+		zap.Bool("restore_span_order", cfg.(*config).RestoreSpanOrder))
+
+	exp, err := newTracesExporter(cfg.(*config), set)
+	if err != nil {
+		return nil, err
+	}
 
-	// 这里的 pushTraces 其实可以改成 自定义struct.pushTraces，自定义 struct 里可以携带 config，参考 arrow
 	return exporterhelper.NewTracesExporter(ctx, set, cfg,
-		pushTraces,
+		exp.pushTraces,
 		//	The parameters below are optional. Uncomment any as you need.
 		//	exporterhelper.WithStart(start component.StartFunc),
-		// exporterhelper.WithShutdown(shutdown component.ShutdownFunc),
+		exporterhelper.WithShutdown(exp.shutdown),
 		// exporterhelper.WithTimeout(timeoutSettings TimeoutSettings),
 		// exporterhelper.WithRetry(retrySettings RetrySettings),
 		// exporterhelper.WithQueue(queueSettings QueueSettings),
@@ -68,8 +85,9 @@ func createLogsExporter(
 	cfg component.Config,
 ) (exporter.Logs, error) {
 
+	exp := &logsExporter{logger: set.Logger}
 	return exporterhelper.NewLogsExporter(ctx, set, cfg,
-		pushLogs,
+		exp.pushLogs,
 		//	The parameters below are optional. Uncomment any as you need.
 		//	exporterhelper.WithStart(start component.StartFunc),
 		// exporterhelper.WithShutdown(shutdown component.ShutdownFunc),
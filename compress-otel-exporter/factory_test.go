@@ -23,6 +23,14 @@ func TestCreateTracesExporter(t *testing.T) {
 	te, err := factory.CreateTracesExporter(context.Background(), exportertest.NewNopCreateSettings(), cfg)
 	assert.NoError(t, err)
 	assert.NotNil(t, te)
+
+	// trace 模型的版本按配置从 Registry 中选择
+	cfg.(*config).TraceModelVersion = 1
+	_, err = factory.CreateTracesExporter(context.Background(), exportertest.NewNopCreateSettings(), cfg)
+	assert.NoError(t, err)
+	cfg.(*config).TraceModelVersion = 2
+	_, err = factory.CreateTracesExporter(context.Background(), exportertest.NewNopCreateSettings(), cfg)
+	assert.EqualError(t, err, "Definition traces version 2 is not registered")
 }
 func TestCreateLogsExporter(t *testing.T) {
 	factory := NewFactory()
@@ -2,22 +2,31 @@ package compressotelexporter
 
 import (
 	"context"
-	"fmt"
+
 	"go.opentelemetry.io/collector/pdata/plog"
+	"go.uber.org/zap"
 )
 
+type logsExporter struct {
+	logger *zap.Logger
+}
+
 // No default function for this. It must be implemented
 // Note: You can change the function name if you like
-func pushLogs(
+// logs 还没有压缩发送，只在 Debug 级别把收到的 logs 以 JSON 写入日志
+func (e *logsExporter) pushLogs(
 	ctx context.Context,
 	td plog.Logs,
 ) (err error) {
+	if !e.logger.Core().Enabled(zap.DebugLevel) {
+		return nil
+	}
 	marshaler := plog.JSONMarshaler{}
 	buf, err := marshaler.MarshalLogs(td)
 	if err != nil {
 		return err
 	}
-	fmt.Println(string(buf))
+	e.logger.Debug("Received logs", zap.Int("logRecords", td.LogRecordCount()), zap.ByteString("logs", buf))
 	return nil
 
 }
@@ -8,6 +8,8 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/collector/exporter/exportertest"
 	"go.opentelemetry.io/collector/pdata/plog"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestLogsExporterNoErrors(t *testing.T) {
@@ -20,3 +22,21 @@ func TestLogsExporterNoErrors(t *testing.T) {
 
 	assert.NoError(t, lle.Shutdown(context.Background()))
 }
+
+func TestLogsExporterLogsAtDebug(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	set := exportertest.NewNopCreateSettings()
+	set.Logger = zap.New(core)
+	f := NewFactory()
+	lle, err := f.CreateLogsExporter(context.Background(), set, f.CreateDefaultConfig())
+	require.NoError(t, err)
+
+	ld := plog.NewLogs()
+	ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Body().SetStr("hello")
+	assert.NoError(t, lle.ConsumeLogs(context.Background(), ld))
+	received := logs.FilterMessage("Received logs").All()
+	require.Len(t, received, 1)
+	assert.Equal(t, zap.DebugLevel, received[0].Level)
+	assert.Contains(t, received[0].ContextMap()["logs"], "hello")
+	assert.NoError(t, lle.Shutdown(context.Background()))
+}
@@ -0,0 +1,117 @@
+package compressotelexporter
+
+import (
+	"context"
+	"time"
+
+	"github.com/beet233/compressotelcollector/codec"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const meterScope = "github.com/beet233/compressotelexporter"
+
+// exporterMetrics 是 exporterhelper 自带的 sent/failed spans 之外，和压缩效果相关的指标
+type exporterMetrics struct {
+	attrs            metric.MeasurementOption
+	inputBytes       metric.Int64Counter
+	encodedBytes     metric.Int64Counter
+	compressedBytes  metric.Int64Counter
+	compressionRatio metric.Float64Histogram
+	encodeDuration   metric.Float64Histogram
+	poolEntries      metric.Int64Histogram
+	poolOverflows    metric.Int64Counter
+	sendDuration     metric.Float64Histogram
+}
+
+func newExporterMetrics(id component.ID, settings component.TelemetrySettings) (*exporterMetrics, error) {
+	meter := settings.MeterProvider.Meter(meterScope)
+	m := &exporterMetrics{
+		attrs: metric.WithAttributes(attribute.String("exporter", id.String())),
+	}
+	var err error
+	m.inputBytes, err = meter.Int64Counter("compressotelexporter_input_bytes",
+		metric.WithDescription("Bytes of the batches if they were marshaled as OTLP proto."),
+		metric.WithUnit("By"))
+	if err != nil {
+		return nil, err
+	}
+	m.encodedBytes, err = meter.Int64Counter("compressotelexporter_encoded_bytes",
+		metric.WithDescription("Bytes of the cprval payloads before outer compression."),
+		metric.WithUnit("By"))
+	if err != nil {
+		return nil, err
+	}
+	m.compressedBytes, err = meter.Int64Counter("compressotelexporter_compressed_bytes",
+		metric.WithDescription("Bytes of the payloads after outer compression, equal to encoded bytes when compression is none."),
+		metric.WithUnit("By"))
+	if err != nil {
+		return nil, err
+	}
+	m.compressionRatio, err = meter.Float64Histogram("compressotelexporter_compression_ratio",
+		metric.WithDescription("OTLP proto bytes divided by compressed bytes of each batch."),
+		metric.WithUnit("1"))
+	if err != nil {
+		return nil, err
+	}
+	m.encodeDuration, err = meter.Float64Histogram("compressotelexporter_encode_duration",
+		metric.WithDescription("Time spent encoding a batch into cprval, outer compression included."),
+		metric.WithUnit("ms"))
+	if err != nil {
+		return nil, err
+	}
+	m.poolEntries, err = meter.Int64Histogram("compressotelexporter_pool_entries",
+		metric.WithDescription("Number of entries in each pool of an encoded payload."),
+		metric.WithUnit("{entries}"))
+	if err != nil {
+		return nil, err
+	}
+	m.poolOverflows, err = meter.Int64Counter("compressotelexporter_pool_overflows",
+		metric.WithDescription("Number of values encoded inline because their pool reached its pool_limits."),
+		metric.WithUnit("{values}"))
+	if err != nil {
+		return nil, err
+	}
+	m.sendDuration, err = meter.Float64Histogram("compressotelexporter_send_duration",
+		metric.WithDescription("Time spent sending a payload to the receiver."),
+		metric.WithUnit("ms"))
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// recordEncode 记录一批数据的编码结果
+func (m *exporterMetrics) recordEncode(ctx context.Context, inputSize int, encodedSize int, compressedSize int, stats codec.EncodeStats, duration time.Duration) {
+	m.inputBytes.Add(ctx, int64(inputSize), m.attrs)
+	m.encodedBytes.Add(ctx, int64(encodedSize), m.attrs)
+	m.compressedBytes.Add(ctx, int64(compressedSize), m.attrs)
+	if compressedSize > 0 {
+		m.compressionRatio.Record(ctx, float64(inputSize)/float64(compressedSize), m.attrs)
+	}
+	m.encodeDuration.Record(ctx, durationMillis(duration), m.attrs)
+	m.poolEntries.Record(ctx, int64(stats.StringPoolSize), m.attrs, metric.WithAttributes(attribute.String("pool", "stringPool")))
+	for poolId, size := range stats.ValuePoolSizes {
+		m.poolEntries.Record(ctx, int64(size), m.attrs, metric.WithAttributes(attribute.String("pool", poolId)))
+	}
+	for poolId, overflows := range stats.PoolOverflows {
+		m.poolOverflows.Add(ctx, int64(overflows), m.attrs, metric.WithAttributes(attribute.String("pool", poolId)))
+	}
+}
+
+// recordSend 记录一次发送，statusCode 为 0 表示没有收到响应
+func (m *exporterMetrics) recordSend(ctx context.Context, statusCode int, err error, duration time.Duration) {
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	m.sendDuration.Record(ctx, durationMillis(duration), m.attrs, metric.WithAttributes(
+		attribute.String("outcome", outcome),
+		attribute.Int("status_code", statusCode),
+	))
+}
+
+func durationMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,48 @@
+package compressotelexporter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/beet233/compressotelcollector/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestExporterMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	settings := componenttest.NewNopTelemetrySettings()
+	settings.MeterProvider = sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	m, err := newExporterMetrics(component.NewID(typeStr), settings)
+	require.NoError(t, err)
+	m.recordEncode(context.Background(), 1000, 400, 250, codec.EncodeStats{
+		StringPoolSize: 3,
+		ValuePoolSizes: map[string]int{"traceId": 2, "spanId": 5},
+		PoolOverflows:  map[string]int{"spanId": 7},
+	}, time.Millisecond)
+	m.recordSend(context.Background(), 200, nil, time.Millisecond)
+	m.recordSend(context.Background(), 0, errors.New("connection refused"), time.Millisecond)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	got := map[string]metricdata.Aggregation{}
+	for _, metric := range rm.ScopeMetrics[0].Metrics {
+		got[metric.Name] = metric.Data
+	}
+	assert.Equal(t, int64(1000), got["compressotelexporter_input_bytes"].(metricdata.Sum[int64]).DataPoints[0].Value)
+	assert.Equal(t, int64(400), got["compressotelexporter_encoded_bytes"].(metricdata.Sum[int64]).DataPoints[0].Value)
+	assert.Equal(t, int64(250), got["compressotelexporter_compressed_bytes"].(metricdata.Sum[int64]).DataPoints[0].Value)
+	assert.Equal(t, 4.0, got["compressotelexporter_compression_ratio"].(metricdata.Histogram[float64]).DataPoints[0].Sum)
+	assert.Len(t, got["compressotelexporter_pool_entries"].(metricdata.Histogram[int64]).DataPoints, 3)
+	assert.Equal(t, int64(7), got["compressotelexporter_pool_overflows"].(metricdata.Sum[int64]).DataPoints[0].Value)
+	// 成功和失败各一个数据点
+	assert.Len(t, got["compressotelexporter_send_duration"].(metricdata.Histogram[float64]).DataPoints, 2)
+}
@@ -4,291 +4,179 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"github.com/beet233/compressotelcollector/codec"
 	"github.com/beet233/compressotelcollector/model"
+	"github.com/klauspost/compress/gzip"
 	"github.com/klauspost/compress/zstd"
-	gzip "github.com/klauspost/pgzip"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/exporter/exporterhelper"
 	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.uber.org/zap"
+	"io"
 	"net/http"
-	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
-// No default function for this. It must be implemented
-// Note: You can change the function name if you like
-func pushTraces(
-	ctx context.Context,
-	td ptrace.Traces,
-) (err error) {
-
-	// 创建一个 zstd writer.
-	var zstdBuf1 bytes.Buffer
-	zstd.WithEncoderConcurrency(1)
-	zw1, err := zstd.NewWriter(&zstdBuf1)
-	if err != nil {
-		return err
-	}
-
-	// 创建一个 zstd writer.
-	var gzipBuf1 bytes.Buffer
-	gz1 := gzip.NewWriter(&gzipBuf1)
-	if err != nil {
-		return err
-	}
-	gz1.SetConcurrency(1<<20, 1)
-
-	start := time.Now()
-	protoMarshaler := ptrace.ProtoMarshaler{}
-	buf, err := protoMarshaler.MarshalTraces(td)
-	if err != nil {
-		return err
-	}
-	end := time.Now()
-	fmt.Println("proto encoding duration:", end.Sub(start).String())
-
-	fileProto, err := os.Create(strconv.FormatInt(time.Now().UnixNano(), 10) + "_out_proto")
-	_, err = fileProto.Write(buf)
-	if err != nil {
-		return err
-	}
-
-	start = time.Now()
-	_, err = zw1.Write(buf)
-	if err != nil {
-		return err
-	}
-	end = time.Now()
-	fmt.Println("proto zstd encoding duration:", end.Sub(start).String())
-
-	// 关闭 writer 用以完成压缩.
-	err = zw1.Close()
-	if err != nil {
-		return err
-	}
-
-	fileProtoZstd, err := os.Create(strconv.FormatInt(time.Now().UnixNano(), 10) + "_out_proto_zstd")
-	_, err = fileProtoZstd.Write(zstdBuf1.Bytes())
-	if err != nil {
-		return err
-	}
-
-	start = time.Now()
-	_, err = gz1.Write(buf)
-	if err != nil {
-		return err
-	}
-	end = time.Now()
-	fmt.Println("proto gzip encoding duration:", end.Sub(start).String())
-
-	// 关闭 writer 用以完成压缩.
-	err = gz1.Close()
-	if err != nil {
-		return err
-	}
-
-	fileProtoGzip, err := os.Create(strconv.FormatInt(time.Now().UnixNano(), 10) + "_out_proto_gzip")
-	_, err = fileProtoGzip.Write(gzipBuf1.Bytes())
-	if err != nil {
-		return err
-	}
-
-	jsonMarshaler := ptrace.JSONMarshaler{}
-	buf, err = jsonMarshaler.MarshalTraces(td)
-	if err != nil {
-		return err
-	}
-
-	fileJSON, err := os.Create(strconv.FormatInt(time.Now().UnixNano(), 10) + "_out_json")
-	_, err = fileJSON.Write(buf)
-	if err != nil {
-		return err
-	}
-	// fmt.Println(string(buf))
-
-	// 将 td 转化为 model.Value 形式
-	tracesValue := tracesToValue(td)
-	if err != nil {
-		return err
-	}
+type tracesExporter struct {
+	config      *config
+	logger      *zap.Logger
+	entry       *model.Entry
+	encoder     *codec.Encoder
+	metrics     *exporterMetrics
+	client      *http.Client
+	zstdEncoder *zstd.Encoder
+}
 
-	// 创建一个 zstd writer.
-	var zstdBuf2 bytes.Buffer
-	zw2, err := zstd.NewWriter(&zstdBuf2)
+func newTracesExporter(cfg *config, set exporter.CreateSettings) (*tracesExporter, error) {
+	registry := model.NewRegistry()
+	_, err := registry.RegisterTraceModel(set.Logger)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	// 将 model.Value 形式的 td 数据完成字典编码，然后打印或保存到文件
-	fileZstd, err := os.Create(strconv.FormatInt(time.Now().UnixNano(), 10) + "_out_zstd")
-	var tempBuf bytes.Buffer
-	err = Encode(tracesValue, model.GetTraceModel(), &tempBuf)
+	entry, err := registry.Resolve(model.Traces, cfg.TraceModelVersion)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	_, err = zw2.Write(tempBuf.Bytes())
+	metrics, err := newExporterMetrics(set.ID, set.TelemetrySettings)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	// 关闭 writer 用以完成压缩.
-	err = zw2.Close()
+	// EncodeAll 可以并发调用，整个 exporter 共用一个 encoder
+	zstdEncoder, err := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return &tracesExporter{
+		config: cfg,
+		logger: set.Logger,
+		entry:  entry,
+		// Encoder 是并发安全的，exporterhelper 的多个 consumer 共用一个
+		encoder: codec.NewEntryEncoder(entry,
+			codec.WithLeb128(cfg.Leb128Enabled),
+			codec.WithStringPool(cfg.StringPoolEnabled),
+			codec.WithPoolLimits(cfg.PoolLimits),
+			codec.WithAdaptivePooling(cfg.AdaptivePoolingEnabled),
+			codec.WithParentReferences(cfg.ParentReferencesEnabled),
+			codec.WithTraceGrouping(cfg.TraceGroupingEnabled),
+			codec.WithRestoreSpanOrder(cfg.RestoreSpanOrder),
+			codec.WithEmbeddedSchema(cfg.EmbeddedSchemaEnabled),
+			codec.WithLogger(set.Logger)),
+		metrics:     metrics,
+		client:      &http.Client{},
+		zstdEncoder: zstdEncoder,
+	}, nil
+}
 
-	_, err = fileZstd.Write(zstdBuf2.Bytes())
-	if err != nil {
-		return err
-	}
+func (e *tracesExporter) shutdown(context.Context) error {
+	return e.zstdEncoder.Close()
+}
 
-	// 创建一个 zstd writer.
-	var gzipBuf2 bytes.Buffer
-	gz2 := gzip.NewWriter(&gzipBuf2)
-	if err != nil {
-		return err
-	}
+// No default function for this. It must be implemented
+// Note: You can change the function name if you like
+func (e *tracesExporter) pushTraces(
+	ctx context.Context,
+	td ptrace.Traces,
+) (err error) {
 
-	// 将 model.Value 形式的 td 数据完成字典编码，然后打印或保存到文件
-	fileGzip, err := os.Create(strconv.FormatInt(time.Now().UnixNano(), 10) + "_out_gzip")
-	var tempBuf2 bytes.Buffer
-	err = Encode(tracesValue, model.GetTraceModel(), &tempBuf2)
-	if err != nil {
-		return err
+	if len(e.config.DumpDir) > 0 {
+		err = e.dumpTraces(td)
+		if err != nil {
+			return err
+		}
 	}
 
-	_, err = gz2.Write(tempBuf2.Bytes())
+	start := time.Now()
+	// 根据 Definition 直接遍历 td 完成字典编码，不再先转化为 model.Value
+	var encoded bytes.Buffer
+	stats, err := e.encoder.EncodeTraces(td, &encoded)
 	if err != nil {
-		return err
+		e.logger.Error("Failed to encode traces", zap.Error(err), zap.Int("spans", td.SpanCount()))
+		// 同样的数据重试也会以同样的方式失败
+		return consumererror.NewPermanent(err)
 	}
-
-	// 关闭 writer 用以完成压缩.
-	err = gz2.Close()
+	body, err := e.compress(encoded.Bytes())
 	if err != nil {
-		return err
+		return consumererror.NewPermanent(err)
 	}
+	e.metrics.recordEncode(ctx, protoSizer.TracesSize(td), encoded.Len(), len(body), stats, time.Since(start))
 
-	_, err = fileGzip.Write(gzipBuf2.Bytes())
-	if err != nil {
-		return err
+	// 如果存在目标 url，则发一个 Post 请求把压缩结果送过去
+	if len(e.config.TargetReceiverUrl) > 0 {
+		return e.send(ctx, body)
 	}
+	return nil
+}
 
-	// 将 model.Value 形式的 td 数据完成字典编码，然后打印或保存到文件
-	file, err := os.Create(strconv.FormatInt(time.Now().UnixNano(), 10) + "_out")
-	err = Encode(tracesValue, model.GetTraceModel(), file)
-	if err != nil {
-		return err
+var protoSizer = &ptrace.ProtoMarshaler{}
+
+// compress 对 cprval 结果做外层压缩
+func (e *tracesExporter) compress(encoded []byte) ([]byte, error) {
+	switch e.config.Compression {
+	case compressionZstd:
+		return e.zstdEncoder.EncodeAll(encoded, nil), nil
+	case compressionGzip:
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		_, err := gw.Write(encoded)
+		if err != nil {
+			return nil, err
+		}
+		err = gw.Close()
+		if err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return encoded, nil
 	}
+}
 
-	// 如果存在目标 url，则发一个 Post 请求把压缩结果送过去
-	if len(MyConfig.TargetReceiverUrl) > 0 {
-		var buffer bytes.Buffer
-		fmt.Println("#########################################################################")
-		start := time.Now()
-		err = Encode(tracesValue, model.GetTraceModel(), &buffer)
+func (e *tracesExporter) send(ctx context.Context, body []byte) error {
+	start := time.Now()
+	statusCode := 0
+	err := func() error {
+		// 创建 HTTP 请求，使用 ctx 以遵守 exporterhelper 的超时设置
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.config.TargetReceiverUrl, bytes.NewReader(body))
 		if err != nil {
-			return err
+			return consumererror.NewPermanent(err)
 		}
-		end := time.Now()
-		fmt.Println("my encoding duration:", end.Sub(start).String())
-		// 创建 HTTP 请求，这里 buffer 是 POST 请求的 body
-		resp, err := http.Post(MyConfig.TargetReceiverUrl, "*/*", &buffer)
+		req.Header.Set("Content-Type", "application/octet-stream")
+		if e.config.Compression != compressionNone {
+			req.Header.Set("Content-Encoding", e.config.Compression)
+		}
+		resp, err := e.client.Do(req)
 		if err != nil {
 			return err
 		}
 		defer resp.Body.Close()
-		fmt.Println("post resp status: ", resp.Status)
-	}
-
-	return nil
+		statusCode = resp.StatusCode
+		return responseToError(resp)
+	}()
+	e.metrics.recordSend(ctx, statusCode, err, time.Since(start))
+	return err
 }
 
-func tracesToValue(td ptrace.Traces) model.Value {
-	tracesValue := model.ObjectValue{Data: map[string]model.Value{}}
-	resourceSpansValue := model.ArrayValue{Data: []model.Value{}}
-	for i := 0; i < td.ResourceSpans().Len(); i++ {
-		resourceSpanValue := model.ObjectValue{Data: map[string]model.Value{}}
-		resourceSpan := td.ResourceSpans().At(i)
-		resourceValue := model.ObjectValue{Data: map[string]model.Value{}}
-		resource := resourceSpan.Resource()
-		resourceValue.Data["attributes"] = model.AnyToValue(resource.Attributes().AsRaw())
-		resourceValue.Data["droppedAttributesCount"] = &model.IntegerValue{Data: int(resource.DroppedAttributesCount())}
-		resourceSpanValue.Data["resource"] = &resourceValue
-		scopeSpansValue := model.ArrayValue{Data: []model.Value{}}
-		for j := 0; j < resourceSpan.ScopeSpans().Len(); j++ {
-			scopeSpanValue := model.ObjectValue{Data: map[string]model.Value{}}
-			scopeSpan := resourceSpan.ScopeSpans().At(j)
-			scopeValue := model.ObjectValue{Data: map[string]model.Value{}}
-			scope := scopeSpan.Scope()
-			scopeValue.Data["name"] = &model.StringValue{Data: scope.Name()}
-			scopeValue.Data["version"] = &model.StringValue{Data: scope.Version()}
-			scopeValue.Data["attributes"] = model.AnyToValue(scope.Attributes().AsRaw())
-			scopeValue.Data["droppedAttributesCount"] = &model.IntegerValue{Data: int(scope.DroppedAttributesCount())}
-			scopeSpanValue.Data["scope"] = &scopeValue
-			spansValue := model.ArrayValue{Data: []model.Value{}}
-			for k := 0; k < scopeSpan.Spans().Len(); k++ {
-				spanValue := model.ObjectValue{Data: map[string]model.Value{}}
-				span := scopeSpan.Spans().At(k)
-				traceId := span.TraceID()
-				traceIdBytes := traceId[:]
-				spanValue.Data["traceId"] = &model.BytesValue{Data: traceIdBytes}
-				spanId := span.SpanID()
-				spanIdBytes := spanId[:]
-				spanValue.Data["spanId"] = &model.BytesValue{Data: spanIdBytes}
-				spanValue.Data["traceState"] = &model.StringValue{Data: span.TraceState().AsRaw()}
-				parentSpanId := span.ParentSpanID()
-				parentSpanIdBytes := parentSpanId[:]
-				spanValue.Data["parentSpanId"] = &model.BytesValue{Data: parentSpanIdBytes}
-				spanValue.Data["name"] = &model.StringValue{Data: span.Name()}
-				spanValue.Data["kind"] = &model.IntegerValue{Data: int(span.Kind())}
-				spanValue.Data["startTimeUnixNano"] = &model.IntegerValue{Data: int(span.StartTimestamp().AsTime().UnixNano())}
-				spanValue.Data["endTimeUnixNano"] = &model.IntegerValue{Data: int(span.EndTimestamp().AsTime().UnixNano())}
-				spanValue.Data["attributes"] = model.AnyToValue(span.Attributes().AsRaw())
-				spanValue.Data["droppedAttributesCount"] = &model.IntegerValue{Data: int(span.DroppedAttributesCount())}
-				eventsValue := model.ArrayValue{Data: []model.Value{}}
-				for m := 0; m < span.Events().Len(); m++ {
-					eventValue := model.ObjectValue{Data: map[string]model.Value{}}
-					event := span.Events().At(m)
-					eventValue.Data["timeUnixNano"] = &model.IntegerValue{Data: int(event.Timestamp().AsTime().UnixNano())}
-					eventValue.Data["name"] = &model.StringValue{Data: event.Name()}
-					eventValue.Data["attributes"] = model.AnyToValue(event.Attributes().AsRaw())
-					eventValue.Data["droppedAttributesCount"] = &model.IntegerValue{Data: int(event.DroppedAttributesCount())}
-					eventsValue.Data = append(eventsValue.Data, &eventValue)
-				}
-				spanValue.Data["events"] = &eventsValue
-				spanValue.Data["droppedEventsCount"] = &model.IntegerValue{Data: int(span.DroppedEventsCount())}
-				linksValue := model.ArrayValue{Data: []model.Value{}}
-				for m := 0; m < span.Links().Len(); m++ {
-					linkValue := model.ObjectValue{Data: map[string]model.Value{}}
-					link := span.Links().At(m)
-					traceId := link.TraceID()
-					traceIdBytes := traceId[:]
-					linkValue.Data["traceId"] = &model.BytesValue{Data: traceIdBytes}
-					spanId := link.SpanID()
-					spanIdBytes := spanId[:]
-					linkValue.Data["spanId"] = &model.BytesValue{Data: spanIdBytes}
-					linkValue.Data["traceState"] = &model.StringValue{Data: link.TraceState().AsRaw()}
-					linkValue.Data["attributes"] = model.AnyToValue(link.Attributes().AsRaw())
-					linkValue.Data["droppedAttributesCount"] = &model.IntegerValue{Data: int(link.DroppedAttributesCount())}
-					linksValue.Data = append(linksValue.Data, &linkValue)
-				}
-				spanValue.Data["links"] = &linksValue
-				spanValue.Data["droppedLinksCount"] = &model.IntegerValue{Data: int(span.DroppedLinksCount())}
-				statusValue := model.ObjectValue{Data: map[string]model.Value{}}
-				status := span.Status()
-				statusValue.Data["message"] = &model.StringValue{Data: status.Message()}
-				statusValue.Data["code"] = &model.IntegerValue{Data: int(status.Code())}
-				spanValue.Data["status"] = &statusValue
-				spansValue.Data = append(spansValue.Data, &spanValue)
-			}
-			scopeSpanValue.Data["spans"] = &spansValue
-			scopeSpanValue.Data["schemaUrl"] = &model.StringValue{Data: scopeSpan.SchemaUrl()}
-			scopeSpansValue.Data = append(scopeSpansValue.Data, &scopeSpanValue)
+// responseToError 根据 receiver 返回的状态码区分可重试和不可重试的失败
+func responseToError(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err := fmt.Errorf("receiver responded with %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable:
+		// receiver 的 pipeline 暂时拒绝数据，按 Retry-After 等待后重试
+		if seconds, parseErr := strconv.Atoi(resp.Header.Get("Retry-After")); parseErr == nil {
+			return exporterhelper.NewThrottleRetry(err, time.Duration(seconds)*time.Second)
 		}
-		resourceSpanValue.Data["scopeSpans"] = &scopeSpansValue
-		resourceSpanValue.Data["schemaUrl"] = &model.StringValue{Data: resourceSpan.SchemaUrl()}
-		resourceSpansValue.Data = append(resourceSpansValue.Data, &resourceSpanValue)
+		return err
+	case resp.StatusCode >= 500:
+		return err
+	default:
+		// 其余 4xx 说明数据本身有问题，重试也不会成功
+		return consumererror.NewPermanent(err)
 	}
-	tracesValue.Data["resourceSpans"] = &resourceSpansValue
-	return &tracesValue
 }
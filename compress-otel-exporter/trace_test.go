@@ -1,11 +1,19 @@
 package compressotelexporter
 
 import (
+	"bytes"
 	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
 
+	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumererror"
 	"go.opentelemetry.io/collector/exporter/exportertest"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 )
@@ -20,3 +28,87 @@ func TestTracesExporterNoErrors(t *testing.T) {
 
 	assert.NoError(t, lte.Shutdown(context.Background()))
 }
+
+func TestResponseToError(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		retryAfter    string
+		wantErr       bool
+		wantPermanent bool
+	}{
+		{name: "ok", status: http.StatusOK},
+		{name: "bad request", status: http.StatusBadRequest, wantErr: true, wantPermanent: true},
+		{name: "too large", status: http.StatusRequestEntityTooLarge, wantErr: true, wantPermanent: true},
+		{name: "unavailable", status: http.StatusServiceUnavailable, retryAfter: "1", wantErr: true},
+		{name: "too many requests", status: http.StatusTooManyRequests, wantErr: true},
+		{name: "internal error", status: http.StatusInternalServerError, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{
+				StatusCode: tt.status,
+				Status:     http.StatusText(tt.status),
+				Header:     http.Header{},
+				Body:       io.NopCloser(strings.NewReader("reason")),
+			}
+			if tt.retryAfter != "" {
+				resp.Header.Set("Retry-After", tt.retryAfter)
+			}
+			err := responseToError(resp)
+			if !tt.wantErr {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Equal(t, tt.wantPermanent, consumererror.IsPermanent(err))
+		})
+	}
+}
+
+func newTestTraces() ptrace.Traces {
+	td := ptrace.NewTraces()
+	rs := td.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("service.name", "test")
+	span := rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetTraceID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16})
+	span.SetSpanID([8]byte{1, 2, 3, 4, 5, 6, 7, 8})
+	span.SetName("test-span")
+	return td
+}
+
+func TestTracesExporterSendsCompressedPayload(t *testing.T) {
+	var gotEncoding string
+	var gotBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotEncoding = r.Header.Get("Content-Encoding")
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	f := NewFactory()
+	cfg := f.CreateDefaultConfig().(*config)
+	cfg.TargetReceiverUrl = server.URL
+	cfg.Compression = compressionZstd
+	dumpDir := t.TempDir()
+	cfg.DumpDir = dumpDir
+	te, err := f.CreateTracesExporter(context.Background(), exportertest.NewNopCreateSettings(), cfg)
+	require.NoError(t, err)
+
+	require.NoError(t, te.ConsumeTraces(context.Background(), newTestTraces()))
+	require.NoError(t, te.Shutdown(context.Background()))
+
+	assert.Equal(t, compressionZstd, gotEncoding)
+	zr, err := zstd.NewReader(nil)
+	require.NoError(t, err)
+	defer zr.Close()
+	decompressed, err := zr.DecodeAll(gotBody, nil)
+	require.NoError(t, err)
+	assert.True(t, bytes.Contains(decompressed, []byte("cprval")))
+
+	entries, err := os.ReadDir(dumpDir)
+	require.NoError(t, err)
+	// proto、proto_zstd、proto_gzip、json、pprof、cprval、cprval_zstd、cprval_gzip
+	assert.Len(t, entries, 8)
+}
@@ -1,13 +1,34 @@
 package compressotelreceiver
 
 import (
+	"errors"
 	"fmt"
+	"github.com/beet233/compressotelcollector/codec"
 	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/confighttp"
+	"strings"
 )
 
 // Config defines configuration for your receiver.
 type Config struct {
-	Port int `mapstructure:"port"`
+	// endpoint、tls、auth、cors、max_request_body_size、include_metadata 等 HTTP 服务配置
+	confighttp.HTTPServerSettings `mapstructure:",squash"`
+	// TracesURLPath 是接收 trace 的 URL 路径，部署在 ingress 之后时可以按需修改
+	TracesURLPath string `mapstructure:"traces_url_path"`
+	// Leb128Enabled 和 StringPoolEnabled 需要和 exporter 的配置一致
+	Leb128Enabled     bool               `mapstructure:"leb128_enabled"`
+	StringPoolEnabled bool               `mapstructure:"string_pool_enabled"`
+	Limits            codec.DecodeLimits `mapstructure:"limits"`
+	// ParentReferencesEnabled 需要和 exporter 的 parent_references_enabled 一致
+	ParentReferencesEnabled bool `mapstructure:"parent_references_enabled"`
+	// TraceGroupingEnabled 和 RestoreSpanOrder 需要和 exporter 的 trace_grouping_enabled、restore_span_order 一致
+	TraceGroupingEnabled bool `mapstructure:"trace_grouping_enabled"`
+	RestoreSpanOrder     bool `mapstructure:"restore_span_order"`
+	// TraceModelVersion 是使用的 trace 模型版本，加载的 trace 模型和 WriterTraceModels 中都没有这个版本时创建失败，0 表示使用加载到的版本
+	TraceModelVersion int `mapstructure:"trace_model_version"`
+	// WriterTraceModels 是其他版本的 exporter 使用的 trace 模型文件，payload 只带模型的版本和指纹时按它们解码；
+	// exporter 开启 embedded_schema_enabled 时 payload 带有完整的模型，不需要配置
+	WriterTraceModels []string `mapstructure:"writer_trace_models"`
 }
 
 var _ component.Config = (*Config)(nil)
@@ -15,7 +36,15 @@ var _ component.Config = (*Config)(nil)
 // Validate the configuration for errors to implement the configvalidator interface.
 // You can skip this if you do not want to validate your config
 func (c *Config) Validate() error {
-	fmt.Println("Port: ", c.Port)
+	if c.Endpoint == "" {
+		return errors.New("endpoint must be specified")
+	}
+	if !strings.HasPrefix(c.TracesURLPath, "/") {
+		return fmt.Errorf("traces_url_path %q must start with \"/\"", c.TracesURLPath)
+	}
+	if c.TraceModelVersion < 0 {
+		return fmt.Errorf("trace_model_version must not be negative, got %d", c.TraceModelVersion)
+	}
 	return nil
 
 }
@@ -16,5 +16,26 @@ func TestCreateUnmarshalConfig(t *testing.T) {
 }
 
 func TestValidate(t *testing.T) {
-
+	tests := []struct {
+		name    string
+		mutate  func(cfg *Config)
+		wantErr bool
+	}{
+		{name: "default", mutate: func(cfg *Config) {}},
+		{name: "custom path", mutate: func(cfg *Config) { cfg.TracesURLPath = "/compressed/v1/traces" }},
+		{name: "empty endpoint", mutate: func(cfg *Config) { cfg.Endpoint = "" }, wantErr: true},
+		{name: "relative path", mutate: func(cfg *Config) { cfg.TracesURLPath = "v1/traces" }, wantErr: true},
+		{name: "negative trace model version", mutate: func(cfg *Config) { cfg.TraceModelVersion = -1 }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewFactory().CreateDefaultConfig().(*Config)
+			tt.mutate(cfg)
+			if tt.wantErr {
+				assert.Error(t, cfg.Validate())
+			} else {
+				assert.NoError(t, cfg.Validate())
+			}
+		})
+	}
 }
@@ -2,14 +2,22 @@ package compressotelreceiver
 
 import (
 	"context"
+	"fmt"
+	"github.com/beet233/compressotelcollector/codec"
+	"github.com/beet233/compressotelcollector/model"
 	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/config/confighttp"
 	"go.opentelemetry.io/collector/consumer"
 	"go.opentelemetry.io/collector/receiver"
+	"go.opentelemetry.io/collector/receiver/receiverhelper"
 )
 
 const (
 	// typeStr is the type of the receiver
 	typeStr = "compressotelreceiver"
+
+	defaultEndpoint      = "0.0.0.0:4319"
+	defaultTracesURLPath = "/"
 )
 
 // NewFactory creates a receiver factory
@@ -33,7 +41,17 @@ func NewFactory() receiver.Factory {
 
 func createDefaultConfig() component.Config {
 
-	return &Config{}
+	return &Config{
+		HTTPServerSettings: confighttp.HTTPServerSettings{
+			Endpoint: defaultEndpoint,
+		},
+		TracesURLPath: defaultTracesURLPath,
+		// 默认使用 sleb128 和 stringPool，需要和 exporter 的 leb128_enabled、string_pool_enabled 保持一致
+		Leb128Enabled:     true,
+		StringPoolEnabled: true,
+		Limits:            codec.DefaultDecodeLimits(),
+		RestoreSpanOrder:  true,
+	}
 }
 func createTracesReceiver(
 	ctx context.Context,
@@ -42,10 +60,52 @@ func createTracesReceiver(
 	nextConsumer consumer.Traces,
 ) (receiver receiver.Traces, err error) {
 
+	registry := model.NewRegistry()
+	entry, err := registry.RegisterTraceModel(set.Logger)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range cfg.(*Config).WriterTraceModels {
+		_, err = registry.RegisterFile(model.Traces, path)
+		if err != nil {
+			return nil, fmt.Errorf("writer_trace_models %s: %w", path, err)
+		}
+	}
+	if cfg.(*Config).TraceModelVersion != 0 {
+		entry, err = registry.Get(model.Traces, cfg.(*Config).TraceModelVersion)
+		if err != nil {
+			return nil, err
+		}
+	}
+	obsrecv, err := receiverhelper.NewObsReport(receiverhelper.ObsReportSettings{
+		ReceiverID:             set.ID,
+		Transport:              "http",
+		ReceiverCreateSettings: set,
+	})
+	if err != nil {
+		return nil, err
+	}
+	metrics, err := newReceiverMetrics(set.ID, set.TelemetrySettings, entry.TopologicalFields)
+	if err != nil {
+		return nil, err
+	}
+
 	return &trace{
 		config:       cfg.(*Config),
+		settings:     set,
 		nextConsumer: nextConsumer,
-	}, err
+		decoder: codec.NewEntryDecoder(entry,
+			codec.WithLeb128(cfg.(*Config).Leb128Enabled),
+			codec.WithStringPool(cfg.(*Config).StringPoolEnabled),
+			codec.WithLimits(cfg.(*Config).Limits),
+			codec.WithParentReferences(cfg.(*Config).ParentReferencesEnabled),
+			codec.WithTraceGrouping(cfg.(*Config).TraceGroupingEnabled),
+			codec.WithRestoreSpanOrder(cfg.(*Config).RestoreSpanOrder),
+			codec.WithWriters(registry),
+			codec.WithLogger(set.Logger)),
+		obsrecv: obsrecv,
+		metrics: metrics,
+	}, nil
 
 }
 func createLogsReceiver(
@@ -23,6 +23,20 @@ func TestCreateTracesReceiver(t *testing.T) {
 	te, err := factory.CreateTracesReceiver(context.Background(), receivertest.NewNopCreateSettings(), cfg, nil)
 	assert.NoError(t, err)
 	assert.NotNil(t, te)
+
+	// trace 模型的版本按配置从 Registry 中选择
+	cfg.(*Config).TraceModelVersion = 1
+	_, err = factory.CreateTracesReceiver(context.Background(), receivertest.NewNopCreateSettings(), cfg, nil)
+	assert.NoError(t, err)
+	cfg.(*Config).TraceModelVersion = 2
+	_, err = factory.CreateTracesReceiver(context.Background(), receivertest.NewNopCreateSettings(), cfg, nil)
+	assert.EqualError(t, err, "Definition traces version 2 is not registered")
+
+	// 其他版本的 exporter 使用的模型文件注册到同一个 Registry
+	cfg.(*Config).TraceModelVersion = 0
+	cfg.(*Config).WriterTraceModels = []string{"missing.json"}
+	_, err = factory.CreateTracesReceiver(context.Background(), receivertest.NewNopCreateSettings(), cfg, nil)
+	assert.ErrorContains(t, err, "writer_trace_models missing.json: ")
 }
 func TestCreateLogsReceiver(t *testing.T) {
 	factory := NewFactory()
@@ -0,0 +1,83 @@
+package compressotelreceiver
+
+import (
+	"context"
+	"time"
+
+	"github.com/beet233/compressotelcollector/codec"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	meterScope = "github.com/beet233/compressotelreceiver"
+	// traceFormat 是 obsreport 中记录的数据格式
+	traceFormat = "cprval"
+	// otherPool 是 receiver 自己的 Definition 中没有的池子在指标中的名字。池子的名字来自 payload，
+	// 不认识的池子合并为一个，避免发送方制造任意多的 pool 属性值
+	otherPool = "other"
+)
+
+// receiverMetrics 是 obsreport 之外，和压缩格式本身相关的指标
+type receiverMetrics struct {
+	attrs           metric.MeasurementOption
+	compressedBytes metric.Int64Counter
+	decodedBytes    metric.Int64Counter
+	decodeDuration  metric.Float64Histogram
+	poolEntries     metric.Int64Histogram
+	// knownPools 是 receiver 的 Definition 中所有的池子
+	knownPools map[string]bool
+}
+
+// newReceiverMetrics 创建指标，knownPools 是 receiver 的 Definition 中的池子，只有它们单独记录 pool 属性
+func newReceiverMetrics(id component.ID, settings component.TelemetrySettings, knownPools []string) (*receiverMetrics, error) {
+	meter := settings.MeterProvider.Meter(meterScope)
+	m := &receiverMetrics{
+		attrs:      metric.WithAttributes(attribute.String("receiver", id.String())),
+		knownPools: make(map[string]bool, len(knownPools)),
+	}
+	for _, poolId := range knownPools {
+		m.knownPools[poolId] = true
+	}
+	var err error
+	m.compressedBytes, err = meter.Int64Counter("compressotelreceiver_compressed_bytes",
+		metric.WithDescription("Bytes of cprval payloads received."),
+		metric.WithUnit("By"))
+	if err != nil {
+		return nil, err
+	}
+	m.decodedBytes, err = meter.Int64Counter("compressotelreceiver_decoded_bytes",
+		metric.WithDescription("Estimated bytes of data decoded from cprval payloads, pool references counted every time they are used."),
+		metric.WithUnit("By"))
+	if err != nil {
+		return nil, err
+	}
+	m.decodeDuration, err = meter.Float64Histogram("compressotelreceiver_decode_duration",
+		metric.WithDescription("Time spent decoding a cprval payload."),
+		metric.WithUnit("ms"))
+	if err != nil {
+		return nil, err
+	}
+	m.poolEntries, err = meter.Int64Histogram("compressotelreceiver_pool_entries",
+		metric.WithDescription("Number of entries in each pool of a decoded payload."),
+		metric.WithUnit("{entries}"))
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// recordDecode 记录一次 Decode 的结果，解码失败时 stats 中只有已经解析到的部分
+func (m *receiverMetrics) recordDecode(ctx context.Context, stats codec.DecodeStats, duration time.Duration) {
+	m.compressedBytes.Add(ctx, int64(stats.CompressedSize), m.attrs)
+	m.decodedBytes.Add(ctx, int64(stats.DecodedSize), m.attrs)
+	m.decodeDuration.Record(ctx, float64(duration)/float64(time.Millisecond), m.attrs)
+	m.poolEntries.Record(ctx, int64(stats.StringPoolSize), m.attrs, metric.WithAttributes(attribute.String("pool", "stringPool")))
+	for poolId, size := range stats.ValuePoolSizes {
+		if !m.knownPools[poolId] {
+			poolId = otherPool
+		}
+		m.poolEntries.Record(ctx, int64(size), m.attrs, metric.WithAttributes(attribute.String("pool", poolId)))
+	}
+}
@@ -0,0 +1,73 @@
+package compressotelreceiver
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/beet233/compressotelcollector/codec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestReceiverMetricsRecordDecode(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	settings := componenttest.NewNopTelemetrySettings()
+	settings.MeterProvider = sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	m, err := newReceiverMetrics(component.NewID(typeStr), settings, []string{"traceId"})
+	require.NoError(t, err)
+	m.recordDecode(context.Background(), codec.DecodeStats{
+		CompressedSize: 100,
+		DecodedSize:    400,
+		StringPoolSize: 3,
+		ValuePoolSizes: map[string]int{"traceId": 2},
+	}, time.Millisecond)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	got := map[string]metricdata.Aggregation{}
+	for _, metric := range rm.ScopeMetrics[0].Metrics {
+		got[metric.Name] = metric.Data
+	}
+	assert.Equal(t, int64(100), got["compressotelreceiver_compressed_bytes"].(metricdata.Sum[int64]).DataPoints[0].Value)
+	assert.Equal(t, int64(400), got["compressotelreceiver_decoded_bytes"].(metricdata.Sum[int64]).DataPoints[0].Value)
+	assert.Equal(t, uint64(1), got["compressotelreceiver_decode_duration"].(metricdata.Histogram[float64]).DataPoints[0].Count)
+	// stringPool 和 traceId 各一个数据点
+	assert.Len(t, got["compressotelreceiver_pool_entries"].(metricdata.Histogram[int64]).DataPoints, 2)
+}
+
+// payload 中的池子名不在 receiver 的 Definition 中时都记录为 other
+func TestReceiverMetricsUnknownPools(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	settings := componenttest.NewNopTelemetrySettings()
+	settings.MeterProvider = sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	m, err := newReceiverMetrics(component.NewID(typeStr), settings, []string{"traceId"})
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		m.recordDecode(context.Background(), codec.DecodeStats{
+			ValuePoolSizes: map[string]int{"traceId": 1, fmt.Sprintf("unknown-%d", i): 1},
+		}, time.Millisecond)
+	}
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	pools := map[string]uint64{}
+	for _, metric := range rm.ScopeMetrics[0].Metrics {
+		if metric.Name != "compressotelreceiver_pool_entries" {
+			continue
+		}
+		for _, point := range metric.Data.(metricdata.Histogram[int64]).DataPoints {
+			pool, _ := point.Attributes.Value("pool")
+			pools[pool.AsString()] = point.Count
+		}
+	}
+	assert.Equal(t, map[string]uint64{"stringPool": 10, "traceId": 10, otherPool: 10}, pools)
+}
@@ -1,18 +1,35 @@
 package compressotelreceiver
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/beet233/compressotelcollector/codec"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/collector/receiver/receivertest"
 )
 
+// newTestConfig 返回一个监听随机端口的默认配置
+func newTestConfig() *Config {
+	cfg := NewFactory().CreateDefaultConfig().(*Config)
+	cfg.Endpoint = "localhost:0"
+	return cfg
+}
+
 func TestTracesReceiverNoErrors(t *testing.T) {
 	f := NewFactory()
-	lle, err := f.CreateTracesReceiver(context.Background(), receivertest.NewNopCreateSettings(), f.CreateDefaultConfig(), nil)
+	lle, err := f.CreateTracesReceiver(context.Background(), receivertest.NewNopCreateSettings(), newTestConfig(), nil)
 	require.NotNil(t, lle)
 	assert.NoError(t, err)
 
@@ -20,3 +37,115 @@ func TestTracesReceiverNoErrors(t *testing.T) {
 
 	assert.NoError(t, lle.Shutdown(context.Background()))
 }
+
+func TestTracesReceiverMultipleInstancesAndRestart(t *testing.T) {
+	f := NewFactory()
+	first, err := f.CreateTracesReceiver(context.Background(), receivertest.NewNopCreateSettings(), newTestConfig(), nil)
+	require.NoError(t, err)
+	second, err := f.CreateTracesReceiver(context.Background(), receivertest.NewNopCreateSettings(), newTestConfig(), nil)
+	require.NoError(t, err)
+
+	require.NoError(t, first.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, second.Start(context.Background(), componenttest.NewNopHost()))
+	assert.NoError(t, second.Shutdown(context.Background()))
+	assert.NoError(t, first.Shutdown(context.Background()))
+
+	restarted, err := f.CreateTracesReceiver(context.Background(), receivertest.NewNopCreateSettings(), newTestConfig(), nil)
+	require.NoError(t, err)
+	require.NoError(t, restarted.Start(context.Background(), componenttest.NewNopHost()))
+	assert.NoError(t, restarted.Shutdown(context.Background()))
+}
+
+func TestTracesReceiverStatusCodes(t *testing.T) {
+	// resourceSpans 为 null 的最小合法 payload
+	emptyTraces := append([]byte{0, 0}, append([]byte("cprval"), 0)...)
+
+	tests := []struct {
+		name           string
+		method         string
+		body           []byte
+		limits         codec.DecodeLimits
+		nextConsumer   consumer.Traces
+		wantStatus     int
+		wantRetryAfter bool
+	}{
+		{
+			name:         "success",
+			method:       http.MethodPost,
+			body:         emptyTraces,
+			limits:       codec.DefaultDecodeLimits(),
+			nextConsumer: consumertest.NewNop(),
+			wantStatus:   http.StatusOK,
+		},
+		{
+			name:         "wrong method",
+			method:       http.MethodGet,
+			limits:       codec.DefaultDecodeLimits(),
+			nextConsumer: consumertest.NewNop(),
+			wantStatus:   http.StatusMethodNotAllowed,
+		},
+		{
+			name:         "malformed payload",
+			method:       http.MethodPost,
+			body:         []byte("garbage"),
+			limits:       codec.DefaultDecodeLimits(),
+			nextConsumer: consumertest.NewNop(),
+			wantStatus:   http.StatusBadRequest,
+		},
+		{
+			name:         "payload too large",
+			method:       http.MethodPost,
+			body:         emptyTraces,
+			limits:       codec.DecodeLimits{MaxBodySize: 2},
+			nextConsumer: consumertest.NewNop(),
+			wantStatus:   http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:           "consumer refuses data",
+			method:         http.MethodPost,
+			body:           emptyTraces,
+			limits:         codec.DefaultDecodeLimits(),
+			nextConsumer:   consumertest.NewErr(errors.New("memory limit exceeded")),
+			wantStatus:     http.StatusServiceUnavailable,
+			wantRetryAfter: true,
+		},
+		{
+			name:         "consumer permanent error",
+			method:       http.MethodPost,
+			body:         emptyTraces,
+			limits:       codec.DefaultDecodeLimits(),
+			nextConsumer: consumertest.NewErr(consumererror.NewPermanent(errors.New("bad data"))),
+			wantStatus:   http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.Limits = tt.limits
+			recv, err := NewFactory().CreateTracesReceiver(context.Background(), receivertest.NewNopCreateSettings(), cfg, tt.nextConsumer)
+			require.NoError(t, err)
+			comp := recv.(*trace)
+			rec := httptest.NewRecorder()
+			comp.handleTraces(rec, httptest.NewRequest(tt.method, "/", bytes.NewReader(tt.body)))
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantRetryAfter, rec.Header().Get("Retry-After") != "")
+		})
+	}
+}
+
+func TestTracesReceiverLogsDecodeFailure(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	set := receivertest.NewNopCreateSettings()
+	set.Logger = zap.New(core)
+	recv, err := NewFactory().CreateTracesReceiver(context.Background(), set, newTestConfig(), consumertest.NewNop())
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte("garbage")))
+	req.RemoteAddr = "10.0.0.1:1234"
+	recv.(*trace).handleTraces(httptest.NewRecorder(), req)
+
+	failures := logs.FilterMessage("Failed to decode compressed traces").All()
+	require.Len(t, failures, 1)
+	assert.Equal(t, zap.WarnLevel, failures[0].Level)
+	assert.Equal(t, "10.0.0.1:1234", failures[0].ContextMap()["remote_addr"])
+}
@@ -0,0 +1,89 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+
+	compressotelexporter "github.com/beet233/compressotelexporter"
+	compressotelreceiver "github.com/beet233/compressotelreceiver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/confmap"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/exporter/exportertest"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/collector/receiver/receivertest"
+)
+
+// availableEndpoint 返回本机一个空闲的 TCP 地址
+func availableEndpoint(t *testing.T) string {
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+// 默认配置的 exporter 发出的 payload 可以被默认配置的 receiver 解码
+func TestDefaultExporterConfigDecodes(t *testing.T) {
+	endpoint := availableEndpoint(t)
+	receiverFactory := compressotelreceiver.NewFactory()
+	receiverCfg := receiverFactory.CreateDefaultConfig()
+	require.NoError(t, confmap.NewFromStringMap(map[string]any{"endpoint": endpoint}).Unmarshal(receiverCfg))
+	sink := new(consumertest.TracesSink)
+	recv, err := receiverFactory.CreateTracesReceiver(context.Background(), receivertest.NewNopCreateSettings(), receiverCfg, sink)
+	require.NoError(t, err)
+	require.NoError(t, recv.Start(context.Background(), componenttest.NewNopHost()))
+	defer func() {
+		assert.NoError(t, recv.Shutdown(context.Background()))
+	}()
+
+	// exporter 经过记录 body 的代理发给 receiver
+	var body []byte
+	proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: endpoint})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		body = data
+		r.Body = io.NopCloser(bytes.NewReader(data))
+		proxy.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	td := ptrace.NewTraces()
+	rs := td.ResourceSpans().AppendEmpty()
+	rs.Resource().Attributes().PutStr("service.name", "default-config")
+	span := rs.ScopeSpans().AppendEmpty().Spans().AppendEmpty()
+	span.SetTraceID([16]byte{1, 2, 3})
+	span.SetSpanID([8]byte{4, 5, 6})
+	span.SetName("default-config-span")
+	span.Attributes().PutStr("http.method", "GET")
+
+	// trace 模型有 Version，默认不压缩的 payload 以 schema id 开头，开启 embedded_schema_enabled 时以完整的 schema 开头
+	for magic, conf := range map[string]map[string]any{
+		"cprsid": {"target_receiver_url": server.URL},
+		"cprdef": {"target_receiver_url": server.URL, "embedded_schema_enabled": true},
+	} {
+		t.Run(magic, func(t *testing.T) {
+			sink.Reset()
+			exporterFactory := compressotelexporter.NewFactory()
+			exporterCfg := exporterFactory.CreateDefaultConfig()
+			require.NoError(t, confmap.NewFromStringMap(conf).Unmarshal(exporterCfg))
+			exp, err := exporterFactory.CreateTracesExporter(context.Background(), exportertest.NewNopCreateSettings(), exporterCfg)
+			require.NoError(t, err)
+			require.NoError(t, exp.ConsumeTraces(context.Background(), td))
+			require.NoError(t, exp.Shutdown(context.Background()))
+
+			require.Len(t, sink.AllTraces(), 1)
+			assert.Equal(t, td, sink.AllTraces()[0])
+			assert.True(t, bytes.HasPrefix(body, []byte(magic)))
+		})
+	}
+}
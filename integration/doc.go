@@ -0,0 +1,3 @@
+// Package integration 检查 compressotelexporter 和 compressotelreceiver 配合使用时的行为。
+// 它是单独的 module，exporter 和 receiver 互相不依赖，各自可以单独加入 collector 的 builder 配置
+package integration
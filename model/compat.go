@@ -0,0 +1,66 @@
+package model
+
+import "errors"
+
+// MatchFields 返回 reader 的每个 field 在 writer 中对应的 field 名，writer 中没有的 field 不在结果中。
+// 两边的 field 都有 Tag 时按 Tag 对应，field 可以改名；否则按 field 名对应
+func MatchFields(writer *Definition, reader *Definition) map[string]string {
+	result := make(map[string]string, len(reader.Fields))
+	if writer.tagged() && reader.tagged() {
+		writerNames := make(map[int]string, len(writer.Fields))
+		for fieldName, fieldDef := range writer.Fields {
+			writerNames[fieldDef.Tag] = fieldName
+		}
+		for fieldName, fieldDef := range reader.Fields {
+			if writerName, exist := writerNames[fieldDef.Tag]; exist {
+				result[fieldName] = writerName
+			}
+		}
+		return result
+	}
+	for fieldName := range reader.Fields {
+		if _, exist := writer.Fields[fieldName]; exist {
+			result[fieldName] = fieldName
+		}
+	}
+	return result
+}
+
+// CheckCompatibility 检查 reader 能否读取 writer 编码的 payload，返回所有不兼容的地方，路径为 reader 中的 field 路径。
+// writer 多出的 field 在解码时跳过，reader 多出的 field 必须可以为 null；对应的 field 类型必须相同，writer 中可以为 null 的 reader 中也必须可以为 null，reader 有 FixedLength 时 writer 的 FixedLength 必须相同。
+// 池化和差分编码的设置可以不同。滚动升级时新旧 Definition 需要在两个方向上都兼容
+func CheckCompatibility(writer *Definition, reader *Definition) error {
+	var errs []error
+	checkCompatibility(writer, reader, "", &errs)
+	return errors.Join(errs...)
+}
+
+func checkCompatibility(writer *Definition, reader *Definition, path string, errs *[]error) {
+	if writer.TypeName() != reader.TypeName() {
+		*errs = append(*errs, definitionErrorf(path, "type %s cannot be read as %s", writer.TypeName(), reader.TypeName()))
+		return
+	}
+	if writer.Nullable && !reader.Nullable {
+		*errs = append(*errs, definitionErrorf(path, "nullable in writer but not in reader"))
+	}
+	if reader.FixedLength > 0 && writer.FixedLength != reader.FixedLength {
+		*errs = append(*errs, definitionErrorf(path, "FixedLength %d in reader but %d in writer", reader.FixedLength, writer.FixedLength))
+	}
+	switch reader.Type {
+	case Object:
+		matched := MatchFields(writer, reader)
+		for _, fieldName := range sortedFieldNames(reader.Fields) {
+			fieldPath := childPath(path, fieldName)
+			writerName, exist := matched[fieldName]
+			if !exist {
+				if !reader.Fields[fieldName].Nullable {
+					*errs = append(*errs, definitionErrorf(fieldPath, "missing in writer and not nullable"))
+				}
+				continue
+			}
+			checkCompatibility(writer.Fields[writerName], reader.Fields[fieldName], fieldPath, errs)
+		}
+	case Array:
+		checkCompatibility(writer.ItemDefinition, reader.ItemDefinition, childPath(path, "item"), errs)
+	}
+}
@@ -0,0 +1,78 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFieldOrder(t *testing.T) {
+	def := &Definition{Type: Object, Fields: map[string]*Definition{
+		"b": {Type: Integer},
+		"a": {Type: Integer},
+		"c": {Type: Integer},
+	}}
+	assert.Equal(t, []string{"a", "b", "c"}, def.FieldOrder())
+	def.Fields["a"].Tag = 3
+	def.Fields["b"].Tag = 1
+	def.Fields["c"].Tag = 2
+	assert.Equal(t, []string{"b", "c", "a"}, def.FieldOrder())
+}
+
+func TestValidateTags(t *testing.T) {
+	def := &Definition{Type: Object, Version: 1, Fields: map[string]*Definition{
+		"a": {Type: Integer, Tag: 1},
+		"b": {Type: Integer, Tag: 1},
+		"c": {Type: Integer},
+		"d": {Type: Object, Tag: 2, Version: 2, Fields: map[string]*Definition{"e": {Type: Integer, Tag: -1}}},
+	}}
+	err := ValidateDefinition(def)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "b: Tag 1 is also used by a")
+	assert.Contains(t, err.Error(), "(root): either all fields or none have a Tag, missing on c")
+	assert.Contains(t, err.Error(), "d: Version is only allowed on the root")
+	assert.Contains(t, err.Error(), "d.e: negative Tag -1")
+}
+
+func TestCheckCompatibility(t *testing.T) {
+	old := &Definition{Type: Object, Version: 1, Fields: map[string]*Definition{
+		"name":    {Type: String, Tag: 1},
+		"count":   {Type: Integer, Tag: 2},
+		"removed": {Type: Bytes, Nullable: true, Tag: 3},
+		"tags":    {Type: Array, Nullable: true, Tag: 4, ItemDefinition: &Definition{Type: String}},
+	}}
+	// title 是改名后的 name，removed 被删除，note 是新增的
+	newer := &Definition{Type: Object, Version: 2, Fields: map[string]*Definition{
+		"title": {Type: String, Tag: 1},
+		"count": {Type: Integer, Tag: 2},
+		"tags":  {Type: Array, Nullable: true, Tag: 4, ItemDefinition: &Definition{Type: String}},
+		"note":  {Type: Object, Nullable: true, Tag: 5},
+	}}
+	assert.Equal(t, map[string]string{"title": "name", "count": "count", "tags": "tags"}, MatchFields(old, newer))
+	assert.NoError(t, CheckCompatibility(old, newer))
+	assert.NoError(t, CheckCompatibility(newer, old))
+
+	broken := newer.Clone()
+	broken.Fields["count"].Type = String
+	broken.Fields["note"].Nullable = false
+	broken.Fields["tags"].Nullable = false
+	broken.Fields["tags"].ItemDefinition.Type = Bytes
+	err := CheckCompatibility(old, broken)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "count: type int cannot be read as string")
+	assert.Contains(t, err.Error(), "note: missing in writer and not nullable")
+	assert.Contains(t, err.Error(), "tags: nullable in writer but not in reader")
+	assert.Contains(t, err.Error(), "tags.item: type string cannot be read as bytes")
+
+	// 没有 Tag 时按名字对应
+	untagged := &Definition{Type: Object, Fields: map[string]*Definition{"name": {Type: String}, "title": {Type: String, Nullable: true}}}
+	assert.Equal(t, map[string]string{"name": "name"}, MatchFields(old, untagged))
+	assert.NoError(t, CheckCompatibility(old, untagged))
+
+	// reader 的 FixedLength 要求 writer 的值长度相同
+	variable := &Definition{Type: Object, Fields: map[string]*Definition{"id": {Type: Bytes}}}
+	fixed := &Definition{Type: Object, Fields: map[string]*Definition{"id": {Type: Bytes, FixedLength: 8}}}
+	assert.NoError(t, CheckCompatibility(fixed, variable))
+	assert.EqualError(t, CheckCompatibility(variable, fixed), "id: FixedLength 8 in reader but 0 in writer")
+}
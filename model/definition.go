@@ -1,13 +1,17 @@
 package model
 
 import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"os"
+	"sort"
 	"strings"
-	"sync"
+
+	"go.uber.org/zap"
 )
 
 type Definition struct {
@@ -17,7 +21,11 @@ type Definition struct {
 	SharePooled    bool                   // share pool with other field
 	SharePoolId    string                 // shared pool id
 	DiffEncode     bool                   // for int, use difference with previous value of this field to encode
-	Fields         map[string]*Definition // need Fields when Type is Object
+	MaxPoolEntries int                    `json:",omitempty"` // max entries of the pool, values beyond it are encoded inline, 0 means unlimited
+	FixedLength    int                    `json:",omitempty"` // for Bytes, every value has exactly this many bytes and is encoded without a length prefix, 0 means variable
+	Tag            int                    `json:",omitempty"` // stable field number, fields of an Object are encoded in Tag order when all of them have one
+	Version        int                    `json:",omitempty"` // version of the whole Definition, only on the root, payloads of a versioned Definition carry its schema
+	Fields         map[string]*Definition // need Fields when Type is Object, nil means a free map like attributes
 	ItemDefinition *Definition            // need ItemDefinition when Type is Array
 }
 
@@ -73,67 +81,230 @@ type Definition struct {
 // 	}}},
 // }}
 
-var traceModel *Definition
+// 内置的 trace.json，工作目录下没有 trace.json 时使用
+//
+//go:embed trace.json
+var defaultTraceModelJSON []byte
 
+// GetDefinitionFromFile 读取 Definition JSON 文件，其中的 $ref 在加载时展开为独立的子 Definition
 func GetDefinitionFromFile(path string) (*Definition, error) {
 	byteValue, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("error reading JSON file: %v", err)
+		return nil, fmt.Errorf("error reading JSON file: %w", err)
 	}
+	return GetDefinitionFromJSON(byteValue)
+}
 
+func GetDefinitionFromJSON(byteValue []byte) (*Definition, error) {
 	var def Definition
-	err = json.Unmarshal(byteValue, &def)
+	err := json.Unmarshal(byteValue, &def)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing JSON to Definition: %v", err)
+		return nil, fmt.Errorf("error parsing JSON to Definition: %w", err)
 	}
 	err = validateDefinition(&def)
 	if err != nil {
-		return nil, fmt.Errorf("error validating Definition: %v", err)
+		return nil, fmt.Errorf("error validating Definition: %w", err)
 	}
 	return &def, nil
 }
 
+// ValidateDefinition 检查 Definition 的结构和池化、差分编码的用法是否合法，返回所有错误，每个错误都是带有 field 路径的 *DefinitionError。
+// GetDefinitionFromJSON 会自动调用，手动构造或修改的 Definition 使用前需要检查
+func ValidateDefinition(def *Definition) error {
+	return validateDefinition(def)
+}
+
 func validateDefinition(def *Definition) error {
-	sharedPool := make(map[string]*Definition)
-	sharedPoolInPreviousPath := make(map[string]bool)
+	v := &validator{
+		sharedPool:               make(map[string]*Definition),
+		sharedPoolPath:           make(map[string]string),
+		sharedPoolInPreviousPath: make(map[string]bool),
+	}
+	v.validate(def, "", "")
+	return errors.Join(v.errs...)
+}
 
-	return validateDfs(def, &sharedPool, &sharedPoolInPreviousPath)
+type validator struct {
+	errs []error
+	// sharedPool 是每个共享池第一次出现的 Definition 和路径
+	sharedPool               map[string]*Definition
+	sharedPoolPath           map[string]string
+	sharedPoolInPreviousPath map[string]bool
 }
 
-func validateDfs(def *Definition, sharedPool *map[string]*Definition, sharedPoolInPreviousPath *map[string]bool) error {
-	if def != nil {
-		if def.SharePooled {
-			if _, exist := (*sharedPoolInPreviousPath)[def.SharePoolId]; exist {
-				return errors.New("existing illegal shared pool in same tree path -> SharedPoolId: " + def.SharePoolId)
-			}
-			(*sharedPoolInPreviousPath)[def.SharePoolId] = true
-			if oldDef, exist := (*sharedPool)[def.SharePoolId]; exist {
-				if !isEqual(oldDef, def) {
-					return errors.New("existing different definitions sharing pool -> SharedPoolId: " + def.SharePoolId)
-				}
-			} else {
-				(*sharedPool)[def.SharePoolId] = def
-			}
+func (v *validator) errorf(path string, format string, args ...any) {
+	v.errs = append(v.errs, definitionErrorf(path, format, args...))
+}
+
+// validate 检查 def 及其子 Definition，pooledAncestor 是最近的池化的祖先的路径
+func (v *validator) validate(def *Definition, path string, pooledAncestor string) {
+	if def == nil {
+		v.errorf(path, "missing Definition")
+		return
+	}
+	if def.Type < Integer || def.Type > Array {
+		v.errorf(path, "unknown Type %d", def.Type)
+		return
+	}
+	basic := def.Type == Integer || def.Type == Boolean || def.Type == Double
+	switch {
+	case def.Fields != nil && def.Type != Object:
+		v.errorf(path, "Fields is only allowed on object, got %s", def.TypeName())
+	case def.Fields != nil && len(def.Fields) == 0:
+		v.errorf(path, "object without Fields, use map for attributes-like free maps")
+	}
+	switch {
+	case def.ItemDefinition != nil && def.Type != Array:
+		v.errorf(path, "ItemDefinition is only allowed on array, got %s", def.TypeName())
+	case def.ItemDefinition == nil && def.Type == Array:
+		v.errorf(path, "array without ItemDefinition")
+	}
+	if def.DiffEncode {
+		if def.Type != Integer {
+			v.errorf(path, "DiffEncode is only allowed on int, got %s", def.TypeName())
+		} else if pooledAncestor != "" {
+			// 包含差分编码的元素及其父元素入池之后，解码时无法还原差分的顺序
+			v.errorf(path, "DiffEncode is not allowed under pooled %s", pooledAncestor)
+		}
+	}
+	if def.Pooled && (def.SharePooled || def.SharePoolId != "") {
+		v.errorf(path, "Pooled cannot be combined with SharePooled or SharePoolId, use either one")
+	}
+	if def.SharePooled && def.SharePoolId == "" {
+		v.errorf(path, "SharePooled without SharePoolId")
+	}
+	if !def.SharePooled && !def.Pooled && def.SharePoolId != "" {
+		v.errorf(path, "SharePoolId %q without SharePooled", def.SharePoolId)
+	}
+	if strings.Contains(def.SharePoolId, " ") {
+		v.errorf(path, "SharePoolId %q must not contain spaces", def.SharePoolId)
+	}
+	if (def.Pooled || def.SharePooled) && basic {
+		v.errorf(path, "%s cannot be pooled", def.TypeName())
+	}
+	if def.Tag < 0 {
+		v.errorf(path, "negative Tag %d", def.Tag)
+	}
+	if def.Version < 0 {
+		v.errorf(path, "negative Version %d", def.Version)
+	} else if def.Version > 0 && path != "" {
+		v.errorf(path, "Version is only allowed on the root")
+	}
+	if def.FixedLength < 0 {
+		v.errorf(path, "negative FixedLength %d", def.FixedLength)
+	} else if def.FixedLength > 0 && def.Type != Bytes {
+		v.errorf(path, "FixedLength is only allowed on bytes, got %s", def.TypeName())
+	}
+	if def.MaxPoolEntries < 0 {
+		v.errorf(path, "negative MaxPoolEntries %d", def.MaxPoolEntries)
+	} else if def.MaxPoolEntries > 0 && !def.Pooled && !def.SharePooled {
+		v.errorf(path, "MaxPoolEntries is only allowed on pooled fields")
+	}
+	if def.SharePooled && def.SharePoolId != "" {
+		if v.sharedPoolInPreviousPath[def.SharePoolId] {
+			v.errorf(path, "shared pool %q is already used by an ancestor", def.SharePoolId)
 		}
-		if def.Type == Array {
-			err := validateDfs(def.ItemDefinition, sharedPool, sharedPoolInPreviousPath)
-			if err != nil {
-				return err
+		if oldDef, exist := v.sharedPool[def.SharePoolId]; exist {
+			if !isEqual(oldDef, def) {
+				v.errorf(path, "shared pool %q has a different definition from %s", def.SharePoolId, v.sharedPoolPath[def.SharePoolId])
 			}
+		} else {
+			v.sharedPool[def.SharePoolId] = def
+			v.sharedPoolPath[def.SharePoolId] = path
 		}
-		if def.Type == Object {
-			for _, fieldDef := range def.Fields {
-				err := validateDfs(fieldDef, sharedPool, sharedPoolInPreviousPath)
-				if err != nil {
-					return err
-				}
+	}
+
+	if (def.Pooled || def.SharePooled) && !basic && pooledAncestor == "" {
+		pooledAncestor = path
+		if pooledAncestor == "" {
+			pooledAncestor = "(root)"
+		}
+	}
+	previous := v.sharedPoolInPreviousPath[def.SharePoolId]
+	if def.SharePooled {
+		v.sharedPoolInPreviousPath[def.SharePoolId] = true
+	}
+	if def.Type == Array && def.ItemDefinition != nil {
+		v.validate(def.ItemDefinition, childPath(path, "item"), pooledAncestor)
+	}
+	if def.Type == Object {
+		v.validateTags(def, path)
+		for _, fieldName := range sortedFieldNames(def.Fields) {
+			fieldPath := childPath(path, fieldName)
+			// valuePool 的 id 用空格分隔 field 名，item 表示 Array 的元素
+			if fieldName == "" || fieldName == "item" || strings.ContainsAny(fieldName, " .") {
+				v.errorf(fieldPath, "invalid field name %q", fieldName)
 			}
+			v.validate(def.Fields[fieldName], fieldPath, pooledAncestor)
+		}
+	}
+	if def.SharePooled {
+		v.sharedPoolInPreviousPath[def.SharePoolId] = previous
+	}
+}
+
+// FieldOrder 返回 Object 的 field 的编解码顺序：所有 field 都有 Tag 时按 Tag 升序，否则按 field 名的字典序
+func (def *Definition) FieldOrder() []string {
+	fieldNames := sortedFieldNames(def.Fields)
+	if !def.tagged() {
+		return fieldNames
+	}
+	sort.Slice(fieldNames, func(i, j int) bool {
+		return def.Fields[fieldNames[i]].Tag < def.Fields[fieldNames[j]].Tag
+	})
+	return fieldNames
+}
+
+// tagged 表示 def 的所有 field 都有 Tag
+func (def *Definition) tagged() bool {
+	if len(def.Fields) == 0 {
+		return false
+	}
+	for _, fieldDef := range def.Fields {
+		if fieldDef == nil || fieldDef.Tag == 0 {
+			return false
 		}
-		if def.SharePooled {
-			delete(*sharedPoolInPreviousPath, def.SharePoolId)
+	}
+	return true
+}
+
+// Clone 返回 def 的深拷贝
+func (def *Definition) Clone() *Definition {
+	if def == nil {
+		return nil
+	}
+	clone := *def
+	if def.Fields != nil {
+		clone.Fields = make(map[string]*Definition, len(def.Fields))
+		for fieldName, fieldDef := range def.Fields {
+			clone.Fields[fieldName] = fieldDef.Clone()
 		}
 	}
-	return nil
+	clone.ItemDefinition = def.ItemDefinition.Clone()
+	return &clone
+}
+
+// validateTags 检查 Object 的 field 要么都有 Tag，要么都没有，并且 Tag 不重复
+func (v *validator) validateTags(def *Definition, path string) {
+	var untagged []string
+	tags := make(map[int]string)
+	for _, fieldName := range sortedFieldNames(def.Fields) {
+		fieldDef := def.Fields[fieldName]
+		if fieldDef == nil {
+			continue
+		}
+		if fieldDef.Tag == 0 {
+			untagged = append(untagged, fieldName)
+			continue
+		}
+		if other, exist := tags[fieldDef.Tag]; exist {
+			v.errorf(childPath(path, fieldName), "Tag %d is also used by %s", fieldDef.Tag, other)
+		}
+		tags[fieldDef.Tag] = fieldName
+	}
+	if len(tags) > 0 && len(untagged) > 0 {
+		v.errorf(path, "either all fields or none have a Tag, missing on %s", strings.Join(untagged, ", "))
+	}
 }
 
 func isEqual(a *Definition, b *Definition) bool {
@@ -157,6 +328,15 @@ func isEqual(a *Definition, b *Definition) bool {
 		if a.SharePoolId != b.SharePoolId {
 			return false
 		}
+		if a.MaxPoolEntries != b.MaxPoolEntries {
+			return false
+		}
+		if a.FixedLength != b.FixedLength {
+			return false
+		}
+		if a.Tag != b.Tag {
+			return false
+		}
 		if a.Type != b.Type {
 			return false
 		}
@@ -181,38 +361,28 @@ func isEqual(a *Definition, b *Definition) bool {
 	return true
 }
 
-var topologicalTraceModelFieldsLock sync.Mutex
-var topologicalTraceModelFields = make([]string, 0)
+// traceModelPath 是 trace Definition 文件的路径，相对于 collector 的工作目录
+const traceModelPath = "./trace.json"
 
-func GetTraceModel() *Definition {
-	if traceModel == nil {
-		fmt.Println("get trace model from file...")
-		tmp, err := GetDefinitionFromFile("./trace.json")
-		if err != nil {
-			log.Fatalln(err)
-		}
-		traceModel = tmp
+// LoadTraceModel 从 ./trace.json 加载 trace 的 Definition，文件不存在时使用内置的 trace.json。
+// 每次调用都重新加载，需要共用时注册到 Registry 中
+func LoadTraceModel(logger *zap.Logger) (*Definition, error) {
+	def, err := GetDefinitionFromFile(traceModelPath)
+	if errors.Is(err, os.ErrNotExist) {
+		logger.Info("Trace model file not found, using built-in trace model", zap.String("path", traceModelPath))
+		return GetDefinitionFromJSON(defaultTraceModelJSON)
 	}
-	return traceModel
-}
-
-func GetTopologicalTraceModelFields() []string {
-	// 获取锁
-	for !topologicalTraceModelFieldsLock.TryLock() {
-	}
-	// 如果还没初始化
-	if len(topologicalTraceModelFields) == 0 {
-		// 初始化模型的 fields 拓扑顺序
-		topologicalTraceModelFields = getTopologicalFieldsByDefinition(GetTraceModel())
-	}
-	// 释放锁
-	topologicalTraceModelFieldsLock.Unlock()
-	return topologicalTraceModelFields
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("Loaded trace model from file", zap.String("path", traceModelPath))
+	return def, nil
 }
 
-// 根据 definition，将所有 fields 以编码的拓扑顺序返回
+// GetTopologicalFields 根据 definition，将所有 fields 以编码的拓扑顺序返回
 // 但整个 definition 已经是拓扑的树形结构，其实只需要一个 dfs
-func getTopologicalFieldsByDefinition(definition *Definition) []string {
+// 同一个 definition 每次返回的顺序都相同
+func GetTopologicalFields(definition *Definition) []string {
 	result := make([]string, 0)
 	result = dfs(definition, "", result)
 	return result
@@ -226,8 +396,14 @@ func dfs(definition *Definition, myName string, result []string) []string {
 		}
 		switch definition.Type {
 		case Object:
-			for fieldName, fieldDef := range definition.Fields {
-				result = dfs(fieldDef, myName+fieldName, result)
+			// 按 field 名的字典序遍历，保证 valuePools 的顺序固定
+			fieldNames := make([]string, 0, len(definition.Fields))
+			for fieldName := range definition.Fields {
+				fieldNames = append(fieldNames, fieldName)
+			}
+			sort.Strings(fieldNames)
+			for _, fieldName := range fieldNames {
+				result = dfs(definition.Fields[fieldName], myName+fieldName, result)
 			}
 		case Array:
 			result = dfs(definition.ItemDefinition, myName+"item", result)
@@ -257,7 +433,7 @@ func dfs(definition *Definition, myName string, result []string) []string {
 func FieldStringToDefinition(field string, def *Definition) *Definition {
 	fieldPath := strings.Split(field, " ")
 	currDef := def
-	for i := 0; i < len(fieldPath); i++ {
+	for i := 0; i < len(fieldPath) && currDef != nil; i++ {
 		if fieldPath[i] == "item" {
 			currDef = currDef.ItemDefinition
 		} else {
@@ -294,3 +470,14 @@ func dfsSharedPoolId(sharedPoolId string, def *Definition) *Definition {
 	}
 	return nil
 }
+
+// Fingerprint 返回 Definition 结构的摘要，结构相同的 Definition 摘要相同，与 JSON 中 field 的书写顺序无关
+func Fingerprint(def *Definition) (string, error) {
+	// encoding/json 按 key 的字典序输出 map，序列化结果是确定的
+	raw, err := json.Marshal(toFingerprintDefinition(def))
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,206 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefinitionTypeNames(t *testing.T) {
+	def, err := GetDefinitionFromJSON([]byte(`{
+		"Type": "object",
+		"Fields": {
+			"count": {"Type": "int", "DiffEncode": true},
+			"legacy": {"Type": 4, "Pooled": true},
+			"flag": {"Type": "Boolean"},
+			"attributes": {"Type": "map", "Nullable": true},
+			"legacyAttributes": {"Type": 5},
+			"items": {"Type": "array", "ItemDefinition": {"Type": "bytes", "FixedLength": 16}}
+		}
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, Integer, def.Fields["count"].Type)
+	assert.Equal(t, String, def.Fields["legacy"].Type)
+	assert.Equal(t, Boolean, def.Fields["flag"].Type)
+	assert.Equal(t, Object, def.Fields["attributes"].Type)
+	assert.Nil(t, def.Fields["attributes"].Fields)
+	// 旧的整数写法没有 Fields 时仍然是 map
+	assert.Nil(t, def.Fields["legacyAttributes"].Fields)
+	assert.Equal(t, Bytes, def.Fields["items"].ItemDefinition.Type)
+	assert.Equal(t, 16, def.Fields["items"].ItemDefinition.FixedLength)
+
+	raw, err := json.Marshal(def)
+	require.NoError(t, err)
+	again, err := GetDefinitionFromJSON(raw)
+	require.NoError(t, err)
+	assert.True(t, isEqual(def, again))
+	assert.Contains(t, string(raw), `"Type":"map"`)
+	assert.Contains(t, string(raw), `"Type":"int"`)
+}
+
+func TestFingerprintIgnoresTypeSpelling(t *testing.T) {
+	named, err := GetDefinitionFromJSON([]byte(`{"Type": "object", "Fields": {"a": {"Type": "string", "Pooled": true}, "b": {"Type": "map"}}}`))
+	require.NoError(t, err)
+	legacy, err := GetDefinitionFromJSON([]byte(`{"Type": 5, "Fields": {"a": {"Type": 4, "Pooled": true}, "b": {"Type": 5}}}`))
+	require.NoError(t, err)
+	namedFingerprint, err := Fingerprint(named)
+	require.NoError(t, err)
+	legacyFingerprint, err := Fingerprint(legacy)
+	require.NoError(t, err)
+	assert.Equal(t, legacyFingerprint, namedFingerprint)
+}
+
+func TestDefinitionParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		src      string
+		expected string
+	}{
+		{`{"Type": "objetc"}`, `(root): unknown Type "objetc"`},
+		{`{"Type": "object", "Fields": {"a": {"Type": 9}}}`, `a: unknown Type 9`},
+		{`{"Type": "array", "ItemDefinition": {"Type": "object", "Fields": {"a": {"Typ": "int"}}}}`, `item.a: json: unknown field "Typ"`},
+		{`{"Type": "object", "Fields": {"a": {"Nullable": true}}}`, `a: missing Type`},
+		{`{"Type": "object", "Fields": {"a": null}}`, `a: Definition is null`},
+		{`{"Type": "map", "Fields": {}}`, `(root): map must not have Fields`},
+	} {
+		_, err := GetDefinitionFromJSON([]byte(tc.src))
+		require.Error(t, err, tc.src)
+		assert.Contains(t, err.Error(), tc.expected, tc.src)
+		var defErr *DefinitionError
+		assert.True(t, errors.As(err, &defErr), tc.src)
+	}
+}
+
+func TestValidateDefinition(t *testing.T) {
+	spans := func(span map[string]*Definition) *Definition {
+		return &Definition{Type: Object, Fields: map[string]*Definition{
+			"resourceSpans": {Type: Array, ItemDefinition: &Definition{Type: Object, Fields: map[string]*Definition{
+				"scopeSpans": {Type: Array, ItemDefinition: &Definition{Type: Object, Fields: map[string]*Definition{
+					"spans": {Type: Array, ItemDefinition: &Definition{Type: Object, Fields: span}},
+				}}},
+			}}},
+		}}
+	}
+	const spanPath = "resourceSpans.item.scopeSpans.item.spans.item."
+	for _, tc := range []struct {
+		name     string
+		span     map[string]*Definition
+		expected []string
+	}{
+		{"object without Fields", map[string]*Definition{"status": {Type: Object, Fields: map[string]*Definition{}}},
+			[]string{spanPath + "status: object without Fields"}},
+		{"array without ItemDefinition", map[string]*Definition{"events": {Type: Array}},
+			[]string{spanPath + "events: array without ItemDefinition"}},
+		{"Fields on array", map[string]*Definition{"events": {Type: Array, ItemDefinition: &Definition{Type: Integer}, Fields: map[string]*Definition{"a": {Type: Integer}}}},
+			[]string{spanPath + "events: Fields is only allowed on object, got array"}},
+		{"DiffEncode on string", map[string]*Definition{"name": {Type: String, DiffEncode: true}},
+			[]string{spanPath + "name: DiffEncode is only allowed on int, got string"}},
+		{"DiffEncode under pool", map[string]*Definition{"status": {Type: Object, Pooled: true, Fields: map[string]*Definition{"code": {Type: Integer, DiffEncode: true}}}},
+			[]string{spanPath + "status.code: DiffEncode is not allowed under pooled " + spanPath + "status"}},
+		{"SharePoolId with Pooled", map[string]*Definition{"traceId": {Type: Bytes, Pooled: true, SharePoolId: "traceId"}},
+			[]string{spanPath + "traceId: Pooled cannot be combined with SharePooled or SharePoolId"}},
+		{"SharePooled without id", map[string]*Definition{"traceId": {Type: Bytes, SharePooled: true}},
+			[]string{spanPath + "traceId: SharePooled without SharePoolId"}},
+		{"pooled int", map[string]*Definition{"kind": {Type: Integer, Pooled: true}},
+			[]string{spanPath + "kind: int cannot be pooled"}},
+		{"MaxPoolEntries without pool", map[string]*Definition{"name": {Type: String, MaxPoolEntries: 10}},
+			[]string{spanPath + "name: MaxPoolEntries is only allowed on pooled fields"}},
+		{"different shared definitions", map[string]*Definition{
+			"parentSpanId": {Type: Bytes, SharePooled: true, SharePoolId: "spanId"},
+			"spanId":       {Type: Bytes, Nullable: true, SharePooled: true, SharePoolId: "spanId"},
+		}, []string{spanPath + "spanId: shared pool \"spanId\" has a different definition from " + spanPath + "parentSpanId"}},
+		{"nested shared pool", map[string]*Definition{
+			"link": {Type: Object, SharePooled: true, SharePoolId: "link", Fields: map[string]*Definition{
+				"next": {Type: Object, SharePooled: true, SharePoolId: "link", Fields: map[string]*Definition{"a": {Type: String}}},
+			}},
+		}, []string{spanPath + "link.next: shared pool \"link\" is already used by an ancestor"}},
+		{"FixedLength on string", map[string]*Definition{"name": {Type: String, FixedLength: 4}},
+			[]string{spanPath + "name: FixedLength is only allowed on bytes, got string"}},
+		{"negative FixedLength", map[string]*Definition{"traceId": {Type: Bytes, FixedLength: -1}},
+			[]string{spanPath + "traceId: negative FixedLength -1"}},
+		{"shared pool with different FixedLength", map[string]*Definition{
+			"parentSpanId": {Type: Bytes, SharePooled: true, SharePoolId: "spanId", FixedLength: 8},
+			"spanId":       {Type: Bytes, SharePooled: true, SharePoolId: "spanId"},
+		}, []string{spanPath + "spanId: shared pool \"spanId\" has a different definition from " + spanPath + "parentSpanId"}},
+		{"invalid field name", map[string]*Definition{"item": {Type: String}},
+			[]string{spanPath + "item: invalid field name"}},
+		{"all errors", map[string]*Definition{"kind": {Type: Integer, Pooled: true}, "name": {Type: String, DiffEncode: true}},
+			[]string{spanPath + "kind: int cannot be pooled", spanPath + "name: DiffEncode is only allowed on int"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateDefinition(spans(tc.span))
+			require.Error(t, err)
+			for _, expected := range tc.expected {
+				assert.Contains(t, err.Error(), expected)
+			}
+		})
+	}
+
+	// map 和共享池的正常用法
+	assert.NoError(t, ValidateDefinition(spans(map[string]*Definition{
+		"attributes":   {Type: Object, Nullable: true, Pooled: true},
+		"spanId":       {Type: Bytes, SharePooled: true, SharePoolId: "spanId"},
+		"parentSpanId": {Type: Bytes, SharePooled: true, SharePoolId: "spanId", MaxPoolEntries: 0},
+		"start":        {Type: Integer, DiffEncode: true},
+	})))
+}
+
+func TestBuiltInTraceModel(t *testing.T) {
+	def, err := GetDefinitionFromJSON(defaultTraceModelJSON)
+	require.NoError(t, err)
+	assert.NoError(t, ValidateDefinition(def))
+}
+
+func TestDefinitionRefs(t *testing.T) {
+	def, err := GetDefinitionFromJSON([]byte(`{
+		"$defs": {
+			"spanId": {"Type": "bytes", "Nullable": true, "SharePooled": true, "SharePoolId": "spanId"},
+			"link": {"Type": "object", "Fields": {"spanId": {"$ref": "#/$defs/spanId"}, "attributes": {"$ref": "attributes"}}},
+			"attributes": {"Type": "map", "Nullable": true, "Pooled": true}
+		},
+		"Type": "object",
+		"Fields": {
+			"spanId": {"$ref": "spanId"},
+			"parentSpanId": {"$ref": "spanId"},
+			"links": {"Type": "array", "ItemDefinition": {"$ref": "link", "Nullable": true}},
+			"attributes": {"$ref": "attributes", "Pooled": false}
+		}
+	}`))
+	require.NoError(t, err)
+	assert.True(t, isEqual(def.Fields["spanId"], def.Fields["parentSpanId"]))
+	assert.True(t, isEqual(def.Fields["spanId"], def.Fields["links"].ItemDefinition.Fields["spanId"]))
+	// 每次引用都是独立的拷贝，覆盖的属性只影响引用处
+	assert.NotSame(t, def.Fields["spanId"], def.Fields["parentSpanId"])
+	assert.True(t, def.Fields["links"].ItemDefinition.Nullable)
+	assert.False(t, def.Fields["attributes"].Pooled)
+	assert.True(t, def.Fields["links"].ItemDefinition.Fields["attributes"].Pooled)
+	assert.Equal(t, []string{"links item attributes", "spanId"}, GetTopologicalFields(def))
+}
+
+func TestDefinitionRefErrors(t *testing.T) {
+	for _, tc := range []struct {
+		src      string
+		expected string
+	}{
+		{`{"Type": "object", "Fields": {"a": {"$ref": "missing"}}}`, `a: unknown $ref "missing"`},
+		{`{"$defs": {"node": {"Type": "object", "Fields": {"child": {"$ref": "node"}}}}, "$ref": "node"}`,
+			`$defs.node.child: cyclic $ref node -> node`},
+		{`{"$defs": {"a": {"Type": "array", "ItemDefinition": {"$ref": "b"}}, "b": {"Type": "object", "Fields": {"x": {"$ref": "a"}}}},
+			"Type": "object", "Fields": {"f": {"$ref": "a"}}}`, `$defs.b.x: cyclic $ref a -> b -> a`},
+		// 没有被引用的 $defs 也会检查
+		{`{"$defs": {"unused": {"Type": "objetc"}}, "Type": "map"}`, `$defs.unused: unknown Type "objetc"`},
+		{`{"Type": "object", "Fields": {"a": {"$defs": {}, "Type": "int"}}}`, `a: $defs is only allowed at the root`},
+		{`{"$defs": {"a": {"Type": "int"}}, "Type": "object", "Fields": {"a": {"$ref": "a", "Type": "string"}}}`,
+			`a: $ref cannot be combined with Type`},
+		// 共享池的检查作用在展开之后的树上
+		{`{"$defs": {"id": {"Type": "bytes", "SharePooled": true, "SharePoolId": "id"}},
+			"Type": "object", "Fields": {"a": {"$ref": "id"}, "b": {"$ref": "id", "Nullable": true}}}`,
+			`b: shared pool "id" has a different definition from a`},
+	} {
+		_, err := GetDefinitionFromJSON([]byte(tc.src))
+		require.Error(t, err, tc.src)
+		assert.Contains(t, err.Error(), tc.expected, tc.src)
+	}
+}
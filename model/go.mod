@@ -2,4 +2,15 @@ module model
 
 go 1.20
 
-require github.com/emirpasic/gods v1.18.1
+require (
+	github.com/cespare/xxhash/v2 v2.2.0
+	github.com/stretchr/testify v1.8.4
+	go.uber.org/zap v1.26.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	go.uber.org/multierr v1.10.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,142 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// 常用的信号名，Registry 的名字也可以是其他任意字符串
+const (
+	Traces  = "traces"
+	Logs    = "logs"
+	Metrics = "metrics"
+)
+
+// Entry 是一个命名的 Definition，以及编解码需要的预先计算好的 valuePools 拓扑顺序和指纹。
+// 创建之后只读，可以被多个 Encoder 和 Decoder 共用
+type Entry struct {
+	Name       string
+	Version    int
+	Definition *Definition
+	// TopologicalFields 是 GetTopologicalFields 的结果
+	TopologicalFields []string
+	// Fingerprint 是 Fingerprint 的结果
+	Fingerprint string
+}
+
+// NewEntry 检查 def 并创建 Entry，Version 取自 def.Version
+func NewEntry(name string, def *Definition) (*Entry, error) {
+	err := ValidateDefinition(def)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Definition %s: %w", name, err)
+	}
+	fingerprint, err := Fingerprint(def)
+	if err != nil {
+		return nil, err
+	}
+	return &Entry{
+		Name:              name,
+		Version:           def.Version,
+		Definition:        def,
+		TopologicalFields: GetTopologicalFields(def),
+		Fingerprint:       fingerprint,
+	}, nil
+}
+
+// Registry 按名字和版本保存多个 Definition，比如 traces 的 v1 和 v2、logs、metrics，可以并发使用
+type Registry struct {
+	lock    sync.RWMutex
+	entries map[string]map[int]*Entry
+}
+
+func NewRegistry() *Registry {
+	return &Registry{entries: make(map[string]map[int]*Entry)}
+}
+
+// Register 把 def 以 name 和 def.Version 注册，同名同版本已经注册过时返回错误
+func (r *Registry) Register(name string, def *Definition) (*Entry, error) {
+	entry, err := NewEntry(name, def)
+	if err != nil {
+		return nil, err
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	versions, exist := r.entries[name]
+	if !exist {
+		versions = make(map[int]*Entry)
+		r.entries[name] = versions
+	}
+	if _, exist := versions[entry.Version]; exist {
+		return nil, fmt.Errorf("Definition %s version %d is already registered", name, entry.Version)
+	}
+	versions[entry.Version] = entry
+	return entry, nil
+}
+
+// RegisterFile 读取 Definition JSON 文件并注册
+func (r *Registry) RegisterFile(name string, path string) (*Entry, error) {
+	def, err := GetDefinitionFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return r.Register(name, def)
+}
+
+// RegisterTraceModel 把 LoadTraceModel 加载的 trace 模型以 Traces 注册
+func (r *Registry) RegisterTraceModel(logger *zap.Logger) (*Entry, error) {
+	def, err := LoadTraceModel(logger)
+	if err != nil {
+		return nil, err
+	}
+	return r.Register(Traces, def)
+}
+
+// Resolve 返回 name 的指定版本，version 为 0 时返回版本最高的，用于按配置选择 Definition
+func (r *Registry) Resolve(name string, version int) (*Entry, error) {
+	if version == 0 {
+		return r.Latest(name)
+	}
+	return r.Get(name, version)
+}
+
+// Get 返回 name 的指定版本
+func (r *Registry) Get(name string, version int) (*Entry, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	entry, exist := r.entries[name][version]
+	if !exist {
+		return nil, fmt.Errorf("Definition %s version %d is not registered", name, version)
+	}
+	return entry, nil
+}
+
+// Latest 返回 name 版本最高的 Entry
+func (r *Registry) Latest(name string) (*Entry, error) {
+	versions := r.Versions(name)
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("Definition %s is not registered", name)
+	}
+	return r.Get(name, versions[len(versions)-1])
+}
+
+// Names 按字典序返回所有注册过的名字
+func (r *Registry) Names() []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return sortedFieldNames(r.entries)
+}
+
+// Versions 按升序返回 name 的所有版本
+func (r *Registry) Versions(name string) []int {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	versions := make([]int, 0, len(r.entries[name]))
+	for version := range r.entries[name] {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+	return versions
+}
@@ -0,0 +1,77 @@
+package model
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	v1, err := GetDefinitionFromJSON(defaultTraceModelJSON)
+	require.NoError(t, err)
+	v1.Version = 1
+	v2 := v1.Clone()
+	v2.Version = 2
+	v2.Fields["resourceSpans"].Nullable = false
+
+	entry1, err := registry.Register(Traces, v1)
+	require.NoError(t, err)
+	entry2, err := registry.Register(Traces, v2)
+	require.NoError(t, err)
+	assert.Equal(t, GetTopologicalFields(v1), entry1.TopologicalFields)
+	fingerprint, err := Fingerprint(v2)
+	require.NoError(t, err)
+	assert.Equal(t, fingerprint, entry2.Fingerprint)
+	assert.NotEqual(t, entry1.Fingerprint, entry2.Fingerprint)
+
+	path := filepath.Join(t.TempDir(), "logs.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"Type": "object", "Fields": {"body": {"Type": "string"}, "attributes": {"Type": "map", "Pooled": true}}}`), 0o644))
+	logs, err := registry.RegisterFile(Logs, path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"attributes"}, logs.TopologicalFields)
+
+	got, err := registry.Get(Traces, 1)
+	require.NoError(t, err)
+	assert.Same(t, entry1, got)
+	latest, err := registry.Latest(Traces)
+	require.NoError(t, err)
+	assert.Same(t, entry2, latest)
+	resolved, err := registry.Resolve(Traces, 0)
+	require.NoError(t, err)
+	assert.Same(t, entry2, resolved)
+	resolved, err = registry.Resolve(Traces, 1)
+	require.NoError(t, err)
+	assert.Same(t, entry1, resolved)
+	assert.Equal(t, []string{Logs, Traces}, registry.Names())
+	assert.Equal(t, []int{1, 2}, registry.Versions(Traces))
+
+	_, err = registry.Register(Traces, v1.Clone())
+	assert.EqualError(t, err, "Definition traces version 1 is already registered")
+	_, err = registry.Get(Traces, 3)
+	assert.EqualError(t, err, "Definition traces version 3 is not registered")
+	_, err = registry.Latest(Metrics)
+	assert.EqualError(t, err, "Definition metrics is not registered")
+	_, err = registry.Register(Metrics, &Definition{Type: Array})
+	assert.ErrorContains(t, err, "array without ItemDefinition")
+	assert.Empty(t, registry.Versions(Metrics))
+}
+
+func TestRegisterTraceModel(t *testing.T) {
+	registry := NewRegistry()
+	entry, err := registry.RegisterTraceModel(zap.NewNop())
+	require.NoError(t, err)
+	assert.Equal(t, Traces, entry.Name)
+	assert.Equal(t, 1, entry.Version)
+	resolved, err := registry.Resolve(Traces, 0)
+	require.NoError(t, err)
+	assert.Same(t, entry, resolved)
+	_, err = registry.Resolve(Traces, 2)
+	assert.EqualError(t, err, "Definition traces version 2 is not registered")
+	_, err = registry.RegisterTraceModel(zap.NewNop())
+	assert.EqualError(t, err, "Definition traces version 1 is already registered")
+}
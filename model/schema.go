@@ -0,0 +1,337 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Definition JSON 中 Type 的写法，旧的整数写法仍然可以读取
+var valueTypeJSONNames = [...]string{"int", "bool", "double", "bytes", "string", "object", "array"}
+
+// freeMapTypeName 是没有 Fields 的 Object，即自由编码的 map，如 attributes
+const freeMapTypeName = "map"
+
+// DefinitionError 是 Definition 中某个 field 的错误，Path 如 resourceSpans.item.scopeSpans.item.spans.item.kind
+type DefinitionError struct {
+	Path string
+	Err  error
+}
+
+func (e *DefinitionError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "(root)"
+	}
+	return path + ": " + e.Err.Error()
+}
+
+func (e *DefinitionError) Unwrap() error {
+	return e.Err
+}
+
+func definitionErrorf(path string, format string, args ...any) error {
+	return &DefinitionError{Path: path, Err: fmt.Errorf(format, args...)}
+}
+
+// childPath 返回 field 的路径，Array 的元素为 item
+func childPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// TypeName 返回 def 的 Type 在 JSON 中的写法，没有 Fields 的 Object 为 map
+func (def *Definition) TypeName() string {
+	if def.Type == Object && def.Fields == nil {
+		return freeMapTypeName
+	}
+	if def.Type < 0 || int(def.Type) >= len(valueTypeJSONNames) {
+		return strconv.Itoa(int(def.Type))
+	}
+	return valueTypeJSONNames[def.Type]
+}
+
+// parseValueType 解析 JSON 中的 Type，可以是名字或旧的整数写法。name 是小写的名字，整数写法时为空
+func parseValueType(raw json.RawMessage) (valueType ValueType, name string, err error) {
+	if json.Unmarshal(raw, &name) == nil {
+		name = strings.ToLower(name)
+		if name == freeMapTypeName {
+			return Object, name, nil
+		}
+		for t, typeName := range valueTypeJSONNames {
+			// 也接受 ValueType.String() 的写法，如 Integer
+			if name == typeName || name == strings.ToLower(ValueType(t).String()) {
+				return ValueType(t), name, nil
+			}
+		}
+		return 0, "", fmt.Errorf("unknown Type %q", name)
+	}
+	var number int
+	if json.Unmarshal(raw, &number) == nil {
+		if number < 0 || number >= len(valueTypeJSONNames) {
+			return 0, "", fmt.Errorf("unknown Type %d", number)
+		}
+		return ValueType(number), "", nil
+	}
+	return 0, "", fmt.Errorf("Type must be a type name or an integer, got %s", raw)
+}
+
+// rawDefinition 是 Definition 在 JSON 中的结构，子 Definition 逐个解析以便在错误中带上路径。
+// 根节点可以用 $defs 定义可复用的 Definition，任意节点可以用 {"$ref": "name"} 引用，
+// 和 $ref 写在一起的 Nullable 等属性会覆盖被引用的 Definition 中的值
+type rawDefinition struct {
+	Defs           map[string]json.RawMessage `json:"$defs"`
+	Ref            string                     `json:"$ref"`
+	Type           json.RawMessage
+	Nullable       *bool
+	Pooled         *bool
+	SharePooled    *bool
+	SharePoolId    *string
+	DiffEncode     *bool
+	MaxPoolEntries *int
+	FixedLength    *int
+	Tag            *int
+	Version        *int
+	Fields         map[string]json.RawMessage
+	ItemDefinition json.RawMessage
+}
+
+func (def *Definition) UnmarshalJSON(data []byte) error {
+	p := &definitionParser{resolved: make(map[string]*Definition)}
+	result, err := p.parse(data, "")
+	if err != nil {
+		return err
+	}
+	// 没有被引用的 $defs 也要检查能否解析
+	for _, name := range sortedFieldNames(p.defs) {
+		_, err := p.resolve(name, defsPath(name))
+		if err != nil {
+			return err
+		}
+	}
+	*def = *result
+	return nil
+}
+
+// definitionParser 解析一个 Definition JSON，$defs 中的 Definition 在第一次被引用时解析，每次引用得到一份独立的拷贝
+type definitionParser struct {
+	defs     map[string]json.RawMessage
+	resolved map[string]*Definition
+	// resolving 是正在解析的 $defs，用于发现循环引用
+	resolving []string
+}
+
+func defsPath(name string) string {
+	return "$defs." + name
+}
+
+func (p *definitionParser) parse(data []byte, path string) (*Definition, error) {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return nil, definitionErrorf(path, "Definition is null")
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	// 拼错的 key 会被 encoding/json 忽略，这里直接报错
+	decoder.DisallowUnknownFields()
+	var raw rawDefinition
+	err := decoder.Decode(&raw)
+	if err != nil {
+		return nil, &DefinitionError{Path: path, Err: err}
+	}
+	if raw.Defs != nil {
+		if path != "" {
+			return nil, definitionErrorf(path, "$defs is only allowed at the root")
+		}
+		p.defs = raw.Defs
+	}
+	var def *Definition
+	if raw.Ref != "" {
+		if raw.Type != nil || raw.Fields != nil || raw.ItemDefinition != nil {
+			return nil, definitionErrorf(path, "$ref cannot be combined with Type, Fields or ItemDefinition")
+		}
+		def, err = p.resolve(raw.Ref, path)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		def, err = p.parseType(&raw, path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if raw.Nullable != nil {
+		def.Nullable = *raw.Nullable
+	}
+	if raw.Pooled != nil {
+		def.Pooled = *raw.Pooled
+	}
+	if raw.SharePooled != nil {
+		def.SharePooled = *raw.SharePooled
+	}
+	if raw.SharePoolId != nil {
+		def.SharePoolId = *raw.SharePoolId
+	}
+	if raw.DiffEncode != nil {
+		def.DiffEncode = *raw.DiffEncode
+	}
+	if raw.MaxPoolEntries != nil {
+		def.MaxPoolEntries = *raw.MaxPoolEntries
+	}
+	if raw.FixedLength != nil {
+		def.FixedLength = *raw.FixedLength
+	}
+	if raw.Tag != nil {
+		def.Tag = *raw.Tag
+	}
+	if raw.Version != nil {
+		def.Version = *raw.Version
+	}
+	return def, nil
+}
+
+// parseType 解析不是 $ref 的节点的 Type、Fields 和 ItemDefinition
+func (p *definitionParser) parseType(raw *rawDefinition, path string) (*Definition, error) {
+	if raw.Type == nil {
+		return nil, definitionErrorf(path, "missing Type")
+	}
+	valueType, typeName, err := parseValueType(raw.Type)
+	if err != nil {
+		return nil, &DefinitionError{Path: path, Err: err}
+	}
+	freeMap := typeName == freeMapTypeName
+	def := &Definition{Type: valueType}
+	if freeMap && raw.Fields != nil {
+		return nil, definitionErrorf(path, "map must not have Fields, use object instead")
+	}
+	if raw.Fields != nil {
+		def.Fields = make(map[string]*Definition, len(raw.Fields))
+		for _, fieldName := range sortedFieldNames(raw.Fields) {
+			def.Fields[fieldName], err = p.parse(raw.Fields[fieldName], childPath(path, fieldName))
+			if err != nil {
+				return nil, err
+			}
+		}
+	} else if valueType == Object && !freeMap && typeName != "" {
+		// 写成 object 却没有 Fields，交给 validateDefinition 报错。旧的整数写法没有 Fields 时是 map
+		def.Fields = map[string]*Definition{}
+	}
+	if raw.ItemDefinition != nil && !bytes.Equal(raw.ItemDefinition, []byte("null")) {
+		def.ItemDefinition, err = p.parse(raw.ItemDefinition, childPath(path, "item"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return def, nil
+}
+
+// resolve 返回 $defs 中 ref 的一份拷贝，ref 可以写成 name 或 #/$defs/name，path 是引用所在的位置
+func (p *definitionParser) resolve(ref string, path string) (*Definition, error) {
+	name := strings.TrimPrefix(ref, "#/$defs/")
+	if def, exist := p.resolved[name]; exist {
+		return def.Clone(), nil
+	}
+	data, exist := p.defs[name]
+	if !exist {
+		return nil, definitionErrorf(path, "unknown $ref %q", ref)
+	}
+	for i, resolving := range p.resolving {
+		if resolving == name {
+			cycle := append(append([]string{}, p.resolving[i:]...), name)
+			return nil, definitionErrorf(path, "cyclic $ref %s", strings.Join(cycle, " -> "))
+		}
+	}
+	p.resolving = append(p.resolving, name)
+	def, err := p.parse(data, defsPath(name))
+	p.resolving = p.resolving[:len(p.resolving)-1]
+	if err != nil {
+		return nil, err
+	}
+	p.resolved[name] = def
+	return def.Clone(), nil
+}
+
+// MarshalJSON 和 JSON 文件的写法一致，Type 输出为名字
+func (def *Definition) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type           string
+		Nullable       bool
+		Pooled         bool
+		SharePooled    bool
+		SharePoolId    string
+		DiffEncode     bool
+		MaxPoolEntries int `json:",omitempty"`
+		FixedLength    int `json:",omitempty"`
+		Tag            int `json:",omitempty"`
+		Version        int `json:",omitempty"`
+		Fields         map[string]*Definition
+		ItemDefinition *Definition
+	}{
+		Type:           def.TypeName(),
+		Nullable:       def.Nullable,
+		Pooled:         def.Pooled,
+		SharePooled:    def.SharePooled,
+		SharePoolId:    def.SharePoolId,
+		DiffEncode:     def.DiffEncode,
+		MaxPoolEntries: def.MaxPoolEntries,
+		FixedLength:    def.FixedLength,
+		Tag:            def.Tag,
+		Version:        def.Version,
+		Fields:         def.Fields,
+		ItemDefinition: def.ItemDefinition,
+	})
+}
+
+// fingerprintDefinition 是计算 Fingerprint 时使用的结构，Type 保持整数，和 JSON 中 Type 的写法无关，
+// 已经生成的代码中记录的 Fingerprint 仍然有效。Version 不影响编码结果，不参与 Fingerprint
+type fingerprintDefinition struct {
+	Type           ValueType
+	Nullable       bool
+	Pooled         bool
+	SharePooled    bool
+	SharePoolId    string
+	DiffEncode     bool
+	MaxPoolEntries int `json:",omitempty"`
+	FixedLength    int `json:",omitempty"`
+	Tag            int `json:",omitempty"`
+	Fields         map[string]*fingerprintDefinition
+	ItemDefinition *fingerprintDefinition
+}
+
+func toFingerprintDefinition(def *Definition) *fingerprintDefinition {
+	if def == nil {
+		return nil
+	}
+	result := &fingerprintDefinition{
+		Type:           def.Type,
+		Nullable:       def.Nullable,
+		Pooled:         def.Pooled,
+		SharePooled:    def.SharePooled,
+		SharePoolId:    def.SharePoolId,
+		DiffEncode:     def.DiffEncode,
+		MaxPoolEntries: def.MaxPoolEntries,
+		FixedLength:    def.FixedLength,
+		Tag:            def.Tag,
+		ItemDefinition: toFingerprintDefinition(def.ItemDefinition),
+	}
+	if def.Fields != nil {
+		result.Fields = make(map[string]*fingerprintDefinition, len(def.Fields))
+		for fieldName, fieldDef := range def.Fields {
+			result.Fields[fieldName] = toFingerprintDefinition(fieldDef)
+		}
+	}
+	return result
+}
+
+// sortedFieldNames 按字典序返回 fields 的 key，使错误的顺序固定
+func sortedFieldNames[T any](fields map[string]T) []string {
+	fieldNames := make([]string, 0, len(fields))
+	for fieldName := range fields {
+		fieldNames = append(fieldNames, fieldName)
+	}
+	sort.Strings(fieldNames)
+	return fieldNames
+}
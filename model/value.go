@@ -1,12 +1,13 @@
 package model
 
 import (
-	"encoding/binary"
-	"hash/fnv"
 	"log"
 	"math"
-	"sort"
+	"strconv"
 	"strings"
+	"sync/atomic"
+
+	"github.com/cespare/xxhash/v2"
 )
 
 // ValueType 是属性节点可能存储的值的枚举类型
@@ -22,10 +23,45 @@ const (
 	Array
 )
 
+var valueTypeNames = [...]string{"Integer", "Boolean", "Double", "Bytes", "String", "Object", "Array"}
+
+func (t ValueType) String() string {
+	if t < 0 || int(t) >= len(valueTypeNames) {
+		return "ValueType(" + strconv.Itoa(int(t)) + ")"
+	}
+	return valueTypeNames[t]
+}
+
 // Value 是属性节点可能存储的值的接口类型
 type Value interface {
 	GetType() ValueType // 获取值的类型
-	Hash() int
+	// Hash 返回 64 位哈希，相等的值哈希相同。Object 和 Array 的哈希会缓存在值中，调用过 Hash 之后不应再修改 Data
+	Hash() uint64
+}
+
+// nilHash 是 null 值参与组合哈希时使用的哈希
+const nilHash uint64 = 0x9e3779b97f4a7c15
+
+// mix64 是 splitmix64 的混淆函数，用于整数类的值和组合哈希
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// combineHash 把 v 组合进 h，和顺序有关
+func combineHash(h uint64, v uint64) uint64 {
+	return mix64(h ^ (v + nilHash + (h << 6) + (h >> 2)))
+}
+
+func hashOf(v Value) uint64 {
+	if v == nil {
+		return nilHash
+	}
+	return v.Hash()
 }
 
 // IntegerValue 是整数值类型
@@ -37,20 +73,8 @@ func (iv *IntegerValue) GetType() ValueType {
 	return Integer
 }
 
-func (iv *IntegerValue) Hash() int {
-	h := fnv.New32a()
-	// 写入整数的字节表示形式
-	h.Write([]byte{
-		byte(iv.Data >> 56),
-		byte(iv.Data >> 48),
-		byte(iv.Data >> 40),
-		byte(iv.Data >> 32),
-		byte(iv.Data >> 24),
-		byte(iv.Data >> 16),
-		byte(iv.Data >> 8),
-		byte(iv.Data),
-	})
-	return int(h.Sum32())
+func (iv *IntegerValue) Hash() uint64 {
+	return mix64(uint64(iv.Data))
 }
 
 // StringValue 是字符串值类型
@@ -62,10 +86,8 @@ func (sv *StringValue) GetType() ValueType {
 	return String
 }
 
-func (sv *StringValue) Hash() int {
-	h := fnv.New32a()
-	h.Write([]byte(sv.Data))
-	return int(h.Sum32())
+func (sv *StringValue) Hash() uint64 {
+	return xxhash.Sum64String(sv.Data)
 }
 
 // BooleanValue 是字符串值类型
@@ -77,19 +99,11 @@ func (bv *BooleanValue) GetType() ValueType {
 	return Boolean
 }
 
-func boolToByte(v bool) byte {
-	if v {
-		return 1
+func (bv *BooleanValue) Hash() uint64 {
+	if bv.Data {
+		return mix64(1)
 	}
-	return 0
-}
-
-func (bv *BooleanValue) Hash() int {
-	h := fnv.New32a()
-	h.Write([]byte{
-		boolToByte(bv.Data),
-	})
-	return int(h.Sum32())
+	return mix64(0)
 }
 
 // DoubleValue 是字符串值类型
@@ -101,20 +115,8 @@ func (dv *DoubleValue) GetType() ValueType {
 	return Double
 }
 
-func (dv *DoubleValue) Hash() int {
-	h := fnv.New32a()
-	bs := math.Float64bits(dv.Data)
-	h.Write([]byte{
-		byte(bs >> 56),
-		byte(bs >> 48),
-		byte(bs >> 40),
-		byte(bs >> 32),
-		byte(bs >> 24),
-		byte(bs >> 16),
-		byte(bs >> 8),
-		byte(bs),
-	})
-	return int(h.Sum32())
+func (dv *DoubleValue) Hash() uint64 {
+	return mix64(math.Float64bits(dv.Data))
 }
 
 // BytesValue 是字符串值类型
@@ -126,67 +128,62 @@ func (bv *BytesValue) GetType() ValueType {
 	return Bytes
 }
 
-func (bv *BytesValue) Hash() int {
-	h := fnv.New32a()
-	h.Write(bv.Data)
-	return int(h.Sum32())
+func (bv *BytesValue) Hash() uint64 {
+	return xxhash.Sum64(bv.Data)
 }
 
 // ObjectValue 是对象值类型
 type ObjectValue struct {
 	Data map[string]Value
+	// hash 缓存 Hash 的结果，0 表示还没有计算，并发的 Encode 可能同时计算，所以用原子操作读写
+	hash uint64
 }
 
 func (ov *ObjectValue) GetType() ValueType {
 	return Object
 }
 
-func (ov *ObjectValue) Hash() int {
-	h := fnv.New32a()
-	keys := getSortedKeys(ov.Data)
-	for _, key := range keys {
-		// 先处理 key
-		h.Write([]byte(key))
-
-		// 然后处理 value
-		val := ov.Data[key]
-		hv := 0
-		if val != nil {
-			hv = ov.Data[key].Hash()
-		}
-
-		// 将 int 转换为 byte 切片
-		buf := make([]byte, 4) // 根据实际 int 类型的大小分配字节数，这里假设是 int32
-		binary.LittleEndian.PutUint32(buf, uint32(hv))
-
-		// 写入哈希器
-		h.Write(buf)
+func (ov *ObjectValue) Hash() uint64 {
+	if h := atomic.LoadUint64(&ov.hash); h != 0 {
+		return h
 	}
-	return int(h.Sum32())
+	// 各个 entry 的哈希相加，和 map 的遍历顺序无关，不需要对 key 排序
+	var sum uint64
+	for key, val := range ov.Data {
+		sum += combineHash(xxhash.Sum64String(key), hashOf(val))
+	}
+	h := combineHash(uint64(len(ov.Data)), sum)
+	if h == 0 {
+		h = 1
+	}
+	atomic.StoreUint64(&ov.hash, h)
+	return h
 }
 
 // ArrayValue 是数组值类型
 type ArrayValue struct {
 	Data []Value
+	// hash 同 ObjectValue.hash
+	hash uint64
 }
 
 func (av *ArrayValue) GetType() ValueType {
 	return Array
 }
 
-func (av *ArrayValue) Hash() int {
-	h := fnv.New32a()
+func (av *ArrayValue) Hash() uint64 {
+	if h := atomic.LoadUint64(&av.hash); h != 0 {
+		return h
+	}
+	h := uint64(len(av.Data))
 	for _, elem := range av.Data {
-		hv := elem.Hash()
-
-		// 将 int 转换为 byte 切片
-		buf := make([]byte, 4) // 根据实际 int 类型的大小分配字节数，这里假设是 int32
-		binary.LittleEndian.PutUint32(buf, uint32(hv))
-
-		// 写入哈希器
-		h.Write(buf)
+		h = combineHash(h, hashOf(elem))
+	}
+	if h == 0 {
+		h = 1
 	}
-	return int(h.Sum32())
+	atomic.StoreUint64(&av.hash, h)
+	return h
 }
 
 func ValueComparator(a, b interface{}) int {
@@ -301,18 +298,6 @@ func ValueComparator(a, b interface{}) int {
 	}
 }
 
-func getSortedKeys(m map[string]Value) []string {
-	// 数组默认长度为map长度,后面append时,不需要重新申请内存和拷贝,效率很高
-	j := 0
-	keys := make([]string, len(m))
-	for k := range m {
-		keys[j] = k
-		j++
-	}
-	sort.Strings(keys)
-	return keys
-}
-
 func mapToValue(m map[string]any) Value {
 	result := &ObjectValue{Data: map[string]Value{}}
 	for key, value := range m {